@@ -163,14 +163,16 @@ func (c *Client) register() error {
 	}
 
 	registerMsg := protocol.RegisterMessage{
-		ProbeID:      c.probeID,
-		Name:         c.config.Probe.Name,
-		Location:     c.config.Probe.Location,
-		Region:       c.config.Probe.Region,
-		Capabilities: c.config.Capabilities,
-		Version:      Version,
-		AuthToken:    c.config.Server.AuthToken,
-		Metadata:     metadata,
+		ProbeID:            c.probeID,
+		Name:               c.config.Probe.Name,
+		Location:           c.config.Probe.Location,
+		Region:             c.config.Probe.Region,
+		Capabilities:       c.config.Capabilities,
+		Version:            Version,
+		AuthToken:          c.config.Server.AuthToken,
+		Metadata:           metadata,
+		Labels:             c.config.Probe.Labels,
+		MaxConcurrentTasks: c.config.Executor.MaxConcurrentTasks,
 	}
 
 	return c.sendMessage(protocol.MsgTypeRegister, registerMsg)
@@ -190,6 +192,7 @@ func (c *Client) heartbeatLoop() {
 				CPUUsage:    0,
 				MemUsage:    0,
 				ActiveTasks: c.taskManager.ActiveTaskCount(),
+				QueuedTasks: c.taskManager.QueuedTaskCount(),
 			}
 
 			if err := c.sendMessage(protocol.MsgTypeHeartbeat, heartbeatMsg); err != nil {
@@ -265,8 +268,16 @@ func (c *Client) handleMessage(message []byte) error {
 		log.Printf("[Client] Received task: %s (type: %s, target: %s)",
 			taskMsg.TaskID, taskMsg.TaskType, taskMsg.Target)
 
-		// 提交任务到任务管理器
-		c.taskManager.SubmitTask(taskMsg, c)
+		// 提交任务到任务管理器；重复投递只回确认，不重复执行
+		accepted := c.taskManager.SubmitTask(taskMsg, c)
+		if err := c.sendMessage(protocol.MsgTypeTaskAssignAck, protocol.TaskAssignAckMessage{
+			ExecutionID: taskMsg.ExecutionID,
+			TaskID:      taskMsg.TaskID,
+			ProbeID:     c.probeID,
+			Duplicate:   !accepted,
+		}); err != nil {
+			log.Printf("[Client] Failed to send task assign ack: %v", err)
+		}
 
 	case protocol.MsgTypeTaskCancel:
 		var cancelMsg protocol.TaskCancelMessage
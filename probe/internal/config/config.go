@@ -23,6 +23,8 @@ type ProbeConfig struct {
 	Region    string   `yaml:"region"`
 	Latitude  *float64 `yaml:"latitude,omitempty"`  // 纬度
 	Longitude *float64 `yaml:"longitude,omitempty"` // 经度
+	// Labels 标签，任务可通过选择表达式按标签挑选探针
+	Labels map[string]string `yaml:"labels,omitempty"`
 }
 
 // ServerConfig 服务器连接配置
@@ -255,6 +255,7 @@ func executeHTTPTest(target string, params map[string]interface{}) (map[string]i
 	success := 0
 	failed := 0
 	var totalMs float64
+	var totalTTFBMs float64
 	var minMs float64
 	var maxMs float64
 	var lastStatusCode int
@@ -281,7 +282,11 @@ func executeHTTPTest(target string, params map[string]interface{}) (map[string]i
 		applyChromeLikeHeaders(req)
 
 		resolvedIP := fallbackResolvedHTTPIP(req.URL.Hostname())
+		var ttfb time.Duration
 		trace := &httptrace.ClientTrace{
+			GotFirstResponseByte: func() {
+				ttfb = time.Since(start)
+			},
 			GotConn: func(info httptrace.GotConnInfo) {
 				if info.Conn == nil {
 					return
@@ -318,6 +323,7 @@ func executeHTTPTest(target string, params map[string]interface{}) (map[string]i
 		lastRequestHeaders = cloneHeaderMap(resp.Request.Header)
 		lastResponseHeaders = cloneHeaderMap(resp.Header)
 		lastStatusCode = resp.StatusCode
+		ttfbMs := float64(ttfb.Milliseconds())
 
 		_ = resp.Body.Close()
 
@@ -325,6 +331,7 @@ func executeHTTPTest(target string, params map[string]interface{}) (map[string]i
 		if resp.StatusCode >= 200 && resp.StatusCode < 400 {
 			success++
 			totalMs += ms
+			totalTTFBMs += ttfbMs
 			if minMs == 0 || ms < minMs {
 				minMs = ms
 			}
@@ -335,6 +342,7 @@ func executeHTTPTest(target string, params map[string]interface{}) (map[string]i
 				"seq":              i,
 				"status":           "success",
 				"time_ms":          ms,
+				"ttfb_ms":          ttfbMs,
 				"status_code":      resp.StatusCode,
 				"response_status":  resp.Status,
 				"resolved_ip":      resolvedIP,
@@ -348,6 +356,7 @@ func executeHTTPTest(target string, params map[string]interface{}) (map[string]i
 				"seq":              i,
 				"status":           "failed",
 				"time_ms":          ms,
+				"ttfb_ms":          ttfbMs,
 				"status_code":      resp.StatusCode,
 				"response_status":  resp.Status,
 				"resolved_ip":      resolvedIP,
@@ -369,6 +378,7 @@ func executeHTTPTest(target string, params map[string]interface{}) (map[string]i
 		result["avg_connect_time_ms"] = totalMs / float64(success)
 		result["min_connect_time_ms"] = minMs
 		result["max_connect_time_ms"] = maxMs
+		result["avg_ttfb_ms"] = totalTTFBMs / float64(success)
 	}
 
 	if lastStatusCode > 0 {
@@ -2,6 +2,7 @@ package manager
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"sync"
@@ -11,6 +12,14 @@ import (
 	"atlas/shared/protocol"
 )
 
+// dedupWindow 已接收执行 ID 的保留时间，覆盖服务端重投未确认任务的窗口
+const dedupWindow = time.Hour
+
+// statusCancelled 任务被服务端取消时回报的状态，与执行失败区分
+const statusCancelled = "cancelled"
+
+var errQueueFull = errors.New("task queue is full")
+
 // TaskClient 任务客户端接口
 type TaskClient interface {
 	SendTaskResult(result protocol.TaskResultMessage) error
@@ -19,13 +28,16 @@ type TaskClient interface {
 
 // Manager 任务管理器
 type Manager struct {
-	taskQueue chan TaskJob
+	taskQueue *jobQueue
 	workers   int
 	wg        sync.WaitGroup
 	stopChan  chan struct{}
 
 	activeTasks sync.Map // executionID => context.CancelFunc
 	activeCount atomic.Int64
+
+	seenMu sync.Mutex
+	seen   map[string]time.Time // executionID => 接收时间
 }
 
 // TaskJob 任务作业
@@ -37,9 +49,10 @@ type TaskJob struct {
 // New 创建新的任务管理器
 func New(maxWorkers int) *Manager {
 	return &Manager{
-		taskQueue: make(chan TaskJob, 100),
+		taskQueue: newJobQueue(maxQueuedJobs),
 		workers:   maxWorkers,
 		stopChan:  make(chan struct{}),
+		seen:      make(map[string]time.Time),
 	}
 }
 
@@ -59,12 +72,49 @@ func (m *Manager) Stop() {
 	m.wg.Wait()
 }
 
-// SubmitTask 提交任务
-func (m *Manager) SubmitTask(task protocol.TaskAssignMessage, client TaskClient) {
-	m.taskQueue <- TaskJob{
-		Task:   task,
-		Client: client,
+// SubmitTask 提交任务，按 task.Priority 排队；同一执行 ID 重复投递时忽略并返回 false。
+// 队列已满时直接回报失败结果，由服务端按重试策略处理
+func (m *Manager) SubmitTask(task protocol.TaskAssignMessage, client TaskClient) bool {
+	if !m.markSeen(task.ExecutionID) {
+		log.Printf("[Manager] Ignoring duplicate task %s", task.ExecutionID)
+		return false
+	}
+
+	if !m.taskQueue.Push(TaskJob{Task: task, Client: client}) {
+		log.Printf("[Manager] Task queue is full (%d queued), rejecting task %s", maxQueuedJobs, task.ExecutionID)
+		if client != nil {
+			_ = client.SendTaskResult(protocol.TaskResultMessage{
+				ExecutionID: task.ExecutionID,
+				TaskID:      task.TaskID,
+				Status:      "failed",
+				Error:       errQueueFull.Error(),
+			})
+		}
+	}
+	return true
+}
+
+// markSeen 记录执行 ID，已存在时返回 false
+func (m *Manager) markSeen(executionID string) bool {
+	if executionID == "" {
+		return true
+	}
+
+	m.seenMu.Lock()
+	defer m.seenMu.Unlock()
+
+	now := time.Now()
+	for id, at := range m.seen {
+		if now.Sub(at) > dedupWindow {
+			delete(m.seen, id)
+		}
+	}
+
+	if _, ok := m.seen[executionID]; ok {
+		return false
 	}
+	m.seen[executionID] = now
+	return true
 }
 
 // CancelTask 取消任务
@@ -78,10 +128,26 @@ func (m *Manager) CancelTask(executionID string) {
 		}
 	}
 
-	// 尝试从队列中移除任务：channel 不支持直接遍历删除，这里仅记录
+	// 尚在排队：直接移出队列并回报取消
+	if job, ok := m.taskQueue.Remove(executionID); ok {
+		log.Printf("[Manager] Removed queued task %s", executionID)
+		_ = job.Client.SendTaskResult(protocol.TaskResultMessage{
+			ExecutionID: job.Task.ExecutionID,
+			TaskID:      job.Task.TaskID,
+			Status:      statusCancelled,
+			Error:       context.Canceled.Error(),
+		})
+		return
+	}
+
 	log.Printf("[Manager] Task %s cancel requested (not running)", executionID)
 }
 
+// QueuedTaskCount 获取排队中的任务数
+func (m *Manager) QueuedTaskCount() int {
+	return m.taskQueue.Len()
+}
+
 // ActiveTaskCount 获取活跃任务数
 func (m *Manager) ActiveTaskCount() int {
 	v := m.activeCount.Load()
@@ -96,9 +162,13 @@ func (m *Manager) worker(id int) {
 	defer m.wg.Done()
 
 	for {
-		select {
-		case job := <-m.taskQueue:
+		if job, ok := m.taskQueue.Pop(); ok {
 			m.executeTask(id, job)
+			continue
+		}
+
+		select {
+		case <-m.taskQueue.Ready():
 		case <-m.stopChan:
 			return
 		}
@@ -142,7 +212,7 @@ func (m *Manager) executeTask(workerID int, job TaskJob) {
 			ExecutionID: task.ExecutionID,
 			TaskID:      task.TaskID,
 			ProbeID:     "", // 会由client填充
-			Status:      "failed",
+			Status:      failureStatus(ctx),
 			Error:       err.Error(),
 			Duration:    0,
 		}
@@ -182,7 +252,7 @@ func (m *Manager) executeTask(workerID int, job TaskJob) {
 	}
 
 	if err != nil {
-		result.Status = "failed"
+		result.Status = failureStatus(ctx)
 		result.Error = err.Error()
 		log.Printf("[Worker %d] Task failed: %s - %v", workerID, task.TaskID, err)
 	} else {
@@ -196,3 +266,11 @@ func (m *Manager) executeTask(workerID int, job TaskJob) {
 		log.Printf("[Worker %d] Failed to send result: %v", workerID, err)
 	}
 }
+
+// failureStatus 执行出错时回报的状态：服务端取消导致的中断回报 cancelled，其余（含超时）为 failed
+func failureStatus(ctx context.Context) string {
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return statusCancelled
+	}
+	return "failed"
+}
@@ -0,0 +1,131 @@
+package manager
+
+import (
+	"container/heap"
+	"sync"
+)
+
+const (
+	// defaultTaskPriority 未携带优先级的任务（旧版服务端）按默认优先级排队
+	defaultTaskPriority = 5
+
+	// maxQueuedJobs 排队任务上限，服务端持续下发而执行跟不上时拒绝新任务，避免内存无限增长
+	maxQueuedJobs = 1000
+)
+
+// jobQueue 按优先级出队的任务队列，同优先级先进先出
+type jobQueue struct {
+	mu       sync.Mutex
+	items    jobHeap
+	seq      uint64
+	capacity int
+	notify   chan struct{}
+}
+
+type queuedJob struct {
+	job      TaskJob
+	priority int
+	seq      uint64
+}
+
+func newJobQueue(capacity int) *jobQueue {
+	return &jobQueue{
+		capacity: capacity,
+		notify:   make(chan struct{}, 1),
+	}
+}
+
+// Push 入队并唤醒一个等待中的 worker；队列已满时返回 false
+func (q *jobQueue) Push(job TaskJob) bool {
+	priority := job.Task.Priority
+	if priority <= 0 {
+		priority = defaultTaskPriority
+	}
+
+	q.mu.Lock()
+	if q.capacity > 0 && q.items.Len() >= q.capacity {
+		q.mu.Unlock()
+		return false
+	}
+	q.seq++
+	heap.Push(&q.items, &queuedJob{job: job, priority: priority, seq: q.seq})
+	q.mu.Unlock()
+
+	q.signal()
+	return true
+}
+
+// Pop 取出优先级最高的任务；队列为空时返回 false
+func (q *jobQueue) Pop() (TaskJob, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.items.Len() == 0 {
+		return TaskJob{}, false
+	}
+	item := heap.Pop(&q.items).(*queuedJob)
+	// 还有剩余任务时继续唤醒其他 worker
+	if q.items.Len() > 0 {
+		q.signal()
+	}
+	return item.job, true
+}
+
+// Remove 移除尚未开始执行的任务
+func (q *jobQueue) Remove(executionID string) (TaskJob, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i, item := range q.items {
+		if item.job.Task.ExecutionID == executionID {
+			heap.Remove(&q.items, i)
+			return item.job, true
+		}
+	}
+	return TaskJob{}, false
+}
+
+// Len 当前排队任务数
+func (q *jobQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.items.Len()
+}
+
+// Ready worker 等待新任务的通知 channel
+func (q *jobQueue) Ready() <-chan struct{} {
+	return q.notify
+}
+
+func (q *jobQueue) signal() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+type jobHeap []*queuedJob
+
+func (h jobHeap) Len() int { return len(h) }
+
+func (h jobHeap) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h jobHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *jobHeap) Push(x interface{}) {
+	*h = append(*h, x.(*queuedJob))
+}
+
+func (h *jobHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return item
+}
@@ -0,0 +1,124 @@
+package manager
+
+import (
+	"testing"
+
+	"atlas/shared/protocol"
+)
+
+func queuedTask(executionID string, priority int) TaskJob {
+	return TaskJob{Task: protocol.TaskAssignMessage{ExecutionID: executionID, Priority: priority}}
+}
+
+func TestJobQueueOrdersByPriorityThenFIFO(t *testing.T) {
+	q := newJobQueue(maxQueuedJobs)
+	q.Push(queuedTask("bulk-1", 3))
+	q.Push(queuedTask("bulk-2", 3))
+	q.Push(queuedTask("legacy", 0))
+	q.Push(queuedTask("interactive", 8))
+
+	want := []string{"interactive", "legacy", "bulk-1", "bulk-2"}
+	for _, id := range want {
+		job, ok := q.Pop()
+		if !ok {
+			t.Fatalf("expected %s, queue empty", id)
+		}
+		if job.Task.ExecutionID != id {
+			t.Fatalf("expected %s, got %s", id, job.Task.ExecutionID)
+		}
+	}
+	if _, ok := q.Pop(); ok {
+		t.Fatal("expected empty queue")
+	}
+}
+
+func TestJobQueueRemove(t *testing.T) {
+	q := newJobQueue(maxQueuedJobs)
+	q.Push(queuedTask("a", 5))
+	q.Push(queuedTask("b", 9))
+	q.Push(queuedTask("c", 1))
+
+	if _, ok := q.Remove("b"); !ok {
+		t.Fatal("expected to remove queued job")
+	}
+	if _, ok := q.Remove("missing"); ok {
+		t.Fatal("expected missing job not to be removed")
+	}
+	if job, _ := q.Pop(); job.Task.ExecutionID != "a" {
+		t.Fatalf("expected a after removing b, got %s", job.Task.ExecutionID)
+	}
+	if q.Len() != 1 {
+		t.Fatalf("expected 1 queued job, got %d", q.Len())
+	}
+}
+
+func TestSubmitTaskIgnoresRedeliveredExecution(t *testing.T) {
+	m := New(1)
+	task := protocol.TaskAssignMessage{ExecutionID: "exec-1", Priority: 5}
+
+	if !m.SubmitTask(task, nil) {
+		t.Fatal("expected first delivery to be accepted")
+	}
+	if m.SubmitTask(task, nil) {
+		t.Fatal("expected redelivery of the same execution to be ignored")
+	}
+	if m.QueuedTaskCount() != 1 {
+		t.Fatalf("expected 1 queued task, got %d", m.QueuedTaskCount())
+	}
+}
+
+// recordingClient 记录回报的任务结果
+type recordingClient struct {
+	results []protocol.TaskResultMessage
+}
+
+func (c *recordingClient) SendTaskResult(result protocol.TaskResultMessage) error {
+	c.results = append(c.results, result)
+	return nil
+}
+
+func (c *recordingClient) SendTaskStatus(protocol.TaskStatusMessage) error { return nil }
+
+func TestJobQueueRejectsWhenFull(t *testing.T) {
+	q := newJobQueue(2)
+	if !q.Push(queuedTask("a", 5)) || !q.Push(queuedTask("b", 5)) {
+		t.Fatal("expected pushes within capacity to succeed")
+	}
+	if q.Push(queuedTask("c", 9)) {
+		t.Fatal("expected push beyond capacity to be rejected")
+	}
+	if q.Len() != 2 {
+		t.Fatalf("expected 2 queued jobs, got %d", q.Len())
+	}
+}
+
+func TestSubmitTaskReportsFailureWhenQueueFull(t *testing.T) {
+	m := New(1)
+	m.taskQueue = newJobQueue(1)
+	client := &recordingClient{}
+
+	m.SubmitTask(protocol.TaskAssignMessage{ExecutionID: "exec-1", TaskID: "task-1"}, client)
+	if !m.SubmitTask(protocol.TaskAssignMessage{ExecutionID: "exec-2", TaskID: "task-1"}, client) {
+		t.Fatal("expected rejected delivery to still be acknowledged")
+	}
+	if m.QueuedTaskCount() != 1 {
+		t.Fatalf("expected 1 queued task, got %d", m.QueuedTaskCount())
+	}
+	if len(client.results) != 1 || client.results[0].ExecutionID != "exec-2" || client.results[0].Status != "failed" {
+		t.Fatalf("expected a failed result for exec-2, got %+v", client.results)
+	}
+}
+
+func TestCancelQueuedTaskReportsCancelled(t *testing.T) {
+	m := New(1)
+	client := &recordingClient{}
+	m.SubmitTask(protocol.TaskAssignMessage{ExecutionID: "exec-1", TaskID: "task-1"}, client)
+
+	m.CancelTask("exec-1")
+	if m.QueuedTaskCount() != 0 {
+		t.Fatalf("expected cancelled task to leave the queue, got %d", m.QueuedTaskCount())
+	}
+	if len(client.results) != 1 || client.results[0].Status != statusCancelled {
+		t.Fatalf("expected a cancelled result, got %+v", client.results)
+	}
+}
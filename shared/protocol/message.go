@@ -5,11 +5,12 @@ import "time"
 // 消息类型常量
 const (
 	// Probe → Web
-	MsgTypeRegister   = "register"
-	MsgTypeHeartbeat  = "heartbeat"
-	MsgTypeTaskResult = "task_result"
-	MsgTypeTaskStatus = "task_status"
-	MsgTypeError      = "error"
+	MsgTypeRegister      = "register"
+	MsgTypeHeartbeat     = "heartbeat"
+	MsgTypeTaskResult    = "task_result"
+	MsgTypeTaskStatus    = "task_status"
+	MsgTypeTaskAssignAck = "task_assign_ack"
+	MsgTypeError         = "error"
 
 	// Web → Probe
 	MsgTypeRegisterAck     = "register_ack"
@@ -34,14 +35,16 @@ type WSMessage struct {
 
 // RegisterMessage 探针注册消息
 type RegisterMessage struct {
-	ProbeID      string            `json:"probe_id"`     // 探针ID
-	Name         string            `json:"name"`         // 探针名称
-	Location     string            `json:"location"`     // 地理位置
-	Region       string            `json:"region"`       // 区域
-	Capabilities []string          `json:"capabilities"` // 支持的测试类型
-	Version      string            `json:"version"`      // 探针版本
-	AuthToken    string            `json:"auth_token"`   // 认证令牌
-	Metadata     map[string]string `json:"metadata"`     // 额外信息
+	ProbeID            string            `json:"probe_id"`             // 探针ID
+	Name               string            `json:"name"`                 // 探针名称
+	Location           string            `json:"location"`             // 地理位置
+	Region             string            `json:"region"`               // 区域
+	Capabilities       []string          `json:"capabilities"`         // 支持的测试类型
+	Version            string            `json:"version"`              // 探针版本
+	AuthToken          string            `json:"auth_token"`           // 认证令牌
+	Metadata           map[string]string `json:"metadata"`             // 额外信息
+	Labels             map[string]string `json:"labels"`               // 标签，用于任务的探针选择表达式
+	MaxConcurrentTasks int               `json:"max_concurrent_tasks"` // 最大并发任务数，服务端据此控制下发
 }
 
 // RegisterAckMessage 注册响应消息
@@ -58,6 +61,7 @@ type HeartbeatMessage struct {
 	CPUUsage    float64 `json:"cpu_usage"`    // CPU使用率
 	MemUsage    float64 `json:"mem_usage"`    // 内存使用率
 	ActiveTasks int     `json:"active_tasks"` // 活跃任务数
+	QueuedTasks int     `json:"queued_tasks"` // 排队任务数
 }
 
 // HeartbeatAckMessage 心跳响应消息
@@ -73,7 +77,16 @@ type TaskAssignMessage struct {
 	TaskType    string                 `json:"task_type"`
 	Target      string                 `json:"target"`
 	Parameters  map[string]interface{} `json:"parameters"`
-	Timeout     int                    `json:"timeout"` // 超时时间(秒)
+	Timeout     int                    `json:"timeout"`            // 超时时间(秒)
+	Priority    int                    `json:"priority,omitempty"` // 优先级 1-10，越大越先执行
+}
+
+// TaskAssignAckMessage 任务分配确认消息，服务端收到后从待确认队列中移除
+type TaskAssignAckMessage struct {
+	ExecutionID string `json:"execution_id"`
+	TaskID      string `json:"task_id"`
+	ProbeID     string `json:"probe_id"`
+	Duplicate   bool   `json:"duplicate,omitempty"` // 该执行此前已接收，本次重投被忽略
 }
 
 // TaskCancelMessage 任务取消消息
@@ -101,7 +114,7 @@ type TaskResultMessage struct {
 	ExecutionID string      `json:"execution_id"`
 	TaskID      string      `json:"task_id"`
 	ProbeID     string      `json:"probe_id"`
-	Status      string      `json:"status"` // success/failed
+	Status      string      `json:"status"` // success/failed/cancelled
 	ResultData  interface{} `json:"result_data"`
 	Error       string      `json:"error,omitempty"`
 	Duration    int64       `json:"duration"` // 执行耗时(ms)
@@ -1,14 +1,26 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
 	"atlas/web/internal/api"
+	"atlas/web/internal/cluster"
 	"atlas/web/internal/config"
 	"atlas/web/internal/database"
+	"atlas/web/internal/geoip"
+	"atlas/web/internal/leader"
+	"atlas/web/internal/peeringdb"
+	"atlas/web/internal/retention"
 	"atlas/web/internal/scheduler"
 	"atlas/web/internal/websocket"
 )
@@ -28,28 +40,115 @@ func main() {
 	}
 
 	// 初始化数据库
-	log.Println("Initializing database...")
-	db, err := database.New(cfg.Database.Path)
+	log.Printf("Initializing database (%s)...", cfg.Database.Driver)
+	dsn := cfg.Database.Path
+	if cfg.Database.Driver == database.DriverPostgres {
+		dsn = cfg.Database.DSN
+	}
+	db, err := database.Open(cfg.Database.Driver, dsn)
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 	defer db.Close()
 
+	// migrate 子命令：只执行迁移操作后退出
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(db, os.Args[2:]); err != nil {
+			db.Close()
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
 	// 运行数据库迁移
 	log.Println("Running database migrations...")
 	if err := db.Migrate(); err != nil {
 		log.Fatalf("Failed to migrate database: %v", err)
 	}
 
+	// GeoIP 服务：查询结果持久化到 geoip_cache 表
+	geoService := geoip.NewWithStore(db, time.Duration(cfg.GeoIP.CacheTTL)*time.Second)
+
 	// 创建WebSocket Hub
 	log.Println("Initializing WebSocket hub...")
-	wsHub := websocket.NewHub(db, cfg.Security.SharedSecret)
+	wsHub := websocket.NewHub(db, geoService, cfg.Security.SharedSecret)
+	wsHub.SetIngestOptions(websocket.IngestOptions{
+		QueueSize:       cfg.Ingest.QueueSize,
+		Workers:         cfg.Ingest.Workers,
+		BatchSize:       cfg.Ingest.BatchSize,
+		FlushInterval:   time.Duration(cfg.Ingest.FlushInterval) * time.Millisecond,
+		EnrichQueueSize: cfg.Ingest.EnrichQueueSize,
+		EnrichWorkers:   cfg.Ingest.EnrichWorkers,
+	})
+	if cfg.PeeringDB.Path != "" {
+		ixIndex, err := peeringdb.Load(cfg.PeeringDB.Path)
+		if err != nil {
+			log.Printf("Failed to load PeeringDB dump (IX detection disabled): %v", err)
+		} else {
+			log.Printf("Loaded %d IX prefixes from %s", ixIndex.Len(), cfg.PeeringDB.Path)
+			wsHub.SetIXIndex(ixIndex)
+		}
+	}
+
+	// 多节点部署：探针连接归属写入共享数据库，跨节点消息经总线转发
+	instanceID := cfg.Scheduler.InstanceID
+	if instanceID == "" {
+		instanceID = leader.DefaultInstanceID()
+	}
+	var bus cluster.Bus
+	if cfg.Cluster.Bus != "" {
+		switch cfg.Cluster.Bus {
+		case "local":
+			bus = cluster.NewLocalBus()
+		case "redis":
+			redisBus, err := cluster.NewRedisBus(cfg.Cluster.RedisAddr, cfg.Cluster.RedisPassword, cfg.Cluster.RedisDB)
+			if err != nil {
+				log.Fatalf("Failed to connect to Redis bus: %v", err)
+			}
+			bus = redisBus
+		default:
+			log.Fatalf("Unknown cluster bus: %s", cfg.Cluster.Bus)
+		}
+		defer bus.Close()
+
+		registry := cluster.NewDBRegistry(db, instanceID, time.Duration(cfg.Cluster.HeartbeatTTL)*time.Second)
+		if err := registry.Reset(); err != nil {
+			log.Printf("Failed to clear stale probe connections: %v", err)
+		}
+		wsHub.SetCluster(registry, bus)
+		log.Printf("Cluster mode enabled (%s bus), node %s", cfg.Cluster.Bus, instanceID)
+	}
+
+	// 创建任务调度器（探针断线时回收其未完成的执行）
+	sched := scheduler.New(db, wsHub, cfg.Scheduler.ScanInterval)
+	sched.SetGeoIP(geoService)
+	wsHub.SetDisconnectHandler(sched.HandleProbeDisconnect)
+	wsHub.SetCapacityHandler(sched.DispatchQueued)
+	wsHub.SetFailureHandler(sched.HandleExecutionFailure)
+	wsHub.SetResultHandler(sched.TriggerFollowUps)
+	if bus != nil {
+		// 非主节点上的断线回收、重试与工作流触发经总线转交主节点
+		sched.SetBus(bus)
+	}
+
+	// 结果保留策略：降采样、删除过期结果并定期压缩数据库
+	retentionJob := retention.New(db, time.Duration(cfg.Retention.Interval)*time.Second)
+	// 后台刷新过期的 GeoIP 缓存条目
+	geoRefresher := geoip.NewRefresher(geoService, time.Duration(cfg.GeoIP.RefreshInterval)*time.Second)
+	// 主节点选举：调度、保留策略与 GeoIP 刷新只在主节点上运行
+	if cfg.Scheduler.LeaderElection {
+		elector := leader.New(db, leader.SchedulerLease, instanceID, time.Duration(cfg.Scheduler.LeaseTTL)*time.Second)
+		log.Printf("Leader election enabled, instance %s", elector.ID())
+		sched.SetElector(elector)
+		retentionJob.SetElector(elector)
+		geoRefresher.SetElector(elector)
+	}
 	go wsHub.Run()
 
-	// 创建任务调度器
 	log.Println("Starting task scheduler...")
-	sched := scheduler.New(db, wsHub, cfg.Scheduler.ScanInterval)
 	go sched.Start()
+	go retentionJob.Start()
+	go geoRefresher.Start()
 
 	// 创建Gin路由
 	r := gin.Default()
@@ -57,7 +156,7 @@ func main() {
 	// 启用CORS
 	r.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -72,7 +171,7 @@ func main() {
 	})
 
 	// 注册API路由
-	api.SetupRoutes(r, db, wsHub, cfg)
+	api.SetupRoutes(r, db, wsHub, geoService, cfg)
 
 	// 静态文件服务(前端)
 	r.Static("/static", cfg.Server.StaticPath)
@@ -98,7 +197,27 @@ func main() {
 	log.Printf("WebSocket route: /ws")
 	log.Printf("API route: /api")
 
-	if err := r.Run(addr); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	srv := &http.Server{Addr: addr, Handler: r}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	// 收到退出信号后停止接收请求与调度，并把已收到的探针结果写完再退出
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	log.Println("Shutting down server...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("Server shutdown failed: %v", err)
 	}
+	sched.Stop()
+	retentionJob.Stop()
+	geoRefresher.Stop()
+	wsHub.Stop()
+	log.Println("Server stopped")
 }
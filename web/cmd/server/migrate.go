@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"atlas/web/internal/database"
+)
+
+const migrateUsage = `usage: atlas-server migrate <command> [steps]
+
+commands:
+  up [N]      apply pending migrations (all by default)
+  down [N]    roll back applied migrations (1 by default)
+  status      list migrations and whether they are applied`
+
+// runMigrate 执行 migrate 子命令
+func runMigrate(db *database.Database, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing migrate command\n%s", migrateUsage)
+	}
+
+	command, rest := args[0], args[1:]
+	steps := 0
+	if command == "down" {
+		steps = 1
+	}
+	if len(rest) > 0 {
+		n, err := strconv.Atoi(rest[0])
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid steps %q\n%s", rest[0], migrateUsage)
+		}
+		steps = n
+	}
+
+	switch command {
+	case "up":
+		applied, err := db.MigrateUp(steps)
+		for _, m := range applied {
+			fmt.Printf("applied  %03d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("no pending migrations")
+		}
+		return nil
+
+	case "down":
+		reverted, err := db.MigrateDown(steps)
+		for _, m := range reverted {
+			fmt.Printf("reverted %03d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(reverted) == 0 {
+			fmt.Println("no applied migrations")
+		}
+		return nil
+
+	case "status":
+		statuses, err := db.MigrationStatus()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT\tDOWN")
+		for _, s := range statuses {
+			state, appliedAt := "pending", "-"
+			if s.Applied {
+				state = "applied"
+				appliedAt = s.AppliedAt.Local().Format("2006-01-02 15:04:05")
+			}
+			down := "yes"
+			if !s.HasDown {
+				down = "no"
+			}
+			fmt.Fprintf(w, "%03d\t%s\t%s\t%s\t%s\n", s.Version, s.Name, state, appliedAt, down)
+		}
+		return w.Flush()
+
+	default:
+		return fmt.Errorf("unknown migrate command %q\n%s", command, migrateUsage)
+	}
+}
@@ -7,7 +7,6 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"errors"
-	"net"
 	"net/http"
 	"strconv"
 	"strings"
@@ -19,14 +18,20 @@ import (
 	"atlas/shared/protocol"
 	"atlas/web/internal/config"
 	"atlas/web/internal/database"
+	"atlas/web/internal/geoip"
 	"atlas/web/internal/model"
+	"atlas/web/internal/retention"
+	"atlas/web/internal/selector"
+	"atlas/web/internal/targetutil"
 	"atlas/web/internal/websocket"
 )
 
 type AdminHandler struct {
-	db  *database.Database
-	cfg *config.Config
+	db               *database.Database
+	cfg              *config.Config
+	geoip            *geoip.GeoIPService
 	sendProbeUpgrade func(string, protocol.ProbeUpgradeMessage) error
+	ingestStats      func() websocket.IngestStats
 }
 
 type adminConfigDTO struct {
@@ -38,6 +43,18 @@ type adminConfigDTO struct {
 	TCPPingMaxRuns           int `json:"tcp_ping_max_runs"`
 	TracerouteTimeoutSeconds int `json:"traceroute_timeout_seconds"`
 	MTRTimeoutSeconds        int `json:"mtr_timeout_seconds"`
+
+	// 路径变化检测：nil 表示不修改
+	PathFingerprintMode  *string `json:"path_fingerprint_mode"`
+	PathChangeWebhookURL *string `json:"path_change_webhook_url"`
+
+	// 断线执行重新分配：nil 表示不修改
+	LostExecutionRetry            *string `json:"lost_execution_retry"`
+	LostExecutionRetryWaitSeconds *int    `json:"lost_execution_retry_wait_seconds"`
+
+	// 结果保留与数据库压缩：nil 表示不修改
+	ResultRetention     *retention.Policy `json:"result_retention"`
+	VacuumIntervalHours *int              `json:"vacuum_interval_hours"`
 }
 
 type adminLoginRequest struct {
@@ -49,16 +66,23 @@ type adminTokenPayload struct {
 	Exp int64  `json:"exp"`
 }
 
-func NewAdminHandler(db *database.Database, hub *websocket.Hub, cfg *config.Config) *AdminHandler {
+func NewAdminHandler(db *database.Database, hub *websocket.Hub, geoService *geoip.GeoIPService, cfg *config.Config) *AdminHandler {
 	return &AdminHandler{
-		db:  db,
-		cfg: cfg,
+		db:    db,
+		cfg:   cfg,
+		geoip: geoService,
 		sendProbeUpgrade: func(probeID string, message protocol.ProbeUpgradeMessage) error {
 			return hub.SendToProbe(probeID, protocol.MsgTypeProbeUpgrade, message)
 		},
+		ingestStats: hub.IngestStats,
 	}
 }
 
+// IngestStats 结果入库流水线的队列深度与计数
+func (h *AdminHandler) IngestStats(c *gin.Context) {
+	c.JSON(http.StatusOK, h.ingestStats())
+}
+
 func (h *AdminHandler) Login(c *gin.Context) {
 	var req adminLoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -99,6 +123,17 @@ func (h *AdminHandler) GetConfig(c *gin.Context) {
 	tcpPingMaxRuns, _ := h.db.GetConfig("tcp_ping_max_runs")
 	trTimeout, _ := h.db.GetConfig("traceroute_timeout_seconds")
 	mtrTimeout, _ := h.db.GetConfig("mtr_timeout_seconds")
+	pathMode, _ := h.db.GetConfig("path_fingerprint_mode")
+	pathWebhook, _ := h.db.GetConfig("path_change_webhook_url")
+	lostRetry, _ := h.db.GetConfig("lost_execution_retry")
+	lostRetryWait, _ := h.db.GetConfig("lost_execution_retry_wait_seconds")
+	retentionRaw, _ := h.db.GetConfig(retention.PolicyConfigKey)
+	vacuumInterval, _ := h.db.GetConfig(retention.CompactIntervalConfigKey)
+
+	retentionPolicy, err := retention.ParsePolicy(retentionRaw)
+	if err != nil {
+		retentionPolicy = retention.Policy{}
+	}
 
 	// 如果DB未初始化这些键，退回到当前运行配置
 	if sharedSecret == "" {
@@ -106,12 +141,18 @@ func (h *AdminHandler) GetConfig(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"shared_secret":              sharedSecret,
-		"blocked_networks":           blocked,
-		"ping_max_runs":              pingMaxRuns,
-		"tcp_ping_max_runs":          tcpPingMaxRuns,
-		"traceroute_timeout_seconds": trTimeout,
-		"mtr_timeout_seconds":        mtrTimeout,
+		"shared_secret":                     sharedSecret,
+		"blocked_networks":                  blocked,
+		"ping_max_runs":                     pingMaxRuns,
+		"tcp_ping_max_runs":                 tcpPingMaxRuns,
+		"traceroute_timeout_seconds":        trTimeout,
+		"mtr_timeout_seconds":               mtrTimeout,
+		"path_fingerprint_mode":             pathMode,
+		"path_change_webhook_url":           pathWebhook,
+		"lost_execution_retry":              lostRetry,
+		"lost_execution_retry_wait_seconds": lostRetryWait,
+		"result_retention":                  retentionPolicy,
+		"vacuum_interval_hours":             vacuumInterval,
 	})
 }
 
@@ -162,9 +203,9 @@ func (h *AdminHandler) UpdateConfig(c *gin.Context) {
 		return
 	}
 
-	blocked := normalizeBlockedNetworks(req.BlockedNetworks)
+	blocked := targetutil.NormalizeBlockedNetworks(req.BlockedNetworks)
 	if blocked != "" {
-		if _, err := parseBlockedNetworks(blocked); err != nil {
+		if _, err := targetutil.ParseBlockedNetworks(blocked); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid blocked_networks"})
 			return
 		}
@@ -186,6 +227,48 @@ func (h *AdminHandler) UpdateConfig(c *gin.Context) {
 	setPositiveInt("traceroute_timeout_seconds", req.TracerouteTimeoutSeconds)
 	setPositiveInt("mtr_timeout_seconds", req.MTRTimeoutSeconds)
 
+	if req.PathFingerprintMode != nil {
+		mode := strings.TrimSpace(*req.PathFingerprintMode)
+		if mode != "ip" && mode != "asn" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "path_fingerprint_mode must be ip or asn"})
+			return
+		}
+		_ = h.db.SetConfig("path_fingerprint_mode", mode)
+	}
+	if req.PathChangeWebhookURL != nil {
+		_ = h.db.SetConfig("path_change_webhook_url", strings.TrimSpace(*req.PathChangeWebhookURL))
+	}
+	if req.LostExecutionRetry != nil {
+		policy := strings.TrimSpace(*req.LostExecutionRetry)
+		if policy != "none" && policy != "same_probe" && policy != "any_probe" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "lost_execution_retry must be none, same_probe or any_probe"})
+			return
+		}
+		_ = h.db.SetConfig("lost_execution_retry", policy)
+	}
+	if req.LostExecutionRetryWaitSeconds != nil {
+		if *req.LostExecutionRetryWaitSeconds < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "lost_execution_retry_wait_seconds must not be negative"})
+			return
+		}
+		_ = h.db.SetConfig("lost_execution_retry_wait_seconds", strconv.Itoa(*req.LostExecutionRetryWaitSeconds))
+	}
+	if req.ResultRetention != nil {
+		if err := req.ResultRetention.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		policyJSON, _ := json.Marshal(req.ResultRetention)
+		_ = h.db.SetConfig(retention.PolicyConfigKey, string(policyJSON))
+	}
+	if req.VacuumIntervalHours != nil {
+		if *req.VacuumIntervalHours < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "vacuum_interval_hours must not be negative"})
+			return
+		}
+		_ = h.db.SetConfig(retention.CompactIntervalConfigKey, strconv.Itoa(*req.VacuumIntervalHours))
+	}
+
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
 
@@ -198,13 +281,21 @@ func (h *AdminHandler) UpdateProbe(c *gin.Context) {
 	}
 
 	var req struct {
-		Name string `json:"name"`
+		Name   string            `json:"name"`
+		Labels map[string]string `json:"labels"` // 后台标签，覆盖探针上报的同名标签；空值删除该标签；nil 表示不修改
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
 		return
 	}
 
+	if req.Labels != nil {
+		if err := selector.ValidateLabels(req.Labels); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
 	if strings.TrimSpace(req.Name) != "" {
 		probe.Name = strings.TrimSpace(req.Name)
 	}
@@ -214,6 +305,14 @@ func (h *AdminHandler) UpdateProbe(c *gin.Context) {
 		return
 	}
 
+	if req.Labels != nil {
+		labelsJSON, _ := json.Marshal(req.Labels)
+		if err := h.db.UpdateProbeAdminLabels(probeID, string(labelsJSON)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update probe labels"})
+			return
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
 
@@ -330,36 +429,6 @@ func (h *AdminHandler) signAdminToken(jwtSecret string, ttl time.Duration) (stri
 	return unsigned + "." + sigB64, nil
 }
 
-func normalizeBlockedNetworks(raw string) string {
-	lines := strings.Split(raw, "\n")
-	out := make([]string, 0, len(lines))
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
-		out = append(out, line)
-	}
-	return strings.Join(out, "\n")
-}
-
-func parseBlockedNetworks(blocked string) ([]*net.IPNet, error) {
-	lines := strings.Split(blocked, "\n")
-	var nets []*net.IPNet
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
-		_, ipnet, err := net.ParseCIDR(line)
-		if err != nil {
-			return nil, err
-		}
-		nets = append(nets, ipnet)
-	}
-	return nets, nil
-}
-
 func (h *AdminHandler) GenerateSharedSecret(c *gin.Context) {
 	b := make([]byte, 32)
 	if _, err := rand.Read(b); err != nil {
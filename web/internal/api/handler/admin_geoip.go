@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"atlas/web/internal/model"
+)
+
+type adminGeoIPCacheDTO struct {
+	*model.GeoIPCacheEntry
+	Expired  bool            `json:"expired"`
+	Location json.RawMessage `json:"location,omitempty"`
+}
+
+const (
+	defaultGeoIPCacheLimit = 100
+	maxGeoIPCacheLimit     = 1000
+)
+
+// ListGeoIPCache 列出 GeoIP 缓存条目
+// GET /api/admin/geoip-cache?ip=&expired=true&limit=&offset=
+func (h *AdminHandler) ListGeoIPCache(c *gin.Context) {
+	limit := defaultGeoIPCacheLimit
+	offset := 0
+	if v, err := strconv.Atoi(c.Query("limit")); err == nil && v > 0 {
+		limit = v
+	}
+	if limit > maxGeoIPCacheLimit {
+		limit = maxGeoIPCacheLimit
+	}
+	if v, err := strconv.Atoi(c.Query("offset")); err == nil && v >= 0 {
+		offset = v
+	}
+	expiredOnly := c.Query("expired") == "true"
+
+	entries, total, err := h.db.ListGeoIPCache(strings.TrimSpace(c.Query("ip")), expiredOnly, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list geoip cache"})
+		return
+	}
+
+	rows := make([]*adminGeoIPCacheDTO, 0, len(entries))
+	for _, entry := range entries {
+		row := &adminGeoIPCacheDTO{
+			GeoIPCacheEntry: entry,
+			Expired:         !entry.ExpiresAt.After(time.Now()),
+		}
+		if json.Valid([]byte(entry.Data)) {
+			row.Location = json.RawMessage(entry.Data)
+		}
+		rows = append(rows, row)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries": rows,
+		"total":   total,
+	})
+}
+
+// DeleteGeoIPCacheEntry 删除单个 IP 的缓存，下次查询会重新拉取
+// DELETE /api/admin/geoip-cache/:ip
+func (h *AdminHandler) DeleteGeoIPCacheEntry(c *gin.Context) {
+	ip := strings.TrimSpace(c.Param("ip"))
+	deleted, err := h.db.DeleteGeoIPCache(ip)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete geoip cache entry"})
+		return
+	}
+	if h.geoip != nil {
+		h.geoip.Forget(ip)
+	}
+	if deleted == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cache entry not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "deleted": deleted})
+}
+
+// PurgeGeoIPCache 清空缓存；expired=true 时只删除已过期条目
+// DELETE /api/admin/geoip-cache
+func (h *AdminHandler) PurgeGeoIPCache(c *gin.Context) {
+	expiredOnly := c.Query("expired") == "true"
+	deleted, err := h.db.PurgeGeoIPCache(expiredOnly)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to purge geoip cache"})
+		return
+	}
+	if h.geoip != nil {
+		h.geoip.ForgetAll()
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "deleted": deleted})
+}
+
+// RefreshGeoIPCacheEntry 立即重新查询单个 IP 并覆盖缓存
+// POST /api/admin/geoip-cache/:ip/refresh
+func (h *AdminHandler) RefreshGeoIPCacheEntry(c *gin.Context) {
+	if h.geoip == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "GeoIP service not configured"})
+		return
+	}
+
+	ip := strings.TrimSpace(c.Param("ip"))
+	location, err := h.geoip.Refresh(ip)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": location})
+}
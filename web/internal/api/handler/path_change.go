@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"atlas/web/internal/database"
+)
+
+const (
+	defaultPathChangeLimit = 100
+	maxPathChangeLimit     = 1000
+)
+
+// PathChangeHandler 路径变化历史处理器
+type PathChangeHandler struct {
+	db *database.Database
+}
+
+// NewPathChangeHandler 创建路径变化历史处理器
+func NewPathChangeHandler(db *database.Database) *PathChangeHandler {
+	return &PathChangeHandler{db: db}
+}
+
+// ListPathChanges 列出路径变化事件
+// GET /api/path-changes?probe_id=&target=&limit=&offset=
+func (h *PathChangeHandler) ListPathChanges(c *gin.Context) {
+	probeID := strings.TrimSpace(c.Query("probe_id"))
+	target := strings.TrimSpace(c.Query("target"))
+	limit := defaultPathChangeLimit
+	offset := 0
+
+	if l := c.Query("limit"); l != "" {
+		v, err := strconv.Atoi(l)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		limit = v
+	}
+	if limit <= 0 {
+		limit = defaultPathChangeLimit
+	}
+	if limit > maxPathChangeLimit {
+		limit = maxPathChangeLimit
+	}
+	if o := c.Query("offset"); o != "" {
+		v, err := strconv.Atoi(o)
+		if err != nil || v < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid offset"})
+			return
+		}
+		offset = v
+	}
+
+	changes, total, err := h.db.ListPathChanges(probeID, target, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list path changes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"changes": changes,
+		"total":   total,
+	})
+}
@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestListPathChangesValidatesPagination(t *testing.T) {
+	handler := NewPathChangeHandler(newTaskHandlerTestDB(t))
+
+	for _, tc := range []struct {
+		query string
+		want  int
+	}{
+		{"", http.StatusOK},
+		{"?limit=0&offset=0", http.StatusOK},
+		{"?limit=1000000", http.StatusOK},
+		{"?limit=ten", http.StatusBadRequest},
+		{"?offset=-1", http.StatusBadRequest},
+		{"?offset=5x", http.StatusBadRequest},
+	} {
+		recorder := serveTaskHandler(t, handler.ListPathChanges, http.MethodGet, "/api/path-changes"+tc.query, nil, "")
+		if recorder.Code != tc.want {
+			t.Fatalf("%q: expected status %d, got %d: %s", tc.query, tc.want, recorder.Code, recorder.Body.String())
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"atlas/web/internal/model"
+	"atlas/web/internal/selector"
 )
 
 type probeMetadataInfo struct {
@@ -44,6 +45,7 @@ type adminProbeDTO struct {
 	UpgradeChannel   string                     `json:"upgrade_channel,omitempty"`
 	LatestUpgrade    *model.ProbeUpgrade        `json:"latest_upgrade,omitempty"`
 	SystemSupport    adminProbeSystemSupportDTO `json:"system_support"`
+	EffectiveLabels  map[string]string          `json:"effective_labels"`
 }
 
 func parseProbeMetadataInfo(raw string) probeMetadataInfo {
@@ -88,6 +90,7 @@ func buildAdminProbeDTO(probe *model.Probe, latestUpgrade *model.ProbeUpgrade) *
 		UpgradeChannel:   info.UpgradeChannel,
 		LatestUpgrade:    latestUpgrade,
 		SystemSupport:    info.SystemSupport,
+		EffectiveLabels:  selector.ProbeLabels(probe),
 	}
 }
 
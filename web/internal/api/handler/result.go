@@ -1,60 +1,219 @@
 package handler
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 
 	"atlas/web/internal/database"
+	"atlas/web/internal/geoip"
+	"atlas/web/internal/model"
+	"atlas/web/internal/routeutil"
+	"atlas/web/internal/sampling"
+	"atlas/web/internal/selector"
 )
 
 // ResultHandler 结果处理器
 
 type ResultHandler struct {
-	db *database.Database
+	db      *database.Database
+	locator sampling.Locator // 探针没有 country 标签时按 IP 查询国家，与抽样策略一致
 }
 
-// NewResultHandler 创建结果处理器
-func NewResultHandler(db *database.Database) *ResultHandler {
-	return &ResultHandler{db: db}
+// NewResultHandler 创建结果处理器；geoIPService 为 nil 时 country 过滤只使用标签
+func NewResultHandler(db *database.Database, geoIPService *geoip.GeoIPService) *ResultHandler {
+	h := &ResultHandler{db: db}
+	if geoIPService != nil {
+		h.locator = geoIPService
+	}
+	return h
+}
+
+const (
+	defaultResultLimit = 100
+	maxResultLimit     = 1000
+)
+
+// resultCursor 游标中记录排序方式，换了排序的游标不能继续使用
+type resultCursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d"`
+	Value string `json:"v"`
+	ID    int64  `json:"id"`
 }
 
-// ListResults 列出结果
-// GET /api/results
+// ListResults 按条件搜索结果
+// GET /api/results?task_id=&probe_id=&probe_ids=a,b&region=&country=&target=&target_contains=&test_type=&status=
+//
+//	&from=&to=&threshold=packet_loss>5&sort=created_at&order=desc&limit=&offset=&cursor=
 func (h *ResultHandler) ListResults(c *gin.Context) {
-	taskID := c.Query("task_id")
-	probeID := c.Query("probe_id")
-	limit := 100
-	offset := 0
+	filter := database.ResultFilter{
+		TaskID:         strings.TrimSpace(c.Query("task_id")),
+		Target:         strings.TrimSpace(c.Query("target")),
+		TargetContains: strings.TrimSpace(c.Query("target_contains")),
+		TestTypes:      splitCSV(c.Query("test_type")),
+		Statuses:       splitCSV(c.Query("status")),
+		Sort:           strings.TrimSpace(c.DefaultQuery("sort", "created_at")),
+		Limit:          defaultResultLimit,
+	}
+
+	if !database.IsResultSortField(filter.Sort) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be one of created_at, avg_latency, packet_loss, http_status, ttfb"})
+		return
+	}
+	switch strings.ToLower(c.DefaultQuery("order", "desc")) {
+	case "desc":
+		filter.Desc = true
+	case "asc":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "order must be asc or desc"})
+		return
+	}
 
 	if l := c.Query("limit"); l != "" {
-		fmt.Sscanf(l, "%d", &limit)
+		fmt.Sscanf(l, "%d", &filter.Limit)
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultResultLimit
+	}
+	if filter.Limit > maxResultLimit {
+		filter.Limit = maxResultLimit
 	}
 	if o := c.Query("offset"); o != "" {
-		fmt.Sscanf(o, "%d", &offset)
+		fmt.Sscanf(o, "%d", &filter.Offset)
 	}
 
-	var results interface{}
 	var err error
-
-	if taskID != "" {
-		results, err = h.db.ListResultsByTask(taskID, limit, offset)
-	} else if probeID != "" {
-		results, err = h.db.ListResultsByProbe(probeID, limit, offset)
-	} else {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "task_id or probe_id required"})
+	if filter.From, err = parseTimeQuery(c.Query("from")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from"})
+		return
+	}
+	if filter.To, err = parseTimeQuery(c.Query("to")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to"})
 		return
 	}
 
+	for _, raw := range c.QueryArray("threshold") {
+		for _, expr := range strings.Split(raw, ",") {
+			if strings.TrimSpace(expr) == "" {
+				continue
+			}
+			threshold, err := database.ParseMetricThreshold(expr)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			filter.Thresholds = append(filter.Thresholds, threshold)
+		}
+	}
+
+	if raw := strings.TrimSpace(c.Query("cursor")); raw != "" {
+		cursor, err := decodeResultCursor(raw)
+		if err != nil || cursor.Sort != filter.Sort || cursor.Desc != filter.Desc {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+		filter.Cursor = &database.ResultCursor{Value: cursor.Value, ID: cursor.ID}
+	}
+
+	probeIDs := splitCSV(c.Query("probe_ids"))
+	if probeID := strings.TrimSpace(c.Query("probe_id")); probeID != "" {
+		probeIDs = append(probeIDs, probeID)
+	}
+	if len(probeIDs) > 0 {
+		filter.ProbeIDs = probeIDs
+	}
+	region := strings.TrimSpace(c.Query("region"))
+	country := strings.TrimSpace(c.Query("country"))
+	if region != "" || country != "" {
+		filter.ProbeIDs, err = h.probesInLocation(filter.ProbeIDs, region, country)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list probes"})
+			return
+		}
+	}
+
+	// 多取一条判断是否还有下一页
+	pageSize := filter.Limit
+	filter.Limit = pageSize + 1
+	results, err := h.db.SearchResults(filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list results"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"results": results,
-	})
+	nextCursor := ""
+	if len(results) > pageSize {
+		results = results[:pageSize]
+		last := database.CursorFor(results[pageSize-1], filter.Sort)
+		nextCursor = encodeResultCursor(resultCursor{Sort: filter.Sort, Desc: filter.Desc, Value: last.Value, ID: last.ID})
+	}
+	if results == nil {
+		results = []*model.Result{}
+	}
+
+	response := gin.H{
+		"results":     results,
+		"next_cursor": nextCursor,
+	}
+	// 总数需要扫描全部匹配行，只在第一页计算；游标翻页沿用第一页的总数
+	if filter.Cursor == nil {
+		total, err := h.db.CountResults(filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count results"})
+			return
+		}
+		response["total"] = total
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// probesInLocation 按探针标签中的 region 与探针所在国家过滤探针；candidates 非空时只在其中筛选
+func (h *ResultHandler) probesInLocation(candidates []string, region, country string) ([]string, error) {
+	probes, err := h.db.ListProbes("")
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := make(map[string]bool, len(candidates))
+	for _, probeID := range candidates {
+		allowed[probeID] = true
+	}
+
+	matched := []string{}
+	for _, probe := range probes {
+		if len(candidates) > 0 && !allowed[probe.ProbeID] {
+			continue
+		}
+		labels := selector.ProbeLabels(probe)
+		if region != "" && !strings.EqualFold(labels["region"], region) {
+			continue
+		}
+		if country != "" && !strings.EqualFold(sampling.ProbeCountry(probe, h.locator), country) {
+			continue
+		}
+		matched = append(matched, probe.ProbeID)
+	}
+	return matched, nil
+}
+
+func encodeResultCursor(cursor resultCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeResultCursor(raw string) (resultCursor, error) {
+	var cursor resultCursor
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return cursor, err
+	}
+	err = json.Unmarshal(data, &cursor)
+	return cursor, err
 }
 
 // GetResult 获取结果详情
@@ -70,3 +229,33 @@ func (h *ResultHandler) GetResult(c *gin.Context) {
 
 	c.JSON(http.StatusOK, result)
 }
+
+// GetResultASPath 获取 route 类结果的 AS 级路径
+// GET /api/results/:id/aspath
+func (h *ResultHandler) GetResultASPath(c *gin.Context) {
+	resultID := c.Param("id")
+
+	result, err := h.db.GetResult(resultID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Result not found"})
+		return
+	}
+
+	if result.TestType != "traceroute" && result.TestType != "mtr" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "AS path is only available for traceroute and mtr results"})
+		return
+	}
+
+	hops, err := routeutil.ParseHopsJSON(result.ResultData)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse result data"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"result_id": result.ResultID,
+		"target":    result.Target,
+		"test_type": result.TestType,
+		"as_path":   routeutil.BuildASPath(hops),
+	})
+}
@@ -0,0 +1,225 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"atlas/web/internal/database"
+	"atlas/web/internal/geoip"
+	"atlas/web/internal/model"
+)
+
+type resultListBody struct {
+	Results    []*model.Result `json:"results"`
+	Total      int             `json:"total"`
+	NextCursor string          `json:"next_cursor"`
+}
+
+func seedSearchResult(t *testing.T, db *database.Database, probeID, resultID, target, status string, latency, loss float64) {
+	t.Helper()
+
+	if err := db.SaveExecution(&model.TaskExecution{ExecutionID: resultID, TaskID: "task-search", ProbeID: probeID, Status: status, StartedAt: time.Now()}); err != nil {
+		t.Fatalf("SaveExecution failed: %v", err)
+	}
+	if err := db.SaveResult(&model.Result{
+		ResultID:    resultID,
+		ExecutionID: resultID,
+		TaskID:      "task-search",
+		ProbeID:     probeID,
+		Target:      target,
+		TestType:    "icmp_ping",
+		Status:      status,
+		ResultData:  "{}",
+		Summary:     fmt.Sprintf(`{"avg_latency":%v,"packet_loss_percent":%v}`, latency, loss),
+		AvgLatency:  &latency,
+		PacketLoss:  &loss,
+	}); err != nil {
+		t.Fatalf("SaveResult failed: %v", err)
+	}
+}
+
+func listResults(t *testing.T, handler *ResultHandler, query url.Values) (int, resultListBody) {
+	t.Helper()
+
+	recorder := serveTaskHandler(t, handler.ListResults, http.MethodGet, "/api/results?"+query.Encode(), nil, "")
+	var body resultListBody
+	if recorder.Code == http.StatusOK {
+		if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+	}
+	return recorder.Code, body
+}
+
+func resultIDs(results []*model.Result) []string {
+	ids := make([]string, 0, len(results))
+	for _, result := range results {
+		ids = append(ids, result.ResultID)
+	}
+	return ids
+}
+
+func TestListResultsFiltersAndPaginatesWithCursor(t *testing.T) {
+	db := newTaskHandlerTestDB(t)
+	seedTaskHandlerProbe(t, db, "probe-de", `["icmp_ping"]`, "online")
+	seedTaskHandlerProbe(t, db, "probe-us", `["icmp_ping"]`, "online")
+	if err := db.UpdateProbeAdminLabels("probe-de", `{"country":"DE","region":"eu"}`); err != nil {
+		t.Fatalf("UpdateProbeAdminLabels failed: %v", err)
+	}
+	if err := db.UpdateProbeAdminLabels("probe-us", `{"country":"US","region":"na"}`); err != nil {
+		t.Fatalf("UpdateProbeAdminLabels failed: %v", err)
+	}
+	if err := db.CreateTask(&model.Task{TaskID: "task-search", TaskType: "icmp_ping", Mode: "continuous", Target: "one.one.one.one", Status: "running", Priority: 5}); err != nil {
+		t.Fatalf("CreateTask failed: %v", err)
+	}
+
+	seedSearchResult(t, db, "probe-de", "r1", "one.one.one.one", "success", 30, 0)
+	seedSearchResult(t, db, "probe-de", "r2", "one.one.one.one", "success", 10, 10)
+	seedSearchResult(t, db, "probe-de", "r3", "dns.google", "failed", 50, 100)
+	seedSearchResult(t, db, "probe-us", "r4", "one.one.one.one", "success", 20, 0)
+	seedSearchResult(t, db, "probe-us", "r5", "one.one.one.one", "success", 40, 6)
+
+	handler := NewResultHandler(db, nil)
+
+	cases := []struct {
+		name  string
+		query url.Values
+		want  int
+	}{
+		{"all", url.Values{}, 5},
+		{"country", url.Values{"country": {"de"}}, 3},
+		{"region and probe", url.Values{"region": {"eu"}, "probe_ids": {"probe-us"}}, 0},
+		{"target contains", url.Values{"target_contains": {"GOOGLE"}}, 1},
+		{"exact target", url.Values{"target": {"one.one.one.one"}}, 4},
+		{"status", url.Values{"status": {"failed"}}, 1},
+		{"threshold", url.Values{"threshold": {"packet_loss>5"}}, 3},
+		{"thresholds", url.Values{"threshold": {"packet_loss>5,avg_latency<=40"}}, 2},
+		{"time range", url.Values{"from": {fmt.Sprint(time.Now().Add(time.Hour).Unix())}}, 0},
+	}
+	for _, tc := range cases {
+		code, body := listResults(t, handler, tc.query)
+		if code != http.StatusOK || body.Total != tc.want || len(body.Results) != tc.want {
+			t.Fatalf("%s: expected %d results, got status %d total %d ids %v", tc.name, tc.want, code, body.Total, resultIDs(body.Results))
+		}
+	}
+
+	// 按延迟升序分页，每页 2 条；总数为全部匹配数，只在第一页返回
+	var pages [][]string
+	query := url.Values{"sort": {"avg_latency"}, "order": {"asc"}, "limit": {"2"}}
+	for {
+		code, body := listResults(t, handler, query)
+		wantTotal := 0
+		if query.Get("cursor") == "" {
+			wantTotal = 5
+		}
+		if code != http.StatusOK || body.Total != wantTotal {
+			t.Fatalf("expected status 200 with total %d, got %d / %d", wantTotal, code, body.Total)
+		}
+		pages = append(pages, resultIDs(body.Results))
+		if body.NextCursor == "" {
+			break
+		}
+		query.Set("cursor", body.NextCursor)
+	}
+	if got := fmt.Sprint(pages); got != "[[r2 r4] [r1 r5] [r3]]" {
+		t.Fatalf("unexpected pages: %s", got)
+	}
+
+	// 默认按时间倒序；同一秒写入的结果按 id 区分游标位置
+	seen := map[string]bool{}
+	query = url.Values{"limit": {"2"}}
+	for {
+		_, body := listResults(t, handler, query)
+		for _, id := range resultIDs(body.Results) {
+			seen[id] = true
+		}
+		if body.NextCursor == "" {
+			break
+		}
+		query.Set("cursor", body.NextCursor)
+	}
+	if len(seen) != 5 {
+		t.Fatalf("expected all 5 results across created_at pages, got %v", seen)
+	}
+
+	// 游标不能跨排序方式使用
+	query = url.Values{"sort": {"avg_latency"}, "order": {"asc"}, "limit": {"2"}}
+	_, first := listResults(t, handler, query)
+	query.Set("cursor", first.NextCursor)
+	query.Set("order", "desc")
+	if code, _ := listResults(t, handler, query); code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for mismatched cursor, got %d", code)
+	}
+	for _, bad := range []url.Values{
+		{"sort": {"summary"}},
+		{"threshold": {"jitter>5"}},
+		{"threshold": {"packet_loss>>5"}},
+		{"cursor": {"not-a-cursor"}},
+	} {
+		if code, _ := listResults(t, handler, bad); code != http.StatusBadRequest {
+			t.Fatalf("expected status 400 for %v, got %d", bad, code)
+		}
+	}
+}
+
+func TestListResultsCountryFilterFallsBackToGeoIP(t *testing.T) {
+	db := newTaskHandlerTestDB(t)
+	seedTaskHandlerProbe(t, db, "probe-labelled", `["icmp_ping"]`, "online")
+	seedTaskHandlerProbe(t, db, "probe-geo", `["icmp_ping"]`, "online")
+	if err := db.UpdateProbeAdminLabels("probe-labelled", `{"country":"DE"}`); err != nil {
+		t.Fatalf("UpdateProbeAdminLabels failed: %v", err)
+	}
+	probe, _ := db.GetProbe("probe-geo")
+	probe.IPAddress = "203.0.113.9"
+	if err := db.SaveProbe(probe); err != nil {
+		t.Fatalf("SaveProbe failed: %v", err)
+	}
+	if err := db.SaveGeoIPCache(&model.GeoIPCacheEntry{IP: "203.0.113.9", Provider: "test", Data: `{"country":"DE"}`, FetchedAt: time.Now(), TTLSeconds: 3600}); err != nil {
+		t.Fatalf("SaveGeoIPCache failed: %v", err)
+	}
+	if err := db.CreateTask(&model.Task{TaskID: "task-search", TaskType: "icmp_ping", Mode: "continuous", Target: "1.1.1.1", Status: "running", Priority: 5}); err != nil {
+		t.Fatalf("CreateTask failed: %v", err)
+	}
+	seedSearchResult(t, db, "probe-labelled", "r1", "1.1.1.1", "success", 10, 0)
+	seedSearchResult(t, db, "probe-geo", "r2", "1.1.1.1", "success", 20, 0)
+
+	// 未配置 GeoIP 时只按标签匹配；配置后没有标签的探针按 IP 所在国家匹配，与抽样策略一致
+	for _, tc := range []struct {
+		handler *ResultHandler
+		want    int
+	}{
+		{NewResultHandler(db, nil), 1},
+		{NewResultHandler(db, geoip.NewWithStore(db, time.Hour)), 2},
+	} {
+		code, body := listResults(t, tc.handler, url.Values{"country": {"de"}})
+		if code != http.StatusOK || len(body.Results) != tc.want {
+			t.Fatalf("expected %d results for country DE, got status %d ids %v", tc.want, code, resultIDs(body.Results))
+		}
+	}
+}
+
+func TestListTasksReturnsTotalAcrossPages(t *testing.T) {
+	db := newTaskHandlerTestDB(t)
+	for i := 0; i < 3; i++ {
+		if err := db.CreateTask(&model.Task{TaskID: fmt.Sprintf("task-%d", i), TaskType: "icmp_ping", Mode: "single", Target: "1.1.1.1", Status: "pending", Priority: 5}); err != nil {
+			t.Fatalf("CreateTask failed: %v", err)
+		}
+	}
+
+	handler := &TaskHandler{db: db}
+	recorder := serveTaskHandler(t, handler.ListTasks, http.MethodGet, "/api/tasks?limit=2", nil, "")
+	var body struct {
+		Tasks []*model.Task `json:"tasks"`
+		Total int           `json:"total"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if len(body.Tasks) != 2 || body.Total != 3 {
+		t.Fatalf("expected 2 tasks with total 3, got %d / %d", len(body.Tasks), body.Total)
+	}
+}
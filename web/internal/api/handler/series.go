@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"atlas/web/internal/database"
+	"atlas/web/internal/series"
+)
+
+const (
+	// 单次查询最多返回的时间桶数
+	maxSeriesPoints = 10000
+	// 单次查询所有序列合计最多返回的数据点数
+	maxSeriesTotalPoints = 100000
+	// 未指定 step 时的目标桶数
+	defaultSeriesPoints = 300
+)
+
+// SeriesHandler 时序查询处理器
+type SeriesHandler struct {
+	db *database.Database
+}
+
+// NewSeriesHandler 创建时序查询处理器
+func NewSeriesHandler(db *database.Database) *SeriesHandler {
+	return &SeriesHandler{db: db}
+}
+
+// GetSeries 按时间桶聚合结果指标
+// GET /api/series?metric=avg_latency&aggregation=p95&probe_ids=a,b&target=&task_type=&from=&to=&step=5m
+func (h *SeriesHandler) GetSeries(c *gin.Context) {
+	metric := strings.TrimSpace(c.DefaultQuery("metric", database.MetricAvgLatency))
+	if !database.IsSeriesMetric(metric) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "metric must be one of avg_latency, packet_loss, http_status, ttfb"})
+		return
+	}
+	aggregation := strings.TrimSpace(c.DefaultQuery("aggregation", series.AggAvg))
+	if err := series.ValidateAggregation(aggregation); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	from, err := parseTimeQuery(c.Query("from"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from"})
+		return
+	}
+	to, err := parseTimeQuery(c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to"})
+		return
+	}
+	// 默认最近 24 小时
+	end := time.Now()
+	if to != nil {
+		end = *to
+	}
+	start := end.Add(-24 * time.Hour)
+	if from != nil {
+		start = *from
+	}
+	if !start.Before(end) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return
+	}
+
+	step, err := parseSeriesStep(c.Query("step"), end.Sub(start))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if end.Sub(start)/step > maxSeriesPoints {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("step too small: at most %d points per query", maxSeriesPoints)})
+		return
+	}
+
+	query := database.SeriesQuery{
+		Metric:      metric,
+		Aggregation: aggregation,
+		ProbeIDs:    splitCSV(c.Query("probe_ids")),
+		Target:      strings.TrimSpace(c.Query("target")),
+		TaskType:    strings.TrimSpace(c.Query("task_type")),
+		From:        start,
+		To:          end,
+		Step:        step,
+	}
+
+	points, truncated, err := h.db.ListSeriesPoints(query, maxSeriesTotalPoints)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query series"})
+		return
+	}
+	if truncated {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("too many points: at most %d per query, narrow the filters or increase step", maxSeriesTotalPoints)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"metric":      metric,
+		"aggregation": aggregation,
+		"from":        start.UTC(),
+		"to":          end.UTC(),
+		"step":        int64(step / time.Second),
+		"series":      series.Build(points),
+	})
+}
+
+// parseSeriesStep 解析 Go duration(5m、1h)或整数秒；为空时按区间取约 defaultSeriesPoints 个桶，最小 1 分钟
+func parseSeriesStep(raw string, span time.Duration) (time.Duration, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		step := (span/defaultSeriesPoints + time.Second - 1).Truncate(time.Second)
+		if step < time.Minute {
+			step = time.Minute
+		}
+		return step, nil
+	}
+
+	var step time.Duration
+	if sec, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		step = time.Duration(sec) * time.Second
+	} else if d, err := time.ParseDuration(raw); err == nil {
+		step = d
+	} else {
+		return 0, fmt.Errorf("invalid step")
+	}
+	if step < time.Second || step%time.Second != 0 {
+		return 0, fmt.Errorf("step must be a whole number of seconds")
+	}
+	return step, nil
+}
@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"atlas/web/internal/database"
+	"atlas/web/internal/model"
+	"atlas/web/internal/series"
+)
+
+func seedSeriesResult(t *testing.T, db *database.Database, probeID, resultID string, latency float64) {
+	t.Helper()
+
+	if err := db.SaveExecution(&model.TaskExecution{ExecutionID: resultID, TaskID: "task-series", ProbeID: probeID, Status: "success", StartedAt: time.Now()}); err != nil {
+		t.Fatalf("SaveExecution failed: %v", err)
+	}
+	loss := 0.0
+	if err := db.SaveResult(&model.Result{
+		ResultID:    resultID,
+		ExecutionID: resultID,
+		TaskID:      "task-series",
+		ProbeID:     probeID,
+		Target:      "1.1.1.1",
+		TestType:    "icmp_ping",
+		Status:      "success",
+		ResultData:  "{}",
+		Summary:     fmt.Sprintf(`{"avg_latency":%v,"packet_loss_percent":0}`, latency),
+		AvgLatency:  &latency,
+		PacketLoss:  &loss,
+	}); err != nil {
+		t.Fatalf("SaveResult failed: %v", err)
+	}
+}
+
+func getSeries(t *testing.T, handler *SeriesHandler, query url.Values) (int, []series.Series) {
+	t.Helper()
+
+	recorder := serveTaskHandler(t, handler.GetSeries, http.MethodGet, "/api/series?"+query.Encode(), nil, "")
+	var body struct {
+		Series []series.Series `json:"series"`
+	}
+	if recorder.Code == http.StatusOK {
+		if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+	}
+	return recorder.Code, body.Series
+}
+
+func TestGetSeriesAggregatesNumericColumns(t *testing.T) {
+	db := newTaskHandlerTestDB(t)
+	seedTaskHandlerProbe(t, db, "probe-a", `["icmp_ping"]`, "online")
+	seedTaskHandlerProbe(t, db, "probe-b", `["icmp_ping"]`, "online")
+	if err := db.CreateTask(&model.Task{TaskID: "task-series", TaskType: "icmp_ping", Mode: "continuous", Target: "1.1.1.1", Status: "running", Priority: 5}); err != nil {
+		t.Fatalf("CreateTask failed: %v", err)
+	}
+	for i, latency := range []float64{40, 10, 30, 20} {
+		seedSeriesResult(t, db, "probe-a", fmt.Sprintf("a-%d", i), latency)
+	}
+	seedSeriesResult(t, db, "probe-b", "b-0", 100)
+
+	handler := NewSeriesHandler(db)
+	now := time.Now()
+	base := url.Values{
+		"from": {strconv.FormatInt(now.Add(-time.Hour).Unix(), 10)},
+		"to":   {strconv.FormatInt(now.Add(time.Hour).Unix(), 10)},
+		// 足够大的 step 让所有样本落在同一个桶
+		"step": {"86400000"},
+	}
+
+	cases := []struct {
+		aggregation string
+		want        float64
+	}{
+		{"avg", 25},
+		{"min", 10},
+		{"max", 40},
+		{"p50", 20},
+		{"p95", 40},
+	}
+	for _, tc := range cases {
+		query := url.Values{"probe_ids": {"probe-a"}, "aggregation": {tc.aggregation}}
+		for k, v := range base {
+			query[k] = v
+		}
+		code, result := getSeries(t, handler, query)
+		if code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d", tc.aggregation, code)
+		}
+		if len(result) != 1 || result[0].ProbeID != "probe-a" || len(result[0].Points) != 1 {
+			t.Fatalf("%s: expected one series with one point, got %+v", tc.aggregation, result)
+		}
+		if point := result[0].Points[0]; point.Value != tc.want || point.Count != 4 {
+			t.Fatalf("%s: expected %v over 4 samples, got %+v", tc.aggregation, tc.want, point)
+		}
+	}
+
+	// 不过滤探针时按探针分别返回
+	code, result := getSeries(t, handler, base)
+	if code != http.StatusOK || len(result) != 2 || result[1].ProbeID != "probe-b" {
+		t.Fatalf("expected a series per probe, got %d %+v", code, result)
+	}
+
+	// 没有该指标的结果时返回空序列
+	query := url.Values{"metric": {"http_status"}}
+	for k, v := range base {
+		query[k] = v
+	}
+	if code, result := getSeries(t, handler, query); code != http.StatusOK || len(result) != 0 {
+		t.Fatalf("expected empty http_status series, got %d %+v", code, result)
+	}
+
+	for _, bad := range []url.Values{
+		{"metric": {"jitter"}},
+		{"aggregation": {"p90"}},
+		{"step": {"1.5s"}},
+		{"from": {strconv.FormatInt(now.Add(-365*24*time.Hour).Unix(), 10)}, "step": {"1"}},
+	} {
+		if code, _ := getSeries(t, handler, bad); code != http.StatusBadRequest {
+			t.Fatalf("expected status 400 for %v, got %d", bad, code)
+		}
+	}
+}
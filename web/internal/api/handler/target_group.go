@@ -0,0 +1,177 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"atlas/web/internal/database"
+	"atlas/web/internal/model"
+	"atlas/web/internal/targets"
+	"atlas/web/internal/targetutil"
+)
+
+// TargetGroupHandler 目标组处理器
+type TargetGroupHandler struct {
+	db *database.Database
+}
+
+// NewTargetGroupHandler 创建目标组处理器
+func NewTargetGroupHandler(db *database.Database) *TargetGroupHandler {
+	return &TargetGroupHandler{db: db}
+}
+
+type targetGroupRequest struct {
+	Name        string   `json:"name" binding:"required"`
+	Description string   `json:"description"`
+	Targets     []string `json:"targets" binding:"required"`
+}
+
+// ListTargetGroups 列出目标组
+// GET /api/target-groups
+func (h *TargetGroupHandler) ListTargetGroups(c *gin.Context) {
+	groups, err := h.db.ListTargetGroups()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list target groups"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"groups": groups})
+}
+
+// GetTargetGroup 获取目标组
+// GET /api/target-groups/:id
+func (h *TargetGroupHandler) GetTargetGroup(c *gin.Context) {
+	group, err := h.db.GetTargetGroup(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Target group not found"})
+		return
+	}
+	c.JSON(http.StatusOK, group)
+}
+
+// CreateTargetGroup 创建目标组
+// POST /api/target-groups
+func (h *TargetGroupHandler) CreateTargetGroup(c *gin.Context) {
+	var req targetGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	members, err := h.validateMembers(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	group := &model.TargetGroup{
+		GroupID:     uuid.New().String(),
+		Name:        strings.TrimSpace(req.Name),
+		Description: strings.TrimSpace(req.Description),
+		Targets:     members,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := h.db.CreateTargetGroup(group); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create target group"})
+		return
+	}
+	c.JSON(http.StatusCreated, group)
+}
+
+// UpdateTargetGroup 更新目标组；引用该组的任务在下次运行时使用新成员
+// PUT /api/target-groups/:id
+func (h *TargetGroupHandler) UpdateTargetGroup(c *gin.Context) {
+	group, err := h.db.GetTargetGroup(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Target group not found"})
+		return
+	}
+
+	var req targetGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	members, err := h.validateMembers(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	group.Name = strings.TrimSpace(req.Name)
+	group.Description = strings.TrimSpace(req.Description)
+	group.Targets = members
+	group.UpdatedAt = time.Now()
+	if err := h.db.UpdateTargetGroup(group); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update target group"})
+		return
+	}
+	c.JSON(http.StatusOK, group)
+}
+
+// DeleteTargetGroup 删除目标组；仍被未结束任务引用时拒绝
+// DELETE /api/target-groups/:id
+func (h *TargetGroupHandler) DeleteTargetGroup(c *gin.Context) {
+	groupID := c.Param("id")
+	inUse, err := h.db.CountActiveTasksUsingTargetGroup(groupID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check target group usage"})
+		return
+	}
+	if inUse > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("target group is used by %d active task(s)", inUse)})
+		return
+	}
+
+	if err := h.db.DeleteTargetGroup(groupID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Target group not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete target group"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Target group deleted"})
+}
+
+// validateMembers 规范化成员并按 blocked_networks 校验
+func (h *TargetGroupHandler) validateMembers(req targetGroupRequest) ([]string, error) {
+	if strings.TrimSpace(req.Name) == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	members := targets.Normalize(req.Targets)
+	if len(members) == 0 {
+		return nil, fmt.Errorf("targets must not be empty")
+	}
+	if len(members) > targets.MaxTargets {
+		return nil, fmt.Errorf("target group supports at most %d targets", targets.MaxTargets)
+	}
+	if blocked := blockedTargets(h.db, members, ""); len(blocked) > 0 {
+		return nil, fmt.Errorf("targets are blocked: %s", strings.Join(blocked, ", "))
+	}
+	return members, nil
+}
+
+// blockedTargets 返回落在 blocked_networks 内的目标
+func blockedTargets(db *database.Database, hosts []string, ipVersion string) []string {
+	raw, _ := db.GetConfig("blocked_networks")
+	nets, err := targetutil.ParseBlockedNetworks(targetutil.NormalizeBlockedNetworks(raw))
+	if err != nil || len(nets) == 0 {
+		return nil
+	}
+
+	blocked := make([]string, 0)
+	for _, host := range hosts {
+		if targetutil.BlockedByPolicy(host, nets, ipVersion) {
+			blocked = append(blocked, host)
+		}
+	}
+	return blocked
+}
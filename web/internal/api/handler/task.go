@@ -1,8 +1,9 @@
 package handler
 
 import (
-	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
@@ -15,8 +16,21 @@ import (
 	"atlas/shared/protocol"
 	"atlas/web/internal/database"
 	"atlas/web/internal/model"
+	"atlas/web/internal/retry"
+	"atlas/web/internal/sampling"
+	"atlas/web/internal/schedule"
+	"atlas/web/internal/selector"
+	"atlas/web/internal/targets"
 	"atlas/web/internal/targetutil"
 	"atlas/web/internal/websocket"
+	"atlas/web/internal/workflow"
+)
+
+// 任务优先级 1-10，越大越先调度
+const (
+	maxTaskPriority               = 10
+	defaultTaskPriority           = 5
+	defaultContinuousTaskPriority = 3
 )
 
 // TaskHandler 任务处理器
@@ -30,73 +44,6 @@ func NewTaskHandler(db *database.Database, hub *websocket.Hub) *TaskHandler {
 	return &TaskHandler{db: db, hub: hub}
 }
 
-func blockedByPolicy(target string, blocked []*net.IPNet, ipVersion string) bool {
-	host := targetutil.ExtractHost(target)
-	if host == "" {
-		return false
-	}
-
-	if ip := net.ParseIP(targetutil.StripIPv6Zone(host)); ip != nil {
-		return ipInBlockedNetworks(ip, blocked)
-	}
-
-	ips, err := resolveHostIPsForVersion(host, ipVersion)
-	if err != nil {
-		return false
-	}
-	for _, ip := range ips {
-		if ipInBlockedNetworks(ip, blocked) {
-			return true
-		}
-	}
-	return false
-}
-
-func resolveHostIPsForVersion(host, ipVersion string) ([]net.IP, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
-	switch ipVersion {
-	case "ipv4":
-		ips, err := net.DefaultResolver.LookupIP(ctx, "ip4", host)
-		if err != nil {
-			return nil, err
-		}
-		return ips, nil
-	case "ipv6":
-		ips, err := net.DefaultResolver.LookupIP(ctx, "ip6", host)
-		if err != nil {
-			return nil, err
-		}
-		return ips, nil
-	default: // auto: prefer ipv4
-		ips4, err4 := net.DefaultResolver.LookupIP(ctx, "ip4", host)
-		if err4 == nil && len(ips4) > 0 {
-			return ips4, nil
-		}
-		ips6, err6 := net.DefaultResolver.LookupIP(ctx, "ip6", host)
-		if err6 != nil {
-			if err4 != nil {
-				return nil, err4
-			}
-			return nil, err6
-		}
-		return ips6, nil
-	}
-}
-
-func ipInBlockedNetworks(ip net.IP, blocked []*net.IPNet) bool {
-	if ip == nil {
-		return false
-	}
-	for _, n := range blocked {
-		if n != nil && n.Contains(ip) {
-			return true
-		}
-	}
-	return false
-}
-
 func normalizeProbeIDs(ids []string) []string {
 	seen := make(map[string]struct{}, len(ids))
 	out := make([]string, 0, len(ids))
@@ -207,24 +154,104 @@ func (h *TaskHandler) resolveTracerouteProbeIDs(requested []string) ([]string, e
 	return h.resolveRouteProbeIDs("traceroute", requested)
 }
 
+func validateTCPPingTarget(target string) error {
+	host, portStr, err := net.SplitHostPort(strings.TrimSpace(target))
+	if err != nil || host == "" || portStr == "" {
+		return fmt.Errorf("tcp_ping target must be host:port or [ipv6]:port")
+	}
+	var port int
+	if _, err := fmt.Sscanf(portStr, "%d", &port); err != nil || port < 1 || port > 65535 {
+		return fmt.Errorf("invalid tcp port")
+	}
+	return nil
+}
+
+// validateTargets 校验多目标定义，并按任务类型与 blocked_networks 检查每个展开后的目标
+func (h *TaskHandler) validateTargets(taskType string, spec *targets.Spec, ipVersion string) error {
+	if taskType == "http_test" {
+		for i, host := range spec.Hosts {
+			spec.Hosts[i] = targetutil.NormalizeHTTPURL(host)
+		}
+	}
+	if err := spec.Validate(); err != nil {
+		return err
+	}
+
+	expanded, err := spec.Expand(h.db.TargetGroupMembers)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("target group %s not found", spec.Group)
+		}
+		return err
+	}
+
+	for _, target := range expanded {
+		if taskType == "tcp_ping" {
+			if err := validateTCPPingTarget(target); err != nil {
+				return fmt.Errorf("%s: %w", target, err)
+			}
+		}
+	}
+
+	if blocked := blockedTargets(h.db, expanded, ipVersion); len(blocked) > 0 {
+		return fmt.Errorf("targets are blocked: %s", strings.Join(blocked, ", "))
+	}
+	return nil
+}
+
+// createTaskRequest 创建任务请求，也是任务模板 definition 的结构
+type createTaskRequest struct {
+	TaskType       string                 `json:"task_type"`
+	Mode           string                 `json:"mode"`   // single/continuous
+	Target         string                 `json:"target"` // 单目标；与 targets 二选一
+	Parameters     map[string]interface{} `json:"parameters"`
+	AssignedProbes []string               `json:"assigned_probes"`
+	Priority       int                    `json:"priority"`
+	IPVersion      string                 `json:"ip_version"`   // auto/ipv4/ipv6
+	Schedule       *schedule.Spec         `json:"schedule"`     // 周期调度，仅 continuous 模式
+	RetryPolicy    *retry.Policy          `json:"retry_policy"` // 失败重试策略，仅 single 模式
+	Selector       string                 `json:"selector"`     // 探针标签选择表达式，与 assigned_probes 互斥
+	Sampling       *sampling.Strategy     `json:"sampling"`     // 探针抽样策略：all/random/per_region/per_country/per_asn/nearest
+	Targets        *targets.Spec          `json:"targets"`      // 多目标：hosts 列表 / cidr / 目标组
+	Workflow       *workflow.Spec         `json:"workflow"`     // 结果满足条件时自动创建的后续步骤
+
+	// keepProbes 编辑时未修改探针相关字段：沿用已保存的探针列表，不再校验探针是否在线
+	keepProbes bool
+}
+
 // CreateTask 创建任务
 // POST /api/tasks
 func (h *TaskHandler) CreateTask(c *gin.Context) {
-	var req struct {
-		TaskType       string                 `json:"task_type" binding:"required"`
-		Mode           string                 `json:"mode" binding:"required"` // single/continuous
-		Target         string                 `json:"target" binding:"required"`
-		Parameters     map[string]interface{} `json:"parameters"`
-		AssignedProbes []string               `json:"assigned_probes"`
-		Priority       int                    `json:"priority"`
-		IPVersion      string                 `json:"ip_version"` // auto/ipv4/ipv6
+	var req createTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
+	task, err := h.buildTask(&req)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	if err := h.db.CreateTask(task); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create task"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, task)
+}
+
+// buildTask 校验请求并构建待保存的任务，返回的错误均为请求错误
+func (h *TaskHandler) buildTask(req *createTaskRequest) (*model.Task, error) {
+	req.TaskType = strings.TrimSpace(req.TaskType)
+	if req.TaskType == "" {
+		return nil, fmt.Errorf("task_type is required")
+	}
+	if req.Mode != "single" && req.Mode != "continuous" {
+		return nil, fmt.Errorf("mode must be single or continuous")
+	}
+
 	ipVersion := req.IPVersion
 	if ipVersion == "" && req.Parameters != nil {
 		if v, ok := req.Parameters["ip_version"].(string); ok {
@@ -240,57 +267,134 @@ func (h *TaskHandler) CreateTask(c *gin.Context) {
 		}
 	}
 
-	if req.TaskType == "http_test" {
-		req.Target = targetutil.NormalizeHTTPURL(req.Target)
+	if req.Priority < 0 || req.Priority > maxTaskPriority {
+		return nil, fmt.Errorf("priority must be between 1 and 10")
 	}
 
-	blocked, _ := h.db.GetConfig("blocked_networks")
-	blocked = normalizeBlockedNetworks(blocked)
-	if blocked != "" {
-		nets, err := parseBlockedNetworks(blocked)
-		if err == nil {
-			if blockedByPolicy(req.Target, nets, ipVersion) {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Target is blocked"})
-				return
-			}
+	req.Target = strings.TrimSpace(req.Target)
+	if req.Targets != nil {
+		if req.Target != "" {
+			return nil, fmt.Errorf("target and targets are mutually exclusive")
+		}
+		if err := h.validateTargets(req.TaskType, req.Targets, ipVersion); err != nil {
+			return nil, err
+		}
+		req.Target = req.Targets.Label()
+	} else {
+		if req.Target == "" {
+			return nil, fmt.Errorf("target or targets is required")
+		}
+
+		if req.TaskType == "http_test" {
+			req.Target = targetutil.NormalizeHTTPURL(req.Target)
+		}
+
+		if len(blockedTargets(h.db, []string{req.Target}, ipVersion)) > 0 {
+			return nil, fmt.Errorf("Target is blocked")
 		}
 	}
 
-	// http_test 仅允许 single
-	if req.TaskType == "http_test" && req.Mode != "single" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "http_test only supports single mode"})
-		return
+	if req.Schedule != nil {
+		if req.Mode != "continuous" {
+			return nil, fmt.Errorf("schedule requires continuous mode")
+		}
+		if req.Schedule.Type != schedule.TypeCron && req.Schedule.Type != schedule.TypeInterval {
+			return nil, fmt.Errorf("schedule.type must be cron or interval")
+		}
+		if err := req.Schedule.Validate(); err != nil {
+			return nil, err
+		}
+		req.Schedule.RunCount = 0
+		req.Schedule.LastRunAt = nil
+	}
+
+	if req.RetryPolicy != nil {
+		if req.Mode != "single" {
+			return nil, fmt.Errorf("retry_policy only supports single mode")
+		}
+		if err := req.RetryPolicy.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	// 未指定 schedule 的 continuous 仅支持 ping/tcp_ping（1s 间隔、最多 N 次）
+	if req.Mode == "continuous" && req.Schedule == nil {
+		if req.TaskType != "icmp_ping" && req.TaskType != "tcp_ping" {
+			return nil, fmt.Errorf("continuous %s requires a schedule", req.TaskType)
+		}
+	}
+
+	req.Selector = strings.TrimSpace(req.Selector)
+	if req.Selector != "" {
+		if len(normalizeProbeIDs(req.AssignedProbes)) > 0 {
+			return nil, fmt.Errorf("selector and assigned_probes are mutually exclusive")
+		}
+		if _, err := selector.Parse(req.Selector); err != nil {
+			return nil, fmt.Errorf("invalid selector: %w", err)
+		}
+	}
+
+	if req.Sampling != nil {
+		if err := req.Sampling.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	if req.Workflow != nil {
+		if err := req.Workflow.Validate(req.TaskType); err != nil {
+			return nil, err
+		}
+		for _, step := range req.Workflow.Steps {
+			if step.Target == "" {
+				continue
+			}
+			if step.TaskType == "tcp_ping" {
+				if err := validateTCPPingTarget(step.Target); err != nil {
+					return nil, fmt.Errorf("workflow step %s: %w", step.Name, err)
+				}
+			}
+			if len(blockedTargets(h.db, []string{step.Target}, ipVersion)) > 0 {
+				return nil, fmt.Errorf("workflow step %s: target is blocked", step.Name)
+			}
+		}
 	}
 
-	if req.TaskType == "traceroute" {
+	// 使用选择表达式、抽样策略或周期调度的路由任务：探针在每次分发时解析
+	dynamicRoute := (req.Schedule != nil || req.Selector != "" || req.Sampling != nil) && (req.TaskType == "traceroute" || req.TaskType == "mtr")
+	switch {
+	case req.keepProbes:
+		// 探针列表创建时已校验，编辑其它字段时不因探针短暂离线而失败
+	case dynamicRoute:
+		// 未指定探针时每次运行都选取当时在线且满足表达式的兼容探针
+		if len(normalizeProbeIDs(req.AssignedProbes)) > 0 {
+			resolvedProbeIDs, err := h.resolveRouteProbeIDs(req.TaskType, req.AssignedProbes)
+			if err != nil {
+				return nil, err
+			}
+			req.AssignedProbes = resolvedProbeIDs
+		}
+	case req.TaskType == "traceroute":
 		resolvedProbeIDs, err := h.resolveTracerouteProbeIDs(req.AssignedProbes)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
+			return nil, err
 		}
 		req.AssignedProbes = resolvedProbeIDs
 	}
 
-	if req.TaskType == "mtr" {
+	if req.TaskType == "mtr" && !dynamicRoute && !req.keepProbes {
 		resolvedProbeIDs, err := h.resolveRouteProbeIDs(req.TaskType, req.AssignedProbes)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
+			return nil, err
 		}
 		req.AssignedProbes = resolvedProbeIDs
 	}
 
-	// tcp_ping: 强制要求 host:port 或 [ipv6]:port
+	// tcp_ping: 强制要求 host:port 或 [ipv6]:port（多目标在 validateTargets 中逐个校验）
 	if req.TaskType == "tcp_ping" {
-		host, portStr, err := net.SplitHostPort(strings.TrimSpace(req.Target))
-		if err != nil || host == "" || portStr == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "tcp_ping target must be host:port or [ipv6]:port"})
-			return
-		}
-		var port int
-		if _, err := fmt.Sscanf(portStr, "%d", &port); err != nil || port < 1 || port > 65535 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tcp port"})
-			return
+		if req.Targets == nil {
+			if err := validateTCPPingTarget(req.Target); err != nil {
+				return nil, err
+			}
 		}
 		// probe 侧不再使用 parameters.port，避免前后端不一致
 		if req.Parameters != nil {
@@ -298,14 +402,6 @@ func (h *TaskHandler) CreateTask(c *gin.Context) {
 		}
 	}
 
-	// continuous 仅支持 ping/tcp_ping
-	if req.Mode == "continuous" {
-		if req.TaskType != "icmp_ping" && req.TaskType != "tcp_ping" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "continuous mode only supports icmp_ping and tcp_ping"})
-			return
-		}
-	}
-
 	// 构建任务
 	parametersJSON, _ := json.Marshal(req.Parameters)
 	assignedProbesJSON, _ := json.Marshal(req.AssignedProbes)
@@ -319,19 +415,161 @@ func (h *TaskHandler) CreateTask(c *gin.Context) {
 		AssignedProbes: string(assignedProbesJSON),
 		Status:         "pending",
 		Priority:       req.Priority,
+		Selector:       req.Selector,
+	}
+
+	if req.RetryPolicy != nil {
+		task.RetryPolicy = req.RetryPolicy.String()
+	}
+	if req.Sampling != nil {
+		task.Sampling = req.Sampling.String()
+	}
+	if req.Targets != nil {
+		task.Targets = req.Targets.String()
+	}
+	if req.Workflow != nil {
+		task.Workflow = req.Workflow.String()
 	}
 
 	if req.Priority == 0 {
-		task.Priority = 5 // 默认优先级
+		task.Priority = defaultTaskPriority
+		// 持续任务默认较低优先级，探针满载时让交互式单次任务先执行
+		if req.Mode == "continuous" {
+			task.Priority = defaultContinuousTaskPriority
+		}
 	}
 
-	// 如果是持续任务，先把 next_run_at 设为现在（调度器会根据策略更新）
-	if req.Mode == "continuous" {
+	// 持续任务：按 schedule 计算首次运行时间；旧版 ping 立即开始（调度器会根据策略更新）
+	if req.Schedule != nil {
+		nextRun, ok := req.Schedule.First(time.Now())
+		if !ok {
+			return nil, fmt.Errorf("schedule has no upcoming runs")
+		}
+		task.Schedule = req.Schedule.String()
+		task.NextRunAt = &nextRun
+	} else if req.Mode == "continuous" {
 		nextRun := time.Now()
 		task.NextRunAt = &nextRun
 	}
 
-	// (校验已提前在构建 task 前完成)
+	return task, nil
+}
+
+// requestFromTask 由已有任务还原创建请求，用于重跑/克隆
+func requestFromTask(task *model.Task) (*createTaskRequest, error) {
+	req := &createTaskRequest{
+		TaskType: task.TaskType,
+		Mode:     task.Mode,
+		Priority: task.Priority,
+		Selector: task.Selector,
+	}
+
+	if strings.TrimSpace(task.Parameters) != "" {
+		if err := json.Unmarshal([]byte(task.Parameters), &req.Parameters); err != nil {
+			return nil, fmt.Errorf("invalid task parameters: %w", err)
+		}
+	}
+	if strings.TrimSpace(task.AssignedProbes) != "" {
+		if err := json.Unmarshal([]byte(task.AssignedProbes), &req.AssignedProbes); err != nil {
+			return nil, fmt.Errorf("invalid assigned probes: %w", err)
+		}
+	}
+
+	if task.Targets != "" {
+		spec, err := targets.Parse(task.Targets)
+		if err != nil {
+			return nil, err
+		}
+		req.Targets = spec
+	} else {
+		req.Target = task.Target
+	}
+
+	// 旧版持续 ping 的 schedule 由调度器维护，重跑时重新开始计数
+	if task.Mode == "continuous" && task.Schedule != "" {
+		spec, err := schedule.Parse(task.Schedule)
+		if err != nil {
+			return nil, err
+		}
+		if !spec.IsLegacy() {
+			req.Schedule = spec
+		}
+	}
+	if task.RetryPolicy != "" {
+		policy, err := retry.Parse(task.RetryPolicy)
+		if err != nil {
+			return nil, err
+		}
+		req.RetryPolicy = policy
+	}
+	if task.Sampling != "" {
+		strategy, err := sampling.Parse(task.Sampling)
+		if err != nil {
+			return nil, err
+		}
+		req.Sampling = strategy
+	}
+	if task.Workflow != "" {
+		spec, err := workflow.Parse(task.Workflow)
+		if err != nil {
+			return nil, err
+		}
+		req.Workflow = spec
+	}
+
+	return req, nil
+}
+
+// RerunTask 以相同的类型、目标、参数与探针选择创建新任务
+// POST /api/tasks/:id/rerun
+//
+// 请求体可选：{"use_resolved_probes": true} 固定使用原任务实际选中的探针，
+// 而不是重新按选择表达式/抽样策略挑选
+func (h *TaskHandler) RerunTask(c *gin.Context) {
+	var body struct {
+		UseResolvedProbes bool `json:"use_resolved_probes"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	source, err := h.db.GetTask(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+
+	req, err := requestFromTask(source)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load task definition"})
+		return
+	}
+
+	if body.UseResolvedProbes {
+		var resolved []string
+		if source.ResolvedProbes != "" {
+			_ = json.Unmarshal([]byte(source.ResolvedProbes), &resolved)
+		}
+		if len(resolved) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "task has no resolved probes"})
+			return
+		}
+		req.AssignedProbes = resolved
+		req.Selector = ""
+		req.Sampling = nil
+	}
+
+	task, err := h.buildTask(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	task.RerunOf = &source.TaskID
+	task.TemplateID = source.TemplateID
+	task.TemplateVersion = source.TemplateVersion
 
 	if err := h.db.CreateTask(task); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create task"})
@@ -341,6 +579,276 @@ func (h *TaskHandler) CreateTask(c *gin.Context) {
 	c.JSON(http.StatusCreated, task)
 }
 
+// PATCH /api/tasks/:id 允许修改的字段
+var editableTaskFields = map[string]bool{
+	"parameters":      true,
+	"schedule":        true,
+	"assigned_probes": true,
+	"selector":        true,
+	"sampling":        true,
+	"priority":        true,
+	"workflow":        true,
+}
+
+// isTaskEditable 等待调度、已暂停或仍在周期运行的任务可以编辑
+func isTaskEditable(task *model.Task) bool {
+	switch task.Status {
+	case "pending", "paused":
+		return true
+	case "running":
+		return task.Mode == "continuous"
+	}
+	return false
+}
+
+// editableSnapshot 任务可编辑字段的快照，用于计算修订差异；不含调度运行状态
+func editableSnapshot(task *model.Task) map[string]interface{} {
+	snapshot := map[string]interface{}{
+		"priority": task.Priority,
+		"selector": task.Selector,
+	}
+	decode := func(key, raw string) {
+		var value interface{}
+		if strings.TrimSpace(raw) != "" && json.Unmarshal([]byte(raw), &value) == nil {
+			snapshot[key] = value
+		}
+	}
+	decode("parameters", task.Parameters)
+	decode("assigned_probes", task.AssignedProbes)
+	decode("sampling", task.Sampling)
+	decode("schedule", task.Schedule)
+	decode("workflow", task.Workflow)
+	if spec, ok := snapshot["schedule"].(map[string]interface{}); ok {
+		delete(spec, "run_count")
+		delete(spec, "last_run_at")
+	}
+	return snapshot
+}
+
+// UpdateTask 修改任务的参数、调度、探针选择与优先级，每次修改记录一个修订
+// PATCH /api/tasks/:id
+//
+// parameters 按键合并（值为 null 删除该参数），其余字段整体替换；
+// 修改 schedule 时保留已运行次数，下次运行时间从当前时刻重新计算
+func (h *TaskHandler) UpdateTask(c *gin.Context) {
+	var patch map[string]interface{}
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(patch) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no fields to update"})
+		return
+	}
+	for key, value := range patch {
+		if !editableTaskFields[key] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("field %s cannot be edited", key)})
+			return
+		}
+		if key == "schedule" && value == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "schedule cannot be removed"})
+			return
+		}
+	}
+
+	task, err := h.db.GetTask(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+	if !isTaskEditable(task) {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("task is %s and can no longer be edited", task.Status)})
+		return
+	}
+
+	base, err := requestFromTask(task)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load task definition"})
+		return
+	}
+	baseJSON, _ := json.Marshal(base)
+	req, err := decodeDefinition(mergeDefinition(parseDefinition(string(baseJSON)), patch))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	_, probes := patch["assigned_probes"]
+	_, sel := patch["selector"]
+	_, samp := patch["sampling"]
+	req.keepProbes = !probes && !sel && !samp
+	built, err := h.buildTask(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 只应用请求中出现的字段，其余保持原样（例如 mtr 任务创建时解析出的探针列表）
+	updated := *task
+	if _, ok := patch["parameters"]; ok {
+		updated.Parameters = built.Parameters
+	}
+	if _, ok := patch["priority"]; ok {
+		updated.Priority = built.Priority
+	}
+	if _, ok := patch["workflow"]; ok {
+		updated.Workflow = built.Workflow
+	}
+	if !req.keepProbes {
+		updated.AssignedProbes = built.AssignedProbes
+		updated.Selector = built.Selector
+		updated.Sampling = built.Sampling
+	}
+	if _, ok := patch["schedule"]; ok {
+		spec := req.Schedule
+		if previous, err := schedule.Parse(task.Schedule); err == nil && task.Schedule != "" {
+			spec.RunCount = previous.RunCount
+			spec.LastRunAt = previous.LastRunAt
+		}
+		nextRun, ok := spec.Next(time.Now())
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "schedule has no upcoming runs"})
+			return
+		}
+		updated.Schedule = spec.String()
+		updated.NextRunAt = &nextRun
+	}
+
+	previous := editableSnapshot(task)
+	current := editableSnapshot(&updated)
+	changed := diffDefinitions(previous, current)
+	if len(changed) == 0 {
+		c.JSON(http.StatusOK, task)
+		return
+	}
+
+	previousJSON, _ := json.Marshal(previous)
+	currentJSON, _ := json.Marshal(current)
+	updated.Revision = task.Revision + 1
+	revision := &model.TaskRevision{
+		TaskID:        task.TaskID,
+		Revision:      updated.Revision,
+		ChangedFields: changed,
+		Previous:      string(previousJSON),
+		Current:       string(currentJSON),
+		CreatedAt:     time.Now(),
+	}
+
+	if err := h.db.UpdateTaskDefinition(&updated, revision); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Task was modified concurrently"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update task"})
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// ListTaskRevisions 列出任务的编辑记录
+// GET /api/tasks/:id/revisions
+func (h *TaskHandler) ListTaskRevisions(c *gin.Context) {
+	taskID := c.Param("id")
+	if _, err := h.db.GetTask(taskID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+
+	revisions, err := h.db.ListTaskRevisions(taskID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list task revisions"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"revisions": revisions})
+}
+
+// ListTaskRuns 列出抽样任务每轮实际选中的探针集合
+// GET /api/tasks/:id/runs
+func (h *TaskHandler) ListTaskRuns(c *gin.Context) {
+	taskID := c.Param("id")
+	if _, err := h.db.GetTask(taskID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+
+	runs, err := h.db.ListTaskRuns(taskID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list task runs"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"runs": runs})
+}
+
+// PauseTask 暂停任务调度；已下发的执行继续完成，排队与等待重试的执行保留到恢复
+// POST /api/tasks/:id/pause
+func (h *TaskHandler) PauseTask(c *gin.Context) {
+	taskID := c.Param("id")
+	task, err := h.db.GetTask(taskID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+
+	if err := h.db.PauseTask(taskID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("task is %s and cannot be paused", task.Status)})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to pause task"})
+		return
+	}
+
+	task.Status = "paused"
+	c.JSON(http.StatusOK, task)
+}
+
+// ResumeTask 恢复已暂停的任务；schedule 中的运行次数保持不变，持续任务从当前时刻重新计算下次运行时间
+// POST /api/tasks/:id/resume
+func (h *TaskHandler) ResumeTask(c *gin.Context) {
+	task, err := h.db.GetTask(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+	if task.Status != "paused" {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("task is %s, not paused", task.Status)})
+		return
+	}
+
+	now := time.Now()
+	task.Status = "pending"
+	if task.StartedAt != nil {
+		task.Status = "running"
+	}
+	if task.Mode == "continuous" {
+		spec, err := schedule.Parse(task.Schedule)
+		switch {
+		case err != nil || spec.IsLegacy():
+			// 旧版持续 ping 由调度器在下次运行时继续计数
+			task.NextRunAt = &now
+		default:
+			if nextRun, ok := spec.Next(now); ok {
+				task.NextRunAt = &nextRun
+			} else {
+				task.Status = "completed"
+				task.NextRunAt = nil
+				task.CompletedAt = &now
+			}
+		}
+	}
+
+	if err := h.db.ResumeTask(task); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusConflict, gin.H{"error": "task is not paused"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resume task"})
+		return
+	}
+
+	c.JSON(http.StatusOK, task)
+}
+
 // ListTasks 列出任务
 // GET /api/tasks
 func (h *TaskHandler) ListTasks(c *gin.Context) {
@@ -367,10 +875,15 @@ func (h *TaskHandler) ListTasks(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list tasks"})
 		return
 	}
+	total, err := h.db.CountTasks(status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count tasks"})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"tasks": tasks,
-		"total": len(tasks),
+		"total": total,
 	})
 }
 
@@ -391,13 +904,46 @@ func (h *TaskHandler) GetTask(c *gin.Context) {
 	// 获取结果
 	results, _ := h.db.ListResultsByTask(taskID, 100, 0)
 
+	// 工作流触发的后续任务，按 parent_execution_id 关联到执行
+	followUps, _ := h.db.ListFollowUpTasks(taskID)
+
 	c.JSON(http.StatusOK, gin.H{
-		"task":       task,
-		"executions": executions,
-		"results":    results,
+		"task":           task,
+		"executions":     executions,
+		"attempt_chains": buildAttemptChains(executions),
+		"results":        results,
+		"follow_ups":     followUps,
 	})
 }
 
+// buildAttemptChains 按 retry_of 将执行记录串成尝试链，每条链从首次执行开始按尝试顺序排列
+func buildAttemptChains(executions []*model.TaskExecution) [][]*model.TaskExecution {
+	next := make(map[string]*model.TaskExecution, len(executions))
+	roots := make([]*model.TaskExecution, 0, len(executions))
+	for _, execution := range executions {
+		if execution.RetryOf != nil {
+			next[*execution.RetryOf] = execution
+		} else {
+			roots = append(roots, execution)
+		}
+	}
+
+	chains := make([][]*model.TaskExecution, 0, len(roots))
+	for _, root := range roots {
+		chain := []*model.TaskExecution{root}
+		for current := root; ; {
+			child, ok := next[current.ExecutionID]
+			if !ok {
+				break
+			}
+			chain = append(chain, child)
+			current = child
+		}
+		chains = append(chains, chain)
+	}
+	return chains
+}
+
 // CancelTask 取消任务
 // DELETE /api/tasks/:id
 func (h *TaskHandler) CancelTask(c *gin.Context) {
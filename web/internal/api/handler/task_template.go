@@ -0,0 +1,416 @@
+package handler
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"atlas/web/internal/database"
+	"atlas/web/internal/model"
+)
+
+// TaskTemplateHandler 任务模板处理器
+type TaskTemplateHandler struct {
+	db    *database.Database
+	tasks *TaskHandler
+}
+
+// NewTaskTemplateHandler 创建任务模板处理器；启动模板时复用任务处理器的校验逻辑
+func NewTaskTemplateHandler(db *database.Database, tasks *TaskHandler) *TaskTemplateHandler {
+	return &TaskTemplateHandler{db: db, tasks: tasks}
+}
+
+type taskTemplateRequest struct {
+	Name        string                 `json:"name" binding:"required"`
+	Description string                 `json:"description"`
+	Definition  map[string]interface{} `json:"definition" binding:"required"` // 结构同 POST /api/tasks 请求体
+}
+
+// 覆盖其中一个字段时需要清除的互斥字段
+var exclusiveDefinitionFields = map[string]string{
+	"target":          "targets",
+	"targets":         "target",
+	"assigned_probes": "selector",
+	"selector":        "assigned_probes",
+}
+
+// decodeDefinition 将模板定义解析为创建任务请求，拒绝未知字段
+func decodeDefinition(definition map[string]interface{}) (*createTaskRequest, error) {
+	data, err := json.Marshal(definition)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var req createTaskRequest
+	if err := decoder.Decode(&req); err != nil {
+		return nil, fmt.Errorf("invalid definition: %w", err)
+	}
+	return &req, nil
+}
+
+// validateDefinition 校验模板定义中与探针在线状态无关的部分
+func validateDefinition(definition map[string]interface{}) error {
+	req, err := decodeDefinition(definition)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(req.TaskType) == "" {
+		return fmt.Errorf("definition.task_type is required")
+	}
+	if req.Mode != "single" && req.Mode != "continuous" {
+		return fmt.Errorf("definition.mode must be single or continuous")
+	}
+	if req.Schedule != nil {
+		if err := req.Schedule.Validate(); err != nil {
+			return err
+		}
+	}
+	if req.RetryPolicy != nil {
+		if err := req.RetryPolicy.Validate(); err != nil {
+			return err
+		}
+	}
+	if req.Sampling != nil {
+		if err := req.Sampling.Validate(); err != nil {
+			return err
+		}
+	}
+	if req.Targets != nil {
+		if err := req.Targets.Validate(); err != nil {
+			return err
+		}
+	}
+	if req.Workflow != nil {
+		if err := req.Workflow.Validate(req.TaskType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeDefinition 将 overrides 合并到模板定义：顶层字段整体替换，parameters 按键合并，
+// 值为 null 表示删除该字段
+func mergeDefinition(base, overrides map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(base)+len(overrides))
+	for key, value := range base {
+		merged[key] = value
+	}
+
+	for key, value := range overrides {
+		if value == nil {
+			delete(merged, key)
+			continue
+		}
+		if other, ok := exclusiveDefinitionFields[key]; ok {
+			delete(merged, other)
+		}
+
+		if key == "parameters" {
+			params := map[string]interface{}{}
+			if existing, ok := merged[key].(map[string]interface{}); ok {
+				for k, v := range existing {
+					params[k] = v
+				}
+			}
+			if patch, ok := value.(map[string]interface{}); ok {
+				for k, v := range patch {
+					if v == nil {
+						delete(params, k)
+					} else {
+						params[k] = v
+					}
+				}
+				merged[key] = params
+				continue
+			}
+		}
+		merged[key] = value
+	}
+	return merged
+}
+
+// diffDefinitions 返回两个版本之间发生变化的顶层字段（parameters 细化到 parameters.<key>）
+func diffDefinitions(prev, next map[string]interface{}) []string {
+	keys := map[string]struct{}{}
+	for key := range prev {
+		keys[key] = struct{}{}
+	}
+	for key := range next {
+		keys[key] = struct{}{}
+	}
+
+	changed := make([]string, 0)
+	for key := range keys {
+		if key == "parameters" {
+			prevParams, _ := prev[key].(map[string]interface{})
+			nextParams, _ := next[key].(map[string]interface{})
+			for _, sub := range diffDefinitions(prevParams, nextParams) {
+				changed = append(changed, key+"."+sub)
+			}
+			continue
+		}
+		if !jsonEqual(prev[key], next[key]) {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+func jsonEqual(a, b interface{}) bool {
+	aJSON, _ := json.Marshal(a)
+	bJSON, _ := json.Marshal(b)
+	return bytes.Equal(aJSON, bJSON)
+}
+
+func parseDefinition(raw string) map[string]interface{} {
+	definition := map[string]interface{}{}
+	_ = json.Unmarshal([]byte(raw), &definition)
+	return definition
+}
+
+// ListTaskTemplates 列出任务模板
+// GET /api/task-templates
+func (h *TaskTemplateHandler) ListTaskTemplates(c *gin.Context) {
+	templates, err := h.db.ListTaskTemplates()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list task templates"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"templates": templates})
+}
+
+// GetTaskTemplate 获取任务模板
+// GET /api/task-templates/:id
+func (h *TaskTemplateHandler) GetTaskTemplate(c *gin.Context) {
+	tpl, err := h.db.GetTaskTemplate(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task template not found"})
+		return
+	}
+	c.JSON(http.StatusOK, tpl)
+}
+
+// CreateTaskTemplate 创建任务模板
+// POST /api/task-templates
+func (h *TaskTemplateHandler) CreateTaskTemplate(c *gin.Context) {
+	var req taskTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+	if err := validateDefinition(req.Definition); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	definitionJSON, _ := json.Marshal(req.Definition)
+	now := time.Now()
+	tpl := &model.TaskTemplate{
+		TemplateID:  uuid.New().String(),
+		Name:        req.Name,
+		Description: strings.TrimSpace(req.Description),
+		Version:     1,
+		Definition:  string(definitionJSON),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	if err := h.db.CreateTaskTemplate(tpl, nil); err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			c.JSON(http.StatusConflict, gin.H{"error": "Task template name already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create task template"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, tpl)
+}
+
+// UpdateTaskTemplate 更新任务模板，每次更新生成一个新版本
+// PUT /api/task-templates/:id
+func (h *TaskTemplateHandler) UpdateTaskTemplate(c *gin.Context) {
+	tpl, err := h.db.GetTaskTemplate(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task template not found"})
+		return
+	}
+
+	var req taskTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	req.Description = strings.TrimSpace(req.Description)
+	if req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+	if err := validateDefinition(req.Definition); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	changed := diffDefinitions(parseDefinition(tpl.Definition), req.Definition)
+	for i := range changed {
+		changed[i] = "definition." + changed[i]
+	}
+	if req.Name != tpl.Name {
+		changed = append([]string{"name"}, changed...)
+	}
+	if req.Description != tpl.Description {
+		changed = append([]string{"description"}, changed...)
+	}
+	if len(changed) == 0 {
+		c.JSON(http.StatusOK, tpl)
+		return
+	}
+
+	definitionJSON, _ := json.Marshal(req.Definition)
+	tpl.Name = req.Name
+	tpl.Description = req.Description
+	tpl.Definition = string(definitionJSON)
+	tpl.Version++
+	tpl.UpdatedAt = time.Now()
+
+	if err := h.db.UpdateTaskTemplate(tpl, changed); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			c.JSON(http.StatusConflict, gin.H{"error": "Task template was modified concurrently"})
+		case strings.Contains(err.Error(), "UNIQUE"):
+			c.JSON(http.StatusConflict, gin.H{"error": "Task template name already exists"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update task template"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, tpl)
+}
+
+// DeleteTaskTemplate 删除任务模板；已启动的任务不受影响
+// DELETE /api/task-templates/:id
+func (h *TaskTemplateHandler) DeleteTaskTemplate(c *gin.Context) {
+	if err := h.db.DeleteTaskTemplate(c.Param("id")); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Task template not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete task template"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Task template deleted"})
+}
+
+// ListTaskTemplateVersions 列出模板的历史版本及每个版本变化的字段
+// GET /api/task-templates/:id/versions
+func (h *TaskTemplateHandler) ListTaskTemplateVersions(c *gin.Context) {
+	templateID := c.Param("id")
+	if _, err := h.db.GetTaskTemplate(templateID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task template not found"})
+		return
+	}
+
+	versions, err := h.db.ListTaskTemplateVersions(templateID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list task template versions"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"versions": versions})
+}
+
+// GetTaskTemplateVersion 获取模板的指定版本
+// GET /api/task-templates/:id/versions/:version
+func (h *TaskTemplateHandler) GetTaskTemplateVersion(c *gin.Context) {
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid version"})
+		return
+	}
+
+	v, err := h.db.GetTaskTemplateVersion(c.Param("id"), version)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task template version not found"})
+		return
+	}
+	c.JSON(http.StatusOK, v)
+}
+
+// StartTaskTemplate 按模板创建任务
+// POST /api/task-templates/:id/start
+//
+//	{"version": 2, "overrides": {"target": "cdn.example.com", "parameters": {"count": 10}}}
+//
+// version 缺省时使用当前版本；overrides 的合并规则见 mergeDefinition
+func (h *TaskTemplateHandler) StartTaskTemplate(c *gin.Context) {
+	var body struct {
+		Version   int                    `json:"version"`
+		Overrides map[string]interface{} `json:"overrides"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	tpl, err := h.db.GetTaskTemplate(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task template not found"})
+		return
+	}
+
+	version := tpl.Version
+	definition := tpl.Definition
+	if body.Version != 0 && body.Version != tpl.Version {
+		v, err := h.db.GetTaskTemplateVersion(tpl.TemplateID, body.Version)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Task template version not found"})
+			return
+		}
+		version = v.Version
+		definition = v.Definition
+	}
+
+	req, err := decodeDefinition(mergeDefinition(parseDefinition(definition), body.Overrides))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	task, err := h.tasks.buildTask(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	task.TemplateID = &tpl.TemplateID
+	task.TemplateVersion = &version
+
+	if err := h.db.CreateTask(task); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create task"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, task)
+}
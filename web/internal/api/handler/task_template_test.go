@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"atlas/web/internal/model"
+)
+
+func serveTaskHandler(t *testing.T, fn gin.HandlerFunc, method, path string, params gin.Params, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Params = params
+	ctx.Request = httptest.NewRequest(method, path, strings.NewReader(body))
+	ctx.Request.Header.Set("Content-Type", "application/json")
+	fn(ctx)
+	return recorder
+}
+
+func TestRerunTaskCopiesDefinition(t *testing.T) {
+	db := newTaskHandlerTestDB(t)
+	handler := &TaskHandler{db: db}
+
+	created := serveTaskHandler(t, handler.CreateTask, http.MethodPost, "/api/tasks", nil,
+		`{"task_type":"icmp_ping","mode":"single","target":"192.0.2.1","parameters":{"count":4},"selector":"region=eu","retry_policy":{"max_attempts":2}}`)
+	if created.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", created.Code, created.Body.String())
+	}
+	var source model.Task
+	if err := json.Unmarshal(created.Body.Bytes(), &source); err != nil {
+		t.Fatalf("failed to decode task: %v", err)
+	}
+
+	recorder := serveTaskHandler(t, handler.RerunTask, http.MethodPost, "/api/tasks/"+source.TaskID+"/rerun",
+		gin.Params{{Key: "id", Value: source.TaskID}}, "")
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	rerun, err := db.GetTask(jsonField(t, recorder, "task_id"))
+	if err != nil {
+		t.Fatalf("GetTask failed: %v", err)
+	}
+	if rerun.TaskID == source.TaskID {
+		t.Fatal("expected rerun to create a new task")
+	}
+	if rerun.RerunOf == nil || *rerun.RerunOf != source.TaskID {
+		t.Fatalf("expected rerun_of %s, got %v", source.TaskID, rerun.RerunOf)
+	}
+	if rerun.TaskType != source.TaskType || rerun.Target != source.Target || rerun.Selector != source.Selector {
+		t.Fatalf("expected same type/target/selector, got %+v", rerun)
+	}
+	if rerun.Parameters != source.Parameters || rerun.RetryPolicy != source.RetryPolicy {
+		t.Fatalf("expected same parameters and retry policy, got %s / %s", rerun.Parameters, rerun.RetryPolicy)
+	}
+	if rerun.Status != "pending" {
+		t.Fatalf("expected pending rerun, got %s", rerun.Status)
+	}
+
+	// 原任务没有解析出的探针时无法固定探针
+	pinned := serveTaskHandler(t, handler.RerunTask, http.MethodPost, "/api/tasks/"+source.TaskID+"/rerun",
+		gin.Params{{Key: "id", Value: source.TaskID}}, `{"use_resolved_probes":true}`)
+	if pinned.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d: %s", pinned.Code, pinned.Body.String())
+	}
+}
+
+func TestTaskTemplateVersionsAndStartWithOverrides(t *testing.T) {
+	db := newTaskHandlerTestDB(t)
+	handler := NewTaskTemplateHandler(db, &TaskHandler{db: db})
+
+	created := serveTaskHandler(t, handler.CreateTaskTemplate, http.MethodPost, "/api/task-templates", nil,
+		`{"name":"CDN edge health","definition":{"task_type":"icmp_ping","mode":"single","target":"192.0.2.1","parameters":{"count":4,"timeout":2}}}`)
+	if created.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", created.Code, created.Body.String())
+	}
+	templateID := jsonField(t, created, "template_id")
+	params := gin.Params{{Key: "id", Value: templateID}}
+
+	updated := serveTaskHandler(t, handler.UpdateTaskTemplate, http.MethodPut, "/api/task-templates/"+templateID, params,
+		`{"name":"CDN edge health","definition":{"task_type":"icmp_ping","mode":"single","target":"192.0.2.2","parameters":{"count":8,"timeout":2}}}`)
+	if updated.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", updated.Code, updated.Body.String())
+	}
+
+	versions, err := db.ListTaskTemplateVersions(templateID)
+	if err != nil {
+		t.Fatalf("ListTaskTemplateVersions failed: %v", err)
+	}
+	if len(versions) != 2 || versions[0].Version != 2 {
+		t.Fatalf("expected versions [2 1], got %+v", versions)
+	}
+	if got := strings.Join(versions[0].ChangedFields, ","); got != "definition.parameters.count,definition.target" {
+		t.Fatalf("unexpected changed fields: %s", got)
+	}
+
+	// 按旧版本启动，并覆盖目标与部分参数
+	started := serveTaskHandler(t, handler.StartTaskTemplate, http.MethodPost, "/api/task-templates/"+templateID+"/start", params,
+		`{"version":1,"overrides":{"target":"198.51.100.7","parameters":{"timeout":5}}}`)
+	if started.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", started.Code, started.Body.String())
+	}
+
+	task, err := db.GetTask(jsonField(t, started, "task_id"))
+	if err != nil {
+		t.Fatalf("GetTask failed: %v", err)
+	}
+	if task.Target != "198.51.100.7" {
+		t.Fatalf("expected overridden target, got %s", task.Target)
+	}
+	if task.TemplateID == nil || *task.TemplateID != templateID || task.TemplateVersion == nil || *task.TemplateVersion != 1 {
+		t.Fatalf("expected template %s v1, got %v v%v", templateID, task.TemplateID, task.TemplateVersion)
+	}
+	var parameters map[string]interface{}
+	_ = json.Unmarshal([]byte(task.Parameters), &parameters)
+	if parameters["count"] != float64(4) || parameters["timeout"] != float64(5) {
+		t.Fatalf("expected merged parameters count=4 timeout=5, got %v", parameters)
+	}
+}
+
+func TestMergeDefinitionClearsExclusiveFields(t *testing.T) {
+	base := map[string]interface{}{
+		"target":   "192.0.2.1",
+		"selector": "region=eu",
+	}
+	merged := mergeDefinition(base, map[string]interface{}{
+		"targets":         map[string]interface{}{"hosts": []interface{}{"192.0.2.1", "192.0.2.2"}},
+		"assigned_probes": []interface{}{"probe-1"},
+	})
+	if _, ok := merged["target"]; ok {
+		t.Fatal("expected target to be cleared when targets is overridden")
+	}
+	if _, ok := merged["selector"]; ok {
+		t.Fatal("expected selector to be cleared when assigned_probes is overridden")
+	}
+	if _, ok := base["selector"]; !ok {
+		t.Fatal("expected base definition to be left untouched")
+	}
+}
+
+func jsonField(t *testing.T, recorder *httptest.ResponseRecorder, field string) string {
+	t.Helper()
+	var body map[string]interface{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	value, _ := body[field].(string)
+	if value == "" {
+		t.Fatalf("response has no %s: %s", field, recorder.Body.String())
+	}
+	return value
+}
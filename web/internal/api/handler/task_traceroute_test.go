@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"atlas/web/internal/database"
+	"atlas/web/internal/database/dbtest"
 	"atlas/web/internal/model"
 )
 
@@ -25,9 +26,9 @@ func newTaskHandlerTestDB(t *testing.T) *database.Database {
 		_ = os.Chdir(wd)
 	})
 
-	db, err := database.New(filepath.Join(t.TempDir(), "atlas-task-handler.db"))
+	db, err := database.Open(dbtest.Source(t, filepath.Join(t.TempDir(), "atlas-task-handler.db")))
 	if err != nil {
-		t.Fatalf("database.New failed: %v", err)
+		t.Fatalf("database.Open failed: %v", err)
 	}
 	t.Cleanup(func() {
 		_ = db.Close()
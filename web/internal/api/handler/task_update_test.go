@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"atlas/web/internal/schedule"
+)
+
+func TestUpdateTaskRecordsRevisionAndKeepsRunCount(t *testing.T) {
+	db := newTaskHandlerTestDB(t)
+	handler := &TaskHandler{db: db}
+
+	created := serveTaskHandler(t, handler.CreateTask, http.MethodPost, "/api/tasks", nil,
+		`{"task_type":"http_test","mode":"continuous","target":"https://example.com","parameters":{"method":"GET"},"schedule":{"type":"interval","expr":"5m"}}`)
+	if created.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", created.Code, created.Body.String())
+	}
+	taskID := jsonField(t, created, "task_id")
+	params := gin.Params{{Key: "id", Value: taskID}}
+
+	// 模拟已运行 3 次
+	task, _ := db.GetTask(taskID)
+	spec, _ := schedule.Parse(task.Schedule)
+	for i := 0; i < 3; i++ {
+		spec.MarkRun(time.Now())
+	}
+	task.Schedule = spec.String()
+	task.Status = "running"
+	if err := db.UpdateTask(task); err != nil {
+		t.Fatalf("UpdateTask failed: %v", err)
+	}
+
+	rejected := serveTaskHandler(t, handler.UpdateTask, http.MethodPatch, "/api/tasks/"+taskID, params, `{"target":"https://example.org"}`)
+	if rejected.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for non-editable field, got %d", rejected.Code)
+	}
+
+	updated := serveTaskHandler(t, handler.UpdateTask, http.MethodPatch, "/api/tasks/"+taskID, params,
+		`{"priority":8,"parameters":{"timeout":10},"schedule":{"type":"interval","expr":"1m"}}`)
+	if updated.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", updated.Code, updated.Body.String())
+	}
+
+	task, _ = db.GetTask(taskID)
+	if task.Priority != 8 || task.Revision != 1 {
+		t.Fatalf("expected priority 8 at revision 1, got %d at %d", task.Priority, task.Revision)
+	}
+	spec, _ = schedule.Parse(task.Schedule)
+	if spec.Expr != "1m" || spec.RunCount != 3 {
+		t.Fatalf("expected new interval with run_count kept, got %s / %d", spec.Expr, spec.RunCount)
+	}
+	var parameters map[string]interface{}
+	_ = json.Unmarshal([]byte(task.Parameters), &parameters)
+	if parameters["method"] != "GET" || parameters["timeout"] != float64(10) {
+		t.Fatalf("expected merged parameters, got %v", parameters)
+	}
+
+	revisions, err := db.ListTaskRevisions(taskID)
+	if err != nil {
+		t.Fatalf("ListTaskRevisions failed: %v", err)
+	}
+	if len(revisions) != 1 {
+		t.Fatalf("expected 1 revision, got %d", len(revisions))
+	}
+	if got := strings.Join(revisions[0].ChangedFields, ","); got != "parameters.timeout,priority,schedule" {
+		t.Fatalf("unexpected changed fields: %s", got)
+	}
+}
+
+func TestUpdateTaskSkipsProbeChecksWhenProbesUnchanged(t *testing.T) {
+	db := newTaskHandlerTestDB(t)
+	handler := &TaskHandler{db: db}
+	seedTaskHandlerProbe(t, db, "probe-trace", `["traceroute"]`, "online")
+
+	created := serveTaskHandler(t, handler.CreateTask, http.MethodPost, "/api/tasks", nil,
+		`{"task_type":"traceroute","mode":"continuous","target":"1.1.1.1","assigned_probes":["probe-trace"],"schedule":{"type":"interval","expr":"5m"}}`)
+	if created.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", created.Code, created.Body.String())
+	}
+	taskID := jsonField(t, created, "task_id")
+	params := gin.Params{{Key: "id", Value: taskID}}
+
+	// 探针短暂离线
+	seedTaskHandlerProbe(t, db, "probe-trace", `["traceroute"]`, "offline")
+
+	updated := serveTaskHandler(t, handler.UpdateTask, http.MethodPatch, "/api/tasks/"+taskID, params, `{"priority":9}`)
+	if updated.Code != http.StatusOK {
+		t.Fatalf("expected unrelated edit to succeed while probe is offline, got %d: %s", updated.Code, updated.Body.String())
+	}
+	task, _ := db.GetTask(taskID)
+	if task.Priority != 9 || task.AssignedProbes != `["probe-trace"]` {
+		t.Fatalf("expected priority 9 with probes kept, got %d / %s", task.Priority, task.AssignedProbes)
+	}
+
+	reassigned := serveTaskHandler(t, handler.UpdateTask, http.MethodPatch, "/api/tasks/"+taskID, params, `{"assigned_probes":["probe-trace"]}`)
+	if reassigned.Code != http.StatusBadRequest {
+		t.Fatalf("expected probe edit to be validated, got %d: %s", reassigned.Code, reassigned.Body.String())
+	}
+}
+
+func TestPauseAndResumeContinuousTask(t *testing.T) {
+	db := newTaskHandlerTestDB(t)
+	handler := &TaskHandler{db: db}
+
+	created := serveTaskHandler(t, handler.CreateTask, http.MethodPost, "/api/tasks", nil,
+		`{"task_type":"http_test","mode":"continuous","target":"https://example.com","schedule":{"type":"interval","expr":"5m"}}`)
+	if created.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", created.Code, created.Body.String())
+	}
+	taskID := jsonField(t, created, "task_id")
+	params := gin.Params{{Key: "id", Value: taskID}}
+
+	paused := serveTaskHandler(t, handler.PauseTask, http.MethodPost, "/api/tasks/"+taskID+"/pause", params, "")
+	if paused.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", paused.Code, paused.Body.String())
+	}
+	due, err := db.GetDueContinuousTasks(time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("GetDueContinuousTasks failed: %v", err)
+	}
+	if len(due) != 0 {
+		t.Fatalf("expected paused task not to be due, got %d task(s)", len(due))
+	}
+
+	again := serveTaskHandler(t, handler.PauseTask, http.MethodPost, "/api/tasks/"+taskID+"/pause", params, "")
+	if again.Code != http.StatusConflict {
+		t.Fatalf("expected status 409 when pausing twice, got %d", again.Code)
+	}
+
+	resumed := serveTaskHandler(t, handler.ResumeTask, http.MethodPost, "/api/tasks/"+taskID+"/resume", params, "")
+	if resumed.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resumed.Code, resumed.Body.String())
+	}
+	task, _ := db.GetTask(taskID)
+	if task.Status != "pending" || task.NextRunAt == nil {
+		t.Fatalf("expected resumed task to be pending with a next run, got %s / %v", task.Status, task.NextRunAt)
+	}
+}
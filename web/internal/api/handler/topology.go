@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"atlas/web/internal/database"
+	"atlas/web/internal/routeutil"
+)
+
+const (
+	defaultTopologyResults = 500
+	maxTopologyResults     = 5000
+)
+
+// TopologyHandler 合并拓扑处理器
+type TopologyHandler struct {
+	db *database.Database
+}
+
+// NewTopologyHandler 创建合并拓扑处理器
+func NewTopologyHandler(db *database.Database) *TopologyHandler {
+	return &TopologyHandler{db: db}
+}
+
+// GetTopology 合并多条 traceroute/mtr 结果为节点/边图
+// GET /api/topology?target=&from=&to=&probe_ids=a,b&limit=&format=json|dot
+func (h *TopologyHandler) GetTopology(c *gin.Context) {
+	target := strings.TrimSpace(c.Query("target"))
+
+	from, err := parseTimeQuery(c.Query("from"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from"})
+		return
+	}
+	to, err := parseTimeQuery(c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to"})
+		return
+	}
+	if target == "" && from == nil && to == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "target or from/to required"})
+		return
+	}
+
+	limit := defaultTopologyResults
+	if v, err := strconv.Atoi(c.Query("limit")); err == nil && v > 0 {
+		limit = v
+	}
+	if limit > maxTopologyResults {
+		limit = maxTopologyResults
+	}
+
+	results, err := h.db.ListRouteResults(target, splitCSV(c.Query("probe_ids")), from, to, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list results"})
+		return
+	}
+
+	builder := routeutil.NewTopologyBuilder()
+	for _, result := range results {
+		hops, err := routeutil.ParseHopsJSON(result.ResultData)
+		if err != nil {
+			continue
+		}
+		builder.AddPath(result.ProbeID, hops)
+	}
+	topology := builder.Build()
+
+	if c.Query("format") == "dot" {
+		c.Data(http.StatusOK, "text/vnd.graphviz; charset=utf-8", []byte(topology.DOT()))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"target":    target,
+		"nodes":     topology.Nodes,
+		"edges":     topology.Edges,
+		"paths":     topology.Paths,
+		"results":   len(results),
+		"truncated": len(results) >= limit,
+	})
+}
+
+// parseTimeQuery 解析 RFC3339 或 Unix 秒时间参数，空值返回 nil
+func parseTimeQuery(raw string) (*time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	if sec, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		t := time.Unix(sec, 0)
+		return &t, nil
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// splitCSV 拆分逗号分隔的查询参数
+func splitCSV(raw string) []string {
+	if strings.TrimSpace(raw) == "" {
+		return nil
+	}
+	return normalizeProbeIDs(strings.Split(raw, ","))
+}
@@ -12,16 +12,21 @@ import (
 )
 
 // SetupRoutes 设置API路由
-func SetupRoutes(r *gin.Engine, db *database.Database, hub *websocket.Hub, cfg *config.Config) {
+func SetupRoutes(r *gin.Engine, db *database.Database, hub *websocket.Hub, geoIPService *geoip.GeoIPService, cfg *config.Config) {
 	// 创建处理器
 	taskHandler := handler.NewTaskHandler(db, hub)
 	probeHandler := handler.NewProbeHandler(db)
-	resultHandler := handler.NewResultHandler(db)
+	resultHandler := handler.NewResultHandler(db, geoIPService)
+	pathChangeHandler := handler.NewPathChangeHandler(db)
+	topologyHandler := handler.NewTopologyHandler(db)
+	targetGroupHandler := handler.NewTargetGroupHandler(db)
+	taskTemplateHandler := handler.NewTaskTemplateHandler(db, taskHandler)
+	seriesHandler := handler.NewSeriesHandler(db)
 
 	// API路由组
 	api := r.Group("/api")
 	{
-		adminHandler := handler.NewAdminHandler(db, hub, cfg)
+		adminHandler := handler.NewAdminHandler(db, hub, geoIPService, cfg)
 		admin := api.Group("/admin")
 		{
 			admin.POST("/login", adminHandler.Login)
@@ -36,6 +41,11 @@ func SetupRoutes(r *gin.Engine, db *database.Database, hub *websocket.Hub, cfg *
 				authed.PUT("/probes/:id", adminHandler.UpdateProbe)
 				authed.POST("/probes/:id/upgrade", adminHandler.UpgradeProbe)
 				authed.DELETE("/probes/:id", adminHandler.DeleteProbe)
+				authed.GET("/geoip-cache", adminHandler.ListGeoIPCache)
+				authed.DELETE("/geoip-cache", adminHandler.PurgeGeoIPCache)
+				authed.DELETE("/geoip-cache/:ip", adminHandler.DeleteGeoIPCacheEntry)
+				authed.POST("/geoip-cache/:ip/refresh", adminHandler.RefreshGeoIPCacheEntry)
+				authed.GET("/ingest/stats", adminHandler.IngestStats)
 			}
 		}
 
@@ -45,7 +55,36 @@ func SetupRoutes(r *gin.Engine, db *database.Database, hub *websocket.Hub, cfg *
 			tasks.POST("", taskHandler.CreateTask)
 			tasks.GET("", taskHandler.ListTasks)
 			tasks.GET("/:id", taskHandler.GetTask)
+			tasks.PATCH("/:id", taskHandler.UpdateTask)
 			tasks.DELETE("/:id", taskHandler.CancelTask)
+			tasks.POST("/:id/rerun", taskHandler.RerunTask)
+			tasks.POST("/:id/pause", taskHandler.PauseTask)
+			tasks.POST("/:id/resume", taskHandler.ResumeTask)
+			tasks.GET("/:id/revisions", taskHandler.ListTaskRevisions)
+			tasks.GET("/:id/runs", taskHandler.ListTaskRuns)
+		}
+
+		// 任务模板
+		taskTemplates := api.Group("/task-templates")
+		{
+			taskTemplates.GET("", taskTemplateHandler.ListTaskTemplates)
+			taskTemplates.POST("", taskTemplateHandler.CreateTaskTemplate)
+			taskTemplates.GET("/:id", taskTemplateHandler.GetTaskTemplate)
+			taskTemplates.PUT("/:id", taskTemplateHandler.UpdateTaskTemplate)
+			taskTemplates.DELETE("/:id", taskTemplateHandler.DeleteTaskTemplate)
+			taskTemplates.GET("/:id/versions", taskTemplateHandler.ListTaskTemplateVersions)
+			taskTemplates.GET("/:id/versions/:version", taskTemplateHandler.GetTaskTemplateVersion)
+			taskTemplates.POST("/:id/start", taskTemplateHandler.StartTaskTemplate)
+		}
+
+		// 目标组
+		targetGroups := api.Group("/target-groups")
+		{
+			targetGroups.GET("", targetGroupHandler.ListTargetGroups)
+			targetGroups.POST("", targetGroupHandler.CreateTargetGroup)
+			targetGroups.GET("/:id", targetGroupHandler.GetTargetGroup)
+			targetGroups.PUT("/:id", targetGroupHandler.UpdateTargetGroup)
+			targetGroups.DELETE("/:id", targetGroupHandler.DeleteTargetGroup)
 		}
 
 		// 探针相关
@@ -66,8 +105,18 @@ func SetupRoutes(r *gin.Engine, db *database.Database, hub *websocket.Hub, cfg *
 		{
 			results.GET("", resultHandler.ListResults)
 			results.GET("/:id", resultHandler.GetResult)
+			results.GET("/:id/aspath", resultHandler.GetResultASPath)
 		}
 
+		// 指标时序
+		api.GET("/series", seriesHandler.GetSeries)
+
+		// 路径变化历史
+		api.GET("/path-changes", pathChangeHandler.ListPathChanges)
+
+		// 合并拓扑
+		api.GET("/topology", topologyHandler.GetTopology)
+
 		// 健康检查
 		api.GET("/health", func(c *gin.Context) {
 			c.JSON(200, gin.H{
@@ -76,7 +125,6 @@ func SetupRoutes(r *gin.Engine, db *database.Database, hub *websocket.Hub, cfg *
 		})
 
 		// GeoIP 查询
-		geoIPService := geoip.New()
 		api.GET("/geoip", func(c *gin.Context) {
 			ip := c.Query("ip")
 			if ip == "" {
@@ -0,0 +1,86 @@
+package cluster
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrBusClosed 消息总线已关闭
+var ErrBusClosed = errors.New("message bus closed")
+
+// Bus 节点间消息总线。每个节点订阅以自身节点 ID 命名的频道，
+// 发往其他节点上探针的消息通过 Publish 投递给目标节点，由其 Hub 写入本地连接
+type Bus interface {
+	// Publish 向 node 投递一条消息；目标节点不在线时消息直接丢弃
+	Publish(node string, payload []byte) error
+	// Subscribe 订阅 node 的消息，返回取消订阅函数；fn 在总线的协程中按顺序调用
+	Subscribe(node string, fn func(payload []byte)) (func(), error)
+	// Close 关闭总线并取消全部订阅
+	Close() error
+}
+
+// LocalBus 进程内消息总线，用于单节点部署和测试
+type LocalBus struct {
+	mu       sync.RWMutex
+	handlers map[string]map[int]func(payload []byte)
+	nextID   int
+	closed   bool
+}
+
+// NewLocalBus 创建进程内消息总线
+func NewLocalBus() *LocalBus {
+	return &LocalBus{handlers: make(map[string]map[int]func(payload []byte))}
+}
+
+// Publish 同步调用 node 上的全部订阅者
+func (b *LocalBus) Publish(node string, payload []byte) error {
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return ErrBusClosed
+	}
+	handlers := make([]func(payload []byte), 0, len(b.handlers[node]))
+	for _, fn := range b.handlers[node] {
+		handlers = append(handlers, fn)
+	}
+	b.mu.RUnlock()
+
+	for _, fn := range handlers {
+		fn(payload)
+	}
+	return nil
+}
+
+// Subscribe 订阅 node 的消息
+func (b *LocalBus) Subscribe(node string, fn func(payload []byte)) (func(), error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, ErrBusClosed
+	}
+
+	id := b.nextID
+	b.nextID++
+	if b.handlers[node] == nil {
+		b.handlers[node] = make(map[int]func(payload []byte))
+	}
+	b.handlers[node][id] = fn
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.handlers[node], id)
+		if len(b.handlers[node]) == 0 {
+			delete(b.handlers, node)
+		}
+	}, nil
+}
+
+// Close 关闭总线
+func (b *LocalBus) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	b.handlers = make(map[string]map[int]func(payload []byte))
+	return nil
+}
@@ -0,0 +1,66 @@
+package cluster
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestLocalBusDeliversOnlyToTargetNode(t *testing.T) {
+	bus := NewLocalBus()
+	var gotA, gotB []string
+	if _, err := bus.Subscribe("node-a", func(p []byte) { gotA = append(gotA, string(p)) }); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	unsubscribe, err := bus.Subscribe("node-b", func(p []byte) { gotB = append(gotB, string(p)) })
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	_ = bus.Publish("node-b", []byte("hello"))
+	unsubscribe()
+	_ = bus.Publish("node-b", []byte("dropped"))
+
+	if len(gotA) != 0 || len(gotB) != 1 || gotB[0] != "hello" {
+		t.Fatalf("unexpected deliveries: a=%v b=%v", gotA, gotB)
+	}
+
+	_ = bus.Close()
+	if err := bus.Publish("node-a", []byte("x")); err != ErrBusClosed {
+		t.Fatalf("expected ErrBusClosed, got %v", err)
+	}
+}
+
+func TestRedisBusRoutesBetweenNodes(t *testing.T) {
+	server := miniredis.RunT(t)
+
+	// 两个节点各自连接同一个 Redis
+	busA, err := NewRedisBus(server.Addr(), "", 0)
+	if err != nil {
+		t.Fatalf("NewRedisBus failed: %v", err)
+	}
+	defer busA.Close()
+	busB, err := NewRedisBus(server.Addr(), "", 0)
+	if err != nil {
+		t.Fatalf("NewRedisBus failed: %v", err)
+	}
+	defer busB.Close()
+
+	received := make(chan string, 1)
+	if _, err := busB.Subscribe("node-b", func(p []byte) { received <- string(p) }); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	if err := busA.Publish("node-b", []byte(`{"probe_id":"probe-1"}`)); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	select {
+	case payload := <-received:
+		if payload != `{"probe_id":"probe-1"}` {
+			t.Fatalf("unexpected payload: %s", payload)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected message on node-b")
+	}
+}
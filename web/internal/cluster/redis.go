@@ -0,0 +1,100 @@
+package cluster
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisChannelPrefix 节点频道名前缀
+const redisChannelPrefix = "atlas:node:"
+
+// RedisBus 基于 Redis pub/sub 的跨节点消息总线。
+// pub/sub 不持久化消息：目标节点短暂断开期间的消息会丢失，由调度器的超时回收与重试兜底
+type RedisBus struct {
+	client *redis.Client
+
+	mu     sync.Mutex
+	subs   map[*redis.PubSub]struct{}
+	closed bool
+}
+
+// NewRedisBus 连接 addr 指定的 Redis（host:port），password 为空时不认证
+func NewRedisBus(addr, password string, db int) (*RedisBus, error) {
+	client := redis.NewClient(&redis.Options{Addr: addr, Password: password, DB: db})
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+	return &RedisBus{client: client, subs: make(map[*redis.PubSub]struct{})}, nil
+}
+
+// Publish 发布到 node 的频道
+func (b *RedisBus) Publish(node string, payload []byte) error {
+	b.mu.Lock()
+	closed := b.closed
+	b.mu.Unlock()
+	if closed {
+		return ErrBusClosed
+	}
+	return b.client.Publish(context.Background(), redisChannelPrefix+node, payload).Err()
+}
+
+// Subscribe 订阅 node 的频道；返回前确认订阅已生效，避免丢失紧随其后发布的消息
+func (b *RedisBus) Subscribe(node string, fn func(payload []byte)) (func(), error) {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil, ErrBusClosed
+	}
+	b.mu.Unlock()
+
+	ctx := context.Background()
+	pubsub := b.client.Subscribe(ctx, redisChannelPrefix+node)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return nil, err
+	}
+
+	b.mu.Lock()
+	b.subs[pubsub] = struct{}{}
+	b.mu.Unlock()
+
+	go func() {
+		for msg := range pubsub.Channel() {
+			fn([]byte(msg.Payload))
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, pubsub)
+			b.mu.Unlock()
+			if err := pubsub.Close(); err != nil {
+				log.Printf("[Cluster] Failed to close subscription for node %s: %v", node, err)
+			}
+		})
+	}, nil
+}
+
+// Close 取消全部订阅并断开 Redis 连接
+func (b *RedisBus) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	subs := b.subs
+	b.subs = nil
+	b.mu.Unlock()
+
+	for pubsub := range subs {
+		_ = pubsub.Close()
+	}
+	return b.client.Close()
+}
@@ -0,0 +1,88 @@
+package cluster
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"atlas/web/internal/database"
+)
+
+// DefaultHeartbeatTTL 默认连接心跳超时；节点每隔其 1/3 刷新一次
+const DefaultHeartbeatTTL = 30 * time.Second
+
+// Registry 集群连接注册表，记录探针连接在哪个节点上
+type Registry interface {
+	// NodeID 本节点 ID
+	NodeID() string
+	// Claim 记录探针连接到本节点
+	Claim(probeID string) error
+	// Release 探针从本节点断开；探针已在其他节点重连时不做任何事
+	Release(probeID string) error
+	// Owner 返回持有探针连接的节点，心跳超时的记录视为不在线
+	Owner(probeID string) (string, bool)
+	// Heartbeat 刷新本节点全部连接的心跳
+	Heartbeat() error
+	// TTL 心跳超时，节点每隔 TTL/3 调用一次 Heartbeat
+	TTL() time.Duration
+}
+
+// DBRegistry 基于共享数据库 probe_connections 表的注册表
+type DBRegistry struct {
+	db     *database.Database
+	nodeID string
+	ttl    time.Duration
+}
+
+// NewDBRegistry 创建注册表；ttl <= 0 时使用 DefaultHeartbeatTTL
+func NewDBRegistry(db *database.Database, nodeID string, ttl time.Duration) *DBRegistry {
+	if ttl <= 0 {
+		ttl = DefaultHeartbeatTTL
+	}
+	return &DBRegistry{db: db, nodeID: nodeID, ttl: ttl}
+}
+
+// NodeID 本节点 ID
+func (r *DBRegistry) NodeID() string {
+	return r.nodeID
+}
+
+// TTL 心跳超时
+func (r *DBRegistry) TTL() time.Duration {
+	return r.ttl
+}
+
+// Claim 记录探针连接到本节点
+func (r *DBRegistry) Claim(probeID string) error {
+	return r.db.ClaimProbeConnection(probeID, r.nodeID, time.Now())
+}
+
+// Release 删除本节点持有的探针连接记录
+func (r *DBRegistry) Release(probeID string) error {
+	return r.db.ReleaseProbeConnection(probeID, r.nodeID)
+}
+
+// Owner 返回持有探针连接且心跳未超时的节点
+func (r *DBRegistry) Owner(probeID string) (string, bool) {
+	conn, err := r.db.GetProbeConnection(probeID)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("[Cluster] Failed to look up owner of probe %s: %v", probeID, err)
+		}
+		return "", false
+	}
+	if time.Since(conn.HeartbeatAt) > r.ttl {
+		return "", false
+	}
+	return conn.NodeID, true
+}
+
+// Heartbeat 刷新本节点全部连接的心跳
+func (r *DBRegistry) Heartbeat() error {
+	return r.db.TouchNodeConnections(r.nodeID, time.Now())
+}
+
+// Reset 清除本节点遗留的记录，节点重启后调用（重启前的连接已全部断开）
+func (r *DBRegistry) Reset() error {
+	return r.db.ReleaseNodeConnections(r.nodeID)
+}
@@ -15,6 +15,11 @@ type Config struct {
 	Database  DatabaseConfig  `yaml:"database"`
 	Scheduler SchedulerConfig `yaml:"scheduler"`
 	Security  SecurityConfig  `yaml:"security"`
+	GeoIP     GeoIPConfig     `yaml:"geoip"`
+	PeeringDB PeeringDBConfig `yaml:"peeringdb"`
+	Cluster   ClusterConfig   `yaml:"cluster"`
+	Ingest    IngestConfig    `yaml:"ingest"`
+	Retention RetentionConfig `yaml:"retention"`
 }
 
 // ServerConfig HTTP服务器配置
@@ -26,12 +31,52 @@ type ServerConfig struct {
 
 // DatabaseConfig 数据库配置
 type DatabaseConfig struct {
-	Path string `yaml:"path"`
+	Driver string `yaml:"driver"` // sqlite（默认）或 postgres
+	Path   string `yaml:"path"`   // SQLite 数据库文件
+	DSN    string `yaml:"dsn"`    // PostgreSQL 连接串
 }
 
 // SchedulerConfig 调度器配置
 type SchedulerConfig struct {
-	ScanInterval int `yaml:"scan_interval"` // 秒
+	ScanInterval   int    `yaml:"scan_interval"`   // 秒
+	LeaderElection bool   `yaml:"leader_election"` // 多副本部署时开启，只有主节点调度
+	LeaseTTL       int    `yaml:"lease_ttl"`       // 主节点租约时长，秒
+	InstanceID     string `yaml:"instance_id"`     // 为空时自动生成
+}
+
+// ClusterConfig 多节点部署配置，节点 ID 复用 scheduler.instance_id
+type ClusterConfig struct {
+	Bus           string `yaml:"bus"`            // 为空时单节点；local（单进程）或 redis
+	RedisAddr     string `yaml:"redis_addr"`     // host:port
+	RedisPassword string `yaml:"redis_password"` // 为空时不认证
+	RedisDB       int    `yaml:"redis_db"`
+	HeartbeatTTL  int    `yaml:"heartbeat_ttl"` // 探针连接心跳超时，秒
+}
+
+// IngestConfig 结果入库流水线配置，未设置的项使用默认值
+type IngestConfig struct {
+	QueueSize       int `yaml:"queue_size"`        // 待入库结果队列容量
+	Workers         int `yaml:"workers"`           // 入库协程数
+	BatchSize       int `yaml:"batch_size"`        // 单个事务最多写入的结果数
+	FlushInterval   int `yaml:"flush_interval_ms"` // 未攒满一批时的最长等待，毫秒
+	EnrichQueueSize int `yaml:"enrich_queue_size"` // 待富化结果队列容量，满时跳过富化
+	EnrichWorkers   int `yaml:"enrich_workers"`    // 富化协程数
+}
+
+// RetentionConfig 结果清理任务配置，保留策略本身在 config 表中
+type RetentionConfig struct {
+	Interval int `yaml:"interval"` // 检查间隔，秒
+}
+
+// GeoIPConfig GeoIP 缓存配置
+type GeoIPConfig struct {
+	CacheTTL        int `yaml:"cache_ttl"`        // 秒
+	RefreshInterval int `yaml:"refresh_interval"` // 秒
+}
+
+// PeeringDBConfig 本地 PeeringDB 导出文件配置
+type PeeringDBConfig struct {
+	Path string `yaml:"path"` // 为空时不做 IX 识别
 }
 
 // SecurityConfig 安全配置
@@ -51,16 +96,28 @@ func Load(configPath string) (*Config, error) {
 			StaticPath: "./static",
 		},
 		Database: DatabaseConfig{
-			Path: "./data/atlas.db",
+			Driver: "sqlite",
+			Path:   "./data/atlas.db",
 		},
 		Scheduler: SchedulerConfig{
 			ScanInterval: 1,
+			LeaseTTL:     15,
 		},
 		Security: SecurityConfig{
 			SharedSecret:  "your-secret-key-change-in-production",
 			JWTSecret:     "your-jwt-secret-change-in-production",
 			AdminPassword: "change-me",
 		},
+		GeoIP: GeoIPConfig{
+			CacheTTL:        7 * 24 * 3600,
+			RefreshInterval: 3600,
+		},
+		Cluster: ClusterConfig{
+			HeartbeatTTL: 30,
+		},
+		Retention: RetentionConfig{
+			Interval: 3600,
+		},
 	}
 
 	// 如果配置文件存在,读取并覆盖默认值
@@ -86,9 +143,24 @@ func Load(configPath string) (*Config, error) {
 	} else if mode := strings.TrimSpace(os.Getenv("SERVER_MODE")); mode != "" {
 		config.Server.Mode = mode
 	}
+	if dbDriver := strings.TrimSpace(os.Getenv("DB_DRIVER")); dbDriver != "" {
+		config.Database.Driver = dbDriver
+	}
 	if dbPath := os.Getenv("DB_PATH"); dbPath != "" {
 		config.Database.Path = dbPath
 	}
+	if dsn := strings.TrimSpace(os.Getenv("DATABASE_DSN")); dsn != "" {
+		config.Database.DSN = dsn
+	}
+	if instanceID := strings.TrimSpace(os.Getenv("INSTANCE_ID")); instanceID != "" {
+		config.Scheduler.InstanceID = instanceID
+	}
+	if redisAddr := strings.TrimSpace(os.Getenv("REDIS_ADDR")); redisAddr != "" {
+		config.Cluster.RedisAddr = redisAddr
+	}
+	if peeringDBPath := os.Getenv("PEERINGDB_PATH"); peeringDBPath != "" {
+		config.PeeringDB.Path = peeringDBPath
+	}
 	if secret := os.Getenv("SHARED_SECRET"); secret != "" {
 		config.Security.SharedSecret = secret
 	}
@@ -128,4 +200,7 @@ func applyRelativePaths(baseDir string, cfg *Config) {
 	if cfg.Database.Path != "" && !filepath.IsAbs(cfg.Database.Path) {
 		cfg.Database.Path = filepath.Clean(filepath.Join(baseDir, cfg.Database.Path))
 	}
+	if cfg.PeeringDB.Path != "" && !filepath.IsAbs(cfg.PeeringDB.Path) {
+		cfg.PeeringDB.Path = filepath.Clean(filepath.Join(baseDir, cfg.PeeringDB.Path))
+	}
 }
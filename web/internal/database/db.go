@@ -5,19 +5,55 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
 	"time"
 
+	_ "github.com/lib/pq"
 	_ "modernc.org/sqlite"
 )
 
 // Database 数据库连接封装
 type Database struct {
-	db *sql.DB
+	db    *sqlConn
+	fence *Fence // 由 WithFence 设置，写入前确认主节点租约
 }
 
-// New 创建新的数据库连接
+// New 创建新的 SQLite 数据库连接
 func New(dbPath string) (*Database, error) {
+	return Open(DriverSQLite, dbPath)
+}
+
+// Open 按驱动打开数据库：sqlite 时 dsn 为数据库文件路径，postgres 时为连接串
+func Open(driver, dsn string) (*Database, error) {
+	d, err := dialectFor(driver)
+	if err != nil {
+		return nil, err
+	}
+	if d.driver() == DriverPostgres {
+		return openPostgres(dsn)
+	}
+	return openSQLite(dsn)
+}
+
+func openPostgres(dsn string) (*Database, error) {
+	db, err := sql.Open(DriverPostgres, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	// PostgreSQL 支持并发写入，连接池按常规服务端设置
+	db.SetMaxOpenConns(20)
+	db.SetMaxIdleConns(5)
+	db.SetConnMaxIdleTime(5 * time.Minute)
+
+	if err := db.Ping(); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	return &Database{db: &sqlConn{db: db, dialect: postgresDialect{}}}, nil
+}
+
+func openSQLite(dbPath string) (*Database, error) {
 	// 确保数据目录存在
 	dir := filepath.Dir(dbPath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
@@ -55,108 +91,12 @@ func New(dbPath string) (*Database, error) {
 		return nil, fmt.Errorf("failed to enable foreign keys: %w", err)
 	}
 
-	return &Database{db: db}, nil
+	return &Database{db: &sqlConn{db: db, dialect: sqliteDialect{}}}, nil
 }
 
-// Migrate 运行数据库迁移
-func (d *Database) Migrate() error {
-	files := []string{
-		"migrations/001_init.sql",
-		"migrations/002_add_probe_coordinates.sql",
-		"migrations/003_add_result_status.sql",
-		"migrations/004_add_probe_upgrades.sql",
-		"migrations/005_add_mtr_timeout_config.sql",
-	}
-
-	if err := d.ensureMigrationTable(); err != nil {
-		return err
-	}
-
-	for _, file := range files {
-		applied, err := d.isMigrationApplied(file)
-		if err != nil {
-			return err
-		}
-		if applied {
-			continue
-		}
-
-		migrationSQL, err := os.ReadFile(file)
-		if err != nil {
-			// 允许从仓库根目录运行：尝试 web/migrations/...
-			migrationSQL, err = os.ReadFile(filepath.Join("web", file))
-			if err != nil {
-				return fmt.Errorf("failed to read migration file %s: %w", file, err)
-			}
-		}
-
-		tx, err := d.db.Begin()
-		if err != nil {
-			return fmt.Errorf("failed to start migration transaction %s: %w", file, err)
-		}
-
-		if _, err := tx.Exec(string(migrationSQL)); err != nil {
-			// 兼容旧数据库：在未记录 schema_migrations 的情况下，ALTER TABLE 可能已执行过
-			if (file == "migrations/002_add_probe_coordinates.sql" || file == "migrations/003_add_result_status.sql") && isSQLiteDuplicateColumnError(err) {
-				if err := d.markMigrationApplied(tx, file); err != nil {
-					_ = tx.Rollback()
-					return err
-				}
-				if err := tx.Commit(); err != nil {
-					return fmt.Errorf("failed to commit migration %s: %w", file, err)
-				}
-				continue
-			}
-
-			_ = tx.Rollback()
-			return fmt.Errorf("failed to execute migration %s: %w", file, err)
-		}
-		if err := d.markMigrationApplied(tx, file); err != nil {
-			_ = tx.Rollback()
-			return err
-		}
-		if err := tx.Commit(); err != nil {
-			return fmt.Errorf("failed to commit migration %s: %w", file, err)
-		}
-	}
-
-	return nil
-}
-
-func (d *Database) ensureMigrationTable() error {
-	_, err := d.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
-		filename TEXT PRIMARY KEY,
-		applied_at DATETIME NOT NULL
-	)`)
-	if err != nil {
-		return fmt.Errorf("failed to ensure schema_migrations table: %w", err)
-	}
-	return nil
-}
-
-func isSQLiteDuplicateColumnError(err error) bool {
-	if err == nil {
-		return false
-	}
-	// modernc sqlite 错误字符串常见形态："SQL logic error: duplicate column name: ..."
-	return strings.Contains(err.Error(), "duplicate column name")
-}
-
-func (d *Database) isMigrationApplied(filename string) (bool, error) {
-	var count int
-	err := d.db.QueryRow(`SELECT COUNT(1) FROM schema_migrations WHERE filename = ?`, filename).Scan(&count)
-	if err != nil {
-		return false, fmt.Errorf("failed to query schema_migrations for %s: %w", filename, err)
-	}
-	return count > 0, nil
-}
-
-func (d *Database) markMigrationApplied(tx *sql.Tx, filename string) error {
-	_, err := tx.Exec(`INSERT OR REPLACE INTO schema_migrations (filename, applied_at) VALUES (?, ?)`, filename, time.Now())
-	if err != nil {
-		return fmt.Errorf("failed to mark migration applied %s: %w", filename, err)
-	}
-	return nil
+// Driver 当前使用的数据库驱动
+func (d *Database) Driver() string {
+	return d.db.dialect.driver()
 }
 
 // Close 关闭数据库连接
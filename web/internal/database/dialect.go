@@ -0,0 +1,226 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 支持的数据库驱动
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+)
+
+// dialect 屏蔽 SQLite 与 PostgreSQL 的语法差异。
+// 查询统一以 SQLite 风格的 ? 占位符书写，执行前由 rebind 转换
+type dialect interface {
+	// driver 驱动名，同时作为 sql.Open 的驱动名
+	driver() string
+	// rebind 把 ? 占位符转换为驱动使用的形式
+	rebind(query string) string
+	// migrationDir 内嵌迁移文件中该引擎使用的目录
+	migrationDir() string
+	// jsonText 取 JSON 文本列中顶层字段的文本值
+	jsonText(column, key string) string
+	// timeArg 与 CURRENT_TIMESTAMP 默认值比较时使用的时间参数
+	timeArg(t time.Time) interface{}
+	// timeBucket 时间列所在 step 桶的起点(Unix 秒)，按 step 对齐到 Unix 纪元
+	timeBucket(column string, step time.Duration) string
+	// returningID 插入后是否需要以 RETURNING id 取得自增主键
+	returningID() bool
+	// compactStatements 回收已删除行占用空间的语句，不能在事务中执行
+	compactStatements() []string
+}
+
+func dialectFor(driver string) (dialect, error) {
+	switch driver {
+	case "", DriverSQLite:
+		return sqliteDialect{}, nil
+	case DriverPostgres:
+		return postgresDialect{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver: %s", driver)
+	}
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) driver() string { return DriverSQLite }
+
+func (sqliteDialect) rebind(query string) string { return query }
+
+func (sqliteDialect) migrationDir() string { return "." }
+
+func (sqliteDialect) jsonText(column, key string) string {
+	return fmt.Sprintf("json_extract(%s, '$.%s')", column, key)
+}
+
+// timeArg CURRENT_TIMESTAMP 在 SQLite 中存为 UTC 文本，以同样格式比较
+func (sqliteDialect) timeArg(t time.Time) interface{} {
+	return t.UTC().Format("2006-01-02 15:04:05")
+}
+
+func (sqliteDialect) timeBucket(column string, step time.Duration) string {
+	sec := int64(step / time.Second)
+	return fmt.Sprintf("(CAST(strftime('%%s', %s) AS INTEGER) / %d * %d)", column, sec, sec)
+}
+
+func (sqliteDialect) returningID() bool { return false }
+
+// compactStatements 先把 WAL 写回主库并截断，再 VACUUM 重建数据库文件
+func (sqliteDialect) compactStatements() []string {
+	return []string{"PRAGMA wal_checkpoint(TRUNCATE)", "VACUUM"}
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) driver() string { return DriverPostgres }
+
+// rebind 把 ? 依次替换为 $1、$2…，跳过字符串字面量和带引号的标识符
+func (postgresDialect) rebind(query string) string {
+	if !strings.Contains(query, "?") {
+		return query
+	}
+
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+	n := 0
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"':
+			quote = ch
+		case ch == '?':
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteByte(ch)
+	}
+	return b.String()
+}
+
+func (postgresDialect) migrationDir() string { return "postgres" }
+
+func (postgresDialect) jsonText(column, key string) string {
+	return fmt.Sprintf("(%s::jsonb ->> '%s')", column, key)
+}
+
+func (postgresDialect) timeArg(t time.Time) interface{} { return t }
+
+// timeBucket EXTRACT(EPOCH) 带小数秒，先向下取整再分桶
+func (postgresDialect) timeBucket(column string, step time.Duration) string {
+	sec := int64(step / time.Second)
+	return fmt.Sprintf("(CAST(FLOOR(EXTRACT(EPOCH FROM %s)) AS BIGINT) / %d * %d)", column, sec, sec)
+}
+
+func (postgresDialect) returningID() bool { return true }
+
+// compactStatements autovacuum 之外主动清理删除最多的两张表并更新统计信息
+func (postgresDialect) compactStatements() []string {
+	return []string{"VACUUM ANALYZE results", "VACUUM ANALYZE result_aggregates"}
+}
+
+// sqlConn 按方言改写查询的 *sql.DB 封装
+type sqlConn struct {
+	db      *sql.DB
+	dialect dialect
+}
+
+// Exec 执行语句
+func (c *sqlConn) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return c.db.Exec(c.dialect.rebind(query), args...)
+}
+
+// Query 查询多行
+func (c *sqlConn) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return c.db.Query(c.dialect.rebind(query), args...)
+}
+
+// QueryRow 查询单行
+func (c *sqlConn) QueryRow(query string, args ...interface{}) *sql.Row {
+	return c.db.QueryRow(c.dialect.rebind(query), args...)
+}
+
+// Begin 开启事务
+func (c *sqlConn) Begin() (*sqlTx, error) {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &sqlTx{tx: tx, dialect: c.dialect}, nil
+}
+
+// Insert 执行 INSERT 并返回自增主键
+func (c *sqlConn) Insert(query string, args ...interface{}) (int64, error) {
+	return insertID(c, c.dialect, query, args...)
+}
+
+// Close 关闭连接池
+func (c *sqlConn) Close() error {
+	return c.db.Close()
+}
+
+// sqlTx 按方言改写查询的 *sql.Tx 封装
+type sqlTx struct {
+	tx      *sql.Tx
+	dialect dialect
+}
+
+// Exec 执行语句
+func (t *sqlTx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return t.tx.Exec(t.dialect.rebind(query), args...)
+}
+
+// Query 查询多行
+func (t *sqlTx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return t.tx.Query(t.dialect.rebind(query), args...)
+}
+
+// QueryRow 查询单行
+func (t *sqlTx) QueryRow(query string, args ...interface{}) *sql.Row {
+	return t.tx.QueryRow(t.dialect.rebind(query), args...)
+}
+
+// Insert 执行 INSERT 并返回自增主键
+func (t *sqlTx) Insert(query string, args ...interface{}) (int64, error) {
+	return insertID(t, t.dialect, query, args...)
+}
+
+// Commit 提交事务
+func (t *sqlTx) Commit() error {
+	return t.tx.Commit()
+}
+
+// Rollback 回滚事务
+func (t *sqlTx) Rollback() error {
+	return t.tx.Rollback()
+}
+
+type execQueryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// insertID PostgreSQL 不支持 LastInsertId，改用 RETURNING id
+func insertID(q execQueryer, d dialect, query string, args ...interface{}) (int64, error) {
+	if d.returningID() {
+		var id int64
+		err := q.QueryRow(query+" RETURNING id", args...).Scan(&id)
+		return id, err
+	}
+	result, err := q.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
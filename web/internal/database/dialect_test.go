@@ -0,0 +1,39 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPostgresRebindSkipsQuotedText(t *testing.T) {
+	query := `SELECT * FROM tasks WHERE task_id = ? AND target != '?' AND "odd?col" = ? LIMIT ?`
+	want := `SELECT * FROM tasks WHERE task_id = $1 AND target != '?' AND "odd?col" = $2 LIMIT $3`
+	if got := (postgresDialect{}).rebind(query); got != want {
+		t.Fatalf("unexpected rebind:\n got %s\nwant %s", got, want)
+	}
+	if got := (sqliteDialect{}).rebind(query); got != query {
+		t.Fatalf("expected sqlite query unchanged, got %s", got)
+	}
+}
+
+func TestDialectForRejectsUnknownDriver(t *testing.T) {
+	if _, err := dialectFor("mysql"); err == nil {
+		t.Fatal("expected unsupported driver error")
+	}
+	d, err := dialectFor("")
+	if err != nil || d.driver() != DriverSQLite {
+		t.Fatalf("expected empty driver to default to sqlite, got %v %v", d, err)
+	}
+	if got := (postgresDialect{}).migrationDir(); got != "postgres" {
+		t.Fatalf("unexpected postgres migration dir: %s", got)
+	}
+}
+
+func TestTimeBucketAlignsToStepSeconds(t *testing.T) {
+	if got, want := (sqliteDialect{}).timeBucket("created_at", 5*time.Minute), "(CAST(strftime('%s', created_at) AS INTEGER) / 300 * 300)"; got != want {
+		t.Fatalf("unexpected sqlite bucket:\n got %s\nwant %s", got, want)
+	}
+	if got, want := (postgresDialect{}).timeBucket("bucket_start", time.Hour), "(CAST(FLOOR(EXTRACT(EPOCH FROM bucket_start)) AS BIGINT) / 3600 * 3600)"; got != want {
+		t.Fatalf("unexpected postgres bucket:\n got %s\nwant %s", got, want)
+	}
+}
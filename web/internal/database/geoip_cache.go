@@ -0,0 +1,157 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"atlas/web/internal/model"
+)
+
+const geoIPCacheColumns = `ip, provider, data, fetched_at, ttl_seconds, expires_at, last_hit_at`
+
+// SaveGeoIPCache 写入或覆盖 GeoIP 缓存条目
+func (d *Database) SaveGeoIPCache(entry *model.GeoIPCacheEntry) error {
+	if entry.ExpiresAt.IsZero() {
+		entry.ExpiresAt = entry.FetchedAt.Add(time.Duration(entry.TTLSeconds) * time.Second)
+	}
+	if entry.LastHitAt.IsZero() {
+		entry.LastHitAt = entry.FetchedAt
+	}
+
+	query := `
+		INSERT INTO geoip_cache (ip, provider, data, fetched_at, ttl_seconds, expires_at, last_hit_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(ip) DO UPDATE SET
+			provider = excluded.provider,
+			data = excluded.data,
+			fetched_at = excluded.fetched_at,
+			ttl_seconds = excluded.ttl_seconds,
+			expires_at = excluded.expires_at,
+			last_hit_at = excluded.last_hit_at
+	`
+
+	_, err := d.db.Exec(query,
+		entry.IP,
+		entry.Provider,
+		entry.Data,
+		entry.FetchedAt,
+		entry.TTLSeconds,
+		entry.ExpiresAt,
+		entry.LastHitAt,
+	)
+	return err
+}
+
+// GetGeoIPCache 获取 GeoIP 缓存条目，不存在时返回 nil
+func (d *Database) GetGeoIPCache(ip string) (*model.GeoIPCacheEntry, error) {
+	query := `SELECT ` + geoIPCacheColumns + ` FROM geoip_cache WHERE ip = ?`
+
+	entry := &model.GeoIPCacheEntry{}
+	err := d.db.QueryRow(query, ip).Scan(
+		&entry.IP,
+		&entry.Provider,
+		&entry.Data,
+		&entry.FetchedAt,
+		&entry.TTLSeconds,
+		&entry.ExpiresAt,
+		&entry.LastHitAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// ListGeoIPCache 列出 GeoIP 缓存条目；ipPrefix 非空时按前缀过滤，expiredOnly 时只返回已过期条目
+func (d *Database) ListGeoIPCache(ipPrefix string, expiredOnly bool, limit, offset int) ([]*model.GeoIPCacheEntry, int, error) {
+	where := " WHERE 1 = 1"
+	args := []interface{}{}
+	if ipPrefix != "" {
+		where += ` AND ip LIKE ? ESCAPE '\'`
+		args = append(args, escapeLike(ipPrefix)+"%")
+	}
+	if expiredOnly {
+		where += " AND expires_at <= ?"
+		args = append(args, time.Now())
+	}
+
+	var total int
+	if err := d.db.QueryRow(`SELECT COUNT(1) FROM geoip_cache`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + geoIPCacheColumns + ` FROM geoip_cache` + where + ` ORDER BY fetched_at DESC LIMIT ? OFFSET ?`
+	entries, err := d.queryGeoIPCache(query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
+
+// ListStaleGeoIPCache 列出在 now 之前已过期、且 hitSince 之后仍被查询过的条目，按过期时间升序
+func (d *Database) ListStaleGeoIPCache(now, hitSince time.Time, limit int) ([]*model.GeoIPCacheEntry, error) {
+	query := `SELECT ` + geoIPCacheColumns + ` FROM geoip_cache WHERE expires_at <= ? AND last_hit_at >= ? ORDER BY expires_at ASC LIMIT ?`
+	return d.queryGeoIPCache(query, now, hitSince, limit)
+}
+
+// TouchGeoIPCache 记录条目最近一次被查询命中的时间
+func (d *Database) TouchGeoIPCache(ip string, hitAt time.Time) error {
+	_, err := d.db.Exec(`UPDATE geoip_cache SET last_hit_at = ? WHERE ip = ?`, hitAt, ip)
+	return err
+}
+
+// DeleteGeoIPCache 删除单个 GeoIP 缓存条目
+func (d *Database) DeleteGeoIPCache(ip string) (int64, error) {
+	result, err := d.db.Exec(`DELETE FROM geoip_cache WHERE ip = ?`, ip)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// PurgeGeoIPCache 清空 GeoIP 缓存；expiredOnly 时只删除已过期条目
+func (d *Database) PurgeGeoIPCache(expiredOnly bool) (int64, error) {
+	var (
+		result sql.Result
+		err    error
+	)
+	if expiredOnly {
+		result, err = d.db.Exec(`DELETE FROM geoip_cache WHERE expires_at <= ?`, time.Now())
+	} else {
+		result, err = d.db.Exec(`DELETE FROM geoip_cache`)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (d *Database) queryGeoIPCache(query string, args ...interface{}) ([]*model.GeoIPCacheEntry, error) {
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]*model.GeoIPCacheEntry, 0)
+	for rows.Next() {
+		entry := &model.GeoIPCacheEntry{}
+		if err := rows.Scan(
+			&entry.IP,
+			&entry.Provider,
+			&entry.Data,
+			&entry.FetchedAt,
+			&entry.TTLSeconds,
+			&entry.ExpiresAt,
+			&entry.LastHitAt,
+		); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
@@ -0,0 +1,51 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"atlas/web/internal/model"
+)
+
+func TestListGeoIPCachePrefixEscapesWildcards(t *testing.T) {
+	db := newTestDatabase(t)
+	now := time.Now()
+	for _, ip := range []string{"10.0.0.1", "1000:db8::1", "10_0::1"} {
+		if err := db.SaveGeoIPCache(&model.GeoIPCacheEntry{IP: ip, Provider: "test", Data: "{}", FetchedAt: now, TTLSeconds: 3600}); err != nil {
+			t.Fatalf("SaveGeoIPCache(%s) failed: %v", ip, err)
+		}
+	}
+
+	entries, total, err := db.ListGeoIPCache("10_", false, 10, 0)
+	if err != nil {
+		t.Fatalf("ListGeoIPCache failed: %v", err)
+	}
+	if total != 1 || len(entries) != 1 || entries[0].IP != "10_0::1" {
+		t.Fatalf("expected only the literal prefix match, got total=%d entries=%+v", total, entries)
+	}
+
+	if _, total, err = db.ListGeoIPCache("%", false, 10, 0); err != nil || total != 0 {
+		t.Fatalf("expected %% to match nothing, got total=%d err=%v", total, err)
+	}
+}
+
+func TestListStaleGeoIPCacheSkipsUnusedEntries(t *testing.T) {
+	db := newTestDatabase(t)
+	now := time.Now()
+	for _, ip := range []string{"192.0.2.1", "192.0.2.2"} {
+		if err := db.SaveGeoIPCache(&model.GeoIPCacheEntry{IP: ip, Provider: "test", Data: "{}", FetchedAt: now.Add(-72 * time.Hour), TTLSeconds: 3600}); err != nil {
+			t.Fatalf("SaveGeoIPCache(%s) failed: %v", ip, err)
+		}
+	}
+	if err := db.TouchGeoIPCache("192.0.2.2", now.Add(-time.Hour)); err != nil {
+		t.Fatalf("TouchGeoIPCache failed: %v", err)
+	}
+
+	stale, err := db.ListStaleGeoIPCache(now, now.Add(-24*time.Hour), 10)
+	if err != nil {
+		t.Fatalf("ListStaleGeoIPCache failed: %v", err)
+	}
+	if len(stale) != 1 || stale[0].IP != "192.0.2.2" {
+		t.Fatalf("expected only the recently hit entry, got %+v", stale)
+	}
+}
@@ -0,0 +1,137 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"atlas/web/internal/model"
+)
+
+// GetLease 获取租约，不存在时返回 sql.ErrNoRows
+func (d *Database) GetLease(name string) (*model.Lease, error) {
+	lease := &model.Lease{}
+	query := `SELECT name, holder, token, renewed_at, expires_at FROM leader_leases WHERE name = ?`
+	err := d.db.QueryRow(query, name).Scan(&lease.Name, &lease.Holder, &lease.Token, &lease.RenewedAt, &lease.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return lease, nil
+}
+
+// AcquireLease 获取或续约租约。租约空闲、已过期或已由 holder 持有时成功，易主时 token 加一；
+// 返回当前（获取失败时为他人持有的）租约与是否由 holder 持有。
+// 以读到的 holder/token 作条件更新，多个实例并发竞争时只有一个能成功
+func (d *Database) AcquireLease(name, holder string, ttl time.Duration, now time.Time) (*model.Lease, bool, error) {
+	expiresAt := now.Add(ttl)
+
+	current, err := d.GetLease(name)
+	if err == sql.ErrNoRows {
+		result, err := d.db.Exec(`INSERT INTO leader_leases (name, holder, token, renewed_at, expires_at) VALUES (?, ?, 1, ?, ?)
+			ON CONFLICT(name) DO NOTHING`,
+			name, holder, now, expiresAt)
+		if err != nil {
+			return nil, false, err
+		}
+		if n, _ := result.RowsAffected(); n == 1 {
+			return &model.Lease{Name: name, Holder: holder, Token: 1, RenewedAt: now, ExpiresAt: expiresAt}, true, nil
+		}
+		// 其他实例抢先创建，按其结果判断
+		current, err = d.GetLease(name)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	if current.Holder != holder && now.Before(current.ExpiresAt) {
+		return current, false, nil
+	}
+
+	token := current.Token
+	if current.Holder != holder {
+		token++
+	}
+	query := `UPDATE leader_leases SET holder = ?, token = ?, renewed_at = ?, expires_at = ?
+	          WHERE name = ? AND holder = ? AND token = ?`
+	result, err := d.db.Exec(query, holder, token, now, expiresAt, name, current.Holder, current.Token)
+	if err != nil {
+		return nil, false, err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		latest, err := d.GetLease(name)
+		if err != nil {
+			return nil, false, err
+		}
+		return latest, false, nil
+	}
+	return &model.Lease{Name: name, Holder: holder, Token: token, RenewedAt: now, ExpiresAt: expiresAt}, true, nil
+}
+
+// ReleaseLease 主动放弃租约，使其他实例无需等待过期即可接管；token 不匹配时不做任何修改
+func (d *Database) ReleaseLease(name, holder string, token int64, now time.Time) error {
+	query := `UPDATE leader_leases SET expires_at = ? WHERE name = ? AND holder = ? AND token = ?`
+	_, err := d.db.Exec(query, now, name, holder, token)
+	return err
+}
+
+// Fence 主节点写入携带的租约 fencing token
+type Fence struct {
+	Lease  string
+	Holder string
+	Token  int64
+}
+
+// ErrFenced 租约已易主或已不由本实例持有，带 fencing token 的写入被拒绝
+var ErrFenced = errors.New("lease no longer held: write rejected by fencing token")
+
+// WithFence 返回带 fencing token 的数据库副本：调度与保留策略使用的写入方法
+// 在同一事务中先确认租约仍由 fence 持有再写入，旧主节点暂停后恢复也无法写入
+func (d *Database) WithFence(fence Fence) *Database {
+	return &Database{db: d.db, fence: &fence}
+}
+
+// checkFence 在事务内确认租约仍由 fence 持有。条件更新同时锁住租约行，
+// 并发的易主更新要等本事务提交后才能进行，因此本事务的写入一定早于新主节点
+func checkFence(tx *sqlTx, fence *Fence) error {
+	result, err := tx.Exec(`UPDATE leader_leases SET token = token WHERE name = ? AND holder = ? AND token = ?`,
+		fence.Lease, fence.Holder, fence.Token)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n != 1 {
+		return ErrFenced
+	}
+	return nil
+}
+
+// begin 开启事务；带 fencing token 时先确认租约
+func (d *Database) begin() (*sqlTx, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	if d.fence != nil {
+		if err := checkFence(tx, d.fence); err != nil {
+			_ = tx.Rollback()
+			return nil, err
+		}
+	}
+	return tx, nil
+}
+
+// exec 执行写入语句；带 fencing token 时与租约确认放在同一事务中
+func (d *Database) exec(query string, args ...interface{}) (sql.Result, error) {
+	if d.fence == nil {
+		return d.db.Exec(query, args...)
+	}
+	tx, err := d.begin()
+	if err != nil {
+		return nil, err
+	}
+	result, err := tx.Exec(query, args...)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	return result, tx.Commit()
+}
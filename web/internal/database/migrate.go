@@ -0,0 +1,309 @@
+package database
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"atlas/web/migrations"
+)
+
+// migrationFilePattern 迁移文件名：NNN_name.sql 或 NNN_name.down.sql
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_(\w+?)(\.down)?\.sql$`)
+
+// addColumnPattern 匹配迁移中的 ADD COLUMN 语句，用于识别旧库中已存在的列
+var addColumnPattern = regexp.MustCompile(`(?i)ALTER\s+TABLE\s+(\w+)\s+ADD\s+COLUMN\s+(\w+)`)
+
+// Migration 一个版本的迁移
+type Migration struct {
+	Version int
+	Name    string
+	Key     string // schema_migrations 中记录的文件名，如 migrations/001_init.sql
+	Up      string
+	Down    string // 为空表示不支持回滚
+}
+
+// MigrationStatus 迁移的应用状态
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+	HasDown   bool
+}
+
+// Migrations 返回当前引擎的全部迁移，按版本升序
+func (d *Database) Migrations() ([]Migration, error) {
+	return loadMigrations(migrations.FS, d.db.dialect.migrationDir())
+}
+
+func loadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		version, _ := strconv.Atoi(match[1])
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version}
+			byVersion[version] = m
+		}
+		if match[3] != "" {
+			m.Down = string(content)
+			continue
+		}
+		if m.Name != "" {
+			return nil, fmt.Errorf("duplicate migration version %03d: %s and %s", version, m.Name, match[2])
+		}
+		m.Name = match[2]
+		m.Key = "migrations/" + entry.Name()
+		m.Up = string(content)
+	}
+
+	list := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %03d has a down script but no up script", m.Version)
+		}
+		list = append(list, *m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// Migrate 应用全部未执行的迁移
+func (d *Database) Migrate() error {
+	_, err := d.MigrateUp(0)
+	return err
+}
+
+// MigrateUp 按版本顺序应用未执行的迁移，steps <= 0 时应用全部；返回本次应用的迁移
+func (d *Database) MigrateUp(steps int) ([]Migration, error) {
+	list, applied, err := d.migrationState()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, m := range list {
+		if steps > 0 && len(done) >= steps {
+			break
+		}
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		if err := d.applyMigration(m); err != nil {
+			return done, err
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// MigrateDown 按版本倒序回滚已应用的迁移，steps <= 0 时回滚全部；返回本次回滚的迁移。
+// 遇到没有回滚脚本的迁移时停止
+func (d *Database) MigrateDown(steps int) ([]Migration, error) {
+	list, applied, err := d.migrationState()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(list) - 1; i >= 0; i-- {
+		if steps > 0 && len(done) >= steps {
+			break
+		}
+		m := list[i]
+		record, ok := applied[m.Version]
+		if !ok {
+			continue
+		}
+		if m.Down == "" {
+			return done, fmt.Errorf("migration %03d_%s has no down script", m.Version, m.Name)
+		}
+		if err := d.revertMigration(m, record.key); err != nil {
+			return done, err
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// MigrationStatus 返回每个迁移的应用状态
+func (d *Database) MigrationStatus() ([]MigrationStatus, error) {
+	list, applied, err := d.migrationState()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(list))
+	for _, m := range list {
+		status := MigrationStatus{Version: m.Version, Name: m.Name, HasDown: m.Down != ""}
+		if record, ok := applied[m.Version]; ok {
+			appliedAt := record.appliedAt
+			status.Applied = true
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+type appliedMigration struct {
+	key       string
+	appliedAt time.Time
+}
+
+// migrationState 读取迁移列表与已应用的版本；已应用记录按文件名中的版本号匹配，兼容旧版按路径记录的数据
+func (d *Database) migrationState() ([]Migration, map[int]appliedMigration, error) {
+	list, err := d.Migrations()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := d.ensureMigrationTable(); err != nil {
+		return nil, nil, err
+	}
+
+	rows, err := d.db.Query(`SELECT filename, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]appliedMigration)
+	for rows.Next() {
+		var record appliedMigration
+		if err := rows.Scan(&record.key, &record.appliedAt); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan schema_migrations: %w", err)
+		}
+		match := migrationFilePattern.FindStringSubmatch(path.Base(record.key))
+		if match == nil {
+			continue
+		}
+		version, _ := strconv.Atoi(match[1])
+		applied[version] = record
+	}
+	return list, applied, rows.Err()
+}
+
+func (d *Database) applyMigration(m Migration) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start migration transaction %s: %w", m.Key, err)
+	}
+
+	if _, err := tx.Exec(m.Up); err != nil {
+		_ = tx.Rollback()
+		// 兼容未记录 schema_migrations 的旧库：新增的列都已存在时视为已执行
+		if !isSQLiteDuplicateColumnError(err) {
+			return fmt.Errorf("failed to execute migration %s: %w", m.Key, err)
+		}
+		exists, checkErr := d.columnsExist(m.Up)
+		if checkErr != nil || !exists {
+			return fmt.Errorf("failed to execute migration %s: %w", m.Key, err)
+		}
+		if tx, err = d.db.Begin(); err != nil {
+			return fmt.Errorf("failed to start migration transaction %s: %w", m.Key, err)
+		}
+	}
+
+	if _, err := tx.Exec(`INSERT INTO schema_migrations (filename, applied_at) VALUES (?, ?)
+		ON CONFLICT(filename) DO UPDATE SET applied_at = excluded.applied_at`, m.Key, time.Now()); err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("failed to mark migration applied %s: %w", m.Key, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %s: %w", m.Key, err)
+	}
+	return nil
+}
+
+func (d *Database) revertMigration(m Migration, key string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start rollback transaction %s: %w", m.Key, err)
+	}
+	if !isEmptySQL(m.Down) {
+		if _, err := tx.Exec(m.Down); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("failed to roll back migration %s: %w", m.Key, err)
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM schema_migrations WHERE filename = ?`, key); err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("failed to unmark migration %s: %w", m.Key, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit rollback %s: %w", m.Key, err)
+	}
+	return nil
+}
+
+func (d *Database) ensureMigrationTable() error {
+	_, err := d.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		filename TEXT PRIMARY KEY,
+		applied_at TIMESTAMP NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to ensure schema_migrations table: %w", err)
+	}
+	return nil
+}
+
+func isSQLiteDuplicateColumnError(err error) bool {
+	if err == nil {
+		return false
+	}
+	// modernc sqlite 错误字符串常见形态："SQL logic error: duplicate column name: ..."
+	return strings.Contains(err.Error(), "duplicate column name")
+}
+
+// columnsExist 判断迁移中 ADD COLUMN 的列是否都已存在（仅 SQLite）
+func (d *Database) columnsExist(migrationSQL string) (bool, error) {
+	matches := addColumnPattern.FindAllStringSubmatch(migrationSQL, -1)
+	if len(matches) == 0 {
+		return false, nil
+	}
+	for _, match := range matches {
+		var count int
+		err := d.db.QueryRow(`SELECT COUNT(1) FROM pragma_table_info(?) WHERE name = ?`, match[1], match[2]).Scan(&count)
+		if err != nil {
+			return false, err
+		}
+		if count == 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// isEmptySQL 脚本是否只有注释和空白
+func isEmptySQL(script string) bool {
+	for _, line := range strings.Split(script, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "--") {
+			return false
+		}
+	}
+	return true
+}
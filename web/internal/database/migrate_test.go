@@ -0,0 +1,137 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+
+	"atlas/web/internal/database/dbtest"
+)
+
+func openUnmigrated(t *testing.T) *Database {
+	t.Helper()
+	db, err := Open(dbtest.Source(t, filepath.Join(t.TempDir(), "atlas-migrate.db")))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func TestMigrationsDiscoveredInVersionOrder(t *testing.T) {
+	db := openUnmigrated(t)
+	list, err := db.Migrations()
+	if err != nil {
+		t.Fatalf("Migrations failed: %v", err)
+	}
+	if len(list) == 0 || list[0].Version != 1 || list[0].Key != "migrations/001_init.sql" {
+		t.Fatalf("unexpected first migration: %+v", list)
+	}
+	for i, m := range list {
+		if m.Version != i+1 {
+			t.Fatalf("expected contiguous versions, got %d at position %d", m.Version, i)
+		}
+		if m.Down == "" {
+			t.Fatalf("expected migration %03d_%s to have a down script", m.Version, m.Name)
+		}
+	}
+}
+
+func TestMigrateDownAndUpRoundTrip(t *testing.T) {
+	db := openUnmigrated(t)
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	list, _ := db.Migrations()
+	latest := list[len(list)-1].Version
+
+	reverted, err := db.MigrateDown(2)
+	if err != nil {
+		t.Fatalf("MigrateDown failed: %v", err)
+	}
+	if len(reverted) != 2 || reverted[0].Version != latest || reverted[1].Version != latest-1 {
+		t.Fatalf("expected latest two migrations reverted in reverse order, got %+v", reverted)
+	}
+
+	statuses, err := db.MigrationStatus()
+	if err != nil {
+		t.Fatalf("MigrationStatus failed: %v", err)
+	}
+	for _, s := range statuses {
+		if want := s.Version < latest-1; s.Applied != want {
+			t.Fatalf("migration %03d applied=%v, want %v", s.Version, s.Applied, want)
+		}
+	}
+
+	// 全部回滚后重新升级，验证每个回滚脚本都把结构恢复到可再次执行升级的状态
+	if _, err := db.MigrateDown(0); err != nil {
+		t.Fatalf("MigrateDown all failed: %v", err)
+	}
+	applied, err := db.MigrateUp(0)
+	if err != nil {
+		t.Fatalf("MigrateUp failed: %v", err)
+	}
+	if len(applied) != len(list) {
+		t.Fatalf("expected %d migrations re-applied, got %d", len(list), len(applied))
+	}
+	if _, err := db.ListProbes(""); err != nil {
+		t.Fatalf("expected schema usable after round trip: %v", err)
+	}
+}
+
+func TestMigrateRecognisesLegacyRecordsAndColumns(t *testing.T) {
+	db := openUnmigrated(t)
+	if db.Driver() != DriverSQLite {
+		t.Skip("legacy untracked columns only exist in SQLite deployments")
+	}
+	if _, err := db.MigrateUp(2); err != nil {
+		t.Fatalf("MigrateUp failed: %v", err)
+	}
+
+	// 旧库：002 的列已存在但没有迁移记录
+	if _, err := db.db.Exec(`DELETE FROM schema_migrations WHERE filename = 'migrations/002_add_probe_coordinates.sql'`); err != nil {
+		t.Fatalf("delete record failed: %v", err)
+	}
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("expected existing columns to be accepted, got %v", err)
+	}
+
+	statuses, _ := db.MigrationStatus()
+	for _, s := range statuses {
+		if !s.Applied {
+			t.Fatalf("expected migration %03d to be applied", s.Version)
+		}
+	}
+}
+
+func TestResultMetricsMigrationBackfillsSummary(t *testing.T) {
+	db := openUnmigrated(t)
+	if _, err := db.MigrateUp(20); err != nil {
+		t.Fatalf("MigrateUp failed: %v", err)
+	}
+
+	for _, stmt := range []string{
+		`INSERT INTO probes (probe_id, name, location) VALUES ('probe-1', 'probe-1', 'Test Lab')`,
+		`INSERT INTO tasks (task_id, task_type, mode, target) VALUES ('task-1', 'http_test', 'single', 'https://example.com')`,
+		`INSERT INTO task_executions (execution_id, task_id, probe_id) VALUES ('exec-1', 'task-1', 'probe-1')`,
+		`INSERT INTO results (result_id, execution_id, task_id, probe_id, target, test_type, result_data, summary)
+		 VALUES ('result-1', 'exec-1', 'task-1', 'probe-1', 'https://example.com', 'http_test', '{"avg_ttfb_ms":120.5}', '{"avg_latency":42.5,"http_status_code":503}')`,
+	} {
+		if _, err := db.db.Exec(stmt); err != nil {
+			t.Fatalf("seed failed: %v", err)
+		}
+	}
+
+	if _, err := db.MigrateUp(1); err != nil {
+		t.Fatalf("MigrateUp failed: %v", err)
+	}
+
+	var latency, ttfb float64
+	var status int
+	if err := db.db.QueryRow(`SELECT avg_latency, http_status, ttfb_ms FROM results WHERE result_id = 'result-1'`).Scan(&latency, &status, &ttfb); err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	// 旧摘要没有 ttfb_ms，从 result_data 中提取
+	if latency != 42.5 || status != 503 || ttfb != 120.5 {
+		t.Fatalf("expected backfilled 42.5/503/120.5, got %v/%d/%v", latency, status, ttfb)
+	}
+}
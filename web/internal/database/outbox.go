@@ -0,0 +1,79 @@
+package database
+
+import (
+	"time"
+
+	"atlas/web/internal/model"
+)
+
+// SaveOutboxEntry 写入待确认的 task_assign；同一执行重复写入时覆盖消息内容
+func (d *Database) SaveOutboxEntry(entry *model.OutboxEntry) error {
+	query := `
+		INSERT INTO dispatch_outbox (execution_id, probe_id, task_id, payload, attempts, created_at, last_sent_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(execution_id) DO UPDATE SET
+			probe_id = excluded.probe_id,
+			task_id = excluded.task_id,
+			payload = excluded.payload
+	`
+
+	_, err := d.exec(query,
+		entry.ExecutionID,
+		entry.ProbeID,
+		entry.TaskID,
+		entry.Payload,
+		entry.Attempts,
+		entry.CreatedAt,
+		entry.LastSentAt,
+	)
+	return err
+}
+
+// MarkOutboxSent 记录一次发送
+func (d *Database) MarkOutboxSent(executionID string, sentAt time.Time) error {
+	query := `UPDATE dispatch_outbox SET attempts = attempts + 1, last_sent_at = ? WHERE execution_id = ?`
+	_, err := d.exec(query, sentAt, executionID)
+	return err
+}
+
+// DeleteOutboxEntry 探针确认或执行结束后移除待确认记录
+func (d *Database) DeleteOutboxEntry(executionID string) error {
+	_, err := d.exec(`DELETE FROM dispatch_outbox WHERE execution_id = ?`, executionID)
+	return err
+}
+
+// ListOutboxEntries 按创建顺序列出待确认记录；probeID 为空时不过滤
+func (d *Database) ListOutboxEntries(probeID string) ([]*model.OutboxEntry, error) {
+	query := `SELECT execution_id, probe_id, task_id, payload, COALESCE(attempts, 0), created_at, last_sent_at
+	          FROM dispatch_outbox`
+	args := []interface{}{}
+	if probeID != "" {
+		query += " WHERE probe_id = ?"
+		args = append(args, probeID)
+	}
+	query += " ORDER BY created_at ASC"
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]*model.OutboxEntry, 0)
+	for rows.Next() {
+		entry := &model.OutboxEntry{}
+		if err := rows.Scan(
+			&entry.ExecutionID,
+			&entry.ProbeID,
+			&entry.TaskID,
+			&entry.Payload,
+			&entry.Attempts,
+			&entry.CreatedAt,
+			&entry.LastSentAt,
+		); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,144 @@
+package database
+
+import (
+	"database/sql"
+
+	"atlas/web/internal/model"
+)
+
+// GetPathState 获取 (probe, target) 的当前路径指纹，不存在时返回 nil
+func (d *Database) GetPathState(probeID, target string) (*model.PathState, error) {
+	query := `SELECT probe_id, target, mode, fingerprint, path, result_id, first_seen_at, last_seen_at
+	          FROM path_states WHERE probe_id = ? AND target = ?`
+
+	state := &model.PathState{}
+	err := d.db.QueryRow(query, probeID, target).Scan(
+		&state.ProbeID,
+		&state.Target,
+		&state.Mode,
+		&state.Fingerprint,
+		&state.Path,
+		&state.ResultID,
+		&state.FirstSeenAt,
+		&state.LastSeenAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// SavePathState 写入或覆盖 (probe, target) 的路径指纹
+func (d *Database) SavePathState(state *model.PathState) error {
+	query := `
+		INSERT INTO path_states (probe_id, target, mode, fingerprint, path, result_id, first_seen_at, last_seen_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(probe_id, target) DO UPDATE SET
+			mode = excluded.mode,
+			fingerprint = excluded.fingerprint,
+			path = excluded.path,
+			result_id = excluded.result_id,
+			first_seen_at = excluded.first_seen_at,
+			last_seen_at = excluded.last_seen_at
+	`
+
+	_, err := d.db.Exec(query,
+		state.ProbeID,
+		state.Target,
+		state.Mode,
+		state.Fingerprint,
+		state.Path,
+		state.ResultID,
+		state.FirstSeenAt,
+		state.LastSeenAt,
+	)
+	return err
+}
+
+// CreatePathChange 记录路径变化事件
+func (d *Database) CreatePathChange(change *model.PathChange) error {
+	query := `
+		INSERT INTO path_changes (change_id, probe_id, target, task_id, result_id, previous_result_id, mode,
+		                          old_fingerprint, new_fingerprint, old_path, new_path, diff, detected_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	id, err := d.db.Insert(query,
+		change.ChangeID,
+		change.ProbeID,
+		change.Target,
+		change.TaskID,
+		change.ResultID,
+		change.PreviousResultID,
+		change.Mode,
+		change.OldFingerprint,
+		change.NewFingerprint,
+		change.OldPath,
+		change.NewPath,
+		change.Diff,
+		change.DetectedAt,
+	)
+	if err != nil {
+		return err
+	}
+	change.ID = id
+	return nil
+}
+
+// ListPathChanges 列出路径变化历史；probeID/target 为空时不过滤
+func (d *Database) ListPathChanges(probeID, target string, limit, offset int) ([]*model.PathChange, int, error) {
+	where := " WHERE 1 = 1"
+	args := []interface{}{}
+	if probeID != "" {
+		where += " AND probe_id = ?"
+		args = append(args, probeID)
+	}
+	if target != "" {
+		where += " AND target = ?"
+		args = append(args, target)
+	}
+
+	var total int
+	if err := d.db.QueryRow(`SELECT COUNT(1) FROM path_changes`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT id, change_id, probe_id, target, task_id, result_id, COALESCE(previous_result_id, ''), mode,
+	                 old_fingerprint, new_fingerprint, old_path, new_path, diff, detected_at
+	          FROM path_changes` + where + ` ORDER BY detected_at DESC, id DESC LIMIT ? OFFSET ?`
+
+	rows, err := d.db.Query(query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	changes := make([]*model.PathChange, 0)
+	for rows.Next() {
+		change := &model.PathChange{}
+		if err := rows.Scan(
+			&change.ID,
+			&change.ChangeID,
+			&change.ProbeID,
+			&change.Target,
+			&change.TaskID,
+			&change.ResultID,
+			&change.PreviousResultID,
+			&change.Mode,
+			&change.OldFingerprint,
+			&change.NewFingerprint,
+			&change.OldPath,
+			&change.NewPath,
+			&change.Diff,
+			&change.DetectedAt,
+		); err != nil {
+			return nil, 0, err
+		}
+		changes = append(changes, change)
+	}
+
+	return changes, total, rows.Err()
+}
@@ -21,8 +21,8 @@ func (d *Database) SaveProbe(probe *model.Probe) error {
 	}
 
 	query := `
-		INSERT INTO probes (probe_id, name, location, region, latitude, longitude, ip_address, capabilities, status, last_heartbeat, metadata)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO probes (probe_id, name, location, region, latitude, longitude, ip_address, capabilities, status, last_heartbeat, metadata, labels, max_concurrent_tasks)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT(probe_id) DO UPDATE SET
 			name = excluded.name,
 			location = excluded.location,
@@ -33,7 +33,9 @@ func (d *Database) SaveProbe(probe *model.Probe) error {
 			capabilities = excluded.capabilities,
 			status = excluded.status,
 			last_heartbeat = excluded.last_heartbeat,
-			metadata = excluded.metadata
+			metadata = excluded.metadata,
+			labels = excluded.labels,
+			max_concurrent_tasks = excluded.max_concurrent_tasks
 	`
 
 	_, err := d.db.Exec(query,
@@ -48,6 +50,8 @@ func (d *Database) SaveProbe(probe *model.Probe) error {
 		probe.Status,
 		probe.LastHeartbeat,
 		probe.Metadata,
+		probe.Labels,
+		probe.MaxConcurrentTasks,
 	)
 
 	return err
@@ -55,7 +59,9 @@ func (d *Database) SaveProbe(probe *model.Probe) error {
 
 // GetProbe 根据ProbeID获取探针信息
 func (d *Database) GetProbe(probeID string) (*model.Probe, error) {
-	query := `SELECT id, probe_id, name, location, region, latitude, longitude, ip_address, capabilities, status, last_heartbeat, registered_at, metadata
+	query := `SELECT id, probe_id, name, location, region, latitude, longitude, ip_address, capabilities, status, last_heartbeat, registered_at, metadata,
+	          COALESCE(labels, '{}'), COALESCE(admin_labels, '{}'),
+	          COALESCE(max_concurrent_tasks, 0), COALESCE(active_tasks, 0), COALESCE(queued_tasks, 0)
 	          FROM probes WHERE probe_id = ?`
 
 	probe := &model.Probe{}
@@ -73,6 +79,11 @@ func (d *Database) GetProbe(probeID string) (*model.Probe, error) {
 		&probe.LastHeartbeat,
 		&probe.RegisteredAt,
 		&probe.Metadata,
+		&probe.Labels,
+		&probe.AdminLabels,
+		&probe.MaxConcurrentTasks,
+		&probe.ActiveTasks,
+		&probe.QueuedTasks,
 	)
 
 	if err == sql.ErrNoRows {
@@ -84,7 +95,9 @@ func (d *Database) GetProbe(probeID string) (*model.Probe, error) {
 
 // ListProbes 列出所有探针
 func (d *Database) ListProbes(status string) ([]*model.Probe, error) {
-	query := `SELECT id, probe_id, name, location, region, latitude, longitude, ip_address, capabilities, status, last_heartbeat, registered_at, metadata
+	query := `SELECT id, probe_id, name, location, region, latitude, longitude, ip_address, capabilities, status, last_heartbeat, registered_at, metadata,
+	          COALESCE(labels, '{}'), COALESCE(admin_labels, '{}'),
+	          COALESCE(max_concurrent_tasks, 0), COALESCE(active_tasks, 0), COALESCE(queued_tasks, 0)
 	          FROM probes`
 
 	args := []interface{}{}
@@ -118,6 +131,11 @@ func (d *Database) ListProbes(status string) ([]*model.Probe, error) {
 			&probe.LastHeartbeat,
 			&probe.RegisteredAt,
 			&probe.Metadata,
+			&probe.Labels,
+			&probe.AdminLabels,
+			&probe.MaxConcurrentTasks,
+			&probe.ActiveTasks,
+			&probe.QueuedTasks,
 		)
 		if err != nil {
 			return nil, err
@@ -142,11 +160,20 @@ func (d *Database) UpdateProbeHeartbeat(probeID string) error {
 	return err
 }
 
+// UpdateProbeLoad 更新心跳上报的探针负载
+func (d *Database) UpdateProbeLoad(probeID string, activeTasks, queuedTasks int) error {
+	query := `UPDATE probes SET active_tasks = ?, queued_tasks = ? WHERE probe_id = ?`
+	_, err := d.db.Exec(query, activeTasks, queuedTasks, probeID)
+	return err
+}
+
 // GetOnlineProbes 获取在线的探针列表
 func (d *Database) GetOnlineProbes() ([]*model.Probe, error) {
 	// 5分钟内有心跳的探针视为在线
 	threshold := time.Now().Add(-5 * time.Minute)
-	query := `SELECT id, probe_id, name, location, region, latitude, longitude, ip_address, capabilities, status, last_heartbeat, registered_at, metadata
+	query := `SELECT id, probe_id, name, location, region, latitude, longitude, ip_address, capabilities, status, last_heartbeat, registered_at, metadata,
+	          COALESCE(labels, '{}'), COALESCE(admin_labels, '{}'),
+	          COALESCE(max_concurrent_tasks, 0), COALESCE(active_tasks, 0), COALESCE(queued_tasks, 0)
 	          FROM probes
 	          WHERE last_heartbeat > ? AND status != 'offline'
 	          ORDER BY name`
@@ -174,6 +201,11 @@ func (d *Database) GetOnlineProbes() ([]*model.Probe, error) {
 			&probe.LastHeartbeat,
 			&probe.RegisteredAt,
 			&probe.Metadata,
+			&probe.Labels,
+			&probe.AdminLabels,
+			&probe.MaxConcurrentTasks,
+			&probe.ActiveTasks,
+			&probe.QueuedTasks,
 		)
 		if err != nil {
 			return nil, err
@@ -184,6 +216,13 @@ func (d *Database) GetOnlineProbes() ([]*model.Probe, error) {
 	return probes, nil
 }
 
+// UpdateProbeAdminLabels 设置后台标签（JSON object）
+func (d *Database) UpdateProbeAdminLabels(probeID, labels string) error {
+	query := `UPDATE probes SET admin_labels = ? WHERE probe_id = ?`
+	_, err := d.db.Exec(query, labels, probeID)
+	return err
+}
+
 // DeleteProbe 删除探针
 func (d *Database) DeleteProbe(probeID string) error {
 	query := `DELETE FROM probes WHERE probe_id = ?`
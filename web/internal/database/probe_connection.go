@@ -0,0 +1,44 @@
+package database
+
+import (
+	"time"
+
+	"atlas/web/internal/model"
+)
+
+// ClaimProbeConnection 记录探针连接到 nodeID；探针从其他节点重连时直接覆盖归属
+func (d *Database) ClaimProbeConnection(probeID, nodeID string, now time.Time) error {
+	query := `INSERT INTO probe_connections (probe_id, node_id, connected_at, heartbeat_at) VALUES (?, ?, ?, ?)
+	          ON CONFLICT(probe_id) DO UPDATE SET node_id = excluded.node_id, connected_at = excluded.connected_at, heartbeat_at = excluded.heartbeat_at`
+	_, err := d.db.Exec(query, probeID, nodeID, now, now)
+	return err
+}
+
+// ReleaseProbeConnection 删除归属记录；只删除仍属于 nodeID 的记录，避免覆盖探针在其他节点上的新连接
+func (d *Database) ReleaseProbeConnection(probeID, nodeID string) error {
+	_, err := d.db.Exec(`DELETE FROM probe_connections WHERE probe_id = ? AND node_id = ?`, probeID, nodeID)
+	return err
+}
+
+// GetProbeConnection 获取探针连接归属，不存在时返回 sql.ErrNoRows
+func (d *Database) GetProbeConnection(probeID string) (*model.ProbeConnection, error) {
+	conn := &model.ProbeConnection{}
+	query := `SELECT probe_id, node_id, connected_at, heartbeat_at FROM probe_connections WHERE probe_id = ?`
+	err := d.db.QueryRow(query, probeID).Scan(&conn.ProbeID, &conn.NodeID, &conn.ConnectedAt, &conn.HeartbeatAt)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// TouchNodeConnections 刷新 nodeID 持有的全部连接的心跳时间
+func (d *Database) TouchNodeConnections(nodeID string, now time.Time) error {
+	_, err := d.db.Exec(`UPDATE probe_connections SET heartbeat_at = ? WHERE node_id = ?`, now, nodeID)
+	return err
+}
+
+// ReleaseNodeConnections 删除 nodeID 持有的全部连接记录，节点启动或退出时调用
+func (d *Database) ReleaseNodeConnections(nodeID string) error {
+	_, err := d.db.Exec(`DELETE FROM probe_connections WHERE node_id = ?`, nodeID)
+	return err
+}
@@ -3,18 +3,21 @@ package database
 import (
 	"database/sql"
 	"fmt"
+	"strings"
+	"time"
 
 	"atlas/web/internal/model"
 )
 
-// SaveResult 保存测试结果
-func (d *Database) SaveResult(result *model.Result) error {
-	query := `
-		INSERT INTO results (result_id, execution_id, task_id, probe_id, target, test_type, status, result_data, summary)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+const insertResultQuery = `
+		INSERT INTO results (result_id, execution_id, task_id, probe_id, target, test_type, status, result_data, summary,
+			avg_latency, packet_loss, http_status, ttfb_ms)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
-	_, err := d.db.Exec(query,
+// SaveResult 保存测试结果
+func (d *Database) SaveResult(result *model.Result) error {
+	_, err := d.db.Exec(insertResultQuery,
 		result.ResultID,
 		result.ExecutionID,
 		result.TaskID,
@@ -24,11 +27,84 @@ func (d *Database) SaveResult(result *model.Result) error {
 		result.Status,
 		result.ResultData,
 		result.Summary,
+		result.AvgLatency,
+		result.PacketLoss,
+		result.HTTPStatus,
+		result.TTFB,
 	)
 
 	return err
 }
 
+// ResultWrite 一条结果及其执行记录的最终状态
+type ResultWrite struct {
+	Execution *model.TaskExecution
+	Result    *model.Result
+	// Stale 由 SaveResults 设置：执行已不在 pending/running 或已转给其它探针，结果照常保存但执行记录未更新
+	Stale bool
+}
+
+// completeExecutionQuery 仅在执行仍由上报探针持有且未结束时写入最终状态，
+// 避免迟到的结果覆盖回收器已写入的 timeout/lost/cancelled
+const completeExecutionQuery = `UPDATE task_executions SET status = ?, completed_at = ?, error = ?, failure_class = ?
+	WHERE execution_id = ? AND probe_id = ? AND status IN ('pending', 'running')`
+
+// SaveResults 在一个事务中批量写入结果：更新执行状态、清除下发待确认记录并插入结果
+func (d *Database) SaveResults(writes []ResultWrite) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	for i := range writes {
+		exec, result := writes[i].Execution, writes[i].Result
+		res, err := tx.Exec(completeExecutionQuery, exec.Status, exec.CompletedAt, exec.Error, exec.FailureClass, exec.ExecutionID, result.ProbeID)
+		if err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("update execution %s: %w", exec.ExecutionID, err)
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("update execution %s: %w", exec.ExecutionID, err)
+		}
+		writes[i].Stale = affected == 0
+		// 收到结果说明探针已收到任务，即使 task_assign_ack 丢失；执行已转给其它探针时保留其待确认记录
+		if !writes[i].Stale {
+			if _, err := tx.Exec(`DELETE FROM dispatch_outbox WHERE execution_id = ?`, exec.ExecutionID); err != nil {
+				_ = tx.Rollback()
+				return fmt.Errorf("clear outbox entry %s: %w", exec.ExecutionID, err)
+			}
+		}
+		if _, err := tx.Exec(insertResultQuery,
+			result.ResultID,
+			result.ExecutionID,
+			result.TaskID,
+			result.ProbeID,
+			result.Target,
+			result.TestType,
+			result.Status,
+			result.ResultData,
+			result.Summary,
+			result.AvgLatency,
+			result.PacketLoss,
+			result.HTTPStatus,
+			result.TTFB,
+		); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("insert result %s: %w", result.ResultID, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UpdateResultEnrichment 写回异步富化后的结果数据与摘要
+func (d *Database) UpdateResultEnrichment(resultID, resultData, summary string) error {
+	_, err := d.db.Exec(`UPDATE results SET result_data = ?, summary = ? WHERE result_id = ?`, resultData, summary, resultID)
+	return err
+}
+
 // GetResult 获取结果详情
 func (d *Database) GetResult(resultID string) (*model.Result, error) {
 	query := `SELECT id, result_id, execution_id, task_id, probe_id, target, test_type, COALESCE(status, 'success') as status, result_data, summary, created_at
@@ -128,6 +204,64 @@ func (d *Database) ListResultsByProbe(probeID string, limit, offset int) ([]*mod
 	return results, nil
 }
 
+// ListRouteResults 列出 traceroute/mtr 结果，用于合并拓扑；target 为空时不过滤目标
+func (d *Database) ListRouteResults(target string, probeIDs []string, from, to *time.Time, limit int) ([]*model.Result, error) {
+	query := `SELECT id, result_id, execution_id, task_id, probe_id, target, test_type, COALESCE(status, 'success') as status, result_data, summary, created_at
+	          FROM results WHERE test_type IN ('traceroute', 'mtr') AND COALESCE(status, 'success') = 'success'`
+
+	args := []interface{}{}
+	if target != "" {
+		query += " AND target = ?"
+		args = append(args, target)
+	}
+	if len(probeIDs) > 0 {
+		query += " AND probe_id IN (?" + strings.Repeat(", ?", len(probeIDs)-1) + ")"
+		for _, probeID := range probeIDs {
+			args = append(args, probeID)
+		}
+	}
+	if from != nil {
+		query += " AND created_at >= ?"
+		args = append(args, d.db.dialect.timeArg(*from))
+	}
+	if to != nil {
+		query += " AND created_at <= ?"
+		args = append(args, d.db.dialect.timeArg(*to))
+	}
+	query += " ORDER BY created_at DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []*model.Result
+	for rows.Next() {
+		result := &model.Result{}
+		err := rows.Scan(
+			&result.ID,
+			&result.ResultID,
+			&result.ExecutionID,
+			&result.TaskID,
+			&result.ProbeID,
+			&result.Target,
+			&result.TestType,
+			&result.Status,
+			&result.ResultData,
+			&result.Summary,
+			&result.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
 // GetConfig 获取配置值
 func (d *Database) GetConfig(key string) (string, error) {
 	var value string
@@ -0,0 +1,259 @@
+package database
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"atlas/web/internal/model"
+)
+
+// 结果列表的排序字段，指标列为空时按 -1 排序，保证游标分页的比较与排序一致
+var resultSortExprs = map[string]string{
+	"created_at":     "created_at",
+	MetricAvgLatency: "COALESCE(avg_latency, -1)",
+	MetricPacketLoss: "COALESCE(packet_loss, -1)",
+	MetricHTTPStatus: "COALESCE(http_status, -1)",
+	MetricTTFB:       "COALESCE(ttfb_ms, -1)",
+}
+
+// IsResultSortField 判断是否为支持的排序字段
+func IsResultSortField(field string) bool {
+	_, ok := resultSortExprs[field]
+	return ok
+}
+
+// 阈值比较运算符，按长度从长到短匹配
+var thresholdOps = []string{">=", "<=", "!=", ">", "<", "="}
+
+// MetricThreshold 摘要指标阈值条件，如 packet_loss>5
+type MetricThreshold struct {
+	Metric string
+	Op     string
+	Value  float64
+}
+
+// ParseMetricThreshold 解析 "<metric><op><value>" 形式的阈值条件
+func ParseMetricThreshold(expr string) (MetricThreshold, error) {
+	expr = strings.TrimSpace(expr)
+	for i := range expr {
+		for _, op := range thresholdOps {
+			if !strings.HasPrefix(expr[i:], op) {
+				continue
+			}
+			metric := strings.TrimSpace(expr[:i])
+			if !IsSeriesMetric(metric) {
+				return MetricThreshold{}, fmt.Errorf("unknown metric in threshold: %q", metric)
+			}
+			value, err := strconv.ParseFloat(strings.TrimSpace(expr[i+len(op):]), 64)
+			if err != nil {
+				return MetricThreshold{}, fmt.Errorf("invalid threshold value in %q", expr)
+			}
+			return MetricThreshold{Metric: metric, Op: op, Value: value}, nil
+		}
+	}
+	return MetricThreshold{}, fmt.Errorf("invalid threshold %q, expected e.g. packet_loss>5", expr)
+}
+
+// ResultCursor 游标分页位置：上一页最后一行的排序值与 id
+type ResultCursor struct {
+	Value string
+	ID    int64
+}
+
+// ResultFilter 结果查询条件，零值字段不过滤
+type ResultFilter struct {
+	TaskID         string
+	ProbeIDs       []string // nil 不过滤；非 nil 的空切片表示没有匹配的探针
+	Target         string   // 精确匹配
+	TargetContains string   // 子串匹配，不区分大小写
+	TestTypes      []string
+	Statuses       []string
+	From           *time.Time
+	To             *time.Time
+	Thresholds     []MetricThreshold
+
+	Sort   string // 见 resultSortExprs，默认 created_at
+	Desc   bool
+	Cursor *ResultCursor // 非空时忽略 Offset
+	Offset int
+	Limit  int
+}
+
+const searchResultColumns = `id, result_id, execution_id, task_id, probe_id, target, test_type, COALESCE(status, 'success') as status,
+	result_data, summary, created_at, avg_latency, packet_loss, http_status, ttfb_ms`
+
+// resultWhere 拼接过滤条件，不含游标
+func (d *Database) resultWhere(f ResultFilter) (string, []interface{}) {
+	conds := []string{"1 = 1"}
+	args := []interface{}{}
+
+	in := func(column string, values []string) {
+		conds = append(conds, column+" IN (?"+strings.Repeat(", ?", len(values)-1)+")")
+		for _, v := range values {
+			args = append(args, v)
+		}
+	}
+
+	if f.TaskID != "" {
+		conds = append(conds, "task_id = ?")
+		args = append(args, f.TaskID)
+	}
+	if f.ProbeIDs != nil {
+		if len(f.ProbeIDs) == 0 {
+			conds = append(conds, "1 = 0")
+		} else {
+			in("probe_id", f.ProbeIDs)
+		}
+	}
+	if f.Target != "" {
+		conds = append(conds, "target = ?")
+		args = append(args, f.Target)
+	}
+	if f.TargetContains != "" {
+		conds = append(conds, `LOWER(target) LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+escapeLike(strings.ToLower(f.TargetContains))+"%")
+	}
+	if len(f.TestTypes) > 0 {
+		in("test_type", f.TestTypes)
+	}
+	if len(f.Statuses) > 0 {
+		in("COALESCE(status, 'success')", f.Statuses)
+	}
+	if f.From != nil {
+		conds = append(conds, "created_at >= ?")
+		args = append(args, d.db.dialect.timeArg(*f.From))
+	}
+	if f.To != nil {
+		conds = append(conds, "created_at <= ?")
+		args = append(args, d.db.dialect.timeArg(*f.To))
+	}
+	for _, th := range f.Thresholds {
+		// 指标与运算符均来自白名单，可直接拼接
+		conds = append(conds, fmt.Sprintf("%s %s ?", metricColumns[th.Metric], th.Op))
+		args = append(args, th.Value)
+	}
+
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// escapeLike 转义 LIKE 通配符
+func escapeLike(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "%", `\%`)
+	return strings.ReplaceAll(s, "_", `\_`)
+}
+
+// CountResults 统计满足条件的结果总数，忽略分页参数
+func (d *Database) CountResults(f ResultFilter) (int, error) {
+	where, args := d.resultWhere(f)
+	var count int
+	err := d.db.QueryRow(`SELECT COUNT(1) FROM results`+where, args...).Scan(&count)
+	return count, err
+}
+
+// SearchResults 按条件、排序与分页列出结果；排序值相同时按 id 排序，保证游标位置唯一
+func (d *Database) SearchResults(f ResultFilter) ([]*model.Result, error) {
+	sort := f.Sort
+	if sort == "" {
+		sort = "created_at"
+	}
+	sortExpr, ok := resultSortExprs[sort]
+	if !ok {
+		return nil, fmt.Errorf("unsupported sort field: %s", sort)
+	}
+
+	where, args := d.resultWhere(f)
+	cmp, order := ">", "ASC"
+	if f.Desc {
+		cmp, order = "<", "DESC"
+	}
+
+	if f.Cursor != nil {
+		var value interface{}
+		if sort == "created_at" {
+			t, err := time.Parse(time.RFC3339Nano, f.Cursor.Value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid cursor")
+			}
+			value = d.db.dialect.timeArg(t)
+		} else {
+			v, err := strconv.ParseFloat(f.Cursor.Value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid cursor")
+			}
+			value = v
+		}
+		where += fmt.Sprintf(" AND (%s %s ? OR (%s = ? AND id %s ?))", sortExpr, cmp, sortExpr, cmp)
+		args = append(args, value, value, f.Cursor.ID)
+	}
+
+	query := `SELECT ` + searchResultColumns + ` FROM results` + where +
+		fmt.Sprintf(" ORDER BY %s %s, id %s LIMIT ?", sortExpr, order, order)
+	args = append(args, f.Limit)
+	if f.Cursor == nil && f.Offset > 0 {
+		query += " OFFSET ?"
+		args = append(args, f.Offset)
+	}
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []*model.Result
+	for rows.Next() {
+		result := &model.Result{}
+		if err := rows.Scan(
+			&result.ID,
+			&result.ResultID,
+			&result.ExecutionID,
+			&result.TaskID,
+			&result.ProbeID,
+			&result.Target,
+			&result.TestType,
+			&result.Status,
+			&result.ResultData,
+			&result.Summary,
+			&result.CreatedAt,
+			&result.AvgLatency,
+			&result.PacketLoss,
+			&result.HTTPStatus,
+			&result.TTFB,
+		); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+// CursorFor 以结果在排序字段上的值生成下一页游标
+func CursorFor(result *model.Result, sort string) ResultCursor {
+	value := func(v *float64) string {
+		if v == nil {
+			return "-1"
+		}
+		return strconv.FormatFloat(*v, 'g', -1, 64)
+	}
+
+	cursor := ResultCursor{ID: result.ID}
+	switch sort {
+	case MetricAvgLatency:
+		cursor.Value = value(result.AvgLatency)
+	case MetricPacketLoss:
+		cursor.Value = value(result.PacketLoss)
+	case MetricTTFB:
+		cursor.Value = value(result.TTFB)
+	case MetricHTTPStatus:
+		cursor.Value = "-1"
+		if result.HTTPStatus != nil {
+			cursor.Value = strconv.Itoa(*result.HTTPStatus)
+		}
+	default:
+		cursor.Value = result.CreatedAt.Format(time.RFC3339Nano)
+	}
+	return cursor
+}
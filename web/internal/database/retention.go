@@ -0,0 +1,227 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"atlas/web/internal/model"
+)
+
+// ResultSeries 同一任务、探针、目标与测试类型的一组结果
+type ResultSeries struct {
+	TaskID   string
+	ProbeID  string
+	Target   string
+	TestType string
+}
+
+// ResultSample 降采样所需的原始结果字段
+type ResultSample struct {
+	Status    string
+	Summary   string
+	CreatedAt time.Time
+}
+
+const resultAggregateColumns = `id, task_id, probe_id, target, test_type, granularity, bucket_start, sample_count, success_count,
+	latency_count, min_latency, avg_latency, max_latency, p95_latency, packet_loss`
+
+const upsertResultAggregateQuery = `
+		INSERT INTO result_aggregates (task_id, probe_id, target, test_type, granularity, bucket_start,
+			sample_count, success_count, latency_count, min_latency, avg_latency, max_latency, p95_latency, packet_loss)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (task_id, probe_id, target, granularity, bucket_start) DO UPDATE SET
+			sample_count = result_aggregates.sample_count + excluded.sample_count,
+			success_count = result_aggregates.success_count + excluded.success_count,
+			latency_count = result_aggregates.latency_count + excluded.latency_count,
+			min_latency = CASE WHEN result_aggregates.min_latency IS NULL OR excluded.min_latency < result_aggregates.min_latency
+				THEN excluded.min_latency ELSE result_aggregates.min_latency END,
+			max_latency = CASE WHEN result_aggregates.max_latency IS NULL OR excluded.max_latency > result_aggregates.max_latency
+				THEN excluded.max_latency ELSE result_aggregates.max_latency END,
+			avg_latency = CASE WHEN result_aggregates.latency_count + excluded.latency_count = 0 THEN NULL
+				ELSE (COALESCE(result_aggregates.avg_latency, 0) * result_aggregates.latency_count + COALESCE(excluded.avg_latency, 0) * excluded.latency_count)
+					/ (result_aggregates.latency_count + excluded.latency_count) END,
+			p95_latency = CASE WHEN result_aggregates.p95_latency IS NULL OR excluded.p95_latency > result_aggregates.p95_latency
+				THEN excluded.p95_latency ELSE result_aggregates.p95_latency END,
+			packet_loss = (COALESCE(result_aggregates.packet_loss, 0) * result_aggregates.sample_count + COALESCE(excluded.packet_loss, 0) * excluded.sample_count)
+				/ (result_aggregates.sample_count + excluded.sample_count)
+	`
+
+// ListDownsampleSeries 列出持续任务中存在早于 before 的 testType 结果的序列
+func (d *Database) ListDownsampleSeries(testType string, before time.Time) ([]ResultSeries, error) {
+	query := `SELECT DISTINCT r.task_id, r.probe_id, r.target FROM results r
+	          JOIN tasks t ON t.task_id = r.task_id
+	          WHERE r.test_type = ? AND t.mode = 'continuous' AND r.created_at < ?`
+
+	rows, err := d.db.Query(query, testType, d.db.dialect.timeArg(before))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var series []ResultSeries
+	for rows.Next() {
+		s := ResultSeries{TestType: testType}
+		if err := rows.Scan(&s.TaskID, &s.ProbeID, &s.Target); err != nil {
+			return nil, err
+		}
+		series = append(series, s)
+	}
+	return series, rows.Err()
+}
+
+// OldestResultTime 序列中最早一条原始结果的时间；没有结果时 ok 为 false
+func (d *Database) OldestResultTime(series ResultSeries) (time.Time, bool, error) {
+	query := `SELECT created_at FROM results
+	          WHERE task_id = ? AND probe_id = ? AND target = ? AND test_type = ?
+	          ORDER BY created_at LIMIT 1`
+
+	var createdAt time.Time
+	err := d.db.QueryRow(query, series.TaskID, series.ProbeID, series.Target, series.TestType).Scan(&createdAt)
+	if err == sql.ErrNoRows {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return createdAt, true, nil
+}
+
+// ListResultSamples 列出序列在 [from, to) 内的原始结果
+func (d *Database) ListResultSamples(series ResultSeries, from, to time.Time) ([]ResultSample, error) {
+	query := `SELECT COALESCE(status, 'success'), COALESCE(summary, ''), created_at FROM results
+	          WHERE task_id = ? AND probe_id = ? AND target = ? AND test_type = ? AND created_at >= ? AND created_at < ?
+	          ORDER BY created_at`
+
+	rows, err := d.db.Query(query, series.TaskID, series.ProbeID, series.Target, series.TestType,
+		d.db.dialect.timeArg(from), d.db.dialect.timeArg(to))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var samples []ResultSample
+	for rows.Next() {
+		var s ResultSample
+		if err := rows.Scan(&s.Status, &s.Summary, &s.CreatedAt); err != nil {
+			return nil, err
+		}
+		samples = append(samples, s)
+	}
+	return samples, rows.Err()
+}
+
+// ReplaceResultsWithAggregates 在一个事务中写入聚合行并删除序列在 [from, to) 内的原始结果，返回删除的行数。
+// 时间桶已有聚合行时按样本数合并，p95 取两者较大值
+func (d *Database) ReplaceResultsWithAggregates(series ResultSeries, from, to time.Time, aggregates []*model.ResultAggregate) (int64, error) {
+	tx, err := d.begin()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, agg := range aggregates {
+		if _, err := tx.Exec(upsertResultAggregateQuery,
+			agg.TaskID,
+			agg.ProbeID,
+			agg.Target,
+			agg.TestType,
+			agg.Granularity,
+			d.db.dialect.timeArg(agg.BucketStart),
+			agg.SampleCount,
+			agg.SuccessCount,
+			agg.LatencyCount,
+			agg.MinLatency,
+			agg.AvgLatency,
+			agg.MaxLatency,
+			agg.P95Latency,
+			agg.PacketLoss,
+		); err != nil {
+			_ = tx.Rollback()
+			return 0, fmt.Errorf("upsert aggregate %s/%s %s: %w", agg.TaskID, agg.ProbeID, agg.BucketStart.Format(time.RFC3339), err)
+		}
+	}
+
+	res, err := tx.Exec(`DELETE FROM results
+		WHERE task_id = ? AND probe_id = ? AND target = ? AND test_type = ? AND created_at >= ? AND created_at < ?`,
+		series.TaskID, series.ProbeID, series.Target, series.TestType,
+		d.db.dialect.timeArg(from), d.db.dialect.timeArg(to))
+	if err != nil {
+		_ = tx.Rollback()
+		return 0, fmt.Errorf("delete downsampled results: %w", err)
+	}
+	deleted, _ := res.RowsAffected()
+
+	return deleted, tx.Commit()
+}
+
+// DeleteResultsBefore 删除早于 before 的 testType 原始结果
+func (d *Database) DeleteResultsBefore(testType string, before time.Time) (int64, error) {
+	res, err := d.exec(`DELETE FROM results WHERE test_type = ? AND created_at < ?`, testType, d.db.dialect.timeArg(before))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// DeleteResultAggregatesBefore 删除时间桶早于 before 的 testType 聚合行
+func (d *Database) DeleteResultAggregatesBefore(testType string, before time.Time) (int64, error) {
+	res, err := d.exec(`DELETE FROM result_aggregates WHERE test_type = ? AND bucket_start < ?`, testType, d.db.dialect.timeArg(before))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// ListResultAggregates 列出序列的聚合行，按时间桶升序
+func (d *Database) ListResultAggregates(taskID, probeID string, from, to time.Time) ([]*model.ResultAggregate, error) {
+	query := `SELECT ` + resultAggregateColumns + ` FROM result_aggregates
+	          WHERE task_id = ? AND probe_id = ? AND bucket_start >= ? AND bucket_start < ?
+	          ORDER BY bucket_start`
+
+	rows, err := d.db.Query(query, taskID, probeID, d.db.dialect.timeArg(from), d.db.dialect.timeArg(to))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanResultAggregates(rows)
+}
+
+// scanResultAggregates 按 resultAggregateColumns 的列顺序读取聚合行
+func scanResultAggregates(rows *sql.Rows) ([]*model.ResultAggregate, error) {
+	var aggregates []*model.ResultAggregate
+	for rows.Next() {
+		agg := &model.ResultAggregate{}
+		if err := rows.Scan(
+			&agg.ID,
+			&agg.TaskID,
+			&agg.ProbeID,
+			&agg.Target,
+			&agg.TestType,
+			&agg.Granularity,
+			&agg.BucketStart,
+			&agg.SampleCount,
+			&agg.SuccessCount,
+			&agg.LatencyCount,
+			&agg.MinLatency,
+			&agg.AvgLatency,
+			&agg.MaxLatency,
+			&agg.P95Latency,
+			&agg.PacketLoss,
+		); err != nil {
+			return nil, err
+		}
+		aggregates = append(aggregates, agg)
+	}
+	return aggregates, rows.Err()
+}
+
+// Compact 回收已删除数据占用的空间：SQLite 执行 checkpoint 与 VACUUM，PostgreSQL 执行 VACUUM ANALYZE
+func (d *Database) Compact() error {
+	for _, stmt := range d.db.dialect.compactStatements() {
+		if _, err := d.db.Exec(stmt); err != nil {
+			return fmt.Errorf("%s: %w", stmt, err)
+		}
+	}
+	return nil
+}
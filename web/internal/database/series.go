@@ -0,0 +1,208 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 时序查询支持的指标
+const (
+	MetricAvgLatency = "avg_latency"
+	MetricPacketLoss = "packet_loss"
+	MetricHTTPStatus = "http_status"
+	MetricTTFB       = "ttfb"
+)
+
+// metricColumns 指标对应 results 表中的数值列
+var metricColumns = map[string]string{
+	MetricAvgLatency: "avg_latency",
+	MetricPacketLoss: "packet_loss",
+	MetricHTTPStatus: "http_status",
+	MetricTTFB:       "ttfb_ms",
+}
+
+// IsSeriesMetric 判断是否为支持的时序指标
+func IsSeriesMetric(metric string) bool {
+	_, ok := metricColumns[metric]
+	return ok
+}
+
+// 支持的聚合方式
+const (
+	AggAvg = "avg"
+	AggMin = "min"
+	AggMax = "max"
+	AggP50 = "p50"
+	AggP95 = "p95"
+	AggP99 = "p99"
+)
+
+// seriesPercentiles 百分位聚合对应的百分数
+var seriesPercentiles = map[string]int{
+	AggP50: 50,
+	AggP95: 95,
+	AggP99: 99,
+}
+
+// IsSeriesAggregation 判断是否为支持的聚合方式
+func IsSeriesAggregation(aggregation string) bool {
+	switch aggregation {
+	case AggAvg, AggMin, AggMax, AggP50, AggP95, AggP99:
+		return true
+	}
+	return false
+}
+
+// SeriesQuery 时序查询条件，From/To 为左闭右开区间，Step 为整数秒
+type SeriesQuery struct {
+	Metric      string
+	Aggregation string
+	ProbeIDs    []string
+	Target      string
+	TaskType    string
+	From        time.Time
+	To          time.Time
+	Step        time.Duration
+}
+
+// SeriesPoint 一个探针、目标在一个时间桶内的聚合值
+type SeriesPoint struct {
+	ProbeID     string
+	Target      string
+	BucketStart time.Time
+	Value       float64
+	Count       int  // 参与聚合的样本数，降采样数据按其原始样本数计
+	Approximate bool // 百分位聚合合并了降采样数据：各聚合行只以一个代表值参与排序，p99 以 p95 代替
+}
+
+// seriesFilter 拼接探针、目标与测试类型过滤条件
+func (d *Database) seriesFilter(q SeriesQuery, timeColumn string) (string, []interface{}) {
+	where := fmt.Sprintf(" AND %s >= ? AND %s < ?", timeColumn, timeColumn)
+	args := []interface{}{d.db.dialect.timeArg(q.From), d.db.dialect.timeArg(q.To)}
+	if q.Target != "" {
+		where += " AND target = ?"
+		args = append(args, q.Target)
+	}
+	if q.TaskType != "" {
+		where += " AND test_type = ?"
+		args = append(args, q.TaskType)
+	}
+	if len(q.ProbeIDs) > 0 {
+		where += " AND probe_id IN (?" + strings.Repeat(", ?", len(q.ProbeIDs)-1) + ")"
+		for _, probeID := range q.ProbeIDs {
+			args = append(args, probeID)
+		}
+	}
+	return where, args
+}
+
+// aggregateSeriesColumns 降采样聚合行在该指标与聚合方式下的代表值列与权重列。
+// 聚合行只有 min/avg/max/p95 与平均丢包，其它指标没有降采样数据；
+// 没有 p99 列，p99 以 p95 代替，数据点标记为近似值
+func aggregateSeriesColumns(metric, aggregation string) (value, weight string, ok bool) {
+	switch metric {
+	case MetricPacketLoss:
+		return "packet_loss", "sample_count", true
+	case MetricAvgLatency:
+		switch aggregation {
+		case AggMin:
+			return "min_latency", "latency_count", true
+		case AggMax:
+			return "max_latency", "latency_count", true
+		case AggP95, AggP99:
+			return "p95_latency", "latency_count", true
+		default:
+			return "avg_latency", "latency_count", true
+		}
+	}
+	return "", "", false
+}
+
+// seriesSamples 区间内按桶标记的加权样本：原始结果权重为 1，
+// 降采样聚合行取代表值并以其样本数为权重，补齐原始结果已被清理的时间段。
+// approx 标记百分位聚合中来自降采样数据的样本，avg/min/max 合并后仍是精确值
+func (d *Database) seriesSamples(q SeriesQuery) (string, []interface{}) {
+	column := metricColumns[q.Metric]
+	where, args := d.seriesFilter(q, "created_at")
+	query := `SELECT probe_id, target, ` + d.db.dialect.timeBucket("created_at", q.Step) + ` AS bucket,
+	                 CAST(` + column + ` AS DOUBLE PRECISION) AS value, 1 AS weight, 0 AS approx
+	          FROM results WHERE ` + column + ` IS NOT NULL` + where
+
+	if value, weight, ok := aggregateSeriesColumns(q.Metric, q.Aggregation); ok {
+		approx := "0"
+		if _, percentile := seriesPercentiles[q.Aggregation]; percentile {
+			approx = "1"
+		}
+		aggWhere, aggArgs := d.seriesFilter(q, "bucket_start")
+		query += `
+	          UNION ALL
+	          SELECT probe_id, target, ` + d.db.dialect.timeBucket("bucket_start", q.Step) + `, ` + value + `, ` + weight + `, ` + approx + `
+	          FROM result_aggregates WHERE ` + value + ` IS NOT NULL AND ` + weight + ` > 0` + aggWhere
+		args = append(args, aggArgs...)
+	}
+	return query, args
+}
+
+// ListSeriesPoints 在数据库中按 Step 分桶聚合指标，按探针、目标与时间排序返回。
+// 百分位取累计权重首次达到 p% 的样本(最近秩法)，与降采样数据合并时为近似值并置 Approximate。
+// 结果超过 limit 个数据点时只返回前 limit 个并置 truncated，由调用方拒绝不完整的序列
+func (d *Database) ListSeriesPoints(q SeriesQuery, limit int) (points []SeriesPoint, truncated bool, err error) {
+	if _, ok := metricColumns[q.Metric]; !ok {
+		return nil, false, fmt.Errorf("unsupported metric: %s", q.Metric)
+	}
+	if !IsSeriesAggregation(q.Aggregation) {
+		return nil, false, fmt.Errorf("unsupported aggregation: %s", q.Aggregation)
+	}
+	if q.Step < time.Second || q.Step%time.Second != 0 {
+		return nil, false, fmt.Errorf("step must be a whole number of seconds")
+	}
+
+	samples, args := d.seriesSamples(q)
+	var query string
+	if p, ok := seriesPercentiles[q.Aggregation]; ok {
+		query = `SELECT probe_id, target, bucket, MIN(value), MAX(total), MAX(approx) FROM (
+		             SELECT probe_id, target, bucket, value,
+		                    SUM(weight) OVER (PARTITION BY probe_id, target, bucket ORDER BY value ROWS UNBOUNDED PRECEDING) AS seen,
+		                    SUM(weight) OVER (PARTITION BY probe_id, target, bucket) AS total,
+		                    MAX(approx) OVER (PARTITION BY probe_id, target, bucket) AS approx
+		             FROM (` + samples + `) samples
+		         ) ranked
+		         WHERE seen * 100 >= total * ` + fmt.Sprint(p)
+	} else {
+		value := "SUM(value * weight) / SUM(weight)"
+		switch q.Aggregation {
+		case AggMin:
+			value = "MIN(value)"
+		case AggMax:
+			value = "MAX(value)"
+		}
+		query = `SELECT probe_id, target, bucket, ` + value + `, SUM(weight), MAX(approx) FROM (` + samples + `) samples`
+	}
+	query += ` GROUP BY probe_id, target, bucket ORDER BY probe_id, target, bucket LIMIT ?`
+	args = append(args, limit+1)
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p SeriesPoint
+		var bucket, approx int64
+		if err := rows.Scan(&p.ProbeID, &p.Target, &bucket, &p.Value, &p.Count, &approx); err != nil {
+			return nil, false, err
+		}
+		p.BucketStart = time.Unix(bucket, 0).UTC()
+		p.Approximate = approx > 0
+		points = append(points, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+	if len(points) > limit {
+		return points[:limit], true, nil
+	}
+	return points, false, nil
+}
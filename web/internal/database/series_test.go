@@ -0,0 +1,109 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"atlas/web/internal/model"
+)
+
+// seedSeriesResult 写入一条带延迟的原始结果并把 created_at 改为指定时间
+func seedSeriesResult(t *testing.T, db *Database, resultID, target string, latency float64, createdAt time.Time) {
+	t.Helper()
+
+	if err := db.SaveExecution(&model.TaskExecution{ExecutionID: resultID, TaskID: "task-series", ProbeID: "probe-1", Status: "success", StartedAt: createdAt}); err != nil {
+		t.Fatalf("SaveExecution failed: %v", err)
+	}
+	if err := db.SaveResult(&model.Result{
+		ResultID:    resultID,
+		ExecutionID: resultID,
+		TaskID:      "task-series",
+		ProbeID:     "probe-1",
+		Target:      target,
+		TestType:    "icmp_ping",
+		Status:      "success",
+		ResultData:  "{}",
+		Summary:     "{}",
+		AvgLatency:  &latency,
+	}); err != nil {
+		t.Fatalf("SaveResult failed: %v", err)
+	}
+	if _, err := db.db.Exec(`UPDATE results SET created_at = ? WHERE result_id = ?`, db.db.dialect.timeArg(createdAt), resultID); err != nil {
+		t.Fatalf("update created_at failed: %v", err)
+	}
+}
+
+func TestListSeriesPointsAggregatesInDatabase(t *testing.T) {
+	db := newTestDatabase(t)
+	seedTestProbe(t, db, "probe-1")
+	if err := db.CreateTask(&model.Task{TaskID: "task-series", TaskType: "icmp_ping", Mode: "continuous", Target: "a", Status: "running", Priority: 5}); err != nil {
+		t.Fatalf("CreateTask failed: %v", err)
+	}
+
+	hour := time.Date(2026, 10, 1, 13, 0, 0, 0, time.UTC)
+	seedSeriesResult(t, db, "a-1", "a", 10, hour.Add(5*time.Minute))
+	seedSeriesResult(t, db, "a-2", "a", 30, hour.Add(50*time.Minute))
+	seedSeriesResult(t, db, "b-1", "b", 5, hour.Add(10*time.Minute))
+	seedSeriesResult(t, db, "b-2", "b", 1, hour.Add(20*time.Minute))
+
+	// 降采样的一小时：a 有 3 个带延迟的样本，b 在下一小时有 98 个
+	avg, p95, three := 20.0, 50.0, 3.0
+	for _, agg := range []*model.ResultAggregate{
+		{TaskID: "task-series", ProbeID: "probe-1", Target: "a", TestType: "icmp_ping", Granularity: "hour", BucketStart: hour.Add(-time.Hour), SampleCount: 3, LatencyCount: 3, AvgLatency: &avg, P95Latency: &p95},
+		{TaskID: "task-series", ProbeID: "probe-1", Target: "b", TestType: "icmp_ping", Granularity: "hour", BucketStart: hour.Add(-time.Hour), SampleCount: 98, LatencyCount: 98, AvgLatency: &three, P95Latency: &three},
+	} {
+		series := ResultSeries{TaskID: agg.TaskID, ProbeID: agg.ProbeID, Target: agg.Target, TestType: agg.TestType}
+		if _, err := db.ReplaceResultsWithAggregates(series, agg.BucketStart, agg.BucketStart.Add(time.Hour), []*model.ResultAggregate{agg}); err != nil {
+			t.Fatalf("ReplaceResultsWithAggregates failed: %v", err)
+		}
+	}
+
+	query := SeriesQuery{Metric: MetricAvgLatency, Target: "a", From: hour.Add(-2 * time.Hour), To: hour.Add(2 * time.Hour)}
+	point := func(aggregation string, step time.Duration) []SeriesPoint {
+		t.Helper()
+		q := query
+		q.Aggregation, q.Step = aggregation, step
+		points, truncated, err := db.ListSeriesPoints(q, 100)
+		if err != nil || truncated {
+			t.Fatalf("%s: ListSeriesPoints failed: truncated=%v err=%v", aggregation, truncated, err)
+		}
+		return points
+	}
+
+	// (10 + 30 + 20*3) / 5，2 小时桶对齐到 12:00
+	points := point(AggAvg, 2*time.Hour)
+	if len(points) != 1 || !points[0].BucketStart.Equal(hour.Add(-time.Hour)) || points[0].Count != 5 || points[0].Value != 20 || points[0].Approximate {
+		t.Fatalf("unexpected weighted 2h bucket: %+v", points)
+	}
+
+	points = point(AggP95, time.Hour)
+	if len(points) != 2 || points[0].Value != 50 || points[1].Value != 30 {
+		t.Fatalf("unexpected hourly p95 points: %+v", points)
+	}
+	// 只有合并了降采样数据的桶标记为近似值
+	if !points[0].Approximate || points[1].Approximate {
+		t.Fatalf("expected only the downsampled bucket to be approximate: %+v", points)
+	}
+
+	// 最近秩法：5、1 与权重 98 的 3 合并后 p99、p50 都取 3
+	query.Target = "b"
+	for _, aggregation := range []string{AggP99, AggP50} {
+		points = point(aggregation, 4*time.Hour)
+		if len(points) != 1 || points[0].Value != 3 || points[0].Count != 100 || !points[0].Approximate {
+			t.Fatalf("%s: unexpected weighted percentile: %+v", aggregation, points)
+		}
+	}
+
+	// http_status 没有降采样数据，也没有原始值
+	query.Metric, query.Target = MetricHTTPStatus, ""
+	if points = point(AggMax, time.Hour); len(points) != 0 {
+		t.Fatalf("expected aggregates to be ignored for http_status, got %+v", points)
+	}
+
+	// 超过上限时明确标记截断
+	q := SeriesQuery{Metric: MetricAvgLatency, Aggregation: AggAvg, From: query.From, To: query.To, Step: time.Hour}
+	points, truncated, err := db.ListSeriesPoints(q, 3)
+	if err != nil || !truncated || len(points) != 3 {
+		t.Fatalf("expected 3 points flagged as truncated, got %d truncated=%v err=%v", len(points), truncated, err)
+	}
+}
@@ -0,0 +1,116 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"atlas/web/internal/model"
+)
+
+// CreateTargetGroup 创建目标组
+func (d *Database) CreateTargetGroup(group *model.TargetGroup) error {
+	targetsJSON, _ := json.Marshal(group.Targets)
+	query := `INSERT INTO target_groups (group_id, name, description, targets, created_at, updated_at)
+	          VALUES (?, ?, ?, ?, ?, ?)`
+
+	id, err := d.db.Insert(query,
+		group.GroupID,
+		group.Name,
+		group.Description,
+		string(targetsJSON),
+		group.CreatedAt,
+		group.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	group.ID = id
+	return nil
+}
+
+// UpdateTargetGroup 更新目标组的名称、描述与成员
+func (d *Database) UpdateTargetGroup(group *model.TargetGroup) error {
+	targetsJSON, _ := json.Marshal(group.Targets)
+	query := `UPDATE target_groups SET name = ?, description = ?, targets = ?, updated_at = ? WHERE group_id = ?`
+	_, err := d.db.Exec(query, group.Name, group.Description, string(targetsJSON), group.UpdatedAt, group.GroupID)
+	return err
+}
+
+// GetTargetGroup 获取目标组，不存在时返回 sql.ErrNoRows
+func (d *Database) GetTargetGroup(groupID string) (*model.TargetGroup, error) {
+	query := `SELECT id, group_id, name, COALESCE(description, ''), targets, created_at, updated_at
+	          FROM target_groups WHERE group_id = ?`
+	return scanTargetGroup(d.db.QueryRow(query, groupID))
+}
+
+// ListTargetGroups 列出所有目标组
+func (d *Database) ListTargetGroups() ([]*model.TargetGroup, error) {
+	query := `SELECT id, group_id, name, COALESCE(description, ''), targets, created_at, updated_at
+	          FROM target_groups ORDER BY name ASC`
+
+	rows, err := d.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := make([]*model.TargetGroup, 0)
+	for rows.Next() {
+		group, err := scanTargetGroup(rows)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+	return groups, rows.Err()
+}
+
+// DeleteTargetGroup 删除目标组
+func (d *Database) DeleteTargetGroup(groupID string) error {
+	result, err := d.db.Exec(`DELETE FROM target_groups WHERE group_id = ?`, groupID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func scanTargetGroup(row rowScanner) (*model.TargetGroup, error) {
+	group := &model.TargetGroup{}
+	var targetsJSON string
+	if err := row.Scan(
+		&group.ID,
+		&group.GroupID,
+		&group.Name,
+		&group.Description,
+		&targetsJSON,
+		&group.CreatedAt,
+		&group.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	group.Targets = []string{}
+	_ = json.Unmarshal([]byte(targetsJSON), &group.Targets)
+	return group, nil
+}
+
+// TargetGroupMembers 读取目标组成员，供多目标任务展开
+func (d *Database) TargetGroupMembers(groupID string) ([]string, error) {
+	group, err := d.GetTargetGroup(groupID)
+	if err != nil {
+		return nil, err
+	}
+	return group.Targets, nil
+}
+
+// CountActiveTasksUsingTargetGroup 统计引用目标组且尚未结束的任务数
+func (d *Database) CountActiveTasksUsingTargetGroup(groupID string) (int, error) {
+	var count int
+	query := `SELECT COUNT(1) FROM tasks
+	          WHERE targets IS NOT NULL AND targets != '' AND ` + d.db.dialect.jsonText("targets", "group") + ` = ?
+	          AND status NOT IN ('completed', 'failed', 'cancelled')`
+	err := d.db.QueryRow(query, groupID).Scan(&count)
+	return count, err
+}
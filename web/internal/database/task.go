@@ -11,11 +11,12 @@ import (
 // CreateTask 创建新任务
 func (d *Database) CreateTask(task *model.Task) error {
 	query := `
-		INSERT INTO tasks (task_id, task_type, mode, target, parameters, assigned_probes, status, schedule, priority, next_run_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO tasks (task_id, task_type, mode, target, parameters, assigned_probes, status, schedule, priority, next_run_at, retry_policy, selector, sampling, targets, rerun_of, template_id, template_version,
+			workflow, parent_task_id, parent_execution_id, workflow_step)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
-	_, err := d.db.Exec(query,
+	_, err := d.exec(query,
 		task.TaskID,
 		task.TaskType,
 		task.Mode,
@@ -26,6 +27,17 @@ func (d *Database) CreateTask(task *model.Task) error {
 		task.Schedule,
 		task.Priority,
 		task.NextRunAt,
+		task.RetryPolicy,
+		task.Selector,
+		task.Sampling,
+		task.Targets,
+		task.RerunOf,
+		task.TemplateID,
+		task.TemplateVersion,
+		task.Workflow,
+		task.ParentTaskID,
+		task.ParentExecutionID,
+		task.WorkflowStep,
 	)
 
 	return err
@@ -33,27 +45,9 @@ func (d *Database) CreateTask(task *model.Task) error {
 
 // GetTask 获取任务详情
 func (d *Database) GetTask(taskID string) (*model.Task, error) {
-	query := `SELECT id, task_id, task_type, mode, target, parameters, assigned_probes, status, schedule, priority,
-	          created_at, started_at, completed_at, next_run_at
-	          FROM tasks WHERE task_id = ?`
+	query := `SELECT ` + taskColumns + ` FROM tasks WHERE task_id = ?`
 
-	task := &model.Task{}
-	err := d.db.QueryRow(query, taskID).Scan(
-		&task.ID,
-		&task.TaskID,
-		&task.TaskType,
-		&task.Mode,
-		&task.Target,
-		&task.Parameters,
-		&task.AssignedProbes,
-		&task.Status,
-		&task.Schedule,
-		&task.Priority,
-		&task.CreatedAt,
-		&task.StartedAt,
-		&task.CompletedAt,
-		&task.NextRunAt,
-	)
+	task, err := scanTask(d.db.QueryRow(query, taskID))
 
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("task not found")
@@ -64,9 +58,7 @@ func (d *Database) GetTask(taskID string) (*model.Task, error) {
 
 // ListTasks 列出任务
 func (d *Database) ListTasks(status string, limit, offset int) ([]*model.Task, error) {
-	query := `SELECT id, task_id, task_type, mode, target, parameters, assigned_probes, status, schedule, priority,
-	          created_at, started_at, completed_at, next_run_at
-	          FROM tasks`
+	query := `SELECT ` + taskColumns + ` FROM tasks`
 
 	args := []interface{}{}
 	if status != "" {
@@ -85,23 +77,7 @@ func (d *Database) ListTasks(status string, limit, offset int) ([]*model.Task, e
 
 	var tasks []*model.Task
 	for rows.Next() {
-		task := &model.Task{}
-		err := rows.Scan(
-			&task.ID,
-			&task.TaskID,
-			&task.TaskType,
-			&task.Mode,
-			&task.Target,
-			&task.Parameters,
-			&task.AssignedProbes,
-			&task.Status,
-			&task.Schedule,
-			&task.Priority,
-			&task.CreatedAt,
-			&task.StartedAt,
-			&task.CompletedAt,
-			&task.NextRunAt,
-		)
+		task, err := scanTask(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -111,32 +87,116 @@ func (d *Database) ListTasks(status string, limit, offset int) ([]*model.Task, e
 	return tasks, nil
 }
 
-// UpdateTask 更新任务
+// CountTasks 统计任务总数，status 为空时不过滤
+func (d *Database) CountTasks(status string) (int, error) {
+	query := `SELECT COUNT(1) FROM tasks`
+	args := []interface{}{}
+	if status != "" {
+		query += " WHERE status = ?"
+		args = append(args, status)
+	}
+
+	var count int
+	err := d.db.QueryRow(query, args...).Scan(&count)
+	return count, err
+}
+
+// UpdateTask 更新任务；已暂停的任务不会被改回 pending/running，避免调度器用旧状态覆盖暂停
 func (d *Database) UpdateTask(task *model.Task) error {
-	query := `UPDATE tasks SET status = ?, started_at = ?, completed_at = ?, next_run_at = ?, schedule = ? WHERE task_id = ?`
-	_, err := d.db.Exec(query, task.Status, task.StartedAt, task.CompletedAt, task.NextRunAt, task.Schedule, task.TaskID)
+	query := `UPDATE tasks SET status = CASE WHEN status = 'paused' AND ? IN ('pending', 'running') THEN status ELSE ? END,
+	          started_at = ?, completed_at = ?, next_run_at = ?, schedule = ? WHERE task_id = ?`
+	_, err := d.exec(query, task.Status, task.Status, task.StartedAt, task.CompletedAt, task.NextRunAt, task.Schedule, task.TaskID)
 	return err
 }
 
+// PauseTask 暂停 pending/running 的任务，任务不处于这两种状态时返回 sql.ErrNoRows
+func (d *Database) PauseTask(taskID string) error {
+	result, err := d.exec(`UPDATE tasks SET status = 'paused' WHERE task_id = ? AND status IN ('pending', 'running')`, taskID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ResumeTask 按 task 中的状态与运行时间恢复已暂停的任务，任务未暂停时返回 sql.ErrNoRows
+func (d *Database) ResumeTask(task *model.Task) error {
+	query := `UPDATE tasks SET status = ?, next_run_at = ?, completed_at = ? WHERE task_id = ? AND status = 'paused'`
+	result, err := d.exec(query, task.Status, task.NextRunAt, task.CompletedAt, task.TaskID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
 // UpdateTaskStatus 更新任务状态
 func (d *Database) UpdateTaskStatus(taskID, status string) error {
 	query := `UPDATE tasks SET status = ? WHERE task_id = ?`
-	_, err := d.db.Exec(query, status, taskID)
+	_, err := d.exec(query, status, taskID)
+	return err
+}
+
+// UpdateTaskResolvedProbes 记录按抽样策略实际选中的探针
+func (d *Database) UpdateTaskResolvedProbes(taskID, resolvedProbes string) error {
+	query := `UPDATE tasks SET resolved_probes = ? WHERE task_id = ?`
+	_, err := d.exec(query, resolvedProbes, taskID)
 	return err
 }
 
 // DeleteTask 删除任务
 func (d *Database) DeleteTask(taskID string) error {
 	query := `DELETE FROM tasks WHERE task_id = ?`
-	_, err := d.db.Exec(query, taskID)
+	_, err := d.exec(query, taskID)
 	return err
 }
 
+// ListFollowUpTasks 列出由任务工作流触发的后续任务
+func (d *Database) ListFollowUpTasks(parentTaskID string) ([]*model.Task, error) {
+	query := `SELECT ` + taskColumns + ` FROM tasks WHERE parent_task_id = ? ORDER BY id ASC`
+
+	rows, err := d.db.Query(query, parentTaskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tasks := make([]*model.Task, 0)
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, rows.Err()
+}
+
+// LastFollowUpAt 返回某步骤最近一次因 (探针, 目标) 上的结果触发的时间，从未触发时返回 nil
+func (d *Database) LastFollowUpAt(parentTaskID, step, probeID, target string) (*time.Time, error) {
+	query := `SELECT t.created_at FROM tasks t
+	          JOIN task_executions e ON e.execution_id = t.parent_execution_id
+	          WHERE t.parent_task_id = ? AND t.workflow_step = ? AND e.probe_id = ? AND COALESCE(e.target, '') = ?
+	          ORDER BY t.id DESC LIMIT 1`
+
+	var createdAt time.Time
+	err := d.db.QueryRow(query, parentTaskID, step, probeID, target).Scan(&createdAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &createdAt, nil
+}
+
 // GetPendingTasks 获取待执行的任务
 func (d *Database) GetPendingTasks() ([]*model.Task, error) {
-	query := `SELECT id, task_id, task_type, mode, target, parameters, assigned_probes, status, schedule, priority,
-	          created_at, started_at, completed_at, next_run_at
-	          FROM tasks
+	query := `SELECT ` + taskColumns + ` FROM tasks
 	          WHERE status = 'pending' AND mode != 'continuous'
 	          ORDER BY priority DESC, created_at ASC`
 
@@ -148,23 +208,7 @@ func (d *Database) GetPendingTasks() ([]*model.Task, error) {
 
 	var tasks []*model.Task
 	for rows.Next() {
-		task := &model.Task{}
-		err := rows.Scan(
-			&task.ID,
-			&task.TaskID,
-			&task.TaskType,
-			&task.Mode,
-			&task.Target,
-			&task.Parameters,
-			&task.AssignedProbes,
-			&task.Status,
-			&task.Schedule,
-			&task.Priority,
-			&task.CreatedAt,
-			&task.StartedAt,
-			&task.CompletedAt,
-			&task.NextRunAt,
-		)
+		task, err := scanTask(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -176,9 +220,7 @@ func (d *Database) GetPendingTasks() ([]*model.Task, error) {
 
 // GetDueContinuousTasks 获取应该执行的持续任务
 func (d *Database) GetDueContinuousTasks(now time.Time) ([]*model.Task, error) {
-	query := `SELECT id, task_id, task_type, mode, target, parameters, assigned_probes, status, schedule, priority,
-	          created_at, started_at, completed_at, next_run_at
-	          FROM tasks
+	query := `SELECT ` + taskColumns + ` FROM tasks
 	          WHERE mode = 'continuous' AND next_run_at <= ?
 	          AND (status = 'running' OR status = 'pending')
 	          ORDER BY priority DESC`
@@ -191,23 +233,7 @@ func (d *Database) GetDueContinuousTasks(now time.Time) ([]*model.Task, error) {
 
 	var tasks []*model.Task
 	for rows.Next() {
-		task := &model.Task{}
-		err := rows.Scan(
-			&task.ID,
-			&task.TaskID,
-			&task.TaskType,
-			&task.Mode,
-			&task.Target,
-			&task.Parameters,
-			&task.AssignedProbes,
-			&task.Status,
-			&task.Schedule,
-			&task.Priority,
-			&task.CreatedAt,
-			&task.StartedAt,
-			&task.CompletedAt,
-			&task.NextRunAt,
-		)
+		task, err := scanTask(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -219,15 +245,29 @@ func (d *Database) GetDueContinuousTasks(now time.Time) ([]*model.Task, error) {
 
 // SaveExecution 保存任务执行记录
 func (d *Database) SaveExecution(execution *model.TaskExecution) error {
-	query := `INSERT INTO task_executions (execution_id, task_id, probe_id, status, started_at)
-	          VALUES (?, ?, ?, ?, ?)`
+	if execution.Attempt <= 0 {
+		execution.Attempt = 1
+	}
+
+	var runNumber interface{}
+	if execution.RunNumber > 0 {
+		runNumber = execution.RunNumber
+	}
+
+	query := `INSERT INTO task_executions (execution_id, task_id, probe_id, target, status, started_at, attempt, retry_of, not_before, run_number)
+	          VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
-	_, err := d.db.Exec(query,
+	_, err := d.exec(query,
 		execution.ExecutionID,
 		execution.TaskID,
 		execution.ProbeID,
+		execution.Target,
 		execution.Status,
 		execution.StartedAt,
+		execution.Attempt,
+		execution.RetryOf,
+		execution.NotBefore,
+		runNumber,
 	)
 
 	return err
@@ -235,41 +275,140 @@ func (d *Database) SaveExecution(execution *model.TaskExecution) error {
 
 // UpdateExecution 更新执行记录
 func (d *Database) UpdateExecution(execution *model.TaskExecution) error {
-	query := `UPDATE task_executions SET status = ?, completed_at = ?, error = ? WHERE execution_id = ?`
-	_, err := d.db.Exec(query, execution.Status, execution.CompletedAt, execution.Error, execution.ExecutionID)
+	_, err := d.exec(updateExecutionQuery, execution.Status, execution.CompletedAt, execution.Error, execution.FailureClass, execution.ExecutionID)
 	return err
 }
 
+const updateExecutionQuery = `UPDATE task_executions SET status = ?, completed_at = ?, error = ?, failure_class = ? WHERE execution_id = ?`
+
 // GetExecution 获取执行记录
 func (d *Database) GetExecution(executionID string) (*model.TaskExecution, error) {
-	query := `SELECT id, execution_id, task_id, probe_id, status, started_at, completed_at, error
-	          FROM task_executions WHERE execution_id = ?`
+	query := `SELECT ` + executionColumns + ` FROM task_executions WHERE execution_id = ?`
+
+	execution, err := scanExecution(d.db.QueryRow(query, executionID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("execution not found")
+	}
+
+	return execution, err
+}
+
+// ListExecutionsByTask 列出任务的所有执行记录
+func (d *Database) ListExecutionsByTask(taskID string) ([]*model.TaskExecution, error) {
+	query := `SELECT ` + executionColumns + ` FROM task_executions WHERE task_id = ? ORDER BY started_at DESC`
+	return d.queryExecutions(query, taskID)
+}
 
+// ListActiveExecutions 列出 pending/running 的执行记录；probeID 为空时不过滤
+func (d *Database) ListActiveExecutions(probeID string) ([]*model.TaskExecution, error) {
+	query := `SELECT ` + executionColumns + ` FROM task_executions WHERE status IN ('pending', 'running')`
+	args := []interface{}{}
+	if probeID != "" {
+		query += " AND probe_id = ?"
+		args = append(args, probeID)
+	}
+	query += " ORDER BY started_at ASC"
+	return d.queryExecutions(query, args...)
+}
+
+// CountActiveExecutionsByTask 统计任务中 pending/running 的执行数
+func (d *Database) CountActiveExecutionsByTask(taskID string) (int, error) {
+	var count int
+	query := `SELECT COUNT(1) FROM task_executions WHERE task_id = ? AND status IN ('pending', 'running')`
+	err := d.db.QueryRow(query, taskID).Scan(&count)
+	return count, err
+}
+
+// CountRunningExecutions 统计已下发到探针且尚未回报结果的执行数
+func (d *Database) CountRunningExecutions(probeID string) (int, error) {
+	var count int
+	query := `SELECT COUNT(1) FROM task_executions WHERE status = 'running' AND probe_id = ?`
+	err := d.db.QueryRow(query, probeID).Scan(&count)
+	return count, err
+}
+
+// ReassignExecution 下发重试执行前设置其探针并重置开始时间
+func (d *Database) ReassignExecution(executionID, probeID string, startedAt time.Time) error {
+	query := `UPDATE task_executions SET probe_id = ?, started_at = ? WHERE execution_id = ?`
+	_, err := d.exec(query, probeID, startedAt, executionID)
+	return err
+}
+
+const taskColumns = `id, task_id, task_type, mode, target, parameters, assigned_probes, status, schedule, priority,
+	created_at, started_at, completed_at, next_run_at, COALESCE(retry_policy, ''), COALESCE(selector, ''),
+	COALESCE(sampling, ''), COALESCE(resolved_probes, ''), COALESCE(targets, ''), rerun_of, template_id, template_version, COALESCE(revision, 0),
+	COALESCE(workflow, ''), parent_task_id, parent_execution_id, workflow_step`
+
+const executionColumns = `id, execution_id, task_id, probe_id, COALESCE(target, ''), status, started_at, completed_at, error,
+	COALESCE(attempt, 1), retry_of, failure_class, not_before, COALESCE(run_number, 0)`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanTask(row rowScanner) (*model.Task, error) {
+	task := &model.Task{}
+	err := row.Scan(
+		&task.ID,
+		&task.TaskID,
+		&task.TaskType,
+		&task.Mode,
+		&task.Target,
+		&task.Parameters,
+		&task.AssignedProbes,
+		&task.Status,
+		&task.Schedule,
+		&task.Priority,
+		&task.CreatedAt,
+		&task.StartedAt,
+		&task.CompletedAt,
+		&task.NextRunAt,
+		&task.RetryPolicy,
+		&task.Selector,
+		&task.Sampling,
+		&task.ResolvedProbes,
+		&task.Targets,
+		&task.RerunOf,
+		&task.TemplateID,
+		&task.TemplateVersion,
+		&task.Revision,
+		&task.Workflow,
+		&task.ParentTaskID,
+		&task.ParentExecutionID,
+		&task.WorkflowStep,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+func scanExecution(row rowScanner) (*model.TaskExecution, error) {
 	execution := &model.TaskExecution{}
-	err := d.db.QueryRow(query, executionID).Scan(
+	err := row.Scan(
 		&execution.ID,
 		&execution.ExecutionID,
 		&execution.TaskID,
 		&execution.ProbeID,
+		&execution.Target,
 		&execution.Status,
 		&execution.StartedAt,
 		&execution.CompletedAt,
 		&execution.Error,
+		&execution.Attempt,
+		&execution.RetryOf,
+		&execution.FailureClass,
+		&execution.NotBefore,
+		&execution.RunNumber,
 	)
-
-	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("execution not found")
+	if err != nil {
+		return nil, err
 	}
-
-	return execution, err
+	return execution, nil
 }
 
-// ListExecutionsByTask 列出任务的所有执行记录
-func (d *Database) ListExecutionsByTask(taskID string) ([]*model.TaskExecution, error) {
-	query := `SELECT id, execution_id, task_id, probe_id, status, started_at, completed_at, error
-	          FROM task_executions WHERE task_id = ? ORDER BY started_at DESC`
-
-	rows, err := d.db.Query(query, taskID)
+func (d *Database) queryExecutions(query string, args ...interface{}) ([]*model.TaskExecution, error) {
+	rows, err := d.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -277,22 +416,12 @@ func (d *Database) ListExecutionsByTask(taskID string) ([]*model.TaskExecution,
 
 	var executions []*model.TaskExecution
 	for rows.Next() {
-		execution := &model.TaskExecution{}
-		err := rows.Scan(
-			&execution.ID,
-			&execution.ExecutionID,
-			&execution.TaskID,
-			&execution.ProbeID,
-			&execution.Status,
-			&execution.StartedAt,
-			&execution.CompletedAt,
-			&execution.Error,
-		)
+		execution, err := scanExecution(rows)
 		if err != nil {
 			return nil, err
 		}
 		executions = append(executions, execution)
 	}
 
-	return executions, nil
+	return executions, rows.Err()
 }
@@ -0,0 +1,90 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"atlas/web/internal/model"
+)
+
+// UpdateTaskDefinition 保存任务的可编辑字段并记录一次修订；task.Revision 需已递增。
+// 任务在此期间被其他请求编辑过时返回 sql.ErrNoRows
+func (d *Database) UpdateTaskDefinition(task *model.Task, revision *model.TaskRevision) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE tasks SET parameters = ?, assigned_probes = ?, selector = ?, sampling = ?, priority = ?,
+	          schedule = ?, next_run_at = ?, workflow = ?, revision = ?
+	          WHERE task_id = ? AND COALESCE(revision, 0) = ?`
+	result, err := tx.Exec(query,
+		task.Parameters,
+		task.AssignedProbes,
+		task.Selector,
+		task.Sampling,
+		task.Priority,
+		task.Schedule,
+		task.NextRunAt,
+		task.Workflow,
+		task.Revision,
+		task.TaskID,
+		task.Revision-1,
+	)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		_ = tx.Rollback()
+		return sql.ErrNoRows
+	}
+
+	changedJSON, _ := json.Marshal(revision.ChangedFields)
+	insert := `INSERT INTO task_revisions (task_id, revision, changed_fields, previous, current, created_at)
+	           VALUES (?, ?, ?, ?, ?, ?)`
+	if _, err := tx.Exec(insert,
+		revision.TaskID,
+		revision.Revision,
+		string(changedJSON),
+		revision.Previous,
+		revision.Current,
+		revision.CreatedAt,
+	); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// ListTaskRevisions 列出任务的编辑记录，新修订在前
+func (d *Database) ListTaskRevisions(taskID string) ([]*model.TaskRevision, error) {
+	query := `SELECT task_id, revision, changed_fields, previous, current, created_at
+	          FROM task_revisions WHERE task_id = ? ORDER BY revision DESC`
+
+	rows, err := d.db.Query(query, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := make([]*model.TaskRevision, 0)
+	for rows.Next() {
+		revision := &model.TaskRevision{}
+		var changedJSON string
+		if err := rows.Scan(
+			&revision.TaskID,
+			&revision.Revision,
+			&changedJSON,
+			&revision.Previous,
+			&revision.Current,
+			&revision.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		revision.ChangedFields = []string{}
+		_ = json.Unmarshal([]byte(changedJSON), &revision.ChangedFields)
+		revisions = append(revisions, revision)
+	}
+	return revisions, rows.Err()
+}
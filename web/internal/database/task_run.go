@@ -0,0 +1,41 @@
+package database
+
+import (
+	"encoding/json"
+
+	"atlas/web/internal/model"
+)
+
+// SaveTaskRun 记录一轮运行选中的探针集合；同一轮重新分配（如上次没有可用探针）时覆盖
+func (d *Database) SaveTaskRun(run *model.TaskRun) error {
+	probesJSON, _ := json.Marshal(run.ResolvedProbes)
+	query := `INSERT INTO task_runs (task_id, run_number, resolved_probes, created_at) VALUES (?, ?, ?, ?)
+	          ON CONFLICT (task_id, run_number) DO UPDATE SET resolved_probes = excluded.resolved_probes, created_at = excluded.created_at`
+	_, err := d.exec(query, run.TaskID, run.RunNumber, string(probesJSON), run.CreatedAt)
+	return err
+}
+
+// ListTaskRuns 列出任务各轮选中的探针集合，新的轮次在前
+func (d *Database) ListTaskRuns(taskID string) ([]*model.TaskRun, error) {
+	query := `SELECT task_id, run_number, resolved_probes, created_at
+	          FROM task_runs WHERE task_id = ? ORDER BY run_number DESC`
+
+	rows, err := d.db.Query(query, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := make([]*model.TaskRun, 0)
+	for rows.Next() {
+		run := &model.TaskRun{}
+		var probesJSON string
+		if err := rows.Scan(&run.TaskID, &run.RunNumber, &probesJSON, &run.CreatedAt); err != nil {
+			return nil, err
+		}
+		run.ResolvedProbes = []string{}
+		_ = json.Unmarshal([]byte(probesJSON), &run.ResolvedProbes)
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
@@ -0,0 +1,48 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"atlas/web/internal/model"
+)
+
+func TestTaskRunsKeepResolvedProbesPerRun(t *testing.T) {
+	db := newTestDatabase(t)
+	now := time.Now()
+	for _, run := range []*model.TaskRun{
+		{TaskID: "task-1", RunNumber: 1, ResolvedProbes: []string{"probe-a"}, CreatedAt: now},
+		{TaskID: "task-1", RunNumber: 2, ResolvedProbes: []string{"probe-b"}, CreatedAt: now},
+		// 同一轮重新分配时覆盖该轮的集合
+		{TaskID: "task-1", RunNumber: 2, ResolvedProbes: []string{"probe-c"}, CreatedAt: now},
+	} {
+		if err := db.SaveTaskRun(run); err != nil {
+			t.Fatalf("SaveTaskRun failed: %v", err)
+		}
+	}
+
+	runs, err := db.ListTaskRuns("task-1")
+	if err != nil {
+		t.Fatalf("ListTaskRuns failed: %v", err)
+	}
+	if len(runs) != 2 || runs[0].RunNumber != 2 || runs[1].RunNumber != 1 {
+		t.Fatalf("expected runs 2 and 1, got %+v", runs)
+	}
+	if !reflect.DeepEqual(runs[0].ResolvedProbes, []string{"probe-c"}) || !reflect.DeepEqual(runs[1].ResolvedProbes, []string{"probe-a"}) {
+		t.Fatalf("expected each run to keep its own probe set, got %v and %v", runs[0].ResolvedProbes, runs[1].ResolvedProbes)
+	}
+
+	seedTestProbe(t, db, "probe-c")
+	if err := db.CreateTask(&model.Task{TaskID: "task-1", TaskType: "icmp_ping", Mode: "continuous", Target: "1.1.1.1", Status: "running", Priority: 5}); err != nil {
+		t.Fatalf("CreateTask failed: %v", err)
+	}
+	execution := &model.TaskExecution{ExecutionID: "exec-1", TaskID: "task-1", ProbeID: "probe-c", RunNumber: 2, Status: "pending", StartedAt: now}
+	if err := db.SaveExecution(execution); err != nil {
+		t.Fatalf("SaveExecution failed: %v", err)
+	}
+	stored, err := db.GetExecution("exec-1")
+	if err != nil || stored.RunNumber != 2 {
+		t.Fatalf("expected execution tagged with run 2, got %+v (%v)", stored, err)
+	}
+}
@@ -0,0 +1,194 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"atlas/web/internal/model"
+)
+
+// CreateTaskTemplate 创建任务模板并写入第 1 个版本
+func (d *Database) CreateTaskTemplate(tpl *model.TaskTemplate, changedFields []string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO task_templates (template_id, name, description, version, definition, created_at, updated_at)
+	          VALUES (?, ?, ?, ?, ?, ?, ?)`
+	id, err := tx.Insert(query,
+		tpl.TemplateID,
+		tpl.Name,
+		tpl.Description,
+		tpl.Version,
+		tpl.Definition,
+		tpl.CreatedAt,
+		tpl.UpdatedAt,
+	)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if err := insertTemplateVersion(tx, tpl, changedFields); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	tpl.ID = id
+	return nil
+}
+
+// UpdateTaskTemplate 保存模板的新版本；tpl.Version 需已递增
+func (d *Database) UpdateTaskTemplate(tpl *model.TaskTemplate, changedFields []string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE task_templates SET name = ?, description = ?, version = ?, definition = ?, updated_at = ?
+	          WHERE template_id = ? AND version = ?`
+	result, err := tx.Exec(query, tpl.Name, tpl.Description, tpl.Version, tpl.Definition, tpl.UpdatedAt, tpl.TemplateID, tpl.Version-1)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	// 并发更新时只有一方能基于旧版本写入
+	if n, _ := result.RowsAffected(); n == 0 {
+		_ = tx.Rollback()
+		return sql.ErrNoRows
+	}
+	if err := insertTemplateVersion(tx, tpl, changedFields); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func insertTemplateVersion(tx *sqlTx, tpl *model.TaskTemplate, changedFields []string) error {
+	if changedFields == nil {
+		changedFields = []string{}
+	}
+	changedJSON, _ := json.Marshal(changedFields)
+	query := `INSERT INTO task_template_versions (template_id, version, name, description, definition, changed_fields, created_at)
+	          VALUES (?, ?, ?, ?, ?, ?, ?)`
+	_, err := tx.Exec(query, tpl.TemplateID, tpl.Version, tpl.Name, tpl.Description, tpl.Definition, string(changedJSON), tpl.UpdatedAt)
+	return err
+}
+
+// GetTaskTemplate 获取任务模板，不存在时返回 sql.ErrNoRows
+func (d *Database) GetTaskTemplate(templateID string) (*model.TaskTemplate, error) {
+	query := `SELECT id, template_id, name, COALESCE(description, ''), version, definition, created_at, updated_at
+	          FROM task_templates WHERE template_id = ?`
+	return scanTaskTemplate(d.db.QueryRow(query, templateID))
+}
+
+// ListTaskTemplates 列出所有任务模板
+func (d *Database) ListTaskTemplates() ([]*model.TaskTemplate, error) {
+	query := `SELECT id, template_id, name, COALESCE(description, ''), version, definition, created_at, updated_at
+	          FROM task_templates ORDER BY name ASC`
+
+	rows, err := d.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := make([]*model.TaskTemplate, 0)
+	for rows.Next() {
+		tpl, err := scanTaskTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, tpl)
+	}
+	return templates, rows.Err()
+}
+
+// DeleteTaskTemplate 删除任务模板及其历史版本
+func (d *Database) DeleteTaskTemplate(templateID string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	result, err := tx.Exec(`DELETE FROM task_templates WHERE template_id = ?`, templateID)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		_ = tx.Rollback()
+		return sql.ErrNoRows
+	}
+	if _, err := tx.Exec(`DELETE FROM task_template_versions WHERE template_id = ?`, templateID); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// ListTaskTemplateVersions 列出模板的全部版本，新版本在前
+func (d *Database) ListTaskTemplateVersions(templateID string) ([]*model.TaskTemplateVersion, error) {
+	query := `SELECT template_id, version, name, COALESCE(description, ''), definition, COALESCE(changed_fields, '[]'), created_at
+	          FROM task_template_versions WHERE template_id = ? ORDER BY version DESC`
+
+	rows, err := d.db.Query(query, templateID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := make([]*model.TaskTemplateVersion, 0)
+	for rows.Next() {
+		version, err := scanTaskTemplateVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+	return versions, rows.Err()
+}
+
+// GetTaskTemplateVersion 获取模板的指定版本，不存在时返回 sql.ErrNoRows
+func (d *Database) GetTaskTemplateVersion(templateID string, version int) (*model.TaskTemplateVersion, error) {
+	query := `SELECT template_id, version, name, COALESCE(description, ''), definition, COALESCE(changed_fields, '[]'), created_at
+	          FROM task_template_versions WHERE template_id = ? AND version = ?`
+	return scanTaskTemplateVersion(d.db.QueryRow(query, templateID, version))
+}
+
+func scanTaskTemplate(row rowScanner) (*model.TaskTemplate, error) {
+	tpl := &model.TaskTemplate{}
+	if err := row.Scan(
+		&tpl.ID,
+		&tpl.TemplateID,
+		&tpl.Name,
+		&tpl.Description,
+		&tpl.Version,
+		&tpl.Definition,
+		&tpl.CreatedAt,
+		&tpl.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return tpl, nil
+}
+
+func scanTaskTemplateVersion(row rowScanner) (*model.TaskTemplateVersion, error) {
+	version := &model.TaskTemplateVersion{}
+	var changedJSON string
+	if err := row.Scan(
+		&version.TemplateID,
+		&version.Version,
+		&version.Name,
+		&version.Description,
+		&version.Definition,
+		&changedJSON,
+		&version.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	version.ChangedFields = []string{}
+	_ = json.Unmarshal([]byte(changedJSON), &version.ChangedFields)
+	return version, nil
+}
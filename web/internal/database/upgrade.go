@@ -212,7 +212,7 @@ func (d *Database) TimeoutStaleProbeUpgrades(timeout time.Duration) (int64, erro
 
 	now := time.Now()
 	cutoff := now.Add(-timeout)
-	result, err := d.db.Exec(`
+	result, err := d.exec(`
 		UPDATE probe_upgrades
 		SET status = ?,
 		    completed_at = COALESCE(completed_at, ?),
@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"atlas/web/internal/database/dbtest"
 	"atlas/web/internal/model"
 )
 
@@ -25,9 +26,9 @@ func newTestDatabase(t *testing.T) *Database {
 	})
 
 	dbPath := filepath.Join(t.TempDir(), "atlas-test.db")
-	db, err := New(dbPath)
+	db, err := Open(dbtest.Source(t, dbPath))
 	if err != nil {
-		t.Fatalf("New failed: %v", err)
+		t.Fatalf("Open failed: %v", err)
 	}
 	t.Cleanup(func() {
 		_ = db.Close()
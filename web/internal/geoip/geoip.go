@@ -4,20 +4,52 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net"
 	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"atlas/web/internal/model"
+)
+
+const (
+	// ProviderIPAPI ip-api.com 数据源标识
+	ProviderIPAPI = "ip-api"
+
+	// DefaultCacheTTL 缓存默认有效期
+	DefaultCacheTTL = 7 * 24 * time.Hour
+
+	// hitRecordInterval 同一条目命中时间写回持久化缓存的最小间隔
+	hitRecordInterval = time.Hour
 )
 
+// CacheStore GeoIP 持久化缓存存储（由 database.Database 实现）
+type CacheStore interface {
+	GetGeoIPCache(ip string) (*model.GeoIPCacheEntry, error)
+	SaveGeoIPCache(entry *model.GeoIPCacheEntry) error
+	ListStaleGeoIPCache(now, hitSince time.Time, limit int) ([]*model.GeoIPCacheEntry, error)
+	TouchGeoIPCache(ip string, hitAt time.Time) error
+}
+
 // GeoIPService IP地理位置查询服务
 type GeoIPService struct {
 	client   *http.Client
-	cache    sync.Map // key: IP string, value: *Location
+	store    CacheStore
+	ttl      time.Duration
+	fetch    func(ip string) (*Location, error)
+	cache    sync.Map // key: IP string, value: *cacheItem
 	inFlight sync.Map // key: IP string, value: *inFlightCall
 }
 
+type cacheItem struct {
+	loc       *Location
+	expiresAt time.Time
+	hitAt     atomic.Int64 // 最近一次写回的命中时间(UnixNano)
+}
+
 type inFlightCall struct {
 	done chan struct{}
 	loc  *Location
@@ -53,20 +85,43 @@ type ipAPIResponse struct {
 	AS          string  `json:"as"`
 }
 
-// New 创建新的GeoIP服务
+// New 创建新的GeoIP服务（仅内存缓存）
 func New() *GeoIPService {
-	return &GeoIPService{
+	return NewWithStore(nil, DefaultCacheTTL)
+}
+
+// NewWithStore 创建带持久化缓存的GeoIP服务；store 为 nil 时退化为内存缓存
+func NewWithStore(store CacheStore, ttl time.Duration) *GeoIPService {
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+	s := &GeoIPService{
 		client: &http.Client{
 			Timeout: 5 * time.Second,
 		},
+		store: store,
+		ttl:   ttl,
 	}
+	s.fetch = s.lookupNoDedup
+	return s
+}
+
+// TTL 返回缓存有效期
+func (s *GeoIPService) TTL() time.Duration {
+	return s.ttl
 }
 
 // Lookup 查询IP地理位置
 func (s *GeoIPService) Lookup(ip string) (*Location, error) {
-	// 1. 检查缓存
+	now := time.Now()
+
+	// 1. 检查内存缓存
 	if cached, ok := s.cache.Load(ip); ok {
-		return cached.(*Location), nil
+		item := cached.(*cacheItem)
+		if now.Before(item.expiresAt) {
+			s.recordHit(ip, item, now)
+			return item.loc, nil
+		}
 	}
 
 	// 2. 检查是否为私有IP，跳过API调用
@@ -75,7 +130,15 @@ func (s *GeoIPService) Lookup(ip string) (*Location, error) {
 		return nil, fmt.Errorf("private or loopback IP address")
 	}
 
-	// 3. 并发去重：同一 IP 同时只发起一次外部查询
+	// 3. 检查持久化缓存（重启后仍然有效，避免重复请求限频 API）
+	stale := s.loadFromStore(ip)
+	if stale != nil && now.Before(stale.expiresAt) {
+		s.cache.Store(ip, stale)
+		s.recordHit(ip, stale, now)
+		return stale.loc, nil
+	}
+
+	// 4. 并发去重：同一 IP 同时只发起一次外部查询
 	call := &inFlightCall{done: make(chan struct{})}
 	actual, loaded := s.inFlight.LoadOrStore(ip, call)
 	if loaded {
@@ -96,7 +159,14 @@ func (s *GeoIPService) Lookup(ip string) (*Location, error) {
 		close(call.done)
 	}()
 
-	location, err := s.lookupNoDedup(ip)
+	location, err := s.fetch(ip)
+	if err != nil && stale != nil {
+		// 外部查询失败时退回过期数据，等待后台刷新
+		location, err = stale.loc, nil
+		s.recordHit(ip, stale, now)
+	} else if err == nil {
+		s.remember(ip, location, now, now)
+	}
 	call.loc = location
 	call.err = err
 	if err != nil {
@@ -105,6 +175,109 @@ func (s *GeoIPService) Lookup(ip string) (*Location, error) {
 	return location, nil
 }
 
+// Refresh 强制重新查询并覆盖缓存（供后台刷新使用），保留原有的命中时间
+func (s *GeoIPService) Refresh(ip string) (*Location, error) {
+	location, err := s.fetch(ip)
+	if err != nil {
+		return nil, err
+	}
+	s.remember(ip, location, time.Now(), s.lastHit(ip))
+	return location, nil
+}
+
+// Forget 移除单个 IP 的内存缓存
+func (s *GeoIPService) Forget(ip string) {
+	s.cache.Delete(ip)
+}
+
+// ForgetAll 清空内存缓存
+func (s *GeoIPService) ForgetAll() {
+	s.cache.Clear()
+}
+
+func (s *GeoIPService) loadFromStore(ip string) *cacheItem {
+	if s.store == nil {
+		return nil
+	}
+
+	entry, err := s.store.GetGeoIPCache(ip)
+	if err != nil {
+		log.Printf("[GeoIP] Failed to load cache entry for %s: %v", ip, err)
+		return nil
+	}
+	if entry == nil {
+		return nil
+	}
+
+	var location Location
+	if err := json.Unmarshal([]byte(entry.Data), &location); err != nil {
+		return nil
+	}
+	item := &cacheItem{loc: &location, expiresAt: entry.ExpiresAt}
+	item.hitAt.Store(entry.LastHitAt.UnixNano())
+	return item
+}
+
+// recordHit 记录条目被查询命中；距上次写回不足 hitRecordInterval 时只保留在内存中
+func (s *GeoIPService) recordHit(ip string, item *cacheItem, now time.Time) {
+	if s.store == nil {
+		return
+	}
+	last := item.hitAt.Load()
+	if now.Sub(time.Unix(0, last)) < hitRecordInterval || !item.hitAt.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+	if err := s.store.TouchGeoIPCache(ip, now); err != nil {
+		log.Printf("[GeoIP] Failed to record cache hit for %s: %v", ip, err)
+	}
+}
+
+// lastHit 条目当前的命中时间，未知时返回零值
+func (s *GeoIPService) lastHit(ip string) time.Time {
+	if cached, ok := s.cache.Load(ip); ok {
+		if at := cached.(*cacheItem).hitAt.Load(); at > 0 {
+			return time.Unix(0, at)
+		}
+	}
+	if s.store != nil {
+		if entry, err := s.store.GetGeoIPCache(ip); err == nil && entry != nil {
+			return entry.LastHitAt
+		}
+	}
+	return time.Time{}
+}
+
+// remember 写入内存与持久化缓存；hitAt 为零值时按 fetchedAt 记为命中
+func (s *GeoIPService) remember(ip string, location *Location, fetchedAt, hitAt time.Time) {
+	if hitAt.IsZero() {
+		hitAt = fetchedAt
+	}
+	expiresAt := fetchedAt.Add(s.ttl)
+	item := &cacheItem{loc: location, expiresAt: expiresAt}
+	item.hitAt.Store(hitAt.UnixNano())
+	s.cache.Store(ip, item)
+
+	if s.store == nil {
+		return
+	}
+
+	data, err := json.Marshal(location)
+	if err != nil {
+		return
+	}
+	if err := s.store.SaveGeoIPCache(&model.GeoIPCacheEntry{
+		IP:         ip,
+		Provider:   ProviderIPAPI,
+		Data:       string(data),
+		FetchedAt:  fetchedAt,
+		TTLSeconds: int(s.ttl / time.Second),
+		ExpiresAt:  expiresAt,
+		LastHitAt:  hitAt,
+	}); err != nil {
+		log.Printf("[GeoIP] Failed to persist cache entry for %s: %v", ip, err)
+	}
+}
+
 func (s *GeoIPService) lookupNoDedup(ip string) (*Location, error) {
 	// 使用免费的 ip-api.com 服务
 	url := fmt.Sprintf("http://ip-api.com/json/%s", ip)
@@ -144,9 +317,6 @@ func (s *GeoIPService) lookupNoDedup(ip string) (*Location, error) {
 		ASName:    asName,
 	}
 
-	// 存入缓存
-	s.cache.Store(ip, location)
-
 	return location, nil
 }
 
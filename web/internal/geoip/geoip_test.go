@@ -0,0 +1,216 @@
+package geoip
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"atlas/web/internal/model"
+)
+
+type memoryCacheStore struct {
+	mu      sync.Mutex
+	entries map[string]*model.GeoIPCacheEntry
+}
+
+func newMemoryCacheStore() *memoryCacheStore {
+	return &memoryCacheStore{entries: map[string]*model.GeoIPCacheEntry{}}
+}
+
+func (m *memoryCacheStore) GetGeoIPCache(ip string) (*model.GeoIPCacheEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.entries[ip]
+	if !ok {
+		return nil, nil
+	}
+	copied := *entry
+	return &copied, nil
+}
+
+func (m *memoryCacheStore) SaveGeoIPCache(entry *model.GeoIPCacheEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	copied := *entry
+	if copied.LastHitAt.IsZero() {
+		copied.LastHitAt = copied.FetchedAt
+	}
+	m.entries[entry.IP] = &copied
+	return nil
+}
+
+func (m *memoryCacheStore) ListStaleGeoIPCache(now, hitSince time.Time, limit int) ([]*model.GeoIPCacheEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []*model.GeoIPCacheEntry
+	for _, entry := range m.entries {
+		if !entry.ExpiresAt.After(now) && !entry.LastHitAt.Before(hitSince) && len(out) < limit {
+			copied := *entry
+			out = append(out, &copied)
+		}
+	}
+	return out, nil
+}
+
+func (m *memoryCacheStore) TouchGeoIPCache(ip string, hitAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if entry, ok := m.entries[ip]; ok {
+		entry.LastHitAt = hitAt
+	}
+	return nil
+}
+
+func countingFetch(calls *int, asn string) func(string) (*Location, error) {
+	return func(ip string) (*Location, error) {
+		*calls++
+		return &Location{IP: ip, Country: "Germany", ASN: asn}, nil
+	}
+}
+
+func TestLookupUsesPersistentCacheAcrossRestarts(t *testing.T) {
+	store := newMemoryCacheStore()
+
+	calls := 0
+	first := NewWithStore(store, time.Hour)
+	first.fetch = countingFetch(&calls, "AS3320")
+	if _, err := first.Lookup("8.8.8.8"); err != nil {
+		t.Fatalf("Lookup failed: %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected 1 upstream call, got %d", calls)
+	}
+
+	entry, _ := store.GetGeoIPCache("8.8.8.8")
+	if entry == nil || entry.Provider != ProviderIPAPI || entry.TTLSeconds != 3600 {
+		t.Fatalf("expected persisted ip-api entry with 3600s ttl, got %#v", entry)
+	}
+
+	// 模拟重启：新的服务实例只共享持久化存储
+	second := NewWithStore(store, time.Hour)
+	second.fetch = countingFetch(&calls, "AS3320")
+	location, err := second.Lookup("8.8.8.8")
+	if err != nil {
+		t.Fatalf("Lookup after restart failed: %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected persistent cache hit, got %d upstream calls", calls)
+	}
+	if location.ASN != "AS3320" {
+		t.Fatalf("expected cached ASN AS3320, got %q", location.ASN)
+	}
+}
+
+func TestLookupRefetchesExpiredEntryAndFallsBackToStale(t *testing.T) {
+	store := newMemoryCacheStore()
+	_ = store.SaveGeoIPCache(&model.GeoIPCacheEntry{
+		IP:         "1.1.1.1",
+		Provider:   ProviderIPAPI,
+		Data:       `{"ip":"1.1.1.1","asn":"AS13335"}`,
+		FetchedAt:  time.Now().Add(-2 * time.Hour),
+		TTLSeconds: 3600,
+		ExpiresAt:  time.Now().Add(-time.Hour),
+	})
+
+	service := NewWithStore(store, time.Hour)
+	service.fetch = func(string) (*Location, error) {
+		return nil, errors.New("rate limited")
+	}
+
+	location, err := service.Lookup("1.1.1.1")
+	if err != nil {
+		t.Fatalf("expected stale fallback, got error: %v", err)
+	}
+	if location.ASN != "AS13335" {
+		t.Fatalf("expected stale ASN AS13335, got %q", location.ASN)
+	}
+
+	calls := 0
+	service.fetch = countingFetch(&calls, "AS13335-new")
+	location, err = service.Lookup("1.1.1.1")
+	if err != nil {
+		t.Fatalf("Lookup failed: %v", err)
+	}
+	if calls != 1 || location.ASN != "AS13335-new" {
+		t.Fatalf("expected expired entry to be refetched, calls=%d asn=%q", calls, location.ASN)
+	}
+
+	entry, _ := store.GetGeoIPCache("1.1.1.1")
+	if entry == nil || !entry.ExpiresAt.After(time.Now()) {
+		t.Fatalf("expected refreshed entry to be persisted with a future expiry, got %#v", entry)
+	}
+}
+
+func TestRefresherRefreshesStaleEntries(t *testing.T) {
+	store := newMemoryCacheStore()
+	_ = store.SaveGeoIPCache(&model.GeoIPCacheEntry{
+		IP:         "9.9.9.9",
+		Provider:   ProviderIPAPI,
+		Data:       `{"ip":"9.9.9.9"}`,
+		FetchedAt:  time.Now().Add(-2 * time.Hour),
+		TTLSeconds: 3600,
+		ExpiresAt:  time.Now().Add(-time.Hour),
+	})
+	// 几天没有被查询过的过期条目不刷新
+	_ = store.SaveGeoIPCache(&model.GeoIPCacheEntry{
+		IP:         "8.8.4.4",
+		Provider:   ProviderIPAPI,
+		Data:       `{"ip":"8.8.4.4"}`,
+		FetchedAt:  time.Now().Add(-72 * time.Hour),
+		TTLSeconds: 3600,
+		ExpiresAt:  time.Now().Add(-71 * time.Hour),
+	})
+
+	calls := 0
+	service := NewWithStore(store, time.Hour)
+	service.fetch = countingFetch(&calls, "AS19281")
+
+	refresher := NewRefresher(service, time.Minute)
+	if refreshed := refresher.refreshStale(); refreshed != 1 || calls != 1 {
+		t.Fatalf("expected only the recently used entry to be refreshed, got %d (calls=%d)", refreshed, calls)
+	}
+
+	stale, _ := store.ListStaleGeoIPCache(time.Now(), time.Time{}, 10)
+	if len(stale) != 1 || stale[0].IP != "8.8.4.4" {
+		t.Fatalf("expected only the unused entry to remain stale, got %+v", stale)
+	}
+	// 刷新不算作命中，保留原命中时间
+	if entry, _ := store.GetGeoIPCache("9.9.9.9"); entry == nil || !entry.LastHitAt.Before(time.Now().Add(-time.Hour)) {
+		t.Fatalf("expected refresh to keep the previous hit time, got %+v", entry)
+	}
+}
+
+func TestLookupRecordsHitsThrottled(t *testing.T) {
+	store := newMemoryCacheStore()
+	fetchedAt := time.Now().Add(-2 * time.Hour)
+	_ = store.SaveGeoIPCache(&model.GeoIPCacheEntry{
+		IP:         "9.9.9.9",
+		Provider:   ProviderIPAPI,
+		Data:       `{"ip":"9.9.9.9"}`,
+		FetchedAt:  fetchedAt,
+		TTLSeconds: 86400,
+		ExpiresAt:  fetchedAt.Add(24 * time.Hour),
+	})
+
+	calls := 0
+	service := NewWithStore(store, 24*time.Hour)
+	service.fetch = countingFetch(&calls, "AS19281")
+
+	if _, err := service.Lookup("9.9.9.9"); err != nil {
+		t.Fatalf("Lookup failed: %v", err)
+	}
+	entry, _ := store.GetGeoIPCache("9.9.9.9")
+	hit := entry.LastHitAt
+	if !hit.After(fetchedAt) {
+		t.Fatalf("expected lookup to record a hit, got %v", hit)
+	}
+
+	// 一小时内的再次命中不写回
+	if _, err := service.Lookup("9.9.9.9"); err != nil {
+		t.Fatalf("Lookup failed: %v", err)
+	}
+	if entry, _ = store.GetGeoIPCache("9.9.9.9"); !entry.LastHitAt.Equal(hit) || calls != 0 {
+		t.Fatalf("expected throttled hit and no fetch, got %v calls=%d", entry.LastHitAt, calls)
+	}
+}
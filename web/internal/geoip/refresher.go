@@ -0,0 +1,106 @@
+package geoip
+
+import (
+	"log"
+	"time"
+
+	"atlas/web/internal/leader"
+)
+
+const (
+	// 每轮最多刷新的条目数
+	refreshBatchSize = 20
+
+	// ip-api 免费额度为 45 次/分钟，逐条刷新之间留出间隔
+	refreshPacing = 1500 * time.Millisecond
+
+	// 只刷新该时间内被查询过的条目；更久未使用的条目留待过期，下次查询时再按需拉取
+	refreshHitWindow = 24 * time.Hour
+)
+
+// Refresher 后台刷新过期的 GeoIP 缓存条目
+type Refresher struct {
+	service  *GeoIPService
+	interval time.Duration
+	elector  *leader.Elector
+	stopChan chan struct{}
+}
+
+// NewRefresher 创建缓存刷新器
+func NewRefresher(service *GeoIPService, interval time.Duration) *Refresher {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	return &Refresher{
+		service:  service,
+		interval: interval,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// SetElector 多副本部署时只在主节点上刷新，避免多个节点重复查询同一批条目消耗外部 API 额度
+func (r *Refresher) SetElector(elector *leader.Elector) {
+	r.elector = elector
+}
+
+// Start 启动刷新循环
+func (r *Refresher) Start() {
+	if r.service == nil || r.service.store == nil {
+		return
+	}
+
+	log.Println("[GeoIP] Starting cache refresher...")
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if r.elector != nil && !r.elector.IsLeader(time.Now()) {
+				continue
+			}
+			r.refreshStale()
+		case <-r.stopChan:
+			log.Println("[GeoIP] Cache refresher stopped")
+			return
+		}
+	}
+}
+
+// Stop 停止刷新循环
+func (r *Refresher) Stop() {
+	close(r.stopChan)
+}
+
+// refreshStale 刷新一批已过期且最近仍被使用的缓存条目
+func (r *Refresher) refreshStale() int {
+	now := time.Now()
+	entries, err := r.service.store.ListStaleGeoIPCache(now, now.Add(-refreshHitWindow), refreshBatchSize)
+	if err != nil {
+		log.Printf("[GeoIP] Failed to list stale cache entries: %v", err)
+		return 0
+	}
+
+	refreshed := 0
+	for i, entry := range entries {
+		if i > 0 {
+			select {
+			case <-time.After(refreshPacing):
+			case <-r.stopChan:
+				return refreshed
+			}
+		}
+
+		if _, err := r.service.Refresh(entry.IP); err != nil {
+			log.Printf("[GeoIP] Failed to refresh %s: %v", entry.IP, err)
+			continue
+		}
+		refreshed++
+	}
+
+	if refreshed > 0 {
+		log.Printf("[GeoIP] Refreshed %d stale cache entries", refreshed)
+	}
+	return refreshed
+}
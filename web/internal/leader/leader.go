@@ -0,0 +1,148 @@
+package leader
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"atlas/web/internal/database"
+)
+
+// SchedulerLease 调度器使用的租约名
+const SchedulerLease = "scheduler"
+
+// DefaultTTL 默认租约时长；续约间隔为其 1/3
+const DefaultTTL = 15 * time.Second
+
+// Elector 基于数据库租约行的主节点选举。
+// 持有租约的实例为主节点，其余实例热备：每隔 ttl/3 尝试获取或续约，主节点失联超过 ttl 后由备节点接管。
+// 每次易主租约 token 递增；主节点的写入经 Fence 携带 token，旧主节点暂停后恢复时写入会被数据库拒绝
+type Elector struct {
+	db   *database.Database
+	name string
+	id   string
+	ttl  time.Duration
+
+	mu        sync.Mutex
+	leader    bool
+	token     int64
+	expiresAt time.Time // 本地保守估计的租约到期时间
+}
+
+// New 创建选举器；id 为空时生成 hostname-pid-随机串，ttl <= 0 时使用 DefaultTTL
+func New(db *database.Database, name, id string, ttl time.Duration) *Elector {
+	if id == "" {
+		id = DefaultInstanceID()
+	}
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Elector{db: db, name: name, id: id, ttl: ttl}
+}
+
+// DefaultInstanceID 生成实例 ID
+func DefaultInstanceID() string {
+	host, _ := os.Hostname()
+	if host == "" {
+		host = "atlas"
+	}
+	buf := make([]byte, 4)
+	_, _ = rand.Read(buf)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(buf))
+}
+
+// ID 实例 ID
+func (e *Elector) ID() string {
+	return e.id
+}
+
+// Tick 获取或续约一次租约，返回本实例是否为主节点
+func (e *Elector) Tick(now time.Time) bool {
+	lease, held, err := e.db.AcquireLease(e.name, e.id, e.ttl, now)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err != nil {
+		// 数据库不可用时保留已有租约直到本地估计的到期时间，之后自动降级
+		log.Printf("[Leader] Failed to renew lease %s: %v", e.name, err)
+		if e.leader && !now.Before(e.expiresAt) {
+			e.leader = false
+			log.Printf("[Leader] Instance %s lost lease %s (token %d)", e.id, e.name, e.token)
+		}
+		return e.leader
+	}
+
+	switch {
+	case held && !e.leader:
+		log.Printf("[Leader] Instance %s acquired lease %s (token %d)", e.id, e.name, lease.Token)
+	case !held && e.leader:
+		log.Printf("[Leader] Instance %s lost lease %s to %s (token %d)", e.id, e.name, lease.Holder, lease.Token)
+	}
+
+	e.leader = held
+	if held {
+		e.token = lease.Token
+		// 以发起续约的时刻计算，避免数据库延迟让本地认为租约比实际更久
+		e.expiresAt = now.Add(e.ttl)
+	}
+	return e.leader
+}
+
+// IsLeader 本地判断是否仍持有未过期的租约
+func (e *Elector) IsLeader(now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.leader && now.Before(e.expiresAt)
+}
+
+// Token 当前持有的 fencing token；非主节点时返回 0
+func (e *Elector) Token() int64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.leader {
+		return 0
+	}
+	return e.token
+}
+
+// Fence 当前租约的 fencing token，传给 database.WithFence；非主节点时 token 为 0，写入总会被拒绝
+func (e *Elector) Fence() database.Fence {
+	return database.Fence{Lease: e.name, Holder: e.id, Token: e.Token()}
+}
+
+// Run 周期续约直到 stop 关闭，退出时主动释放租约
+func (e *Elector) Run(stop <-chan struct{}) {
+	e.Tick(time.Now())
+
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			e.Tick(time.Now())
+		case <-stop:
+			e.Release(time.Now())
+			return
+		}
+	}
+}
+
+// Release 放弃租约，其他实例下次续约时即可接管
+func (e *Elector) Release(now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.leader {
+		return
+	}
+	if err := e.db.ReleaseLease(e.name, e.id, e.token, now); err != nil {
+		log.Printf("[Leader] Failed to release lease %s: %v", e.name, err)
+	}
+	e.leader = false
+	log.Printf("[Leader] Instance %s released lease %s (token %d)", e.id, e.name, e.token)
+}
@@ -0,0 +1,114 @@
+package leader
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"atlas/web/internal/database"
+	"atlas/web/internal/database/dbtest"
+)
+
+// openShared 以两个独立连接打开同一个 SQLite 文件，模拟两个副本
+func openShared(t *testing.T) (*database.Database, *database.Database) {
+	t.Helper()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Getwd failed: %v", err)
+	}
+	if err := os.Chdir(filepath.Clean(filepath.Join(wd, "..", ".."))); err != nil {
+		t.Fatalf("Chdir failed: %v", err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+
+	path := filepath.Join(t.TempDir(), "atlas-leader.db")
+	open := func() *database.Database {
+		db, err := database.Open(dbtest.Source(t, path))
+		if err != nil {
+			t.Fatalf("database.Open failed: %v", err)
+		}
+		t.Cleanup(func() { _ = db.Close() })
+		return db
+	}
+
+	first := open()
+	if err := first.Migrate(); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	return first, open()
+}
+
+func TestOnlyOneInstanceHoldsLease(t *testing.T) {
+	dbA, dbB := openShared(t)
+	a := New(dbA, SchedulerLease, "instance-a", 3*time.Second)
+	b := New(dbB, SchedulerLease, "instance-b", 3*time.Second)
+
+	now := time.Now()
+	if !a.Tick(now) {
+		t.Fatal("expected first instance to acquire the lease")
+	}
+	if b.Tick(now) {
+		t.Fatal("expected second instance to stay standby while the lease is held")
+	}
+	if !a.IsLeader(now) || b.IsLeader(now) {
+		t.Fatal("expected only instance-a to be leader")
+	}
+
+	// 续约不改变 token
+	token := a.Token()
+	if !a.Tick(now.Add(time.Second)) || a.Token() != token {
+		t.Fatalf("expected renewal to keep token %d, got %d", token, a.Token())
+	}
+}
+
+func TestStandbyTakesOverExpiredLeaseWithNewToken(t *testing.T) {
+	dbA, dbB := openShared(t)
+	a := New(dbA, SchedulerLease, "instance-a", 3*time.Second)
+	b := New(dbB, SchedulerLease, "instance-b", 3*time.Second)
+
+	now := time.Now()
+	a.Tick(now)
+	oldToken := a.Token()
+
+	// instance-a 停止续约，租约过期后由 instance-b 接管
+	later := now.Add(4 * time.Second)
+	if !b.Tick(later) {
+		t.Fatal("expected standby to take over the expired lease")
+	}
+	if b.Token() != oldToken+1 {
+		t.Fatalf("expected fencing token %d, got %d", oldToken+1, b.Token())
+	}
+
+	// 旧主节点恢复：本地状态仍认为持有，但带旧 token 的写入被拒绝
+	if !a.IsLeader(now.Add(time.Second)) {
+		t.Fatal("expected stale leader to still believe it leads before renewing")
+	}
+	if err := dbA.WithFence(a.Fence()).UpdateTaskStatus("task-1", "running"); !errors.Is(err, database.ErrFenced) {
+		t.Fatalf("expected stale leader's write to be fenced, got %v", err)
+	}
+	if err := dbB.WithFence(b.Fence()).UpdateTaskStatus("task-1", "running"); err != nil {
+		t.Fatalf("expected new leader's write to pass the fence, got %v", err)
+	}
+	if a.Tick(later) {
+		t.Fatal("expected stale leader to step down on its next renewal")
+	}
+}
+
+func TestReleaseHandsOverWithoutWaitingForExpiry(t *testing.T) {
+	dbA, dbB := openShared(t)
+	a := New(dbA, SchedulerLease, "instance-a", time.Minute)
+	b := New(dbB, SchedulerLease, "instance-b", time.Minute)
+
+	now := time.Now()
+	a.Tick(now)
+	a.Release(now)
+	if a.IsLeader(now) {
+		t.Fatal("expected released instance to no longer be leader")
+	}
+	if !b.Tick(now.Add(time.Millisecond)) {
+		t.Fatal("expected standby to acquire the released lease immediately")
+	}
+}
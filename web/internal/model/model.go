@@ -14,37 +14,99 @@ const (
 
 // Probe 探针模型
 type Probe struct {
-	ID            int64     `json:"id" db:"id"`
-	ProbeID       string    `json:"probe_id" db:"probe_id"`
-	Name          string    `json:"name" db:"name"`
-	Location      string    `json:"location" db:"location"`
-	Region        string    `json:"region" db:"region"`
-	Latitude      *float64  `json:"latitude,omitempty" db:"latitude"`   // 纬度
-	Longitude     *float64  `json:"longitude,omitempty" db:"longitude"` // 经度
-	IPAddress     string    `json:"ip_address" db:"ip_address"`
-	Capabilities  string    `json:"capabilities" db:"capabilities"` // JSON array
-	Status        string    `json:"status" db:"status"`             // online/offline/busy
-	LastHeartbeat time.Time `json:"last_heartbeat" db:"last_heartbeat"`
-	RegisteredAt  time.Time `json:"registered_at" db:"registered_at"`
-	Metadata      string    `json:"metadata" db:"metadata"` // JSON object
+	ID                 int64     `json:"id" db:"id"`
+	ProbeID            string    `json:"probe_id" db:"probe_id"`
+	Name               string    `json:"name" db:"name"`
+	Location           string    `json:"location" db:"location"`
+	Region             string    `json:"region" db:"region"`
+	Latitude           *float64  `json:"latitude,omitempty" db:"latitude"`   // 纬度
+	Longitude          *float64  `json:"longitude,omitempty" db:"longitude"` // 经度
+	IPAddress          string    `json:"ip_address" db:"ip_address"`
+	Capabilities       string    `json:"capabilities" db:"capabilities"` // JSON array
+	Status             string    `json:"status" db:"status"`             // online/offline/busy
+	LastHeartbeat      time.Time `json:"last_heartbeat" db:"last_heartbeat"`
+	RegisteredAt       time.Time `json:"registered_at" db:"registered_at"`
+	Metadata           string    `json:"metadata" db:"metadata"`                         // JSON object
+	Labels             string    `json:"labels" db:"labels"`                             // 配置上报的标签 JSON object
+	AdminLabels        string    `json:"admin_labels" db:"admin_labels"`                 // 后台设置的标签 JSON object
+	MaxConcurrentTasks int       `json:"max_concurrent_tasks" db:"max_concurrent_tasks"` // 注册时上报的容量，0 表示未知
+	ActiveTasks        int       `json:"active_tasks" db:"active_tasks"`                 // 心跳上报的执行中任务数
+	QueuedTasks        int       `json:"queued_tasks" db:"queued_tasks"`                 // 心跳上报的排队任务数
 }
 
 // Task 任务模型
 type Task struct {
-	ID             int64      `json:"id" db:"id"`
-	TaskID         string     `json:"task_id" db:"task_id"`
-	TaskType       string     `json:"task_type" db:"task_type"`
-	Mode           string     `json:"mode" db:"mode"` // single/continuous
-	Target         string     `json:"target" db:"target"`
-	Parameters     string     `json:"parameters" db:"parameters"`           // JSON
-	AssignedProbes string     `json:"assigned_probes" db:"assigned_probes"` // JSON array
-	Status         string     `json:"status" db:"status"`
-	Schedule       string     `json:"schedule,omitempty" db:"schedule"` // JSON
-	Priority       int        `json:"priority" db:"priority"`
-	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
-	StartedAt      *time.Time `json:"started_at,omitempty" db:"started_at"`
-	CompletedAt    *time.Time `json:"completed_at,omitempty" db:"completed_at"`
-	NextRunAt      *time.Time `json:"next_run_at,omitempty" db:"next_run_at"`
+	ID                int64      `json:"id" db:"id"`
+	TaskID            string     `json:"task_id" db:"task_id"`
+	TaskType          string     `json:"task_type" db:"task_type"`
+	Mode              string     `json:"mode" db:"mode"` // single/continuous
+	Target            string     `json:"target" db:"target"`
+	Parameters        string     `json:"parameters" db:"parameters"`           // JSON
+	AssignedProbes    string     `json:"assigned_probes" db:"assigned_probes"` // JSON array
+	Status            string     `json:"status" db:"status"`
+	Schedule          string     `json:"schedule,omitempty" db:"schedule"` // JSON
+	Priority          int        `json:"priority" db:"priority"`
+	CreatedAt         time.Time  `json:"created_at" db:"created_at"`
+	StartedAt         *time.Time `json:"started_at,omitempty" db:"started_at"`
+	CompletedAt       *time.Time `json:"completed_at,omitempty" db:"completed_at"`
+	NextRunAt         *time.Time `json:"next_run_at,omitempty" db:"next_run_at"`
+	RetryPolicy       string     `json:"retry_policy,omitempty" db:"retry_policy"`               // JSON
+	Selector          string     `json:"selector,omitempty" db:"selector"`                       // 探针标签选择表达式
+	Sampling          string     `json:"sampling,omitempty" db:"sampling"`                       // 探针抽样策略 JSON
+	ResolvedProbes    string     `json:"resolved_probes,omitempty" db:"resolved_probes"`         // 最近一轮实际选中的探针 JSON array，各轮见 task_runs
+	Targets           string     `json:"targets,omitempty" db:"targets"`                         // 多目标定义 JSON
+	RerunOf           *string    `json:"rerun_of,omitempty" db:"rerun_of"`                       // 重跑来源任务
+	TemplateID        *string    `json:"template_id,omitempty" db:"template_id"`                 // 启动该任务的模板
+	TemplateVersion   *int       `json:"template_version,omitempty" db:"template_version"`       // 启动时的模板版本
+	Revision          int        `json:"revision" db:"revision"`                                 // 已编辑次数
+	Workflow          string     `json:"workflow,omitempty" db:"workflow"`                       // 后续步骤定义 JSON
+	ParentTaskID      *string    `json:"parent_task_id,omitempty" db:"parent_task_id"`           // 由工作流触发时的父任务
+	ParentExecutionID *string    `json:"parent_execution_id,omitempty" db:"parent_execution_id"` // 触发该任务的父执行
+	WorkflowStep      *string    `json:"workflow_step,omitempty" db:"workflow_step"`             // 触发的步骤名
+}
+
+// TaskRevision 任务的一次编辑记录
+type TaskRevision struct {
+	TaskID        string    `json:"task_id" db:"task_id"`
+	Revision      int       `json:"revision" db:"revision"`
+	ChangedFields []string  `json:"changed_fields" db:"changed_fields"` // 存储为 JSON 数组
+	Previous      string    `json:"previous" db:"previous"`             // 编辑前的可编辑字段 JSON
+	Current       string    `json:"current" db:"current"`               // 编辑后的可编辑字段 JSON
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+}
+
+// TaskTemplate 可复用的命名任务定义
+type TaskTemplate struct {
+	ID          int64     `json:"id" db:"id"`
+	TemplateID  string    `json:"template_id" db:"template_id"`
+	Name        string    `json:"name" db:"name"`
+	Description string    `json:"description" db:"description"`
+	Version     int       `json:"version" db:"version"`
+	Definition  string    `json:"definition" db:"definition"` // JSON，结构同创建任务请求
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// TaskTemplateVersion 任务模板的历史版本
+type TaskTemplateVersion struct {
+	TemplateID    string    `json:"template_id" db:"template_id"`
+	Version       int       `json:"version" db:"version"`
+	Name          string    `json:"name" db:"name"`
+	Description   string    `json:"description" db:"description"`
+	Definition    string    `json:"definition" db:"definition"`         // JSON
+	ChangedFields []string  `json:"changed_fields" db:"changed_fields"` // 相对上一版本变化的字段，存储为 JSON 数组
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+}
+
+// TargetGroup 可被多目标任务引用的目标组
+type TargetGroup struct {
+	ID          int64     `json:"id" db:"id"`
+	GroupID     string    `json:"group_id" db:"group_id"`
+	Name        string    `json:"name" db:"name"`
+	Description string    `json:"description" db:"description"`
+	Targets     []string  `json:"targets" db:"targets"` // 存储为 JSON 数组
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
 }
 
 // TaskExecution 任务执行记录
@@ -53,10 +115,25 @@ type TaskExecution struct {
 	ExecutionID string     `json:"execution_id" db:"execution_id"`
 	TaskID      string     `json:"task_id" db:"task_id"`
 	ProbeID     string     `json:"probe_id" db:"probe_id"`
+	Target      string     `json:"target,omitempty" db:"target"` // 多目标任务中的目标，为空时使用任务目标
 	Status      string     `json:"status" db:"status"`
 	StartedAt   time.Time  `json:"started_at" db:"started_at"`
 	CompletedAt *time.Time `json:"completed_at,omitempty" db:"completed_at"`
 	Error       *string    `json:"error,omitempty" db:"error"`
+	Attempt     int        `json:"attempt" db:"attempt"`             // 第几次尝试，从 1 开始
+	RetryOf     *string    `json:"retry_of,omitempty" db:"retry_of"` // 上一次尝试的 execution_id
+	// 失败分类: send_failure/timeout/probe_error/lost
+	FailureClass *string    `json:"failure_class,omitempty" db:"failure_class"`
+	NotBefore    *time.Time `json:"not_before,omitempty" db:"not_before"` // 重试尝试的最早下发时间
+	RunNumber    int        `json:"run_number,omitempty" db:"run_number"` // 产生该执行的运行轮次，对应 task_runs
+}
+
+// TaskRun 一轮运行按抽样策略实际选中的探针集合
+type TaskRun struct {
+	TaskID         string    `json:"task_id" db:"task_id"`
+	RunNumber      int       `json:"run_number" db:"run_number"`           // 单次任务为 1，持续任务为调度的第几次运行
+	ResolvedProbes []string  `json:"resolved_probes" db:"resolved_probes"` // 存储为 JSON 数组
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
 }
 
 // Result 测试结果
@@ -72,6 +149,31 @@ type Result struct {
 	ResultData  string    `json:"result_data" db:"result_data"`   // JSON
 	Summary     string    `json:"summary,omitempty" db:"summary"` // JSON
 	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+
+	// 写入时从摘要提取的数值指标，供时序查询按列聚合
+	AvgLatency *float64 `json:"-" db:"avg_latency"`
+	PacketLoss *float64 `json:"-" db:"packet_loss"`
+	HTTPStatus *int     `json:"-" db:"http_status"`
+	TTFB       *float64 `json:"-" db:"ttfb_ms"`
+}
+
+// ResultAggregate 降采样后的结果聚合行：一个时间桶内同一任务、探针与目标的统计值
+type ResultAggregate struct {
+	ID           int64     `json:"id" db:"id"`
+	TaskID       string    `json:"task_id" db:"task_id"`
+	ProbeID      string    `json:"probe_id" db:"probe_id"`
+	Target       string    `json:"target" db:"target"`
+	TestType     string    `json:"test_type" db:"test_type"`
+	Granularity  string    `json:"granularity" db:"granularity"` // hour/day
+	BucketStart  time.Time `json:"bucket_start" db:"bucket_start"`
+	SampleCount  int       `json:"sample_count" db:"sample_count"`
+	SuccessCount int       `json:"success_count" db:"success_count"`
+	LatencyCount int       `json:"latency_count" db:"latency_count"`
+	MinLatency   *float64  `json:"min_latency,omitempty" db:"min_latency"`
+	AvgLatency   *float64  `json:"avg_latency,omitempty" db:"avg_latency"`
+	MaxLatency   *float64  `json:"max_latency,omitempty" db:"max_latency"`
+	P95Latency   *float64  `json:"p95_latency,omitempty" db:"p95_latency"`
+	PacketLoss   *float64  `json:"packet_loss,omitempty" db:"packet_loss"`
 }
 
 // ProbeUpgrade 探针升级记录
@@ -87,3 +189,72 @@ type ProbeUpgrade struct {
 	AckedAt       *time.Time `json:"acked_at,omitempty" db:"acked_at"`
 	CompletedAt   *time.Time `json:"completed_at,omitempty" db:"completed_at"`
 }
+
+// GeoIPCacheEntry GeoIP 持久化缓存条目
+type GeoIPCacheEntry struct {
+	IP         string    `json:"ip" db:"ip"`
+	Provider   string    `json:"provider" db:"provider"`
+	Data       string    `json:"data" db:"data"` // JSON
+	FetchedAt  time.Time `json:"fetched_at" db:"fetched_at"`
+	TTLSeconds int       `json:"ttl_seconds" db:"ttl_seconds"`
+	ExpiresAt  time.Time `json:"expires_at" db:"expires_at"`
+	LastHitAt  time.Time `json:"last_hit_at" db:"last_hit_at"` // 最近一次查询命中，为零值时写入 fetched_at
+}
+
+// PathState (probe, target) 当前路径指纹
+type PathState struct {
+	ProbeID     string    `json:"probe_id" db:"probe_id"`
+	Target      string    `json:"target" db:"target"`
+	Mode        string    `json:"mode" db:"mode"`
+	Fingerprint string    `json:"fingerprint" db:"fingerprint"`
+	Path        string    `json:"path" db:"path"` // JSON array
+	ResultID    string    `json:"result_id" db:"result_id"`
+	FirstSeenAt time.Time `json:"first_seen_at" db:"first_seen_at"`
+	LastSeenAt  time.Time `json:"last_seen_at" db:"last_seen_at"`
+}
+
+// OutboxEntry 已下发但探针尚未确认的 task_assign
+type OutboxEntry struct {
+	ExecutionID string     `json:"execution_id" db:"execution_id"`
+	ProbeID     string     `json:"probe_id" db:"probe_id"`
+	TaskID      string     `json:"task_id" db:"task_id"`
+	Payload     string     `json:"payload" db:"payload"` // task_assign 消息 JSON
+	Attempts    int        `json:"attempts" db:"attempts"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	LastSentAt  *time.Time `json:"last_sent_at,omitempty" db:"last_sent_at"`
+}
+
+// Lease 主节点选举租约
+type Lease struct {
+	Name      string    `json:"name" db:"name"`
+	Holder    string    `json:"holder" db:"holder"` // 持有者实例 ID
+	Token     int64     `json:"token" db:"token"`   // fencing token，每次易主递增
+	RenewedAt time.Time `json:"renewed_at" db:"renewed_at"`
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
+}
+
+// ProbeConnection 探针连接归属：探针当前连接在哪个 Web 节点
+type ProbeConnection struct {
+	ProbeID     string    `json:"probe_id" db:"probe_id"`
+	NodeID      string    `json:"node_id" db:"node_id"`
+	ConnectedAt time.Time `json:"connected_at" db:"connected_at"`
+	HeartbeatAt time.Time `json:"heartbeat_at" db:"heartbeat_at"`
+}
+
+// PathChange 路径变化事件
+type PathChange struct {
+	ID               int64     `json:"id" db:"id"`
+	ChangeID         string    `json:"change_id" db:"change_id"`
+	ProbeID          string    `json:"probe_id" db:"probe_id"`
+	Target           string    `json:"target" db:"target"`
+	TaskID           string    `json:"task_id" db:"task_id"`
+	ResultID         string    `json:"result_id" db:"result_id"`
+	PreviousResultID string    `json:"previous_result_id,omitempty" db:"previous_result_id"`
+	Mode             string    `json:"mode" db:"mode"`
+	OldFingerprint   string    `json:"old_fingerprint" db:"old_fingerprint"`
+	NewFingerprint   string    `json:"new_fingerprint" db:"new_fingerprint"`
+	OldPath          string    `json:"old_path" db:"old_path"` // JSON array
+	NewPath          string    `json:"new_path" db:"new_path"` // JSON array
+	Diff             string    `json:"diff" db:"diff"`         // JSON
+	DetectedAt       time.Time `json:"detected_at" db:"detected_at"`
+}
@@ -0,0 +1,154 @@
+package pathwatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"atlas/web/internal/database"
+	"atlas/web/internal/model"
+	"atlas/web/internal/routeutil"
+)
+
+// Tracker 维护每个 (probe, target) 的路径指纹并记录路径变化
+type Tracker struct {
+	db     *database.Database
+	client *http.Client
+}
+
+// New 创建路径变化跟踪器
+func New(db *database.Database) *Tracker {
+	return &Tracker{
+		db: db,
+		client: &http.Client{
+			Timeout: 5 * time.Second,
+		},
+	}
+}
+
+// Observe 用一条 route 类结果更新路径指纹；路径发生变化时返回记录的变化事件
+func (t *Tracker) Observe(result *model.Result, hops []routeutil.Hop) (*model.PathChange, error) {
+	mode := t.fingerprintMode()
+	path := routeutil.NormalizePath(hops, mode)
+	if len(path) == 0 {
+		return nil, nil
+	}
+	fingerprint := routeutil.Fingerprint(path)
+	pathJSON, _ := json.Marshal(path)
+	now := time.Now()
+
+	state, err := t.db.GetPathState(result.ProbeID, result.Target)
+	if err != nil {
+		return nil, err
+	}
+
+	next := &model.PathState{
+		ProbeID:     result.ProbeID,
+		Target:      result.Target,
+		Mode:        mode,
+		Fingerprint: fingerprint,
+		Path:        string(pathJSON),
+		ResultID:    result.ResultID,
+		FirstSeenAt: now,
+		LastSeenAt:  now,
+	}
+
+	// 首次观测或切换了指纹模式：只建立基线
+	if state == nil || state.Mode != mode {
+		return nil, t.db.SavePathState(next)
+	}
+
+	var oldPath []string
+	_ = json.Unmarshal([]byte(state.Path), &oldPath)
+	diff := routeutil.DiffPaths(oldPath, path)
+
+	// 指纹相同或仅未响应跳点不同：视为同一路径，保留原基线
+	if state.Fingerprint == fingerprint || len(diff) == 0 {
+		state.ResultID = result.ResultID
+		state.LastSeenAt = now
+		return nil, t.db.SavePathState(state)
+	}
+
+	diffJSON, _ := json.Marshal(diff)
+	change := &model.PathChange{
+		ChangeID:         uuid.New().String(),
+		ProbeID:          result.ProbeID,
+		Target:           result.Target,
+		TaskID:           result.TaskID,
+		ResultID:         result.ResultID,
+		PreviousResultID: state.ResultID,
+		Mode:             mode,
+		OldFingerprint:   state.Fingerprint,
+		NewFingerprint:   fingerprint,
+		OldPath:          state.Path,
+		NewPath:          string(pathJSON),
+		Diff:             string(diffJSON),
+		DetectedAt:       now,
+	}
+	if err := t.db.CreatePathChange(change); err != nil {
+		return nil, err
+	}
+	if err := t.db.SavePathState(next); err != nil {
+		return change, err
+	}
+
+	log.Printf("[PathWatch] Path changed: probe=%s target=%s hops_changed=%d", change.ProbeID, change.Target, len(diff))
+	t.notify(change, diff)
+	return change, nil
+}
+
+func (t *Tracker) fingerprintMode() string {
+	mode, _ := t.db.GetConfig("path_fingerprint_mode")
+	if strings.TrimSpace(mode) == routeutil.FingerprintByASN {
+		return routeutil.FingerprintByASN
+	}
+	return routeutil.FingerprintByIP
+}
+
+// notify 异步推送路径变化到配置的 Webhook
+func (t *Tracker) notify(change *model.PathChange, diff []routeutil.HopDiff) {
+	url, _ := t.db.GetConfig("path_change_webhook_url")
+	url = strings.TrimSpace(url)
+	if url == "" {
+		return
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"event":       "path_change",
+		"change_id":   change.ChangeID,
+		"probe_id":    change.ProbeID,
+		"target":      change.Target,
+		"task_id":     change.TaskID,
+		"result_id":   change.ResultID,
+		"mode":        change.Mode,
+		"diff":        diff,
+		"detected_at": change.DetectedAt,
+	})
+	if err != nil {
+		return
+	}
+
+	go func() {
+		if err := t.post(url, payload); err != nil {
+			log.Printf("[PathWatch] Failed to deliver path change webhook: %v", err)
+		}
+	}()
+}
+
+func (t *Tracker) post(url string, payload []byte) error {
+	resp, err := t.client.Post(url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package pathwatch
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"atlas/web/internal/database"
+	"atlas/web/internal/database/dbtest"
+	"atlas/web/internal/model"
+	"atlas/web/internal/routeutil"
+)
+
+func newTestDatabase(t *testing.T) *database.Database {
+	t.Helper()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Getwd failed: %v", err)
+	}
+	webRoot := filepath.Clean(filepath.Join(wd, "..", ".."))
+	if err := os.Chdir(webRoot); err != nil {
+		t.Fatalf("Chdir failed: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = os.Chdir(wd)
+	})
+
+	db, err := database.Open(dbtest.Source(t, filepath.Join(t.TempDir(), "atlas-pathwatch.db")))
+	if err != nil {
+		t.Fatalf("database.Open failed: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+
+	return db
+}
+
+func hopsFor(ips ...string) []routeutil.Hop {
+	hops := make([]routeutil.Hop, 0, len(ips))
+	for i, ip := range ips {
+		hops = append(hops, routeutil.Hop{Hop: i + 1, IP: ip})
+	}
+	return hops
+}
+
+func routeResult(resultID string) *model.Result {
+	return &model.Result{
+		ResultID: resultID,
+		TaskID:   "task-1",
+		ProbeID:  "probe-1",
+		Target:   "example.com",
+		TestType: "traceroute",
+		Status:   "success",
+	}
+}
+
+func TestObserveRecordsPathChangeWithHopDiff(t *testing.T) {
+	db := newTestDatabase(t)
+	tracker := New(db)
+
+	if change, err := tracker.Observe(routeResult("r1"), hopsFor("10.0.0.1", "203.0.113.1", "198.51.100.1")); err != nil || change != nil {
+		t.Fatalf("expected baseline without change, got change=%v err=%v", change, err)
+	}
+
+	// 中间跳超时、末尾截断：不算路径变化
+	if change, err := tracker.Observe(routeResult("r2"), hopsFor("10.0.0.1", "", "198.51.100.1", "")); err != nil || change != nil {
+		t.Fatalf("expected wildcard-only difference to be ignored, got change=%v err=%v", change, err)
+	}
+
+	change, err := tracker.Observe(routeResult("r3"), hopsFor("10.0.0.1", "203.0.113.77", "198.51.100.1", "192.0.2.1"))
+	if err != nil {
+		t.Fatalf("Observe failed: %v", err)
+	}
+	if change == nil {
+		t.Fatal("expected path change")
+	}
+	if change.PreviousResultID != "r2" || change.ResultID != "r3" {
+		t.Fatalf("unexpected result linkage: previous=%s current=%s", change.PreviousResultID, change.ResultID)
+	}
+
+	var diff []routeutil.HopDiff
+	if err := json.Unmarshal([]byte(change.Diff), &diff); err != nil {
+		t.Fatalf("failed to decode diff: %v", err)
+	}
+	if len(diff) != 2 {
+		t.Fatalf("expected 2 hop diffs, got %#v", diff)
+	}
+	if diff[0].Hop != 2 || diff[0].Change != "changed" || diff[0].Old != "203.0.113.1" || diff[0].New != "203.0.113.77" {
+		t.Fatalf("unexpected first diff: %#v", diff[0])
+	}
+	if diff[1].Hop != 4 || diff[1].Change != "added" {
+		t.Fatalf("unexpected second diff: %#v", diff[1])
+	}
+
+	changes, total, err := db.ListPathChanges("probe-1", "example.com", 10, 0)
+	if err != nil {
+		t.Fatalf("ListPathChanges failed: %v", err)
+	}
+	if total != 1 || len(changes) != 1 || changes[0].ChangeID != change.ChangeID {
+		t.Fatalf("expected stored change, total=%d changes=%v", total, changes)
+	}
+}
+
+func TestObserveASNModeNotifiesWebhook(t *testing.T) {
+	db := newTestDatabase(t)
+
+	received := make(chan map[string]interface{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var payload map[string]interface{}
+		_ = json.Unmarshal(body, &payload)
+		received <- payload
+	}))
+	defer server.Close()
+
+	if err := db.SetConfig("path_fingerprint_mode", "asn"); err != nil {
+		t.Fatalf("SetConfig failed: %v", err)
+	}
+	if err := db.SetConfig("path_change_webhook_url", server.URL); err != nil {
+		t.Fatalf("SetConfig failed: %v", err)
+	}
+
+	tracker := New(db)
+	first := []routeutil.Hop{{Hop: 1, IP: "203.0.113.1", ASN: "AS64500"}, {Hop: 2, IP: "198.51.100.1", ASN: "AS64501"}}
+	sameASes := []routeutil.Hop{{Hop: 1, IP: "203.0.113.2", ASN: "AS64500"}, {Hop: 2, IP: "198.51.100.9", ASN: "AS64501"}}
+	rerouted := []routeutil.Hop{{Hop: 1, IP: "203.0.113.1", ASN: "AS64500"}, {Hop: 2, IP: "192.0.2.1", ASN: "AS64999"}}
+
+	if _, err := tracker.Observe(routeResult("r1"), first); err != nil {
+		t.Fatalf("Observe failed: %v", err)
+	}
+	if change, _ := tracker.Observe(routeResult("r2"), sameASes); change != nil {
+		t.Fatal("expected IP churn inside the same ASes to be ignored in asn mode")
+	}
+	change, err := tracker.Observe(routeResult("r3"), rerouted)
+	if err != nil || change == nil {
+		t.Fatalf("expected AS path change, got change=%v err=%v", change, err)
+	}
+
+	select {
+	case payload := <-received:
+		if payload["event"] != "path_change" || payload["change_id"] != change.ChangeID {
+			t.Fatalf("unexpected webhook payload: %#v", payload)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("expected webhook delivery")
+	}
+}
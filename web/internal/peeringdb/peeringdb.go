@@ -0,0 +1,173 @@
+package peeringdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Index 基于本地 PeeringDB 导出文件构建的 IX 查询索引
+type Index struct {
+	prefixes []ixPrefix           // 按掩码长度降序，保证最长前缀匹配
+	members  map[string]*netIXLAN // key: 规范化后的成员 IP
+}
+
+// Match IX 匹配结果
+type Match struct {
+	IXID       int    `json:"ix_id"`
+	Name       string `json:"name"`
+	City       string `json:"city,omitempty"`
+	Country    string `json:"country,omitempty"`
+	Prefix     string `json:"prefix"`
+	MemberASN  int    `json:"member_asn,omitempty"`
+	MemberName string `json:"member_name,omitempty"`
+}
+
+type ixPrefix struct {
+	network *net.IPNet
+	ix      *ixRecord
+}
+
+// PeeringDB 导出结构：{"ix":{"data":[...]},"ixlan":{"data":[...]},"ixpfx":{"data":[...]},"netixlan":{"data":[...]}}
+type dump struct {
+	IX struct {
+		Data []ixRecord `json:"data"`
+	} `json:"ix"`
+	IXLAN struct {
+		Data []ixlanRecord `json:"data"`
+	} `json:"ixlan"`
+	IXPfx struct {
+		Data []ixpfxRecord `json:"data"`
+	} `json:"ixpfx"`
+	NetIXLAN struct {
+		Data []netIXLAN `json:"data"`
+	} `json:"netixlan"`
+}
+
+type ixRecord struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	City    string `json:"city"`
+	Country string `json:"country"`
+}
+
+type ixlanRecord struct {
+	ID   int `json:"id"`
+	IXID int `json:"ix_id"`
+}
+
+type ixpfxRecord struct {
+	IXLANID int    `json:"ixlan_id"`
+	Prefix  string `json:"prefix"`
+}
+
+type netIXLAN struct {
+	IXID    int    `json:"ix_id"`
+	IXLANID int    `json:"ixlan_id"`
+	Name    string `json:"name"`
+	ASN     int    `json:"asn"`
+	IPAddr4 string `json:"ipaddr4"`
+	IPAddr6 string `json:"ipaddr6"`
+}
+
+// Load 从磁盘读取 PeeringDB JSON 导出文件
+func Load(path string) (*Index, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read peeringdb dump: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse 解析 PeeringDB JSON 导出内容
+func Parse(data []byte) (*Index, error) {
+	var d dump
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, fmt.Errorf("failed to parse peeringdb dump: %w", err)
+	}
+
+	ixByID := make(map[int]*ixRecord, len(d.IX.Data))
+	for i := range d.IX.Data {
+		ix := &d.IX.Data[i]
+		ixByID[ix.ID] = ix
+	}
+
+	ixByLAN := make(map[int]*ixRecord, len(d.IXLAN.Data))
+	for _, lan := range d.IXLAN.Data {
+		if ix, ok := ixByID[lan.IXID]; ok {
+			ixByLAN[lan.ID] = ix
+		}
+	}
+
+	idx := &Index{
+		prefixes: make([]ixPrefix, 0, len(d.IXPfx.Data)),
+		members:  make(map[string]*netIXLAN, len(d.NetIXLAN.Data)),
+	}
+
+	for _, pfx := range d.IXPfx.Data {
+		ix, ok := ixByLAN[pfx.IXLANID]
+		if !ok {
+			continue
+		}
+		_, network, err := net.ParseCIDR(strings.TrimSpace(pfx.Prefix))
+		if err != nil {
+			continue
+		}
+		idx.prefixes = append(idx.prefixes, ixPrefix{network: network, ix: ix})
+	}
+	sort.SliceStable(idx.prefixes, func(i, j int) bool {
+		oi, _ := idx.prefixes[i].network.Mask.Size()
+		oj, _ := idx.prefixes[j].network.Mask.Size()
+		return oi > oj
+	})
+
+	for i := range d.NetIXLAN.Data {
+		member := &d.NetIXLAN.Data[i]
+		for _, raw := range []string{member.IPAddr4, member.IPAddr6} {
+			if ip := net.ParseIP(strings.TrimSpace(raw)); ip != nil {
+				idx.members[ip.String()] = member
+			}
+		}
+	}
+
+	return idx, nil
+}
+
+// Len 返回已加载的 IX 前缀数量
+func (idx *Index) Len() int {
+	if idx == nil {
+		return 0
+	}
+	return len(idx.prefixes)
+}
+
+// Lookup 判断 IP 是否位于某个 IX 的 peering LAN 内
+func (idx *Index) Lookup(ip net.IP) (*Match, bool) {
+	if idx == nil || ip == nil {
+		return nil, false
+	}
+
+	for _, pfx := range idx.prefixes {
+		if !pfx.network.Contains(ip) {
+			continue
+		}
+
+		match := &Match{
+			IXID:    pfx.ix.ID,
+			Name:    pfx.ix.Name,
+			City:    pfx.ix.City,
+			Country: pfx.ix.Country,
+			Prefix:  pfx.network.String(),
+		}
+		if member, ok := idx.members[ip.String()]; ok {
+			match.MemberASN = member.ASN
+			match.MemberName = member.Name
+		}
+		return match, true
+	}
+
+	return nil, false
+}
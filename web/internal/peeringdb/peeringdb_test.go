@@ -0,0 +1,76 @@
+package peeringdb
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testDump = `{
+	"ix": {"data": [
+		{"id": 26, "name": "AMS-IX", "city": "Amsterdam", "country": "NL"},
+		{"id": 31, "name": "DE-CIX Frankfurt", "city": "Frankfurt", "country": "DE"}
+	]},
+	"ixlan": {"data": [
+		{"id": 26, "ix_id": 26},
+		{"id": 31, "ix_id": 31}
+	]},
+	"ixpfx": {"data": [
+		{"ixlan_id": 26, "prefix": "80.249.208.0/21", "protocol": "IPv4"},
+		{"ixlan_id": 26, "prefix": "2001:7f8:1::/64", "protocol": "IPv6"},
+		{"ixlan_id": 31, "prefix": "80.81.192.0/21", "protocol": "IPv4"},
+		{"ixlan_id": 999, "prefix": "192.0.2.0/24", "protocol": "IPv4"}
+	]},
+	"netixlan": {"data": [
+		{"ix_id": 26, "ixlan_id": 26, "name": "Google LLC", "asn": 15169, "ipaddr4": "80.249.208.247", "ipaddr6": "2001:7f8:1::a501:5169:1"},
+		{"ix_id": 31, "ixlan_id": 31, "name": "Cloudflare", "asn": 13335, "ipaddr4": "80.81.194.180", "ipaddr6": null}
+	]}
+}`
+
+func TestLoadAndLookup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "peeringdb.json")
+	if err := os.WriteFile(path, []byte(testDump), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	idx, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if idx.Len() != 3 {
+		t.Fatalf("expected 3 prefixes with known IX, got %d", idx.Len())
+	}
+
+	match, ok := idx.Lookup(net.ParseIP("80.249.208.247"))
+	if !ok {
+		t.Fatal("expected AMS-IX match")
+	}
+	if match.Name != "AMS-IX" || match.City != "Amsterdam" || match.MemberASN != 15169 {
+		t.Fatalf("unexpected match: %#v", match)
+	}
+
+	match, ok = idx.Lookup(net.ParseIP("2001:7f8:1:0:0:a501:5169:1"))
+	if !ok || match.MemberASN != 15169 {
+		t.Fatalf("expected IPv6 member match, got %#v", match)
+	}
+
+	match, ok = idx.Lookup(net.ParseIP("80.81.192.10"))
+	if !ok || match.Name != "DE-CIX Frankfurt" || match.MemberASN != 0 {
+		t.Fatalf("expected DE-CIX match without member, got %#v", match)
+	}
+
+	if _, ok := idx.Lookup(net.ParseIP("8.8.8.8")); ok {
+		t.Fatal("expected no match outside IX LANs")
+	}
+	if _, ok := idx.Lookup(net.ParseIP("192.0.2.1")); ok {
+		t.Fatal("expected prefixes of unknown ixlan to be ignored")
+	}
+}
+
+func TestNilIndexLookup(t *testing.T) {
+	var idx *Index
+	if _, ok := idx.Lookup(net.ParseIP("80.249.208.1")); ok {
+		t.Fatal("expected nil index to never match")
+	}
+}
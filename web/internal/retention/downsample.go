@@ -0,0 +1,96 @@
+package retention
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"time"
+
+	"atlas/web/internal/database"
+	"atlas/web/internal/model"
+)
+
+// bucket 一个时间桶内的样本
+type bucket struct {
+	start     time.Time
+	samples   int
+	successes int
+	latencies []float64
+	lossSum   float64
+}
+
+// aggregate 把一组按时间升序的原始结果按粒度聚合为多行。
+// 延迟取每条结果摘要中的 avg_latency；丢包取 packet_loss_percent，缺失时失败记 100%、成功记 0
+func aggregate(series database.ResultSeries, granularity string, samples []database.ResultSample) []*model.ResultAggregate {
+	var buckets []*bucket
+	var current *bucket
+	for _, sample := range samples {
+		start := truncate(sample.CreatedAt, granularity)
+		if current == nil || !current.start.Equal(start) {
+			current = &bucket{start: start}
+			buckets = append(buckets, current)
+		}
+
+		summary := map[string]interface{}{}
+		if sample.Summary != "" {
+			_ = json.Unmarshal([]byte(sample.Summary), &summary)
+		}
+		success := sample.Status == "success"
+
+		current.samples++
+		if success {
+			current.successes++
+			if latency, ok := summary["avg_latency"].(float64); ok {
+				current.latencies = append(current.latencies, latency)
+			}
+		}
+		if loss, ok := summary["packet_loss_percent"].(float64); ok {
+			current.lossSum += loss
+		} else if !success {
+			current.lossSum += 100
+		}
+	}
+
+	aggregates := make([]*model.ResultAggregate, 0, len(buckets))
+	for _, b := range buckets {
+		loss := b.lossSum / float64(b.samples)
+		agg := &model.ResultAggregate{
+			TaskID:       series.TaskID,
+			ProbeID:      series.ProbeID,
+			Target:       series.Target,
+			TestType:     series.TestType,
+			Granularity:  granularity,
+			BucketStart:  b.start,
+			SampleCount:  b.samples,
+			SuccessCount: b.successes,
+			LatencyCount: len(b.latencies),
+			PacketLoss:   &loss,
+		}
+		if len(b.latencies) > 0 {
+			sort.Float64s(b.latencies)
+			sum := 0.0
+			for _, v := range b.latencies {
+				sum += v
+			}
+			minLatency := b.latencies[0]
+			maxLatency := b.latencies[len(b.latencies)-1]
+			avgLatency := sum / float64(len(b.latencies))
+			p95Latency := percentile(b.latencies, 95)
+			agg.MinLatency = &minLatency
+			agg.MaxLatency = &maxLatency
+			agg.AvgLatency = &avgLatency
+			agg.P95Latency = &p95Latency
+		}
+		aggregates = append(aggregates, agg)
+	}
+	return aggregates
+}
+
+// percentile 最近秩法取已排序样本的百分位数
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,216 @@
+package retention
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"atlas/web/internal/database"
+	"atlas/web/internal/leader"
+)
+
+// defaultCompactIntervalHours config 表缺少压缩间隔时使用的默认值
+const defaultCompactIntervalHours = 24
+
+// Job 后台执行结果保留策略：过期结果降采样或删除，并定期压缩数据库
+type Job struct {
+	db       *database.Database
+	interval time.Duration
+	elector  *leader.Elector
+	stopChan chan struct{}
+}
+
+// Stats 一轮清理的统计
+type Stats struct {
+	Downsampled      int64 `json:"downsampled"`       // 聚合后删除的原始结果数
+	Aggregates       int   `json:"aggregates"`        // 写入的聚合行数
+	Pruned           int64 `json:"pruned"`            // 未聚合直接删除的原始结果数
+	AggregatesPruned int64 `json:"aggregates_pruned"` // 过期删除的聚合行数
+	Compacted        bool  `json:"compacted"`
+}
+
+// New 创建保留策略任务，interval 为检查间隔
+func New(db *database.Database, interval time.Duration) *Job {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	return &Job{
+		db:       db,
+		interval: interval,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// SetElector 多副本部署时只在主节点上清理，避免多个节点重复聚合同一批结果
+func (j *Job) SetElector(elector *leader.Elector) {
+	j.elector = elector
+}
+
+// Start 启动清理循环
+func (j *Job) Start() {
+	log.Println("[Retention] Starting retention job...")
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	j.tick()
+	for {
+		select {
+		case <-ticker.C:
+			j.tick()
+		case <-j.stopChan:
+			log.Println("[Retention] Retention job stopped")
+			return
+		}
+	}
+}
+
+// Stop 停止清理循环
+func (j *Job) Stop() {
+	close(j.stopChan)
+}
+
+// store 清理写入使用的数据库：启用选举时携带 fencing token，租约易主后旧主节点的聚合与删除被拒绝
+func (j *Job) store() *database.Database {
+	if j.elector == nil {
+		return j.db
+	}
+	return j.db.WithFence(j.elector.Fence())
+}
+
+func (j *Job) tick() {
+	now := time.Now()
+	if j.elector != nil && !j.elector.IsLeader(now) {
+		return
+	}
+
+	stats, err := j.Run(now)
+	if err != nil {
+		log.Printf("[Retention] Run failed: %v", err)
+	}
+	if stats.Downsampled > 0 || stats.Pruned > 0 || stats.AggregatesPruned > 0 || stats.Compacted {
+		log.Printf("[Retention] Downsampled %d results into %d aggregates, pruned %d results and %d aggregates, compacted=%v",
+			stats.Downsampled, stats.Aggregates, stats.Pruned, stats.AggregatesPruned, stats.Compacted)
+	}
+}
+
+// Run 按 config 表中的策略执行一轮清理，now 为判断过期的基准时间
+func (j *Job) Run(now time.Time) (Stats, error) {
+	var stats Stats
+
+	raw, _ := j.db.GetConfig(PolicyConfigKey)
+	policy, err := ParsePolicy(raw)
+	if err != nil {
+		return stats, err
+	}
+
+	testTypes := make([]string, 0, len(policy))
+	for testType := range policy {
+		testTypes = append(testTypes, testType)
+	}
+	sort.Strings(testTypes)
+
+	for _, testType := range testTypes {
+		rule := policy[testType]
+		if rule.RawDays > 0 {
+			cutoff := daysBefore(now, rule.RawDays)
+			if rule.Downsample != "" {
+				// 截断到桶边界，保证每个桶一次聚合完整
+				cutoff = truncate(cutoff, rule.Downsample)
+				if err := j.downsample(testType, rule.Downsample, cutoff, &stats); err != nil {
+					return stats, fmt.Errorf("downsample %s: %w", testType, err)
+				}
+			}
+			// 单次任务等未聚合的过期结果直接删除
+			pruned, err := j.store().DeleteResultsBefore(testType, cutoff)
+			if err != nil {
+				return stats, fmt.Errorf("prune %s results: %w", testType, err)
+			}
+			stats.Pruned += pruned
+		}
+		if rule.AggregateDays > 0 {
+			pruned, err := j.store().DeleteResultAggregatesBefore(testType, daysBefore(now, rule.AggregateDays))
+			if err != nil {
+				return stats, fmt.Errorf("prune %s aggregates: %w", testType, err)
+			}
+			stats.AggregatesPruned += pruned
+		}
+	}
+
+	compacted, err := j.compactIfDue(now)
+	stats.Compacted = compacted
+	return stats, err
+}
+
+// downsample 把持续任务中早于 cutoff 的结果逐序列、逐天聚合并删除原始行，
+// 按天分段避免积压较多时一次读入过多结果
+func (j *Job) downsample(testType, granularity string, cutoff time.Time, stats *Stats) error {
+	seriesList, err := j.db.ListDownsampleSeries(testType, cutoff)
+	if err != nil {
+		return err
+	}
+
+	for _, series := range seriesList {
+		oldest, ok, err := j.db.OldestResultTime(series)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+
+		for from := truncate(oldest, GranularityDay); from.Before(cutoff); from = from.Add(24 * time.Hour) {
+			to := from.Add(24 * time.Hour)
+			if to.After(cutoff) {
+				to = cutoff
+			}
+
+			samples, err := j.db.ListResultSamples(series, from, to)
+			if err != nil {
+				return err
+			}
+			if len(samples) == 0 {
+				continue
+			}
+
+			aggregates := aggregate(series, granularity, samples)
+			deleted, err := j.store().ReplaceResultsWithAggregates(series, from, to, aggregates)
+			if err != nil {
+				return err
+			}
+			stats.Downsampled += deleted
+			stats.Aggregates += len(aggregates)
+		}
+	}
+	return nil
+}
+
+// compactIfDue 距上次压缩超过配置的间隔时压缩数据库，上次时间记录在 config 表中以便重启和主节点切换后延续
+func (j *Job) compactIfDue(now time.Time) (bool, error) {
+	hours := defaultCompactIntervalHours
+	if raw, err := j.db.GetConfig(CompactIntervalConfigKey); err == nil {
+		if v, err := strconv.Atoi(strings.TrimSpace(raw)); err == nil {
+			hours = v
+		}
+	}
+	if hours <= 0 {
+		return false, nil
+	}
+
+	if raw, err := j.db.GetConfig(compactLastRunConfigKey); err == nil {
+		if last, err := time.Parse(time.RFC3339, raw); err == nil && now.Sub(last) < time.Duration(hours)*time.Hour {
+			return false, nil
+		}
+	}
+
+	if err := j.db.Compact(); err != nil {
+		return false, fmt.Errorf("compact database: %w", err)
+	}
+	if err := j.db.SetConfig(compactLastRunConfigKey, now.UTC().Format(time.RFC3339)); err != nil {
+		return true, fmt.Errorf("record compaction time: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,191 @@
+package retention
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"atlas/web/internal/database"
+	"atlas/web/internal/database/dbtest"
+	"atlas/web/internal/model"
+)
+
+func newTestDatabase(t *testing.T) *database.Database {
+	t.Helper()
+
+	db, err := database.Open(dbtest.Source(t, filepath.Join(t.TempDir(), "atlas-retention.db")))
+	if err != nil {
+		t.Fatalf("database.Open failed: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	if err := db.SaveProbe(&model.Probe{
+		ProbeID:       "probe-1",
+		Name:          "probe-1",
+		Capabilities:  `["icmp_ping"]`,
+		Status:        "online",
+		LastHeartbeat: time.Now(),
+		Metadata:      `{}`,
+	}); err != nil {
+		t.Fatalf("SaveProbe failed: %v", err)
+	}
+
+	return db
+}
+
+func seedTask(t *testing.T, db *database.Database, taskID, mode string) {
+	t.Helper()
+	if err := db.CreateTask(&model.Task{TaskID: taskID, TaskType: "icmp_ping", Mode: mode, Target: "1.1.1.1", Status: "running", Priority: 5}); err != nil {
+		t.Fatalf("CreateTask failed: %v", err)
+	}
+}
+
+func seedResult(t *testing.T, db *database.Database, taskID, resultID, status, summary string) {
+	t.Helper()
+	if err := db.SaveExecution(&model.TaskExecution{ExecutionID: resultID, TaskID: taskID, ProbeID: "probe-1", Status: status, StartedAt: time.Now()}); err != nil {
+		t.Fatalf("SaveExecution failed: %v", err)
+	}
+	if err := db.SaveResult(&model.Result{
+		ResultID:    resultID,
+		ExecutionID: resultID,
+		TaskID:      taskID,
+		ProbeID:     "probe-1",
+		Target:      "1.1.1.1",
+		TestType:    "icmp_ping",
+		Status:      status,
+		ResultData:  "{}",
+		Summary:     summary,
+	}); err != nil {
+		t.Fatalf("SaveResult failed: %v", err)
+	}
+}
+
+func TestParsePolicyValidation(t *testing.T) {
+	cases := []struct {
+		raw     string
+		wantErr bool
+	}{
+		{``, false},
+		{`{"icmp_ping":{"raw_days":7,"downsample":"hour","aggregate_days":365}}`, false},
+		{`{"traceroute":{"raw_days":30}}`, false},
+		{`{"dns":{"raw_days":7}}`, true},
+		{`{"traceroute":{"raw_days":7,"downsample":"hour"}}`, true},
+		{`{"icmp_ping":{"downsample":"day"}}`, true},
+		{`{"icmp_ping":{"raw_days":7,"downsample":"week"}}`, true},
+		{`{"tcp_ping":{"raw_days":-1}}`, true},
+		{`{"tcp_ping":{"raw_days":7,"aggregate_days":30}}`, true},
+		{`not json`, true},
+	}
+	for _, tc := range cases {
+		_, err := ParsePolicy(tc.raw)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("ParsePolicy(%q) error = %v, wantErr %v", tc.raw, err, tc.wantErr)
+		}
+	}
+}
+
+func TestRunDownsamplesContinuousResultsAndPrunesTheRest(t *testing.T) {
+	db := newTestDatabase(t)
+	seedTask(t, db, "task-continuous", "continuous")
+	seedTask(t, db, "task-single", "single")
+
+	for i, latency := range []int{10, 20, 30} {
+		seedResult(t, db, "task-continuous", fmt.Sprintf("ok-%d", i), "success",
+			fmt.Sprintf(`{"avg_latency":%d,"packet_loss_percent":0}`, latency))
+	}
+	seedResult(t, db, "task-continuous", "failed-0", "failed", `{}`)
+	seedResult(t, db, "task-single", "single-0", "success", `{"avg_latency":5,"packet_loss_percent":0}`)
+
+	if err := db.SetConfig(PolicyConfigKey, `{"icmp_ping":{"raw_days":1,"downsample":"hour","aggregate_days":30}}`); err != nil {
+		t.Fatalf("SetConfig failed: %v", err)
+	}
+
+	job := New(db, time.Hour)
+	now := time.Now().Add(3 * 24 * time.Hour)
+	stats, err := job.Run(now)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if stats.Downsampled != 4 || stats.Pruned != 1 || !stats.Compacted {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	for _, taskID := range []string{"task-continuous", "task-single"} {
+		if results, _ := db.ListResultsByTask(taskID, 10, 0); len(results) != 0 {
+			t.Fatalf("expected raw results of %s to be removed, got %d", taskID, len(results))
+		}
+	}
+
+	from, to := time.Now().Add(-24*time.Hour), time.Now().Add(24*time.Hour)
+	aggregates, err := db.ListResultAggregates("task-continuous", "probe-1", from, to)
+	if err != nil {
+		t.Fatalf("ListResultAggregates failed: %v", err)
+	}
+	if len(aggregates) != stats.Aggregates {
+		t.Fatalf("expected %d aggregates, got %d", stats.Aggregates, len(aggregates))
+	}
+	samples, successes := 0, 0
+	for _, agg := range aggregates {
+		samples += agg.SampleCount
+		successes += agg.SuccessCount
+	}
+	if samples != 4 || successes != 3 {
+		t.Fatalf("expected 4 samples with 3 successes, got %d/%d", samples, successes)
+	}
+	// 样本落在同一小时时校验统计值
+	if len(aggregates) == 1 {
+		agg := aggregates[0]
+		if *agg.MinLatency != 10 || *agg.MaxLatency != 30 || *agg.AvgLatency != 20 || *agg.P95Latency != 30 || *agg.PacketLoss != 25 {
+			t.Fatalf("unexpected aggregate: min=%v avg=%v max=%v p95=%v loss=%v",
+				*agg.MinLatency, *agg.AvgLatency, *agg.MaxLatency, *agg.P95Latency, *agg.PacketLoss)
+		}
+	}
+
+	// 后到的结果合并进已有的时间桶
+	seedResult(t, db, "task-continuous", "ok-late", "success", `{"avg_latency":40,"packet_loss_percent":0}`)
+	stats, err = job.Run(now)
+	if err != nil {
+		t.Fatalf("second Run failed: %v", err)
+	}
+	if stats.Downsampled != 1 || stats.Compacted {
+		t.Fatalf("unexpected stats on second run: %+v", stats)
+	}
+	aggregates, _ = db.ListResultAggregates("task-continuous", "probe-1", from, to)
+	samples = 0
+	for _, agg := range aggregates {
+		samples += agg.SampleCount
+	}
+	if samples != 5 {
+		t.Fatalf("expected late result merged into aggregates, got %d samples", samples)
+	}
+
+	// 聚合行超过保留期后删除
+	stats, err = job.Run(now.Add(40 * 24 * time.Hour))
+	if err != nil {
+		t.Fatalf("third Run failed: %v", err)
+	}
+	if stats.AggregatesPruned != int64(len(aggregates)) {
+		t.Fatalf("expected %d aggregates pruned, got %+v", len(aggregates), stats)
+	}
+}
+
+func TestRunSkipsCompactionWhenDisabled(t *testing.T) {
+	db := newTestDatabase(t)
+	if err := db.SetConfig(CompactIntervalConfigKey, "0"); err != nil {
+		t.Fatalf("SetConfig failed: %v", err)
+	}
+
+	stats, err := New(db, time.Hour).Run(time.Now())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if stats.Compacted {
+		t.Fatalf("expected compaction to be disabled, got %+v", stats)
+	}
+}
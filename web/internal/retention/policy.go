@@ -0,0 +1,108 @@
+package retention
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// config 表中的配置键
+const (
+	PolicyConfigKey          = "result_retention"
+	CompactIntervalConfigKey = "vacuum_interval_hours"
+	compactLastRunConfigKey  = "vacuum_last_run"
+)
+
+// 降采样粒度
+const (
+	GranularityHour = "hour"
+	GranularityDay  = "day"
+)
+
+// Rule 单个测试类型的保留规则
+type Rule struct {
+	RawDays       int    `json:"raw_days"`                 // 原始结果保留天数，0 表示永久保留
+	Downsample    string `json:"downsample,omitempty"`     // hour/day：持续任务的过期结果先聚合再删除；为空时直接删除
+	AggregateDays int    `json:"aggregate_days,omitempty"` // 聚合行保留天数，0 表示永久保留
+}
+
+// Policy 按测试类型配置的保留策略，未列出的类型永久保留
+type Policy map[string]Rule
+
+// knownTestTypes 可配置保留策略的测试类型
+var knownTestTypes = map[string]bool{
+	"icmp_ping":  true,
+	"tcp_ping":   true,
+	"http_test":  true,
+	"traceroute": true,
+	"mtr":        true,
+}
+
+// downsampleTestTypes 结果摘要带延迟与丢包、可以聚合的测试类型
+var downsampleTestTypes = map[string]bool{
+	"icmp_ping": true,
+	"tcp_ping":  true,
+}
+
+// ParsePolicy 解析 config 表中的 JSON 策略，空串表示不清理
+func ParsePolicy(raw string) (Policy, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return Policy{}, nil
+	}
+
+	var policy Policy
+	if err := json.Unmarshal([]byte(raw), &policy); err != nil {
+		return nil, fmt.Errorf("invalid retention policy: %w", err)
+	}
+	if policy == nil {
+		policy = Policy{}
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// Validate 校验各测试类型的规则
+func (p Policy) Validate() error {
+	for testType, rule := range p {
+		if !knownTestTypes[testType] {
+			return fmt.Errorf("unknown test type in retention policy: %s", testType)
+		}
+		if rule.RawDays < 0 || rule.AggregateDays < 0 {
+			return fmt.Errorf("%s: retention days must not be negative", testType)
+		}
+		switch rule.Downsample {
+		case "":
+			if rule.AggregateDays > 0 {
+				return fmt.Errorf("%s: aggregate_days requires downsample", testType)
+			}
+		case GranularityHour, GranularityDay:
+			if !downsampleTestTypes[testType] {
+				return fmt.Errorf("%s: downsampling is only supported for icmp_ping and tcp_ping", testType)
+			}
+			if rule.RawDays == 0 {
+				return fmt.Errorf("%s: downsample requires raw_days", testType)
+			}
+		default:
+			return fmt.Errorf("%s: downsample must be hour or day", testType)
+		}
+	}
+	return nil
+}
+
+// truncate 把时间截断到粒度的起点(UTC)
+func truncate(t time.Time, granularity string) time.Time {
+	t = t.UTC()
+	if granularity == GranularityDay {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	return t.Truncate(time.Hour)
+}
+
+// daysBefore now 之前 days 天的时刻
+func daysBefore(now time.Time, days int) time.Time {
+	return now.Add(-time.Duration(days) * 24 * time.Hour)
+}
@@ -0,0 +1,156 @@
+package retry
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"atlas/web/internal/model"
+)
+
+// 执行失败分类
+const (
+	ClassSendFailure = "send_failure" // 下发到探针失败
+	ClassTimeout     = "timeout"      // 超时未回报或探针回报超时
+	ClassProbeError  = "probe_error"  // 探针执行出错
+	ClassLost        = "lost"         // 探针断线导致结果丢失
+)
+
+// 重试时的探针选择
+const (
+	ReassignSameProbe = "same_probe" // 等待原探针可用
+	ReassignAnyProbe  = "any_probe"  // 原探针不可用时改派给同等能力的探针
+)
+
+// MaxAttemptsLimit 单个执行链允许的最大尝试次数
+const MaxAttemptsLimit = 10
+
+// Policy 任务级重试策略，保存在 tasks.retry_policy 中
+//
+//	{"max_attempts":3,"backoff_seconds":30,"backoff_multiplier":2,"retry_on":["timeout","lost"]}
+type Policy struct {
+	MaxAttempts       int      `json:"max_attempts"`                  // 含首次执行的总尝试次数
+	BackoffSeconds    int      `json:"backoff_seconds,omitempty"`     // 首次重试前的等待，默认 30
+	BackoffMultiplier float64  `json:"backoff_multiplier,omitempty"`  // 每次重试等待的倍数，默认 2
+	MaxBackoffSeconds int      `json:"max_backoff_seconds,omitempty"` // 等待上限，默认 600
+	RetryOn           []string `json:"retry_on,omitempty"`            // 需要重试的失败分类，默认全部
+	Reassign          string   `json:"reassign,omitempty"`            // same_probe/any_probe，默认 same_probe
+}
+
+// Parse 解析 tasks.retry_policy；为空时返回 nil
+func Parse(raw string) (*Policy, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	policy := &Policy{}
+	if err := json.Unmarshal([]byte(raw), policy); err != nil {
+		return nil, fmt.Errorf("invalid retry_policy: %w", err)
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// Validate 填充默认值并校验
+func (p *Policy) Validate() error {
+	if p.MaxAttempts < 1 || p.MaxAttempts > MaxAttemptsLimit {
+		return fmt.Errorf("max_attempts must be between 1 and %d", MaxAttemptsLimit)
+	}
+	if p.BackoffSeconds < 0 || p.MaxBackoffSeconds < 0 || p.BackoffMultiplier < 0 {
+		return fmt.Errorf("backoff settings must not be negative")
+	}
+	if p.BackoffSeconds == 0 {
+		p.BackoffSeconds = 30
+	}
+	if p.BackoffMultiplier == 0 {
+		p.BackoffMultiplier = 2
+	}
+	if p.MaxBackoffSeconds == 0 {
+		p.MaxBackoffSeconds = 600
+	}
+
+	if len(p.RetryOn) == 0 {
+		p.RetryOn = []string{ClassSendFailure, ClassTimeout, ClassProbeError, ClassLost}
+	}
+	for _, class := range p.RetryOn {
+		switch class {
+		case ClassSendFailure, ClassTimeout, ClassProbeError, ClassLost:
+		default:
+			return fmt.Errorf("unsupported retry_on class %q", class)
+		}
+	}
+
+	switch p.Reassign {
+	case "":
+		p.Reassign = ReassignSameProbe
+	case ReassignSameProbe, ReassignAnyProbe:
+	default:
+		return fmt.Errorf("reassign must be same_probe or any_probe")
+	}
+	return nil
+}
+
+// String 序列化为 tasks.retry_policy 存储格式
+func (p *Policy) String() string {
+	data, _ := json.Marshal(p)
+	return string(data)
+}
+
+// ShouldRetry 第 attempt 次尝试以 class 失败后是否需要重试
+func (p *Policy) ShouldRetry(class string, attempt int) bool {
+	if p == nil || attempt >= p.MaxAttempts {
+		return false
+	}
+	for _, c := range p.RetryOn {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// Backoff 第 attempt 次尝试失败后到下一次尝试的等待时间
+func (p *Policy) Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	seconds := float64(p.BackoffSeconds) * math.Pow(p.BackoffMultiplier, float64(attempt-1))
+	if seconds > float64(p.MaxBackoffSeconds) {
+		seconds = float64(p.MaxBackoffSeconds)
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// NextAttempt 为失败的执行创建下一次尝试（status=pending，not_before 之后由调度器下发）
+func NextAttempt(failed *model.TaskExecution, notBefore time.Time) *model.TaskExecution {
+	retryOf := failed.ExecutionID
+	attempt := failed.Attempt
+	if attempt < 1 {
+		attempt = 1
+	}
+	return &model.TaskExecution{
+		ExecutionID: uuid.New().String(),
+		TaskID:      failed.TaskID,
+		ProbeID:     failed.ProbeID,
+		Target:      failed.Target,
+		Status:      "pending",
+		StartedAt:   time.Now(),
+		Attempt:     attempt + 1,
+		RetryOf:     &retryOf,
+		NotBefore:   &notBefore,
+		RunNumber:   failed.RunNumber,
+	}
+}
+
+// ClassifyResult 将探针回报的失败状态归类
+func ClassifyResult(status string) string {
+	if status == "timeout" {
+		return ClassTimeout
+	}
+	return ClassProbeError
+}
@@ -0,0 +1,64 @@
+package retry
+
+import (
+	"testing"
+	"time"
+
+	"atlas/web/internal/model"
+)
+
+func TestPolicyDefaultsAndBackoff(t *testing.T) {
+	policy, err := Parse(`{"max_attempts":4,"backoff_seconds":10,"max_backoff_seconds":25}`)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if policy.BackoffMultiplier != 2 || policy.Reassign != ReassignSameProbe || len(policy.RetryOn) != 4 {
+		t.Fatalf("unexpected defaults: %+v", policy)
+	}
+
+	want := []time.Duration{10 * time.Second, 20 * time.Second, 25 * time.Second}
+	for i, expected := range want {
+		if got := policy.Backoff(i + 1); got != expected {
+			t.Fatalf("attempt %d: expected backoff %s, got %s", i+1, expected, got)
+		}
+	}
+
+	if !policy.ShouldRetry(ClassProbeError, 3) || policy.ShouldRetry(ClassProbeError, 4) {
+		t.Fatal("expected retries to stop at max_attempts")
+	}
+}
+
+func TestPolicyRetryOnFilter(t *testing.T) {
+	policy, err := Parse(`{"max_attempts":3,"retry_on":["timeout","lost"]}`)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if policy.ShouldRetry(ClassProbeError, 1) || policy.ShouldRetry(ClassSendFailure, 1) {
+		t.Fatal("expected unlisted failure classes not to be retried")
+	}
+	if !policy.ShouldRetry(ClassTimeout, 1) {
+		t.Fatal("expected timeout to be retried")
+	}
+
+	for _, raw := range []string{`{"max_attempts":0}`, `{"max_attempts":11}`, `{"max_attempts":2,"retry_on":["oops"]}`, `{"max_attempts":2,"reassign":"nearest"}`} {
+		if _, err := Parse(raw); err == nil {
+			t.Fatalf("expected %s to be rejected", raw)
+		}
+	}
+}
+
+func TestNextAttemptLinksChain(t *testing.T) {
+	failed := &model.TaskExecution{ExecutionID: "exec-1", TaskID: "task-1", ProbeID: "probe-1", Attempt: 1}
+	notBefore := time.Now().Add(time.Minute)
+
+	next := NextAttempt(failed, notBefore)
+	if next.ExecutionID == "" || next.ExecutionID == failed.ExecutionID {
+		t.Fatalf("expected new execution id, got %q", next.ExecutionID)
+	}
+	if next.Attempt != 2 || next.RetryOf == nil || *next.RetryOf != "exec-1" || next.Status != "pending" {
+		t.Fatalf("unexpected next attempt: %+v", next)
+	}
+	if next.NotBefore == nil || !next.NotBefore.Equal(notBefore) {
+		t.Fatalf("expected not_before %s, got %v", notBefore, next.NotBefore)
+	}
+}
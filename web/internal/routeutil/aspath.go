@@ -0,0 +1,93 @@
+package routeutil
+
+// AS 路径分段类型
+const (
+	SegmentAS      = "as"      // 已知 ASN
+	SegmentPrivate = "private" // 私有/CGNAT 地址
+	SegmentUnknown = "unknown" // 超时或无 ASN 信息
+)
+
+// ASSegment AS 级路径中的一段（连续同 AS 的跳点合并）
+type ASSegment struct {
+	Kind       string   `json:"kind"`
+	ASN        string   `json:"asn,omitempty"`
+	ASName     string   `json:"as_name,omitempty"`
+	IXNames    []string `json:"ix_names,omitempty"`
+	FirstHop   int      `json:"first_hop"`
+	LastHop    int      `json:"last_hop"`
+	HopCount   int      `json:"hop_count"`
+	EntryRTTMs *float64 `json:"entry_rtt_ms,omitempty"`
+	ExitRTTMs  *float64 `json:"exit_rtt_ms,omitempty"`
+	// AddedRTTMs 本段出口 RTT 相对上一段出口 RTT 的增量
+	AddedRTTMs *float64 `json:"added_rtt_ms,omitempty"`
+	IPs        []string `json:"ips,omitempty"`
+}
+
+// BuildASPath 将逐跳路径折叠为 AS 级路径
+func BuildASPath(hops []Hop) []ASSegment {
+	segments := make([]ASSegment, 0)
+	var lastExit *float64
+
+	for _, hop := range hops {
+		kind, asn := classifyHop(hop)
+
+		if n := len(segments); n == 0 || segments[n-1].Kind != kind || segments[n-1].ASN != asn {
+			if n > 0 && segments[n-1].ExitRTTMs != nil {
+				lastExit = segments[n-1].ExitRTTMs
+			}
+			segments = append(segments, ASSegment{
+				Kind:     kind,
+				ASN:      asn,
+				FirstHop: hop.Hop,
+			})
+		}
+
+		seg := &segments[len(segments)-1]
+		seg.LastHop = hop.Hop
+		seg.HopCount++
+		if seg.ASName == "" {
+			seg.ASName = hop.ASName
+		}
+		if hop.Responded() {
+			seg.IPs = appendUnique(seg.IPs, hop.IP)
+		}
+		if hop.IXName != "" {
+			seg.IXNames = appendUnique(seg.IXNames, hop.IXName)
+		}
+		if hop.RTTMs != nil {
+			rtt := *hop.RTTMs
+			if seg.EntryRTTMs == nil {
+				seg.EntryRTTMs = &rtt
+			}
+			seg.ExitRTTMs = &rtt
+			if lastExit != nil {
+				added := rtt - *lastExit
+				seg.AddedRTTMs = &added
+			}
+		}
+	}
+
+	return segments
+}
+
+func classifyHop(hop Hop) (kind string, asn string) {
+	switch {
+	case !hop.Responded():
+		return SegmentUnknown, ""
+	case hop.Private:
+		return SegmentPrivate, ""
+	case hop.ASN != "":
+		return SegmentAS, hop.ASN
+	default:
+		return SegmentUnknown, ""
+	}
+}
+
+func appendUnique(values []string, v string) []string {
+	for _, existing := range values {
+		if existing == v {
+			return values
+		}
+	}
+	return append(values, v)
+}
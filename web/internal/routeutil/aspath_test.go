@@ -0,0 +1,75 @@
+package routeutil
+
+import "testing"
+
+const tracerouteWithGeo = `{
+	"target": "example.com",
+	"hops": [
+		{"hop": 1, "ip": "192.168.1.1", "rtts": [0.5, 0.7]},
+		{"hop": 2, "ip": "203.0.113.1", "rtts": [3, 5], "geo": {"asn": "AS64500", "as_name": "Access ISP"}},
+		{"hop": 3, "ip": "203.0.113.9", "rtts": [6], "geo": {"asn": "AS64500", "as_name": "Access ISP"}},
+		{"hop": 4, "ip": "*", "timeout": true},
+		{"hop": 5, "ip": "80.249.208.247", "rtts": [12], "geo": {"asn": "AS1200"}, "ix": {"name": "AMS-IX", "member_asn": "AS15169", "member_name": "Google LLC"}},
+		{"hop": 6, "ip": "142.250.1.1", "rtts": [14, 16], "geo": {"asn": "AS15169", "as_name": "Google LLC"}}
+	]
+}`
+
+func TestBuildASPathMergesConsecutiveHops(t *testing.T) {
+	hops, err := ParseHopsJSON(tracerouteWithGeo)
+	if err != nil {
+		t.Fatalf("ParseHopsJSON failed: %v", err)
+	}
+
+	path := BuildASPath(hops)
+	if len(path) != 4 {
+		t.Fatalf("expected 4 segments, got %d: %#v", len(path), path)
+	}
+
+	if path[0].Kind != SegmentPrivate || path[0].HopCount != 1 {
+		t.Fatalf("expected leading private segment, got %#v", path[0])
+	}
+
+	access := path[1]
+	if access.Kind != SegmentAS || access.ASN != "AS64500" || access.HopCount != 2 {
+		t.Fatalf("unexpected access segment: %#v", access)
+	}
+	if access.EntryRTTMs == nil || *access.EntryRTTMs != 4 || access.ExitRTTMs == nil || *access.ExitRTTMs != 6 {
+		t.Fatalf("unexpected access RTTs: entry=%v exit=%v", access.EntryRTTMs, access.ExitRTTMs)
+	}
+	if access.AddedRTTMs == nil || *access.AddedRTTMs != 5.4 {
+		t.Fatalf("expected 5.4ms added over private segment, got %v", access.AddedRTTMs)
+	}
+
+	if path[2].Kind != SegmentUnknown || path[2].FirstHop != 4 || path[2].LastHop != 4 {
+		t.Fatalf("expected unknown gap at hop 4, got %#v", path[2])
+	}
+
+	google := path[3]
+	if google.ASN != "AS15169" || google.HopCount != 2 || google.FirstHop != 5 {
+		t.Fatalf("expected IX member hop merged into AS15169, got %#v", google)
+	}
+	if len(google.IXNames) != 1 || google.IXNames[0] != "AMS-IX" {
+		t.Fatalf("expected AMS-IX to be recorded, got %v", google.IXNames)
+	}
+	if google.AddedRTTMs == nil || *google.AddedRTTMs != 9 {
+		t.Fatalf("expected 9ms added over access network, got %v", google.AddedRTTMs)
+	}
+}
+
+func TestParseHopsUsesMTRAverage(t *testing.T) {
+	hops := ParseHops(map[string]interface{}{
+		"hops": []interface{}{
+			map[string]interface{}{"hop": 1, "ip": "100.64.0.1", "avg_rtt_ms": 1.5, "loss_percent": 0},
+			map[string]interface{}{"hop": 2, "ip": "198.51.100.1", "avg_rtt_ms": 0.0, "timeout": true},
+		},
+	})
+	if len(hops) != 2 {
+		t.Fatalf("expected 2 hops, got %d", len(hops))
+	}
+	if hops[0].RTTMs == nil || *hops[0].RTTMs != 1.5 || !hops[0].Private {
+		t.Fatalf("expected CGNAT hop with 1.5ms avg, got %#v", hops[0])
+	}
+	if hops[1].RTTMs != nil {
+		t.Fatalf("expected zero avg to be treated as missing, got %v", *hops[1].RTTMs)
+	}
+}
@@ -0,0 +1,94 @@
+package routeutil
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// 路径指纹模式
+const (
+	FingerprintByIP  = "ip"  // 逐跳 IP
+	FingerprintByASN = "asn" // AS 级路径
+)
+
+// 未响应跳点的占位符
+const wildcardHop = "*"
+
+// HopDiff 两条路径在某个位置上的差异
+type HopDiff struct {
+	Hop    int    `json:"hop"` // ip 模式为跳数，asn 模式为 AS 路径中的序号
+	Old    string `json:"old,omitempty"`
+	New    string `json:"new,omitempty"`
+	Change string `json:"change"` // changed/added/removed
+}
+
+// NormalizePath 将 hops 规范化为用于比较的 token 序列
+func NormalizePath(hops []Hop, mode string) []string {
+	if mode == FingerprintByASN {
+		tokens := make([]string, 0)
+		for _, seg := range BuildASPath(hops) {
+			if seg.Kind != SegmentAS {
+				continue
+			}
+			if n := len(tokens); n > 0 && tokens[n-1] == seg.ASN {
+				continue
+			}
+			tokens = append(tokens, seg.ASN)
+		}
+		return tokens
+	}
+
+	tokens := make([]string, 0, len(hops))
+	for _, hop := range hops {
+		if hop.Responded() {
+			tokens = append(tokens, hop.IP)
+		} else {
+			tokens = append(tokens, wildcardHop)
+		}
+	}
+	// 末尾连续超时通常只是探测截断，不计入指纹
+	for len(tokens) > 0 && tokens[len(tokens)-1] == wildcardHop {
+		tokens = tokens[:len(tokens)-1]
+	}
+	return tokens
+}
+
+// Fingerprint 计算规范化路径的指纹
+func Fingerprint(path []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(path, ">")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// DiffPaths 按位置比较两条规范化路径；任一侧为未响应占位符时不视为变化
+func DiffPaths(oldPath, newPath []string) []HopDiff {
+	n := len(oldPath)
+	if len(newPath) > n {
+		n = len(newPath)
+	}
+
+	diffs := make([]HopDiff, 0)
+	for i := 0; i < n; i++ {
+		var oldToken, newToken string
+		if i < len(oldPath) {
+			oldToken = oldPath[i]
+		}
+		if i < len(newPath) {
+			newToken = newPath[i]
+		}
+
+		switch {
+		case oldToken == newToken:
+			continue
+		case oldToken == wildcardHop || newToken == wildcardHop:
+			continue
+		case oldToken == "":
+			diffs = append(diffs, HopDiff{Hop: i + 1, New: newToken, Change: "added"})
+		case newToken == "":
+			diffs = append(diffs, HopDiff{Hop: i + 1, Old: oldToken, Change: "removed"})
+		default:
+			diffs = append(diffs, HopDiff{Hop: i + 1, Old: oldToken, New: newToken, Change: "changed"})
+		}
+	}
+	return diffs
+}
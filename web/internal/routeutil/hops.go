@@ -0,0 +1,148 @@
+package routeutil
+
+import (
+	"encoding/json"
+	"net"
+	"strings"
+
+	"atlas/web/internal/targetutil"
+)
+
+// Hop traceroute/mtr 单跳的统一视图
+type Hop struct {
+	Hop      int      `json:"hop"`
+	IP       string   `json:"ip,omitempty"`
+	Hostname string   `json:"hostname,omitempty"`
+	RTTMs    *float64 `json:"rtt_ms,omitempty"` // traceroute 取多次探测均值，mtr 取 avg_rtt_ms
+	Timeout  bool     `json:"timeout,omitempty"`
+	ASN      string   `json:"asn,omitempty"`
+	ASName   string   `json:"as_name,omitempty"`
+	IXName   string   `json:"ix_name,omitempty"`
+	Private  bool     `json:"private,omitempty"`
+}
+
+// Responded 是否拿到了该跳的有效 IP
+func (h Hop) Responded() bool {
+	return h.IP != "" && h.IP != "*"
+}
+
+type rawResult struct {
+	Hops []rawHop `json:"hops"`
+}
+
+type rawHop struct {
+	Hop      int       `json:"hop"`
+	IP       string    `json:"ip"`
+	Hostname string    `json:"hostname"`
+	RTTs     []float64 `json:"rtts"`
+	AvgRTTMs *float64  `json:"avg_rtt_ms"`
+	Timeout  bool      `json:"timeout"`
+	Geo      *struct {
+		ASN    string `json:"asn"`
+		ASName string `json:"as_name"`
+	} `json:"geo"`
+	IX *struct {
+		Name       string `json:"name"`
+		MemberASN  string `json:"member_asn"`
+		MemberName string `json:"member_name"`
+	} `json:"ix"`
+}
+
+// ParseHopsJSON 从 result_data JSON 中解析 hops
+func ParseHopsJSON(raw string) ([]Hop, error) {
+	var result rawResult
+	if err := json.Unmarshal([]byte(raw), &result); err != nil {
+		return nil, err
+	}
+	return convertHops(result.Hops), nil
+}
+
+// ParseHops 从已解码的 result_data 中解析 hops
+func ParseHops(resultData interface{}) []Hop {
+	data, err := json.Marshal(resultData)
+	if err != nil {
+		return nil
+	}
+	hops, err := ParseHopsJSON(string(data))
+	if err != nil {
+		return nil
+	}
+	return hops
+}
+
+func convertHops(raw []rawHop) []Hop {
+	hops := make([]Hop, 0, len(raw))
+	for _, r := range raw {
+		hop := Hop{
+			Hop:      r.Hop,
+			IP:       strings.TrimSpace(r.IP),
+			Hostname: r.Hostname,
+			Timeout:  r.Timeout,
+		}
+		if hop.IP == "*" {
+			hop.IP = ""
+		}
+
+		if r.AvgRTTMs != nil && *r.AvgRTTMs > 0 {
+			v := *r.AvgRTTMs
+			hop.RTTMs = &v
+		} else if avg, ok := averagePositive(r.RTTs); ok {
+			hop.RTTMs = &avg
+		}
+
+		if ip := net.ParseIP(targetutil.StripIPv6Zone(hop.IP)); ip != nil {
+			hop.Private = ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || isSharedAddressSpace(ip)
+		}
+
+		if r.Geo != nil {
+			hop.ASN = normalizeASN(r.Geo.ASN)
+			hop.ASName = r.Geo.ASName
+		}
+		if r.IX != nil {
+			hop.IXName = r.IX.Name
+			// IX LAN 地址的 GeoIP 往往指向交换中心自身，成员 ASN 才是该路由器所属网络
+			if asn := normalizeASN(r.IX.MemberASN); asn != "" {
+				hop.ASN = asn
+				if r.IX.MemberName != "" {
+					hop.ASName = r.IX.MemberName
+				}
+			}
+		}
+
+		hops = append(hops, hop)
+	}
+	return hops
+}
+
+func averagePositive(values []float64) (float64, bool) {
+	sum := 0.0
+	n := 0
+	for _, v := range values {
+		if v > 0 {
+			sum += v
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, false
+	}
+	return sum / float64(n), true
+}
+
+func normalizeASN(raw string) string {
+	raw = strings.ToUpper(strings.TrimSpace(raw))
+	if raw == "" || raw == "AS0" {
+		return ""
+	}
+	if !strings.HasPrefix(raw, "AS") {
+		raw = "AS" + raw
+	}
+	return raw
+}
+
+// 100.64.0.0/10 运营商级 NAT 地址段
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0).To4(), Mask: net.CIDRMask(10, 32)}
+
+func isSharedAddressSpace(ip net.IP) bool {
+	return sharedAddressSpace.Contains(ip)
+}
@@ -0,0 +1,230 @@
+package routeutil
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 拓扑节点类型
+const (
+	NodeProbe = "probe"
+	NodeHop   = "hop"
+)
+
+// TopologyNode 拓扑图节点
+type TopologyNode struct {
+	ID            string   `json:"id"`
+	Kind          string   `json:"kind"`
+	IP            string   `json:"ip,omitempty"`
+	Hostname      string   `json:"hostname,omitempty"`
+	ASN           string   `json:"asn,omitempty"`
+	ASName        string   `json:"as_name,omitempty"`
+	IXName        string   `json:"ix_name,omitempty"`
+	Private       bool     `json:"private,omitempty"`
+	ObservedCount int      `json:"observed_count"`
+	Probes        []string `json:"probes"`
+}
+
+// TopologyEdge 拓扑图边（相邻两个有响应的跳点）
+type TopologyEdge struct {
+	From          string   `json:"from"`
+	To            string   `json:"to"`
+	ObservedCount int      `json:"observed_count"`
+	SkippedHops   int      `json:"skipped_hops,omitempty"` // 两端之间未响应跳点的最大数量
+	AvgDeltaMs    *float64 `json:"avg_latency_delta_ms,omitempty"`
+	MinDeltaMs    *float64 `json:"min_latency_delta_ms,omitempty"`
+	MaxDeltaMs    *float64 `json:"max_latency_delta_ms,omitempty"`
+	Probes        []string `json:"probes"`
+
+	deltaSum   float64
+	deltaCount int
+}
+
+// Topology 由多条路径合并得到的节点/边图
+type Topology struct {
+	Nodes []*TopologyNode `json:"nodes"`
+	Edges []*TopologyEdge `json:"edges"`
+	Paths int             `json:"paths"`
+}
+
+// TopologyBuilder 增量合并多条路径
+type TopologyBuilder struct {
+	nodes map[string]*TopologyNode
+	edges map[string]*TopologyEdge
+	paths int
+}
+
+// NewTopologyBuilder 创建拓扑构建器
+func NewTopologyBuilder() *TopologyBuilder {
+	return &TopologyBuilder{
+		nodes: make(map[string]*TopologyNode),
+		edges: make(map[string]*TopologyEdge),
+	}
+}
+
+// AddPath 合并一条由 probeID 观测到的路径
+func (b *TopologyBuilder) AddPath(probeID string, hops []Hop) {
+	if len(hops) == 0 {
+		return
+	}
+	b.paths++
+
+	prevID := "probe:" + probeID
+	prevNode := b.node(prevID, NodeProbe)
+	prevNode.ObservedCount++
+	prevNode.Probes = appendUnique(prevNode.Probes, probeID)
+
+	// 探针自身作为起点，RTT 视为 0
+	zero := 0.0
+	prevRTT := &zero
+	skipped := 0
+
+	for _, hop := range hops {
+		if !hop.Responded() {
+			skipped++
+			continue
+		}
+
+		node := b.node(hop.IP, NodeHop)
+		node.IP = hop.IP
+		node.Private = hop.Private
+		if node.Hostname == "" && hop.Hostname != "" && hop.Hostname != hop.IP {
+			node.Hostname = hop.Hostname
+		}
+		if node.ASN == "" {
+			node.ASN = hop.ASN
+			node.ASName = hop.ASName
+		}
+		if node.IXName == "" {
+			node.IXName = hop.IXName
+		}
+		node.ObservedCount++
+		node.Probes = appendUnique(node.Probes, probeID)
+
+		if prevID != hop.IP {
+			edge := b.edge(prevID, hop.IP)
+			edge.ObservedCount++
+			edge.Probes = appendUnique(edge.Probes, probeID)
+			if skipped > edge.SkippedHops {
+				edge.SkippedHops = skipped
+			}
+			if prevRTT != nil && hop.RTTMs != nil {
+				edge.addDelta(*hop.RTTMs - *prevRTT)
+			}
+		}
+
+		prevID = hop.IP
+		prevRTT = hop.RTTMs
+		skipped = 0
+	}
+}
+
+// Build 输出按 ID 排序的拓扑
+func (b *TopologyBuilder) Build() *Topology {
+	topo := &Topology{
+		Nodes: make([]*TopologyNode, 0, len(b.nodes)),
+		Edges: make([]*TopologyEdge, 0, len(b.edges)),
+		Paths: b.paths,
+	}
+	for _, node := range b.nodes {
+		sort.Strings(node.Probes)
+		topo.Nodes = append(topo.Nodes, node)
+	}
+	for _, edge := range b.edges {
+		sort.Strings(edge.Probes)
+		if edge.deltaCount > 0 {
+			avg := edge.deltaSum / float64(edge.deltaCount)
+			edge.AvgDeltaMs = &avg
+		}
+		topo.Edges = append(topo.Edges, edge)
+	}
+	sort.Slice(topo.Nodes, func(i, j int) bool { return topo.Nodes[i].ID < topo.Nodes[j].ID })
+	sort.Slice(topo.Edges, func(i, j int) bool {
+		if topo.Edges[i].From != topo.Edges[j].From {
+			return topo.Edges[i].From < topo.Edges[j].From
+		}
+		return topo.Edges[i].To < topo.Edges[j].To
+	})
+	return topo
+}
+
+// DOT 输出 Graphviz DOT 格式
+func (t *Topology) DOT() string {
+	var sb strings.Builder
+	sb.WriteString("digraph topology {\n")
+	sb.WriteString("  rankdir=LR;\n")
+	sb.WriteString("  node [shape=box, fontsize=10];\n")
+
+	for _, node := range t.Nodes {
+		label := node.ID
+		attrs := ""
+		if node.Kind == NodeProbe {
+			label = strings.TrimPrefix(node.ID, "probe:")
+			attrs = ", shape=ellipse, style=filled, fillcolor=lightblue"
+		} else {
+			if node.Hostname != "" {
+				label += "\\n" + node.Hostname
+			}
+			if node.ASN != "" {
+				label += "\\n" + node.ASN
+			}
+			if node.IXName != "" {
+				label += "\\n" + node.IXName
+				attrs = ", style=filled, fillcolor=lightyellow"
+			}
+		}
+		fmt.Fprintf(&sb, "  %s [label=%s%s];\n", dotQuote(node.ID), dotQuote(label), attrs)
+	}
+
+	for _, edge := range t.Edges {
+		label := fmt.Sprintf("n=%d", edge.ObservedCount)
+		if edge.AvgDeltaMs != nil {
+			label = fmt.Sprintf("%+.1fms n=%d", *edge.AvgDeltaMs, edge.ObservedCount)
+		}
+		style := ""
+		if edge.SkippedHops > 0 {
+			style = ", style=dashed"
+		}
+		fmt.Fprintf(&sb, "  %s -> %s [label=%s%s];\n", dotQuote(edge.From), dotQuote(edge.To), dotQuote(label), style)
+	}
+
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+func (b *TopologyBuilder) node(id, kind string) *TopologyNode {
+	node, ok := b.nodes[id]
+	if !ok {
+		node = &TopologyNode{ID: id, Kind: kind, Probes: []string{}}
+		b.nodes[id] = node
+	}
+	return node
+}
+
+func (b *TopologyBuilder) edge(from, to string) *TopologyEdge {
+	key := from + "->" + to
+	edge, ok := b.edges[key]
+	if !ok {
+		edge = &TopologyEdge{From: from, To: to, Probes: []string{}}
+		b.edges[key] = edge
+	}
+	return edge
+}
+
+func (e *TopologyEdge) addDelta(delta float64) {
+	e.deltaSum += delta
+	e.deltaCount++
+	if e.MinDeltaMs == nil || delta < *e.MinDeltaMs {
+		v := delta
+		e.MinDeltaMs = &v
+	}
+	if e.MaxDeltaMs == nil || delta > *e.MaxDeltaMs {
+		v := delta
+		e.MaxDeltaMs = &v
+	}
+}
+
+func dotQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
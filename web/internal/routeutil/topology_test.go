@@ -0,0 +1,64 @@
+package routeutil
+
+import (
+	"strings"
+	"testing"
+)
+
+func rtt(v float64) *float64 {
+	return &v
+}
+
+func TestTopologyBuilderMergesPaths(t *testing.T) {
+	builder := NewTopologyBuilder()
+	builder.AddPath("probe-a", []Hop{
+		{Hop: 1, IP: "10.0.0.1", RTTMs: rtt(1), Private: true},
+		{Hop: 2, IP: "203.0.113.1", RTTMs: rtt(5), ASN: "AS64500"},
+		{Hop: 3, IP: "198.51.100.1", RTTMs: rtt(20), ASN: "AS64501"},
+	})
+	builder.AddPath("probe-b", []Hop{
+		{Hop: 1, IP: "192.168.1.1", RTTMs: rtt(2), Private: true},
+		{Hop: 2, Timeout: true},
+		{Hop: 3, IP: "203.0.113.1", RTTMs: rtt(9), ASN: "AS64500"},
+		{Hop: 4, IP: "198.51.100.1", RTTMs: rtt(30), ASN: "AS64501"},
+	})
+
+	topo := builder.Build()
+	if topo.Paths != 2 {
+		t.Fatalf("expected 2 paths, got %d", topo.Paths)
+	}
+	if len(topo.Nodes) != 6 {
+		t.Fatalf("expected 6 nodes, got %d", len(topo.Nodes))
+	}
+
+	var shared *TopologyEdge
+	var skipped *TopologyEdge
+	for _, edge := range topo.Edges {
+		if edge.From == "203.0.113.1" && edge.To == "198.51.100.1" {
+			shared = edge
+		}
+		if edge.From == "192.168.1.1" && edge.To == "203.0.113.1" {
+			skipped = edge
+		}
+	}
+	if shared == nil {
+		t.Fatal("expected shared edge")
+	}
+	if shared.ObservedCount != 2 || len(shared.Probes) != 2 {
+		t.Fatalf("expected shared edge observed by both probes, got %+v", shared)
+	}
+	if shared.AvgDeltaMs == nil || *shared.AvgDeltaMs != 18 || *shared.MinDeltaMs != 15 || *shared.MaxDeltaMs != 21 {
+		t.Fatalf("unexpected latency delta on shared edge: %+v", shared)
+	}
+	if skipped == nil || skipped.SkippedHops != 1 {
+		t.Fatalf("expected edge across timed-out hop, got %+v", skipped)
+	}
+
+	dot := topo.DOT()
+	if !strings.HasPrefix(dot, "digraph topology {") {
+		t.Fatalf("unexpected DOT header: %s", dot)
+	}
+	if !strings.Contains(dot, `"203.0.113.1" -> "198.51.100.1"`) || !strings.Contains(dot, "style=dashed") {
+		t.Fatalf("expected merged edges in DOT output: %s", dot)
+	}
+}
@@ -0,0 +1,239 @@
+package sampling
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"atlas/web/internal/geoip"
+	"atlas/web/internal/model"
+	"atlas/web/internal/selector"
+	"atlas/web/internal/targetutil"
+)
+
+// 探针抽样策略
+const (
+	StrategyAll        = "all"         // 全部候选探针
+	StrategyRandom     = "random"      // 随机 N 个
+	StrategyPerRegion  = "per_region"  // 每个区域 N 个（默认 1）
+	StrategyPerCountry = "per_country" // 每个国家 N 个（默认 1）
+	StrategyPerASN     = "per_asn"     // 每个 ASN N 个（默认 1）
+	StrategyNearest    = "nearest"     // 按 GeoIP 距离离目标最近的 N 个
+)
+
+// Strategy 任务的探针抽样配置，保存在 tasks.sampling 中
+//
+//	{"strategy":"random","count":10}
+//	{"strategy":"per_asn"}
+//	{"strategy":"nearest","count":5}
+type Strategy struct {
+	Strategy string `json:"strategy"`
+	Count    int    `json:"count,omitempty"` // random/nearest 为总数，per_* 为每组数量
+}
+
+// Locator 查询 IP 的地理与 ASN 信息
+type Locator interface {
+	Lookup(ip string) (*geoip.Location, error)
+}
+
+// Parse 解析 tasks.sampling；为空时返回 nil（等同 all）
+func Parse(raw string) (*Strategy, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	s := &Strategy{}
+	if err := json.Unmarshal([]byte(raw), s); err != nil {
+		return nil, fmt.Errorf("invalid sampling: %w", err)
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Validate 填充默认值并校验
+func (s *Strategy) Validate() error {
+	s.Strategy = strings.ToLower(strings.TrimSpace(s.Strategy))
+	if s.Count < 0 {
+		return fmt.Errorf("sampling count must not be negative")
+	}
+
+	switch s.Strategy {
+	case "", StrategyAll:
+		s.Strategy = StrategyAll
+		s.Count = 0
+	case StrategyRandom, StrategyNearest:
+		if s.Count == 0 {
+			return fmt.Errorf("sampling strategy %s requires count", s.Strategy)
+		}
+	case StrategyPerRegion, StrategyPerCountry, StrategyPerASN:
+		if s.Count == 0 {
+			s.Count = 1
+		}
+	default:
+		return fmt.Errorf("unsupported sampling strategy %q", s.Strategy)
+	}
+	return nil
+}
+
+// String 序列化为 tasks.sampling 存储格式
+func (s *Strategy) String() string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
+
+// Select 从候选探针中按策略抽样；locator 为 nil 时 per_country/per_asn/nearest 只使用已有信息
+func (s *Strategy) Select(candidates []*model.Probe, target string, locator Locator) []*model.Probe {
+	if s == nil || s.Strategy == StrategyAll || len(candidates) == 0 {
+		return candidates
+	}
+
+	// 打乱后再分组/截取，避免总是命中同一批探针
+	shuffled := append([]*model.Probe(nil), candidates...)
+	rand.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+
+	switch s.Strategy {
+	case StrategyRandom:
+		return limit(shuffled, s.Count)
+	case StrategyPerRegion:
+		return perGroup(shuffled, s.Count, func(p *model.Probe) string { return p.Region })
+	case StrategyPerCountry:
+		return perGroup(shuffled, s.Count, func(p *model.Probe) string { return ProbeCountry(p, locator) })
+	case StrategyPerASN:
+		return perGroup(shuffled, s.Count, func(p *model.Probe) string { return probeASN(p, locator) })
+	case StrategyNearest:
+		return nearest(candidates, s.Count, target, locator)
+	}
+	return candidates
+}
+
+func limit(probes []*model.Probe, n int) []*model.Probe {
+	if n > 0 && len(probes) > n {
+		return probes[:n]
+	}
+	return probes
+}
+
+// perGroup 每组最多取 n 个；无法分组（key 为空）的探针不参与
+func perGroup(probes []*model.Probe, n int, key func(*model.Probe) string) []*model.Probe {
+	counts := make(map[string]int)
+	selected := make([]*model.Probe, 0)
+	for _, probe := range probes {
+		k := strings.ToLower(strings.TrimSpace(key(probe)))
+		if k == "" || counts[k] >= n {
+			continue
+		}
+		counts[k]++
+		selected = append(selected, probe)
+	}
+	return selected
+}
+
+// nearest 按与目标的大圆距离排序；缺少坐标的探针排在最后
+func nearest(probes []*model.Probe, n int, target string, locator Locator) []*model.Probe {
+	lat, lon, ok := targetCoordinates(target, locator)
+	if !ok {
+		return limit(probes, n)
+	}
+
+	type ranked struct {
+		probe    *model.Probe
+		distance float64
+	}
+	list := make([]ranked, 0, len(probes))
+	for _, probe := range probes {
+		d := math.Inf(1)
+		if probe.Latitude != nil && probe.Longitude != nil {
+			d = haversineKm(lat, lon, *probe.Latitude, *probe.Longitude)
+		}
+		list = append(list, ranked{probe: probe, distance: d})
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].distance < list[j].distance })
+
+	selected := make([]*model.Probe, 0, n)
+	for _, r := range list {
+		if len(selected) >= n {
+			break
+		}
+		selected = append(selected, r.probe)
+	}
+	return selected
+}
+
+func targetCoordinates(target string, locator Locator) (float64, float64, bool) {
+	if locator == nil {
+		return 0, 0, false
+	}
+	host := targetutil.StripIPv6Zone(targetutil.ExtractHost(target))
+	if host == "" {
+		return 0, 0, false
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		ips, err := net.DefaultResolver.LookupIP(ctx, "ip", host)
+		if err != nil || len(ips) == 0 {
+			return 0, 0, false
+		}
+		ip = ips[0]
+	}
+
+	location, err := locator.Lookup(ip.String())
+	if err != nil || location == nil || (location.Latitude == 0 && location.Longitude == 0) {
+		return 0, 0, false
+	}
+	return location.Latitude, location.Longitude, true
+}
+
+// ProbeCountry 探针所在国家：优先使用 country 标签，其次按探针 IP 查询 GeoIP。
+// 抽样与结果搜索的 country 过滤共用，保证两处对同一探针的判断一致
+func ProbeCountry(probe *model.Probe, locator Locator) string {
+	if country := selector.ProbeLabels(probe)["country"]; country != "" {
+		return country
+	}
+	if location := lookupProbe(probe, locator); location != nil {
+		return location.Country
+	}
+	return ""
+}
+
+// probeASN 优先使用 asn 标签，其次按探针 IP 查询 GeoIP
+func probeASN(probe *model.Probe, locator Locator) string {
+	if asn := selector.ProbeLabels(probe)["asn"]; asn != "" {
+		return asn
+	}
+	if location := lookupProbe(probe, locator); location != nil {
+		return location.ASN
+	}
+	return ""
+}
+
+func lookupProbe(probe *model.Probe, locator Locator) *geoip.Location {
+	if locator == nil || probe.IPAddress == "" {
+		return nil
+	}
+	location, err := locator.Lookup(probe.IPAddress)
+	if err != nil {
+		return nil
+	}
+	return location
+}
+
+func haversineKm(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadiusKm = 6371.0
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := toRad(lat2 - lat1)
+	dLon := toRad(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return earthRadiusKm * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
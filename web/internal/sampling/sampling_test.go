@@ -0,0 +1,122 @@
+package sampling
+
+import (
+	"fmt"
+	"testing"
+
+	"atlas/web/internal/geoip"
+	"atlas/web/internal/model"
+)
+
+type fakeLocator map[string]*geoip.Location
+
+func (f fakeLocator) Lookup(ip string) (*geoip.Location, error) {
+	if location, ok := f[ip]; ok {
+		return location, nil
+	}
+	return nil, fmt.Errorf("no location for %s", ip)
+}
+
+func coords(lat, lon float64) (*float64, *float64) {
+	return &lat, &lon
+}
+
+func testProbes() []*model.Probe {
+	berlinLat, berlinLon := coords(52.52, 13.40)
+	parisLat, parisLon := coords(48.85, 2.35)
+	tokyoLat, tokyoLon := coords(35.68, 139.69)
+	return []*model.Probe{
+		{ProbeID: "berlin", Region: "eu", IPAddress: "192.0.2.1", Latitude: berlinLat, Longitude: berlinLon},
+		{ProbeID: "paris", Region: "eu", IPAddress: "192.0.2.2", Latitude: parisLat, Longitude: parisLon},
+		{ProbeID: "tokyo", Region: "ap", IPAddress: "192.0.2.3", Latitude: tokyoLat, Longitude: tokyoLon},
+		{ProbeID: "unknown", Region: "ap", IPAddress: "192.0.2.4", Labels: `{"asn":"AS64500"}`},
+	}
+}
+
+func probeIDs(probes []*model.Probe) map[string]bool {
+	ids := make(map[string]bool, len(probes))
+	for _, probe := range probes {
+		ids[probe.ProbeID] = true
+	}
+	return ids
+}
+
+func TestParseValidatesStrategies(t *testing.T) {
+	if s, err := Parse(""); err != nil || s != nil {
+		t.Fatalf("expected empty sampling to be nil, got %v err=%v", s, err)
+	}
+	if _, err := Parse(`{"strategy":"random"}`); err == nil {
+		t.Fatal("expected random without count to fail")
+	}
+	if _, err := Parse(`{"strategy":"closest","count":2}`); err == nil {
+		t.Fatal("expected unknown strategy to fail")
+	}
+
+	s, err := Parse(`{"strategy":"PER_ASN"}`)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if s.Strategy != StrategyPerASN || s.Count != 1 {
+		t.Fatalf("unexpected defaults: %#v", s)
+	}
+	if s.String() != `{"strategy":"per_asn","count":1}` {
+		t.Fatalf("unexpected serialization: %s", s.String())
+	}
+}
+
+func TestSelectRandomAndPerRegion(t *testing.T) {
+	probes := testProbes()
+
+	random := &Strategy{Strategy: StrategyRandom, Count: 2}
+	if got := random.Select(probes, "example.com", nil); len(got) != 2 {
+		t.Fatalf("expected 2 random probes, got %d", len(got))
+	}
+
+	perRegion := &Strategy{Strategy: StrategyPerRegion, Count: 1}
+	got := perRegion.Select(probes, "example.com", nil)
+	regions := map[string]int{}
+	for _, probe := range got {
+		regions[probe.Region]++
+	}
+	if len(got) != 2 || regions["eu"] != 1 || regions["ap"] != 1 {
+		t.Fatalf("expected one probe per region, got %v", regions)
+	}
+}
+
+func TestSelectPerASNUsesLabelsAndGeoIP(t *testing.T) {
+	locator := fakeLocator{
+		"192.0.2.1": {ASN: "AS64501", Country: "DE"},
+		"192.0.2.2": {ASN: "AS64501", Country: "FR"},
+		"192.0.2.3": {ASN: "AS64502", Country: "JP"},
+	}
+
+	s := &Strategy{Strategy: StrategyPerASN, Count: 1}
+	got := s.Select(testProbes(), "example.com", locator)
+	ids := probeIDs(got)
+	if len(got) != 3 || !ids["tokyo"] || !ids["unknown"] || ids["berlin"] == ids["paris"] {
+		t.Fatalf("expected one probe for each of 3 ASNs, got %v", ids)
+	}
+
+	perCountry := &Strategy{Strategy: StrategyPerCountry, Count: 1}
+	if got := perCountry.Select(testProbes(), "example.com", locator); len(got) != 3 || probeIDs(got)["unknown"] {
+		t.Fatalf("expected probes without country to be skipped, got %v", probeIDs(got))
+	}
+}
+
+func TestSelectNearestByTargetLocation(t *testing.T) {
+	locator := fakeLocator{
+		// 目标位于阿姆斯特丹
+		"198.51.100.10": {Latitude: 52.37, Longitude: 4.90},
+	}
+
+	s := &Strategy{Strategy: StrategyNearest, Count: 2}
+	got := s.Select(testProbes(), "198.51.100.10", locator)
+	if len(got) != 2 || got[0].ProbeID != "paris" || got[1].ProbeID != "berlin" {
+		t.Fatalf("expected paris then berlin as nearest, got %v", probeIDs(got))
+	}
+
+	// 无法定位目标时退化为按候选顺序截取
+	if got := s.Select(testProbes(), "203.0.113.99", locator); len(got) != 2 {
+		t.Fatalf("expected fallback to return 2 probes, got %d", len(got))
+	}
+}
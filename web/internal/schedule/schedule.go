@@ -0,0 +1,177 @@
+package schedule
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// 调度类型
+const (
+	TypeCron     = "cron"
+	TypeInterval = "interval"
+)
+
+// MinInterval 周期任务允许的最小间隔
+const MinInterval = time.Second
+
+// Spec 任务调度配置，保存在 tasks.schedule 中
+//
+//	{"type":"cron","expr":"*/15 * * * *","timezone":"Asia/Shanghai"}
+//	{"type":"interval","expr":"15m","jitter_seconds":30,"end_at":"2026-12-31T00:00:00Z"}
+//
+// type 为空时表示旧版持续 ping：按 interval_seconds（默认 1s）执行，最多 max_runs 次
+type Spec struct {
+	Type            string     `json:"type,omitempty"`
+	Expr            string     `json:"expr,omitempty"`             // cron 表达式，或 interval 的时长（如 "15m"）
+	IntervalSeconds int        `json:"interval_seconds,omitempty"` // interval 的秒数，与 expr 二选一
+	Timezone        string     `json:"timezone,omitempty"`         // cron 使用的 IANA 时区，默认 UTC
+	StartAt         *time.Time `json:"start_at,omitempty"`         // 窗口开始，之前不执行
+	EndAt           *time.Time `json:"end_at,omitempty"`           // 窗口结束，之后任务完成
+	JitterSeconds   int        `json:"jitter_seconds,omitempty"`   // 每次运行随机延后 [0, jitter) 秒
+	MaxRuns         int        `json:"max_runs,omitempty"`         // 最多运行次数，0 表示不限
+	RunCount        int        `json:"run_count"`
+	LastRunAt       *time.Time `json:"last_run_at,omitempty"`
+
+	cron     *cronExpr
+	location *time.Location
+	interval time.Duration
+}
+
+// Parse 解析并校验 tasks.schedule；空字符串返回旧版持续 ping 调度
+func Parse(raw string) (*Spec, error) {
+	spec := &Spec{}
+	if strings.TrimSpace(raw) != "" {
+		if err := json.Unmarshal([]byte(raw), spec); err != nil {
+			return nil, fmt.Errorf("invalid schedule: %w", err)
+		}
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// IsLegacy 是否为旧版持续 ping 调度（未指定 type）
+func (s *Spec) IsLegacy() bool {
+	return s.Type == ""
+}
+
+// String 序列化为 tasks.schedule 存储格式
+func (s *Spec) String() string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
+
+// Validate 规范化并校验调度配置
+func (s *Spec) Validate() error {
+	s.Type = strings.ToLower(strings.TrimSpace(s.Type))
+	if s.RunCount < 0 {
+		s.RunCount = 0
+	}
+	if s.MaxRuns < 0 {
+		return fmt.Errorf("max_runs must not be negative")
+	}
+	if s.JitterSeconds < 0 {
+		return fmt.Errorf("jitter_seconds must not be negative")
+	}
+	if s.StartAt != nil && s.EndAt != nil && !s.EndAt.After(*s.StartAt) {
+		return fmt.Errorf("end_at must be after start_at")
+	}
+
+	s.location = time.UTC
+	if s.Timezone != "" {
+		loc, err := time.LoadLocation(s.Timezone)
+		if err != nil {
+			return fmt.Errorf("invalid timezone %q", s.Timezone)
+		}
+		s.location = loc
+	}
+
+	switch s.Type {
+	case TypeCron:
+		c, err := parseCron(s.Expr)
+		if err != nil {
+			return err
+		}
+		s.cron = c
+	case TypeInterval, "":
+		switch {
+		case s.Expr != "":
+			d, err := time.ParseDuration(s.Expr)
+			if err != nil {
+				return fmt.Errorf("invalid interval %q", s.Expr)
+			}
+			s.interval = d
+		case s.IntervalSeconds > 0:
+			s.interval = time.Duration(s.IntervalSeconds) * time.Second
+		case s.Type == "":
+			s.interval = MinInterval
+		default:
+			return fmt.Errorf("interval schedule requires expr or interval_seconds")
+		}
+		if s.interval < MinInterval {
+			return fmt.Errorf("interval must be at least %s", MinInterval)
+		}
+	default:
+		return fmt.Errorf("unsupported schedule type %q", s.Type)
+	}
+	return nil
+}
+
+// First 计算任务创建后的首次运行时间；窗口已结束时返回 false
+func (s *Spec) First(now time.Time) (time.Time, bool) {
+	if s.cron == nil {
+		// interval 立即开始（或从窗口开始时刻开始）
+		first := now
+		if s.StartAt != nil && s.StartAt.After(now) {
+			first = *s.StartAt
+		}
+		return s.finish(first)
+	}
+	return s.Next(now)
+}
+
+// Next 计算 now 之后的下次运行时间（已含抖动）；到达窗口结束或运行次数上限时返回 false
+func (s *Spec) Next(now time.Time) (time.Time, bool) {
+	if s.MaxRuns > 0 && s.RunCount >= s.MaxRuns {
+		return time.Time{}, false
+	}
+
+	base := now
+	if s.StartAt != nil && s.StartAt.After(base) {
+		// cron 从窗口开始前一刻起找，使恰好落在 start_at 的时间点也能命中
+		base = s.StartAt.Add(-time.Nanosecond)
+		if s.cron == nil {
+			return s.finish(*s.StartAt)
+		}
+	}
+
+	if s.cron != nil {
+		next, ok := s.cron.next(base, s.location)
+		if !ok {
+			return time.Time{}, false
+		}
+		return s.finish(next)
+	}
+	return s.finish(base.Add(s.interval))
+}
+
+// MarkRun 记录一次运行
+func (s *Spec) MarkRun(at time.Time) {
+	s.RunCount++
+	s.LastRunAt = &at
+}
+
+func (s *Spec) finish(t time.Time) (time.Time, bool) {
+	if s.JitterSeconds > 0 {
+		t = t.Add(time.Duration(rand.Int63n(int64(s.JitterSeconds) * int64(time.Second))))
+	}
+	if s.EndAt != nil && t.After(*s.EndAt) {
+		return time.Time{}, false
+	}
+	// 与 time.Now() 同时区存储，保证 next_run_at 字符串比较有序
+	return t.In(time.Local).Round(0), true
+}
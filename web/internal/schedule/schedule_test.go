@@ -0,0 +1,110 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func mustTime(t *testing.T, value string) time.Time {
+	t.Helper()
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t.Fatalf("time.Parse failed: %v", err)
+	}
+	return parsed
+}
+
+func TestCronNextWithTimezone(t *testing.T) {
+	spec, err := Parse(`{"type":"cron","expr":"30 9 * * mon-fri","timezone":"Asia/Shanghai"}`)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	// 2026-10-16 是周五，上海时间 09:30 已过，下一次为周一
+	next, ok := spec.Next(mustTime(t, "2026-10-16T02:00:00Z"))
+	if !ok {
+		t.Fatal("expected next run")
+	}
+	if want := mustTime(t, "2026-10-19T01:30:00Z"); !next.Equal(want) {
+		t.Fatalf("expected %s, got %s", want, next.UTC())
+	}
+}
+
+func TestCronStepsAndDayUnion(t *testing.T) {
+	spec, err := Parse(`{"type":"cron","expr":"*/15 * * * *"}`)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	next, _ := spec.Next(mustTime(t, "2026-10-18T10:15:00Z"))
+	if want := mustTime(t, "2026-10-18T10:30:00Z"); !next.Equal(want) {
+		t.Fatalf("expected %s, got %s", want, next.UTC())
+	}
+
+	// 日与周同时指定时取并集：1 号或周日
+	spec, err = Parse(`{"type":"cron","expr":"0 0 1 * 7"}`)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	next, _ = spec.Next(mustTime(t, "2026-10-18T00:00:00Z"))
+	if want := mustTime(t, "2026-10-25T00:00:00Z"); !next.Equal(want) {
+		t.Fatalf("expected %s, got %s", want, next.UTC())
+	}
+
+	for _, expr := range []string{"* * *", "61 * * * *", "* * * * 8", "5-1 * * * *", "*/0 * * * *"} {
+		if _, err := Parse(`{"type":"cron","expr":"` + expr + `"}`); err == nil {
+			t.Fatalf("expected %q to be rejected", expr)
+		}
+	}
+}
+
+func TestIntervalWindowAndJitter(t *testing.T) {
+	spec, err := Parse(`{"type":"interval","expr":"15m","start_at":"2026-10-18T12:00:00Z","end_at":"2026-10-18T12:40:00Z","jitter_seconds":30}`)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	now := mustTime(t, "2026-10-18T11:00:00Z")
+	first, ok := spec.First(now)
+	if !ok {
+		t.Fatal("expected first run")
+	}
+	start := mustTime(t, "2026-10-18T12:00:00Z")
+	if first.Before(start) || !first.Before(start.Add(30*time.Second)) {
+		t.Fatalf("expected first run within jitter of window start, got %s", first.UTC())
+	}
+
+	next, ok := spec.Next(mustTime(t, "2026-10-18T12:15:00Z"))
+	if !ok || next.Before(mustTime(t, "2026-10-18T12:30:00Z")) {
+		t.Fatalf("expected next run after 12:30, got %s ok=%v", next.UTC(), ok)
+	}
+
+	if _, ok := spec.Next(mustTime(t, "2026-10-18T12:30:00Z")); ok {
+		t.Fatal("expected no run past end_at")
+	}
+}
+
+func TestMaxRunsAndLegacy(t *testing.T) {
+	spec, err := Parse(`{"run_count":2,"interval_seconds":1,"max_runs":3}`)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if !spec.IsLegacy() {
+		t.Fatal("expected legacy schedule")
+	}
+
+	now := time.Now()
+	if next, ok := spec.Next(now); !ok || next.Sub(now) != time.Second {
+		t.Fatalf("expected 1s legacy interval, got %s ok=%v", next.Sub(now), ok)
+	}
+	spec.MarkRun(now)
+	if _, ok := spec.Next(now); ok {
+		t.Fatal("expected schedule to stop after max_runs")
+	}
+
+	if _, err := Parse(`{"type":"interval","expr":"100ms"}`); err == nil {
+		t.Fatal("expected sub-second interval to be rejected")
+	}
+	if _, err := Parse(`{"type":"cron","expr":"* * * * *","timezone":"Mars/Base"}`); err == nil {
+		t.Fatal("expected unknown timezone to be rejected")
+	}
+}
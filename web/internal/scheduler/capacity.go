@@ -0,0 +1,119 @@
+package scheduler
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	"atlas/web/internal/model"
+)
+
+// hasCapacity 探针是否还有空闲执行槽位
+//
+// 负载按服务端已下发且未回报结果的执行计算，比 30s 一次的心跳更及时；
+// 容量为注册时上报的 max_concurrent_tasks，旧版探针未上报时不限制。
+func (s *Scheduler) hasCapacity(probeID string) bool {
+	probe, err := s.db.GetProbe(probeID)
+	if err != nil || probe.MaxConcurrentTasks <= 0 {
+		return true
+	}
+
+	running, err := s.db.CountRunningExecutions(probeID)
+	if err != nil {
+		log.Printf("[Scheduler] Failed to count running executions of probe %s: %v", probeID, err)
+		return true
+	}
+	return running < probe.MaxConcurrentTasks
+}
+
+// DispatchQueued 探针释放执行槽位后，按任务优先级下发其排队中的执行。
+// 非主节点转交主节点处理；未配置总线时由主节点的周期扫描下发
+func (s *Scheduler) DispatchQueued(probeID string) {
+	if !s.isLeader() {
+		s.forwardToLeader(leaderEvent{Kind: eventProbeCapacity, ProbeID: probeID})
+		return
+	}
+	s.dispatchQueuedFor(probeID)
+}
+
+// dispatchQueuedFor 在主节点上下发探针排队中的执行
+func (s *Scheduler) dispatchQueuedFor(probeID string) {
+	s.dispatchMu.Lock()
+	defer s.dispatchMu.Unlock()
+
+	if !s.hub.IsProbeOnline(probeID) {
+		return
+	}
+
+	executions, err := s.db.ListActiveExecutions(probeID)
+	if err != nil {
+		log.Printf("[Scheduler] Failed to list executions of probe %s: %v", probeID, err)
+		return
+	}
+
+	tasks := s.loadExecutionTasks(executions)
+	now := time.Now()
+	for _, execution := range executions {
+		if !isQueued(execution) {
+			continue
+		}
+		if !s.hasCapacity(probeID) {
+			return
+		}
+		if s.dispatchQueued(tasks[execution.TaskID], execution, now) {
+			s.finalizeTaskIfDone(execution.TaskID)
+		}
+	}
+}
+
+// dispatchQueued 探针有空闲槽位时下发排队中的执行。返回执行是否已进入终态
+func (s *Scheduler) dispatchQueued(task *model.Task, execution *model.TaskExecution, now time.Time) bool {
+	if task == nil || isTaskTerminal(task.Status) {
+		s.finishExecution(execution, "cancelled", "", "task is no longer active")
+		return true
+	}
+	// 任务暂停期间保留排队的执行，恢复后再下发
+	if task.Status == "paused" {
+		return false
+	}
+	if !s.hasCapacity(execution.ProbeID) {
+		return false
+	}
+
+	// 超时从实际下发时开始计算
+	if err := s.store().ReassignExecution(execution.ExecutionID, execution.ProbeID, now); err != nil {
+		log.Printf("[Scheduler] Failed to dequeue execution %s: %v", execution.ExecutionID, err)
+		return false
+	}
+	execution.StartedAt = now
+
+	log.Printf("[Scheduler] Dispatching queued execution %s to probe %s", execution.ExecutionID, execution.ProbeID)
+	return !s.dispatchExecution(task, execution)
+}
+
+// loadExecutionTasks 加载执行所属的任务，并将执行按任务优先级从高到低稳定排序
+func (s *Scheduler) loadExecutionTasks(executions []*model.TaskExecution) map[string]*model.Task {
+	tasks := make(map[string]*model.Task)
+	for _, execution := range executions {
+		if _, ok := tasks[execution.TaskID]; !ok {
+			task, _ := s.db.GetTask(execution.TaskID)
+			tasks[execution.TaskID] = task
+		}
+	}
+
+	priority := func(execution *model.TaskExecution) int {
+		if task := tasks[execution.TaskID]; task != nil {
+			return task.Priority
+		}
+		return 0
+	}
+	sort.SliceStable(executions, func(i, j int) bool {
+		return priority(executions[i]) > priority(executions[j])
+	})
+	return tasks
+}
+
+// isQueued 因探针满载尚未下发的首次执行
+func isQueued(execution *model.TaskExecution) bool {
+	return execution.Status == "pending" && execution.RetryOf == nil
+}
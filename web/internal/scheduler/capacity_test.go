@@ -0,0 +1,121 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"atlas/web/internal/model"
+)
+
+func TestHasCapacityCountsRunningExecutions(t *testing.T) {
+	sched, db := newTestScheduler(t)
+	seedRunningExecution(t, db, "task-1", "exec-1")
+
+	// 旧版探针未上报容量：不限制
+	if !sched.hasCapacity("probe-1") {
+		t.Fatal("expected probe without reported capacity to be unlimited")
+	}
+
+	probe, err := db.GetProbe("probe-1")
+	if err != nil {
+		t.Fatalf("GetProbe failed: %v", err)
+	}
+	probe.MaxConcurrentTasks = 1
+	if err := db.SaveProbe(probe); err != nil {
+		t.Fatalf("SaveProbe failed: %v", err)
+	}
+	if sched.hasCapacity("probe-1") {
+		t.Fatal("expected probe with 1 running execution and capacity 1 to be full")
+	}
+
+	execution, _ := db.GetExecution("exec-1")
+	sched.finishExecution(execution, "success", "", "")
+	if !sched.hasCapacity("probe-1") {
+		t.Fatal("expected capacity to free up after the execution finished")
+	}
+}
+
+func TestQueuedExecutionsOrderedByPriorityAndCancelledWithTask(t *testing.T) {
+	sched, db := newTestScheduler(t)
+	seedRunningExecution(t, db, "task-1", "exec-1")
+
+	for _, task := range []*model.Task{
+		{TaskID: "task-low", TaskType: "icmp_ping", Mode: "single", Target: "1.1.1.1", Status: "running", Priority: 2},
+		{TaskID: "task-high", TaskType: "icmp_ping", Mode: "single", Target: "1.1.1.1", Status: "cancelled", Priority: 9},
+	} {
+		if err := db.CreateTask(task); err != nil {
+			t.Fatalf("CreateTask failed: %v", err)
+		}
+		execution := &model.TaskExecution{
+			ExecutionID: "exec-" + task.TaskID,
+			TaskID:      task.TaskID,
+			ProbeID:     "probe-1",
+			Status:      "pending",
+			StartedAt:   time.Now(),
+		}
+		if err := db.SaveExecution(execution); err != nil {
+			t.Fatalf("SaveExecution failed: %v", err)
+		}
+	}
+
+	executions, err := db.ListActiveExecutions("probe-1")
+	if err != nil {
+		t.Fatalf("ListActiveExecutions failed: %v", err)
+	}
+	tasks := sched.loadExecutionTasks(executions)
+	if executions[0].TaskID != "task-high" || executions[len(executions)-1].TaskID != "task-low" {
+		t.Fatalf("expected executions ordered by task priority, got %s ... %s", executions[0].TaskID, executions[len(executions)-1].TaskID)
+	}
+	if !isQueued(executions[0]) {
+		t.Fatal("expected first-attempt pending execution to be queued")
+	}
+
+	if !sched.dispatchQueued(tasks["task-high"], executions[0], time.Now()) {
+		t.Fatal("expected queued execution of a cancelled task to finish")
+	}
+	execution, _ := db.GetExecution("exec-task-high")
+	if execution.Status != "cancelled" {
+		t.Fatalf("expected queued execution to be cancelled, got %s", execution.Status)
+	}
+}
+
+func TestQueuedExecutionHeldWhileTaskPaused(t *testing.T) {
+	sched, db := newTestScheduler(t)
+	seedRunningExecution(t, db, "task-1", "exec-1")
+
+	task := &model.Task{TaskID: "task-paused", TaskType: "icmp_ping", Mode: "single", Target: "1.1.1.1", Status: "pending", Priority: 5}
+	if err := db.CreateTask(task); err != nil {
+		t.Fatalf("CreateTask failed: %v", err)
+	}
+	if err := db.PauseTask(task.TaskID); err != nil {
+		t.Fatalf("PauseTask failed: %v", err)
+	}
+	execution := &model.TaskExecution{
+		ExecutionID: "exec-paused",
+		TaskID:      task.TaskID,
+		ProbeID:     "probe-1",
+		Status:      "pending",
+		StartedAt:   time.Now(),
+	}
+	if err := db.SaveExecution(execution); err != nil {
+		t.Fatalf("SaveExecution failed: %v", err)
+	}
+
+	// 调度器用旧状态回写任务时不应解除暂停
+	task.Status = "running"
+	if err := db.UpdateTask(task); err != nil {
+		t.Fatalf("UpdateTask failed: %v", err)
+	}
+	paused, _ := db.GetTask(task.TaskID)
+	if paused.Status != "paused" {
+		t.Fatalf("expected task to stay paused, got %s", paused.Status)
+	}
+
+	if sched.dispatchQueued(paused, execution, time.Now()) {
+		t.Fatal("expected queued execution of a paused task to stay queued")
+	}
+	stored, _ := db.GetExecution("exec-paused")
+	if stored.Status != "pending" {
+		t.Fatalf("expected execution to stay pending, got %s", stored.Status)
+	}
+}
@@ -0,0 +1,110 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"log"
+
+	"atlas/web/internal/cluster"
+)
+
+// leaderChannel 所有节点订阅的总线频道。非主节点把探针断线、槽位释放、失败重试与工作流触发
+// 转交到该频道，只有确认持有租约的节点处理，其余节点忽略。易主期间的消息可能丢失，
+// 由新主节点的周期回收兜底
+const leaderChannel = "scheduler-leader"
+
+// 转交主节点的事件类型
+const (
+	eventProbeDisconnect  = "probe_disconnect"
+	eventProbeCapacity    = "probe_capacity"
+	eventExecutionFailure = "execution_failure"
+	eventResultStored     = "result_stored"
+)
+
+// leaderEvent 转交主节点的调度事件；执行、任务与结果由主节点从数据库重新读取
+type leaderEvent struct {
+	Kind        string `json:"kind"`
+	ProbeID     string `json:"probe_id,omitempty"`
+	ExecutionID string `json:"execution_id,omitempty"`
+	ResultID    string `json:"result_id,omitempty"`
+}
+
+// SetBus 多节点部署时经 bus 把调度副作用转交主节点（需在 Start 之前调用）
+func (s *Scheduler) SetBus(bus cluster.Bus) {
+	s.bus = bus
+}
+
+// subscribeLeaderEvents 订阅转交主节点的事件，调度器停止时取消订阅
+func (s *Scheduler) subscribeLeaderEvents() {
+	if s.bus == nil {
+		return
+	}
+	unsubscribe, err := s.bus.Subscribe(leaderChannel, s.handleLeaderEvent)
+	if err != nil {
+		log.Printf("[Scheduler] Failed to subscribe to leader events: %v", err)
+		return
+	}
+	go func() {
+		<-s.stopChan
+		unsubscribe()
+	}()
+}
+
+// forwardToLeader 把事件发布给主节点；未配置总线时返回 false，由调用方决定是否在本节点处理
+func (s *Scheduler) forwardToLeader(event leaderEvent) bool {
+	if s.bus == nil {
+		return false
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return false
+	}
+	if err := s.bus.Publish(leaderChannel, payload); err != nil {
+		log.Printf("[Scheduler] Failed to forward %s event to leader: %v", event.Kind, err)
+	}
+	return true
+}
+
+// handleLeaderEvent 主节点处理其他节点转交的事件
+func (s *Scheduler) handleLeaderEvent(payload []byte) {
+	var event leaderEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		log.Printf("[Scheduler] Invalid leader event: %v", err)
+		return
+	}
+	if !s.isLeader() {
+		return
+	}
+
+	switch event.Kind {
+	case eventProbeDisconnect:
+		s.reapDisconnectedProbe(event.ProbeID)
+	case eventProbeCapacity:
+		s.dispatchQueuedFor(event.ProbeID)
+	case eventExecutionFailure:
+		execution, err := s.db.GetExecution(event.ExecutionID)
+		if err != nil {
+			log.Printf("[Scheduler] Failed to get execution %s for retry: %v", event.ExecutionID, err)
+			return
+		}
+		s.retryFailedExecution(execution)
+		// 转交方不再检查任务是否完成，重试创建后由主节点检查
+		s.finalizeTaskIfDone(execution.TaskID)
+	case eventResultStored:
+		result, err := s.db.GetResult(event.ResultID)
+		if err != nil {
+			log.Printf("[Scheduler] Failed to get result %s for workflow: %v", event.ResultID, err)
+			return
+		}
+		execution, err := s.db.GetExecution(result.ExecutionID)
+		if err != nil {
+			return
+		}
+		task, err := s.db.GetTask(result.TaskID)
+		if err != nil {
+			return
+		}
+		s.triggerFollowUps(task, execution, result)
+	default:
+		log.Printf("[Scheduler] Unknown leader event: %s", event.Kind)
+	}
+}
@@ -0,0 +1,153 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"atlas/web/internal/cluster"
+	"atlas/web/internal/leader"
+	"atlas/web/internal/model"
+	"atlas/web/internal/retry"
+	"atlas/web/internal/websocket"
+)
+
+func TestOnlyLeaderSchedules(t *testing.T) {
+	path := testDatabasePath(t)
+	dbA := openTestDatabase(t, path)
+	if err := dbA.Migrate(); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	dbB := openTestDatabase(t, path)
+
+	a := New(dbA, websocket.NewHub(dbA, nil, "secret"), 1)
+	b := New(dbB, websocket.NewHub(dbB, nil, "secret"), 1)
+	electorA := leader.New(dbA, leader.SchedulerLease, "instance-a", time.Minute)
+	electorB := leader.New(dbB, leader.SchedulerLease, "instance-b", time.Minute)
+	a.SetElector(electorA)
+	b.SetElector(electorB)
+
+	// 探针 probe-1 不在线：主节点扫描时会回收其执行
+	seedRunningExecution(t, dbA, "task-1", "exec-1")
+
+	now := time.Now()
+	if !electorA.Tick(now) || electorB.Tick(now) {
+		t.Fatal("expected instance-a to lead and instance-b to stay standby")
+	}
+
+	b.scanAndSchedule()
+	if execution, _ := dbA.GetExecution("exec-1"); execution.Status != "running" {
+		t.Fatalf("expected standby not to touch executions, got %s", execution.Status)
+	}
+
+	a.scanAndSchedule()
+	if execution, _ := dbA.GetExecution("exec-1"); execution.Status != "lost" {
+		t.Fatalf("expected leader to reap the execution, got %s", execution.Status)
+	}
+
+	// 主节点释放租约后备节点接管，旧主节点不再调度
+	electorA.Release(time.Now())
+	if !electorB.Tick(time.Now()) {
+		t.Fatal("expected standby to take over the released lease")
+	}
+	if a.isLeader() || !b.isLeader() {
+		t.Fatal("expected leadership to move to instance-b")
+	}
+}
+
+func TestStandbyForwardsSideEffectsToLeader(t *testing.T) {
+	path := testDatabasePath(t)
+	dbA := openTestDatabase(t, path)
+	if err := dbA.Migrate(); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	dbB := openTestDatabase(t, path)
+
+	a := New(dbA, websocket.NewHub(dbA, nil, "secret"), 1)
+	b := New(dbB, websocket.NewHub(dbB, nil, "secret"), 1)
+	electorA := leader.New(dbA, leader.SchedulerLease, "instance-a", time.Minute)
+	electorB := leader.New(dbB, leader.SchedulerLease, "instance-b", time.Minute)
+	a.SetElector(electorA)
+	b.SetElector(electorB)
+	bus := cluster.NewLocalBus()
+	a.SetBus(bus)
+	b.SetBus(bus)
+	a.subscribeLeaderEvents()
+	b.subscribeLeaderEvents()
+	t.Cleanup(func() {
+		a.Stop()
+		b.Stop()
+	})
+
+	now := time.Now()
+	if !electorA.Tick(now) || electorB.Tick(now) {
+		t.Fatal("expected instance-a to lead and instance-b to stay standby")
+	}
+
+	// 备节点上的断线事件由主节点回收
+	seedRunningExecution(t, dbA, "task-1", "exec-1")
+	b.HandleProbeDisconnect("probe-1")
+	if execution, _ := dbA.GetExecution("exec-1"); execution.Status != executionLost {
+		t.Fatalf("expected leader to reap the forwarded disconnect, got %s", execution.Status)
+	}
+
+	// 备节点入库的失败结果由主节点安排重试
+	if err := dbA.CreateTask(&model.Task{
+		TaskID:      "task-2",
+		TaskType:    "icmp_ping",
+		Mode:        "single",
+		Target:      "1.1.1.1",
+		Status:      "running",
+		Priority:    5,
+		RetryPolicy: `{"max_attempts":2,"retry_on":["probe_error"]}`,
+	}); err != nil {
+		t.Fatalf("CreateTask failed: %v", err)
+	}
+	probeError := retry.ClassProbeError
+	failed := &model.TaskExecution{ExecutionID: "exec-2", TaskID: "task-2", ProbeID: "probe-1", Status: "failed", StartedAt: now, Attempt: 1}
+	if err := dbA.SaveExecution(failed); err != nil {
+		t.Fatalf("SaveExecution failed: %v", err)
+	}
+	failed.FailureClass = &probeError
+	if err := dbA.UpdateExecution(failed); err != nil {
+		t.Fatalf("UpdateExecution failed: %v", err)
+	}
+
+	if !b.HandleExecutionFailure(failed) {
+		t.Fatal("expected standby to forward the failure to the leader")
+	}
+	if executions, _ := dbA.ListExecutionsByTask("task-2"); len(executions) != 2 {
+		t.Fatalf("expected leader to schedule a retry, got %d executions", len(executions))
+	}
+	if task, _ := dbA.GetTask("task-2"); task.Status != "running" {
+		t.Fatalf("expected task to keep running while the retry is pending, got %s", task.Status)
+	}
+}
+
+func TestStaleLeaderWritesAreFenced(t *testing.T) {
+	path := testDatabasePath(t)
+	dbA := openTestDatabase(t, path)
+	if err := dbA.Migrate(); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	dbB := openTestDatabase(t, path)
+
+	a := New(dbA, websocket.NewHub(dbA, nil, "secret"), 1)
+	electorA := leader.New(dbA, leader.SchedulerLease, "instance-a", time.Minute)
+	electorB := leader.New(dbB, leader.SchedulerLease, "instance-b", time.Minute)
+	a.SetElector(electorA)
+	seedRunningExecution(t, dbA, "task-1", "exec-1")
+
+	// instance-a 暂停期间租约过期并被 instance-b 接管，恢复后本地仍认为自己是主节点
+	now := time.Now()
+	if !electorA.Tick(now) || !electorB.Tick(now.Add(2*time.Minute)) {
+		t.Fatal("expected instance-b to take over instance-a's expired lease")
+	}
+	if !a.isLeader() {
+		t.Fatal("expected instance-a to still believe it leads")
+	}
+
+	a.scanAndSchedule()
+	if execution, _ := dbA.GetExecution("exec-1"); execution.Status != "running" {
+		t.Fatalf("expected the stale leader's writes to be fenced, got %s", execution.Status)
+	}
+}
@@ -0,0 +1,472 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"atlas/web/internal/model"
+	"atlas/web/internal/retry"
+	"atlas/web/internal/selector"
+)
+
+// 执行的回收终态
+const (
+	executionTimeout = "timeout" // 探针在线但超过任务超时仍未回报结果
+	executionLost    = "lost"    // 探针断线，执行结果丢失
+)
+
+// lostRetryNone 未配置任务级重试策略时，断线执行不重新分配（config: lost_execution_retry）
+const lostRetryNone = "none"
+
+const (
+	// executionTimeoutGrace 在任务超时基础上额外等待的时间，覆盖结果回传延迟
+	executionTimeoutGrace = 60 * time.Second
+	// maxLostAttempts 全局断线重新分配的最大尝试次数（含首次执行）
+	maxLostAttempts = 2
+	// defaultLostRetryWait 等待探针重连或可用探针的默认时间
+	defaultLostRetryWait = 5 * time.Minute
+	// assignAckTimeout 下发后等待 task_assign_ack 的时间，超时则重投
+	assignAckTimeout = 30 * time.Second
+	// maxDeliveryAttempts 未确认 task_assign 的最大发送次数，超过后按发送失败处理
+	maxDeliveryAttempts = 5
+)
+
+// HandleProbeDisconnect 探针断线时将其未完成的执行标记为 lost，并按策略安排重新分配。
+// 非主节点转交主节点处理；未配置总线时由主节点的周期回收处理
+func (s *Scheduler) HandleProbeDisconnect(probeID string) {
+	if !s.isLeader() {
+		s.forwardToLeader(leaderEvent{Kind: eventProbeDisconnect, ProbeID: probeID})
+		return
+	}
+	s.reapDisconnectedProbe(probeID)
+}
+
+// reapDisconnectedProbe 回收断线探针上未完成的执行；探针已重连到本节点或其他节点时跳过
+func (s *Scheduler) reapDisconnectedProbe(probeID string) {
+	if s.hub.IsProbeOnline(probeID) {
+		return
+	}
+
+	executions, err := s.db.ListActiveExecutions(probeID)
+	if err != nil {
+		log.Printf("[Scheduler] Failed to list executions of disconnected probe %s: %v", probeID, err)
+		return
+	}
+
+	outbox := s.loadOutbox()
+	touched := make(map[string]struct{})
+	for _, execution := range executions {
+		// 等待该探针重连的重新分配执行继续等待
+		if isPendingRetry(execution) {
+			continue
+		}
+		// 探针尚未确认收到的任务在重连后重投，不视为丢失
+		if _, ok := outbox[execution.ExecutionID]; ok {
+			continue
+		}
+		s.loseExecution(execution, "probe disconnected")
+		touched[execution.TaskID] = struct{}{}
+	}
+
+	for taskID := range touched {
+		s.finalizeTaskIfDone(taskID)
+	}
+}
+
+// reapExecutions 回收超时或探针已离线的执行，并下发等待中的重新分配与排队执行
+func (s *Scheduler) reapExecutions() {
+	executions, err := s.db.ListActiveExecutions("")
+	if err != nil {
+		log.Printf("[Scheduler] Failed to list active executions: %v", err)
+		return
+	}
+
+	now := time.Now()
+	tasks := s.loadExecutionTasks(executions)
+	outbox := s.loadOutbox()
+	touched := make(map[string]struct{})
+
+	// 清理已结束执行的待确认记录
+	active := make(map[string]struct{}, len(executions))
+	for _, execution := range executions {
+		active[execution.ExecutionID] = struct{}{}
+	}
+	for executionID := range outbox {
+		if _, ok := active[executionID]; !ok {
+			_ = s.store().DeleteOutboxEntry(executionID)
+			delete(outbox, executionID)
+		}
+	}
+
+	for _, execution := range executions {
+		task := tasks[execution.TaskID]
+
+		if isPendingRetry(execution) {
+			if s.dispatchRetry(task, execution, now) {
+				touched[execution.TaskID] = struct{}{}
+			}
+			continue
+		}
+
+		if entry, ok := outbox[execution.ExecutionID]; ok && execution.Status == "running" {
+			if s.redeliver(task, execution, entry, now) {
+				touched[execution.TaskID] = struct{}{}
+			}
+			continue
+		}
+
+		if !s.hub.IsProbeOnline(execution.ProbeID) {
+			s.loseExecution(execution, "probe offline")
+			touched[execution.TaskID] = struct{}{}
+			continue
+		}
+
+		// 排队中的执行等待探针释放槽位，不计超时
+		if isQueued(execution) {
+			if s.dispatchQueued(task, execution, now) {
+				touched[execution.TaskID] = struct{}{}
+			}
+			continue
+		}
+
+		timeout := time.Duration(s.taskTimeoutSeconds(taskTypeOf(task)))*time.Second + executionTimeoutGrace
+		if now.Sub(execution.StartedAt) > timeout {
+			log.Printf("[Scheduler] Execution %s on probe %s timed out", execution.ExecutionID, execution.ProbeID)
+			s.finishExecution(execution, executionTimeout, retry.ClassTimeout, "no result within task timeout")
+			s.scheduleRetry(task, execution, retry.ClassTimeout)
+			touched[execution.TaskID] = struct{}{}
+		}
+	}
+
+	for taskID := range touched {
+		s.finalizeTaskIfDone(taskID)
+	}
+}
+
+// loseExecution 将执行标记为 lost，并在策略允许时创建重新分配的执行
+func (s *Scheduler) loseExecution(execution *model.TaskExecution, reason string) {
+	log.Printf("[Scheduler] Execution %s on probe %s lost: %s", execution.ExecutionID, execution.ProbeID, reason)
+	s.finishExecution(execution, executionLost, retry.ClassLost, reason)
+
+	task, err := s.db.GetTask(execution.TaskID)
+	if err != nil {
+		return
+	}
+	s.scheduleRetry(task, execution, retry.ClassLost)
+}
+
+// HandleExecutionFailure 探针回报失败结果后按任务重试策略安排下一次尝试，与回收路径共用 scheduleRetry。
+// 非主节点转交主节点处理并返回 true，此时任务是否完成也由主节点在安排重试后检查
+func (s *Scheduler) HandleExecutionFailure(execution *model.TaskExecution) bool {
+	if execution.FailureClass == nil {
+		return false
+	}
+	if !s.isLeader() && s.forwardToLeader(leaderEvent{Kind: eventExecutionFailure, ExecutionID: execution.ExecutionID}) {
+		return true
+	}
+	s.retryFailedExecution(execution)
+	return false
+}
+
+// retryFailedExecution 按任务重试策略为失败的执行安排下一次尝试
+func (s *Scheduler) retryFailedExecution(execution *model.TaskExecution) {
+	if execution.FailureClass == nil {
+		return
+	}
+	// 重新读取任务，批内缓存的任务状态可能已过期
+	task, err := s.db.GetTask(execution.TaskID)
+	if err != nil {
+		log.Printf("[Scheduler] Failed to get task %s for retry: %v", execution.TaskID, err)
+		return
+	}
+	s.scheduleRetry(task, execution, *execution.FailureClass)
+}
+
+// scheduleRetry 按任务重试策略（未配置时按全局断线策略）为失败的执行创建下一次尝试
+func (s *Scheduler) scheduleRetry(task *model.Task, execution *model.TaskExecution, class string) bool {
+	// 持续任务下一轮会自然重新执行，只重试单次任务
+	if task == nil || task.Mode != "single" || isTaskTerminal(task.Status) {
+		return false
+	}
+
+	now := time.Now()
+	notBefore := now
+	policy, err := retry.Parse(task.RetryPolicy)
+	if err != nil {
+		log.Printf("[Scheduler] Ignoring invalid retry policy of task %s: %v", task.TaskID, err)
+	}
+	switch {
+	case policy != nil:
+		if !policy.ShouldRetry(class, execution.Attempt) {
+			return false
+		}
+		notBefore = now.Add(policy.Backoff(execution.Attempt))
+	case class == retry.ClassLost:
+		if s.lostRetryPolicy() == lostRetryNone || execution.Attempt >= maxLostAttempts {
+			return false
+		}
+	default:
+		return false
+	}
+
+	next := retry.NextAttempt(execution, notBefore)
+	if err := s.store().SaveExecution(next); err != nil {
+		log.Printf("[Scheduler] Failed to save retry execution: %v", err)
+		return false
+	}
+	log.Printf("[Scheduler] Scheduled attempt %d (%s) for execution %s after %s, not before %s",
+		next.Attempt, next.ExecutionID, execution.ExecutionID, class, notBefore.Format(time.RFC3339))
+	return true
+}
+
+// dispatchRetry 尝试下发等待中的重试执行；等待超时则记为 lost。返回执行是否已进入终态
+func (s *Scheduler) dispatchRetry(task *model.Task, execution *model.TaskExecution, now time.Time) bool {
+	if task == nil || isTaskTerminal(task.Status) {
+		s.finishExecution(execution, "cancelled", "", "task is no longer active")
+		return true
+	}
+	if task.Status == "paused" {
+		return false
+	}
+
+	// 退避期内不下发
+	waitFrom := execution.StartedAt
+	if execution.NotBefore != nil {
+		if now.Before(*execution.NotBefore) {
+			return false
+		}
+		waitFrom = *execution.NotBefore
+	}
+
+	probeID := ""
+	if s.hub.IsProbeOnline(execution.ProbeID) {
+		probeID = execution.ProbeID
+	} else if s.reassignMode(task) == retry.ReassignAnyProbe {
+		probeID = s.findEquivalentProbe(task, execution.ProbeID, execution.Target)
+	}
+
+	if probeID == "" {
+		if now.Sub(waitFrom) >= s.lostRetryWait() {
+			s.finishExecution(execution, executionLost, retry.ClassLost, "no probe available for reassignment")
+			return true
+		}
+		return false
+	}
+
+	// 探针满载时继续等待槽位
+	if !s.hasCapacity(probeID) {
+		return false
+	}
+
+	if err := s.store().ReassignExecution(execution.ExecutionID, probeID, now); err != nil {
+		log.Printf("[Scheduler] Failed to reassign execution %s: %v", execution.ExecutionID, err)
+		return false
+	}
+	execution.ProbeID = probeID
+	execution.StartedAt = now
+
+	log.Printf("[Scheduler] Dispatching execution %s (attempt %d) to probe %s", execution.ExecutionID, execution.Attempt, probeID)
+	if !s.dispatchExecution(task, execution) {
+		return true
+	}
+	return false
+}
+
+// redeliver 处理未确认的下发：探针在线时超时重投，离线时等待重连。返回执行是否已进入终态
+func (s *Scheduler) redeliver(task *model.Task, execution *model.TaskExecution, entry *model.OutboxEntry, now time.Time) bool {
+	if !s.hub.IsProbeOnline(execution.ProbeID) {
+		if now.Sub(execution.StartedAt) >= s.lostRetryWait() {
+			s.loseExecution(execution, "task was never acknowledged by probe")
+			return true
+		}
+		return false
+	}
+
+	if entry.LastSentAt != nil && now.Sub(*entry.LastSentAt) < assignAckTimeout {
+		return false
+	}
+
+	if entry.Attempts >= maxDeliveryAttempts {
+		log.Printf("[Scheduler] Execution %s was not acknowledged after %d deliveries", execution.ExecutionID, entry.Attempts)
+		s.finishExecution(execution, "failed", retry.ClassSendFailure, "task was never acknowledged by probe")
+		s.scheduleRetry(task, execution, retry.ClassSendFailure)
+		return true
+	}
+
+	if err := s.hub.DeliverOutboxEntry(entry); err != nil {
+		log.Printf("[Scheduler] Failed to redeliver execution %s to probe %s: %v", execution.ExecutionID, execution.ProbeID, err)
+		return false
+	}
+	log.Printf("[Scheduler] Redelivered unacknowledged execution %s to probe %s", execution.ExecutionID, execution.ProbeID)
+	return false
+}
+
+// loadOutbox 加载所有未确认的下发，按执行 ID 索引
+func (s *Scheduler) loadOutbox() map[string]*model.OutboxEntry {
+	outbox := make(map[string]*model.OutboxEntry)
+	entries, err := s.db.ListOutboxEntries("")
+	if err != nil {
+		log.Printf("[Scheduler] Failed to list outbox: %v", err)
+		return outbox
+	}
+	for _, entry := range entries {
+		outbox[entry.ExecutionID] = entry
+	}
+	return outbox
+}
+
+// reassignMode 重试时的探针选择：任务策略优先，否则使用全局断线策略
+func (s *Scheduler) reassignMode(task *model.Task) string {
+	if policy, err := retry.Parse(task.RetryPolicy); err == nil && policy != nil {
+		return policy.Reassign
+	}
+	return s.lostRetryPolicy()
+}
+
+// findEquivalentProbe 选择与原探针能力相同的在线探针，优先同区域；跳过已测量同一目标的探针
+func (s *Scheduler) findEquivalentProbe(task *model.Task, originalProbeID, target string) string {
+	probes, err := s.db.GetOnlineProbes()
+	if err != nil {
+		return ""
+	}
+
+	region := ""
+	if original, err := s.db.GetProbe(originalProbeID); err == nil {
+		region = original.Region
+	}
+
+	busy := make(map[string]struct{})
+	if executions, err := s.db.ListExecutionsByTask(task.TaskID); err == nil {
+		for _, execution := range executions {
+			if execution.Target != target {
+				continue
+			}
+			if execution.Status == "pending" || execution.Status == "running" || execution.Status == "success" {
+				busy[execution.ProbeID] = struct{}{}
+			}
+		}
+	}
+
+	// 带选择表达式的任务只改派给同样满足表达式的探针
+	sel, err := selector.Parse(task.Selector)
+	if err != nil {
+		return ""
+	}
+
+	fallback := ""
+	for _, probe := range probes {
+		if probe.ProbeID == originalProbeID || !s.hub.IsProbeOnline(probe.ProbeID) {
+			continue
+		}
+		if !sel.Matches(selector.ProbeLabels(probe)) {
+			continue
+		}
+		if _, ok := busy[probe.ProbeID]; ok || !s.hasCapacity(probe.ProbeID) {
+			continue
+		}
+		var capabilities []string
+		_ = json.Unmarshal([]byte(probe.Capabilities), &capabilities)
+		if !supportsTaskType(capabilities, task.TaskType) {
+			continue
+		}
+		if region != "" && probe.Region == region {
+			return probe.ProbeID
+		}
+		if fallback == "" {
+			fallback = probe.ProbeID
+		}
+	}
+	return fallback
+}
+
+// finishExecution 将执行置为终态；class 为空表示非失败终态
+func (s *Scheduler) finishExecution(execution *model.TaskExecution, status, class, reason string) {
+	now := time.Now()
+	execution.Status = status
+	execution.CompletedAt = &now
+	execution.Error = &reason
+	if class != "" {
+		execution.FailureClass = &class
+	}
+	if err := s.store().UpdateExecution(execution); err != nil {
+		log.Printf("[Scheduler] Failed to update execution %s: %v", execution.ExecutionID, err)
+	}
+	if err := s.store().DeleteOutboxEntry(execution.ExecutionID); err != nil {
+		log.Printf("[Scheduler] Failed to clear outbox entry %s: %v", execution.ExecutionID, err)
+	}
+}
+
+// finalizeTaskIfDone 单次任务的所有执行都进入终态后将任务标记为 completed
+func (s *Scheduler) finalizeTaskIfDone(taskID string) {
+	task, err := s.db.GetTask(taskID)
+	if err != nil || task.Mode != "single" || isTaskTerminal(task.Status) {
+		return
+	}
+
+	executions, err := s.db.ListExecutionsByTask(taskID)
+	if err != nil {
+		return
+	}
+	for _, execution := range executions {
+		if execution.Status == "pending" || execution.Status == "running" {
+			return
+		}
+	}
+
+	now := time.Now()
+	task.Status = "completed"
+	task.CompletedAt = &now
+	if err := s.store().UpdateTask(task); err != nil {
+		log.Printf("[Scheduler] Failed to complete task %s: %v", taskID, err)
+		return
+	}
+	log.Printf("[Scheduler] Task completed after reaping executions: %s", taskID)
+}
+
+func (s *Scheduler) lostRetryPolicy() string {
+	v, _ := s.db.GetConfig("lost_execution_retry")
+	switch strings.TrimSpace(v) {
+	case retry.ReassignSameProbe:
+		return retry.ReassignSameProbe
+	case retry.ReassignAnyProbe:
+		return retry.ReassignAnyProbe
+	default:
+		return lostRetryNone
+	}
+}
+
+func (s *Scheduler) lostRetryWait() time.Duration {
+	if v, err := s.db.GetConfig("lost_execution_retry_wait_seconds"); err == nil {
+		if i, err := strconv.Atoi(strings.TrimSpace(v)); err == nil && i >= 0 {
+			return time.Duration(i) * time.Second
+		}
+	}
+	return defaultLostRetryWait
+}
+
+func isPendingRetry(execution *model.TaskExecution) bool {
+	return execution.Status == "pending" && execution.RetryOf != nil
+}
+
+func isTaskTerminal(status string) bool {
+	return status == "completed" || status == "failed" || status == "cancelled"
+}
+
+func supportsTaskType(capabilities []string, taskType string) bool {
+	for _, cap := range capabilities {
+		if cap == taskType || cap == "all" {
+			return true
+		}
+	}
+	return false
+}
+
+func taskTypeOf(task *model.Task) string {
+	if task == nil {
+		return ""
+	}
+	return task.TaskType
+}
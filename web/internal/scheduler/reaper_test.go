@@ -0,0 +1,275 @@
+package scheduler
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"atlas/web/internal/database"
+	"atlas/web/internal/database/dbtest"
+	"atlas/web/internal/model"
+	"atlas/web/internal/retry"
+	"atlas/web/internal/websocket"
+)
+
+func newTestScheduler(t *testing.T) (*Scheduler, *database.Database) {
+	t.Helper()
+
+	db := openTestDatabase(t, testDatabasePath(t))
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+
+	return New(db, websocket.NewHub(db, nil, "secret"), 1), db
+}
+
+// testDatabasePath 切换到 web 根目录并返回临时数据库路径
+func testDatabasePath(t *testing.T) string {
+	t.Helper()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Getwd failed: %v", err)
+	}
+	webRoot := filepath.Clean(filepath.Join(wd, "..", ".."))
+	if err := os.Chdir(webRoot); err != nil {
+		t.Fatalf("Chdir failed: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = os.Chdir(wd)
+	})
+	return filepath.Join(t.TempDir(), "atlas-scheduler.db")
+}
+
+func openTestDatabase(t *testing.T, path string) *database.Database {
+	t.Helper()
+
+	db, err := database.Open(dbtest.Source(t, path))
+	if err != nil {
+		t.Fatalf("database.Open failed: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	return db
+}
+
+func seedRunningExecution(t *testing.T, db *database.Database, taskID, executionID string) {
+	t.Helper()
+
+	probe := &model.Probe{
+		ProbeID:       "probe-1",
+		Name:          "probe-1",
+		Location:      "Tokyo",
+		Capabilities:  `["icmp_ping"]`,
+		Status:        "online",
+		LastHeartbeat: time.Now(),
+	}
+	if err := db.SaveProbe(probe); err != nil {
+		t.Fatalf("SaveProbe failed: %v", err)
+	}
+
+	task := &model.Task{
+		TaskID:   taskID,
+		TaskType: "icmp_ping",
+		Mode:     "single",
+		Target:   "1.1.1.1",
+		Status:   "running",
+		Priority: 5,
+	}
+	if err := db.CreateTask(task); err != nil {
+		t.Fatalf("CreateTask failed: %v", err)
+	}
+
+	execution := &model.TaskExecution{
+		ExecutionID: executionID,
+		TaskID:      taskID,
+		ProbeID:     probe.ProbeID,
+		Status:      "running",
+		StartedAt:   time.Now(),
+	}
+	if err := db.SaveExecution(execution); err != nil {
+		t.Fatalf("SaveExecution failed: %v", err)
+	}
+}
+
+func TestReapMarksOfflineExecutionLostAndCompletesTask(t *testing.T) {
+	s, db := newTestScheduler(t)
+	seedRunningExecution(t, db, "task-1", "exec-1")
+
+	s.reapExecutions()
+
+	execution, err := db.GetExecution("exec-1")
+	if err != nil {
+		t.Fatalf("GetExecution failed: %v", err)
+	}
+	if execution.Status != executionLost || execution.CompletedAt == nil {
+		t.Fatalf("expected lost execution, got %+v", execution)
+	}
+
+	task, _ := db.GetTask("task-1")
+	if task.Status != "completed" || task.CompletedAt == nil {
+		t.Fatalf("expected task to reach terminal state, got %s", task.Status)
+	}
+}
+
+func TestUnacknowledgedExecutionWaitsForReconnect(t *testing.T) {
+	s, db := newTestScheduler(t)
+	seedRunningExecution(t, db, "task-1", "exec-1")
+	if err := db.SaveOutboxEntry(&model.OutboxEntry{
+		ExecutionID: "exec-1",
+		ProbeID:     "probe-1",
+		TaskID:      "task-1",
+		Payload:     `{"task_id":"task-1","execution_id":"exec-1"}`,
+		CreatedAt:   time.Now(),
+	}); err != nil {
+		t.Fatalf("SaveOutboxEntry failed: %v", err)
+	}
+
+	// 未确认的任务等待重连后重投，不因断线记为 lost
+	s.HandleProbeDisconnect("probe-1")
+	s.reapExecutions()
+	if execution, _ := db.GetExecution("exec-1"); execution.Status != "running" {
+		t.Fatalf("expected unacknowledged execution to keep waiting, got %s", execution.Status)
+	}
+
+	if err := db.SetConfig("lost_execution_retry_wait_seconds", "0"); err != nil {
+		t.Fatalf("SetConfig failed: %v", err)
+	}
+	s.reapExecutions()
+
+	if execution, _ := db.GetExecution("exec-1"); execution.Status != executionLost {
+		t.Fatalf("expected execution to be lost after wait, got %s", execution.Status)
+	}
+	if entries, _ := db.ListOutboxEntries("probe-1"); len(entries) != 0 {
+		t.Fatalf("expected outbox entry to be cleared, got %d", len(entries))
+	}
+}
+
+func TestDisconnectSchedulesRetryUntilWaitExpires(t *testing.T) {
+	s, db := newTestScheduler(t)
+	seedRunningExecution(t, db, "task-1", "exec-1")
+	if err := db.SetConfig("lost_execution_retry", retry.ReassignSameProbe); err != nil {
+		t.Fatalf("SetConfig failed: %v", err)
+	}
+
+	s.HandleProbeDisconnect("probe-1")
+
+	executions, err := db.ListExecutionsByTask("task-1")
+	if err != nil {
+		t.Fatalf("ListExecutionsByTask failed: %v", err)
+	}
+	if len(executions) != 2 {
+		t.Fatalf("expected original and retry executions, got %d", len(executions))
+	}
+
+	var retry *model.TaskExecution
+	for _, execution := range executions {
+		if execution.RetryOf != nil {
+			retry = execution
+		}
+	}
+	if retry == nil || *retry.RetryOf != "exec-1" || retry.Attempt != 2 || retry.Status != "pending" {
+		t.Fatalf("unexpected retry execution: %+v", retry)
+	}
+
+	// 探针仍未重连：等待期内保持 pending，任务未结束
+	s.reapExecutions()
+	if task, _ := db.GetTask("task-1"); task.Status != "running" {
+		t.Fatalf("expected task to keep running while waiting for reconnect, got %s", task.Status)
+	}
+
+	// 等待时间耗尽后记为 lost，任务进入终态
+	if err := db.SetConfig("lost_execution_retry_wait_seconds", "0"); err != nil {
+		t.Fatalf("SetConfig failed: %v", err)
+	}
+	s.reapExecutions()
+
+	retry, _ = db.GetExecution(retry.ExecutionID)
+	if retry.Status != executionLost {
+		t.Fatalf("expected retry to be lost after wait, got %s", retry.Status)
+	}
+	if task, _ := db.GetTask("task-1"); task.Status != "completed" {
+		t.Fatalf("expected task to complete, got %s", task.Status)
+	}
+}
+
+func TestSendFailureRetriesWithBackoffChain(t *testing.T) {
+	s, db := newTestScheduler(t)
+	seedRunningExecution(t, db, "task-1", "exec-1")
+
+	task, _ := db.GetTask("task-1")
+	task.RetryPolicy = `{"max_attempts":2,"backoff_seconds":60,"retry_on":["send_failure"]}`
+
+	// 探针离线：下发失败，按策略在退避后重试
+	execution, _ := db.GetExecution("exec-1")
+	if s.dispatchExecution(task, execution) {
+		t.Fatal("expected dispatch to offline probe to fail")
+	}
+
+	execution, _ = db.GetExecution("exec-1")
+	if execution.Status != "failed" || execution.FailureClass == nil || *execution.FailureClass != retry.ClassSendFailure {
+		t.Fatalf("expected send_failure, got %+v", execution)
+	}
+
+	executions, _ := db.ListExecutionsByTask("task-1")
+	if len(executions) != 2 {
+		t.Fatalf("expected retry attempt, got %d executions", len(executions))
+	}
+	var next *model.TaskExecution
+	for _, e := range executions {
+		if e.RetryOf != nil {
+			next = e
+		}
+	}
+	if next == nil || next.NotBefore == nil || time.Until(*next.NotBefore) < 50*time.Second {
+		t.Fatalf("expected retry delayed by backoff, got %+v", next)
+	}
+
+	// 退避期内 reaper 不下发，也不结束任务
+	s.reapExecutions()
+	if next, _ = db.GetExecution(next.ExecutionID); next.Status != "pending" {
+		t.Fatalf("expected retry to wait for backoff, got %s", next.Status)
+	}
+}
+
+func TestExecutionFailureRetriesOnlyActiveTasks(t *testing.T) {
+	s, db := newTestScheduler(t)
+	seedRunningExecution(t, db, "task-seed", "exec-seed")
+
+	probeError := retry.ClassProbeError
+	for _, taskID := range []string{"task-active", "task-cancelled"} {
+		if err := db.CreateTask(&model.Task{
+			TaskID:      taskID,
+			TaskType:    "icmp_ping",
+			Mode:        "single",
+			Target:      "1.1.1.1",
+			Status:      "running",
+			Priority:    5,
+			RetryPolicy: `{"max_attempts":2,"retry_on":["probe_error"]}`,
+		}); err != nil {
+			t.Fatalf("CreateTask failed: %v", err)
+		}
+		if err := db.SaveExecution(&model.TaskExecution{ExecutionID: taskID + "-exec", TaskID: taskID, ProbeID: "probe-1", Status: "failed", StartedAt: time.Now(), Attempt: 1}); err != nil {
+			t.Fatalf("SaveExecution failed: %v", err)
+		}
+	}
+	// 结果入库前任务已被取消，不应再创建重试
+	if err := db.UpdateTaskStatus("task-cancelled", "cancelled"); err != nil {
+		t.Fatalf("UpdateTaskStatus failed: %v", err)
+	}
+
+	for _, taskID := range []string{"task-active", "task-cancelled"} {
+		execution, _ := db.GetExecution(taskID + "-exec")
+		execution.FailureClass = &probeError
+		s.HandleExecutionFailure(execution)
+	}
+
+	if executions, _ := db.ListExecutionsByTask("task-active"); len(executions) != 2 {
+		t.Fatalf("expected retry attempt for active task, got %d executions", len(executions))
+	}
+	if executions, _ := db.ListExecutionsByTask("task-cancelled"); len(executions) != 1 {
+		t.Fatalf("expected no retry for cancelled task, got %d executions", len(executions))
+	}
+}
@@ -2,16 +2,28 @@ package scheduler
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 
 	"atlas/shared/protocol"
+	"atlas/web/internal/cluster"
 	"atlas/web/internal/database"
+	"atlas/web/internal/leader"
 	"atlas/web/internal/model"
+	"atlas/web/internal/retry"
+	"atlas/web/internal/sampling"
+	"atlas/web/internal/schedule"
+	"atlas/web/internal/selector"
+	"atlas/web/internal/targets"
+	"atlas/web/internal/targetutil"
 	"atlas/web/internal/websocket"
 )
 
@@ -23,6 +35,15 @@ type Scheduler struct {
 	hub      *websocket.Hub
 	interval time.Duration
 	stopChan chan struct{}
+	locator  sampling.Locator
+	elector  *leader.Elector
+	bus      cluster.Bus // 为 nil 时不向主节点转交事件
+
+	// dispatchMu 串行化周期扫描与槽位释放触发的下发，避免超出探针容量
+	dispatchMu sync.Mutex
+	// followUpMu 串行化工作流冷却检查与后续任务创建：结果由 ingest 并发回调，
+	// 同一 (任务, 步骤, 探针, 目标) 的两个结果同时通过冷却检查会重复创建
+	followUpMu sync.Mutex
 }
 
 // New 创建新的调度器
@@ -35,8 +56,35 @@ func New(db *database.Database, hub *websocket.Hub, scanInterval int) *Scheduler
 	}
 }
 
+// SetGeoIP 设置抽样策略（per_country/per_asn/nearest）使用的 GeoIP 查询
+func (s *Scheduler) SetGeoIP(locator sampling.Locator) {
+	s.locator = locator
+}
+
+// SetElector 启用主节点选举（需在 Start 之前调用）：只有持有租约的实例扫描并下发任务，其余实例热备
+func (s *Scheduler) SetElector(elector *leader.Elector) {
+	s.elector = elector
+}
+
+// isLeader 未启用选举时总是主节点；启用时按本地租约状态判断，写入由 store 的 fencing token 兜底
+func (s *Scheduler) isLeader() bool {
+	return s.elector == nil || s.elector.IsLeader(time.Now())
+}
+
+// store 调度写入使用的数据库：启用选举时携带 fencing token，租约易主后旧主节点的写入被拒绝
+func (s *Scheduler) store() *database.Database {
+	if s.elector == nil {
+		return s.db
+	}
+	return s.db.WithFence(s.elector.Fence())
+}
+
 // Start 启动调度器
 func (s *Scheduler) Start() {
+	if s.elector != nil {
+		go s.elector.Run(s.stopChan)
+	}
+	s.subscribeLeaderEvents()
 	log.Println("[Scheduler] Starting scheduler...")
 
 	ticker := time.NewTicker(s.interval)
@@ -60,12 +108,22 @@ func (s *Scheduler) Stop() {
 
 // scanAndSchedule 扫描并调度任务
 func (s *Scheduler) scanAndSchedule() {
-	if count, err := s.db.TimeoutStaleProbeUpgrades(probeUpgradeTimeout); err != nil {
+	s.dispatchMu.Lock()
+	defer s.dispatchMu.Unlock()
+
+	if !s.isLeader() {
+		return
+	}
+
+	if count, err := s.store().TimeoutStaleProbeUpgrades(probeUpgradeTimeout); err != nil {
 		log.Printf("[Scheduler] Failed to timeout stale probe upgrades: %v", err)
 	} else if count > 0 {
 		log.Printf("[Scheduler] Timed out %d stale probe upgrade(s)", count)
 	}
 
+	// 回收超时或探针已断线的执行，并下发待重新分配的执行
+	s.reapExecutions()
+
 	// 1. 获取待执行的单次任务
 	tasks, err := s.db.GetPendingTasks()
 	if err != nil {
@@ -73,12 +131,6 @@ func (s *Scheduler) scanAndSchedule() {
 		return
 	}
 
-	for _, task := range tasks {
-		if err := s.assignTask(task); err != nil {
-			log.Printf("[Scheduler] Failed to assign task %s: %v", task.TaskID, err)
-		}
-	}
-
 	// 2. 获取需要运行的持续任务
 	continuousTasks, err := s.db.GetDueContinuousTasks(time.Now())
 	if err != nil {
@@ -86,20 +138,47 @@ func (s *Scheduler) scanAndSchedule() {
 		return
 	}
 
-	for _, task := range continuousTasks {
+	// 3. 按优先级统一分发；同优先级时单次任务先于持续任务
+	due := append(tasks, continuousTasks...)
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].Priority > due[j].Priority
+	})
+
+	for _, task := range due {
 		if err := s.assignTask(task); err != nil {
-			log.Printf("[Scheduler] Failed to assign continuous task %s: %v", task.TaskID, err)
+			log.Printf("[Scheduler] Failed to assign task %s: %v", task.TaskID, err)
 		}
 
-		// 更新下次运行时间
-		s.updateNextRun(task)
+		// 持续任务：更新下次运行时间
+		if task.Mode == "continuous" {
+			s.updateNextRun(task)
+		}
 	}
 }
 
 // assignTask 分配任务到探针
 func (s *Scheduler) assignTask(task *model.Task) error {
+	// 目标组为空、已删除或目标全部被屏蔽时不会产生任何执行：单次任务直接标记失败，持续任务跳过本轮
+	taskTargets, err := s.expandTargets(task)
+	if err == nil && len(taskTargets) == 0 {
+		err = fmt.Errorf("no targets resolved: all targets are blocked")
+	}
+	if err != nil {
+		if task.Mode == "single" {
+			log.Printf("[Scheduler] No targets resolved for task %s, marking failed: %v", task.TaskID, err)
+			now := time.Now()
+			task.Status = "failed"
+			task.CompletedAt = &now
+			if updateErr := s.store().UpdateTask(task); updateErr != nil {
+				return updateErr
+			}
+		}
+		return err
+	}
+
 	// 获取可用的探针
-	probes, err := s.selectProbes(task)
+	runNumber := taskRunNumber(task)
+	probes, err := s.selectProbes(task, runNumber)
 	if err != nil {
 		return err
 	}
@@ -109,87 +188,220 @@ func (s *Scheduler) assignTask(task *model.Task) error {
 		return nil
 	}
 
-	log.Printf("[Scheduler] Assigning task %s to %d probes", task.TaskID, len(probes))
+	log.Printf("[Scheduler] Assigning task %s to %d probes x %d targets", task.TaskID, len(probes), len(taskTargets))
 
-	// 为每个探针创建执行记录并发送任务
+	// 为每个 (探针, 目标) 创建执行记录并发送任务；满载的探针上单次任务排队，持续任务跳过本轮
 	for _, probe := range probes {
-		execution := &model.TaskExecution{
-			ExecutionID: uuid.New().String(),
-			TaskID:      task.TaskID,
-			ProbeID:     probe.ProbeID,
-			Status:      "pending",
-			StartedAt:   time.Now(),
+		for _, target := range taskTargets {
+			queued := !s.hasCapacity(probe.ProbeID)
+			if queued && task.Mode == "continuous" {
+				log.Printf("[Scheduler] Probe %s is at capacity, skipping task %s this round", probe.ProbeID, task.TaskID)
+				break
+			}
+
+			execution := &model.TaskExecution{
+				ExecutionID: uuid.New().String(),
+				TaskID:      task.TaskID,
+				ProbeID:     probe.ProbeID,
+				Target:      target,
+				RunNumber:   runNumber,
+				Status:      "pending",
+				StartedAt:   time.Now(),
+			}
+
+			// 保存执行记录
+			if err := s.store().SaveExecution(execution); err != nil {
+				log.Printf("[Scheduler] Failed to save execution: %v", err)
+				continue
+			}
+
+			if queued {
+				log.Printf("[Scheduler] Probe %s is at capacity, queued execution %s", probe.ProbeID, execution.ExecutionID)
+				continue
+			}
+			s.dispatchExecution(task, execution)
 		}
+	}
 
-		// 保存执行记录
-		if err := s.db.SaveExecution(execution); err != nil {
-			log.Printf("[Scheduler] Failed to save execution: %v", err)
-			continue
+	// 更新任务状态
+	now := time.Now()
+	task.Status = "running"
+	if task.StartedAt == nil {
+		task.StartedAt = &now
+	}
+	return s.store().UpdateTask(task)
+}
+
+// dispatchExecution 将执行记录下发到其探针，并按发送结果更新执行状态
+func (s *Scheduler) dispatchExecution(task *model.Task, execution *model.TaskExecution) bool {
+	// 解析任务参数
+	var parameters map[string]interface{}
+	_ = json.Unmarshal([]byte(task.Parameters), &parameters)
+	if parameters == nil {
+		parameters = map[string]interface{}{}
+	}
+
+	// 旧版 continuous ping/tcp：每次只做 1 次（调度器负责 1s 间隔和最多 N 次）
+	if task.Mode == "continuous" && (task.TaskType == "icmp_ping" || task.TaskType == "tcp_ping") && isLegacySchedule(task.Schedule) {
+		parameters["count"] = 1
+	}
+
+	target := task.Target
+	if execution.Target != "" {
+		target = execution.Target
+	}
+
+	// 构建任务分配消息
+	assignMsg := protocol.TaskAssignMessage{
+		TaskID:      task.TaskID,
+		ExecutionID: execution.ExecutionID,
+		TaskType:    task.TaskType,
+		Target:      target,
+		Parameters:  parameters,
+		Timeout:     s.taskTimeoutSeconds(task.TaskType),
+		Priority:    task.Priority,
+	}
+
+	// 先写入待确认队列，探针回复 task_assign_ack 前由调度器重投
+	payload, _ := json.Marshal(assignMsg)
+	entry := &model.OutboxEntry{
+		ExecutionID: execution.ExecutionID,
+		ProbeID:     execution.ProbeID,
+		TaskID:      task.TaskID,
+		Payload:     string(payload),
+		CreatedAt:   time.Now(),
+	}
+	if err := s.store().SaveOutboxEntry(entry); err != nil {
+		log.Printf("[Scheduler] Failed to save outbox entry for execution %s: %v", execution.ExecutionID, err)
+	}
+
+	// 发送任务到探针
+	err := s.hub.SendToProbe(execution.ProbeID, "task_assign", assignMsg)
+	switch {
+	case err == nil:
+		if err := s.store().MarkOutboxSent(execution.ExecutionID, time.Now()); err != nil {
+			log.Printf("[Scheduler] Failed to update outbox entry %s: %v", execution.ExecutionID, err)
 		}
+	case errors.Is(err, websocket.ErrSendTimeout):
+		// 探针在线但发送缓冲区已满：保留在待确认队列中稍后重投
+		log.Printf("[Scheduler] Send buffer of probe %s is full, execution %s will be redelivered", execution.ProbeID, execution.ExecutionID)
+	default:
+		log.Printf("[Scheduler] Failed to send task to probe %s: %v", execution.ProbeID, err)
+		s.finishExecution(execution, "failed", retry.ClassSendFailure, err.Error())
+		s.scheduleRetry(task, execution, retry.ClassSendFailure)
+		return false
+	}
+
+	// 更新执行状态为running
+	execution.Status = "running"
+	s.store().UpdateExecution(execution)
+	return true
+}
+
+// expandTargets 展开任务目标；单目标任务返回空字符串占位（执行使用 tasks.target）。
+// 目标组在每次分发时读取，展开结果仍需逐个通过 blocked_networks 检查。
+func (s *Scheduler) expandTargets(task *model.Task) ([]string, error) {
+	spec, err := targets.Parse(task.Targets)
+	if err != nil {
+		return nil, err
+	}
+	if spec == nil {
+		return []string{""}, nil
+	}
+
+	expanded, err := spec.Expand(s.db.TargetGroupMembers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand targets: %w", err)
+	}
+
+	raw, _ := s.db.GetConfig("blocked_networks")
+	nets, err := targetutil.ParseBlockedNetworks(targetutil.NormalizeBlockedNetworks(raw))
+	if err != nil || len(nets) == 0 {
+		return expanded, nil
+	}
+
+	var ipVersion string
+	var parameters map[string]interface{}
+	if json.Unmarshal([]byte(task.Parameters), &parameters) == nil {
+		ipVersion, _ = parameters["ip_version"].(string)
+	}
 
-		// 解析任务参数
-		var parameters map[string]interface{}
-		_ = json.Unmarshal([]byte(task.Parameters), &parameters)
-		if parameters == nil {
-			parameters = map[string]interface{}{}
+	allowed := make([]string, 0, len(expanded))
+	for _, target := range expanded {
+		if targetutil.BlockedByPolicy(target, nets, ipVersion) {
+			log.Printf("[Scheduler] Skipping blocked target %s of task %s", target, task.TaskID)
+			continue
 		}
+		allowed = append(allowed, target)
+	}
+	return allowed, nil
+}
 
-		// continuous ping/tcp：每次只做 1 次（调度器负责 1s 间隔和最多 N 次）
-		if task.Mode == "continuous" && (task.TaskType == "icmp_ping" || task.TaskType == "tcp_ping") {
-			parameters["count"] = 1
+// taskTimeoutSeconds 任务在探针侧的执行超时
+func (s *Scheduler) taskTimeoutSeconds(taskType string) int {
+	timeoutSec := 300
+	if v, err := s.db.GetConfig("task_timeout"); err == nil {
+		if i, err := strconv.Atoi(strings.TrimSpace(v)); err == nil && i > 0 {
+			timeoutSec = i
 		}
+	}
 
-		// 构建任务分配消息
-		timeoutSec := 300
-		if v, err := s.db.GetConfig("task_timeout"); err == nil {
+	if configKey := routeTaskTimeoutConfigKey(taskType); configKey != "" {
+		if v, err := s.db.GetConfig(configKey); err == nil {
 			if i, err := strconv.Atoi(strings.TrimSpace(v)); err == nil && i > 0 {
 				timeoutSec = i
 			}
 		}
+	}
+	return timeoutSec
+}
 
-		if configKey := routeTaskTimeoutConfigKey(task.TaskType); configKey != "" {
-			if v, err := s.db.GetConfig(configKey); err == nil {
-				if i, err := strconv.Atoi(strings.TrimSpace(v)); err == nil && i > 0 {
-					timeoutSec = i
-				}
-			}
-		}
-
-		assignMsg := protocol.TaskAssignMessage{
-			TaskID:      task.TaskID,
-			ExecutionID: execution.ExecutionID,
-			TaskType:    task.TaskType,
-			Target:      task.Target,
-			Parameters:  parameters,
-			Timeout:     timeoutSec,
-		}
+// selectProbes 选择执行任务的探针，并按抽样策略记录实际选中的探针集合：
+// 每轮的集合写入 task_runs，tasks.resolved_probes 只保留最近一轮
+func (s *Scheduler) selectProbes(task *model.Task, runNumber int) ([]*model.Probe, error) {
+	strategy, err := sampling.Parse(task.Sampling)
+	if err != nil {
+		return nil, err
+	}
 
-		// 发送任务到探针
-		if err := s.hub.SendToProbe(probe.ProbeID, "task_assign", assignMsg); err != nil {
-			log.Printf("[Scheduler] Failed to send task to probe %s: %v", probe.ProbeID, err)
-			execution.Status = "failed"
-			errMsg := err.Error()
-			execution.Error = &errMsg
-			s.db.UpdateExecution(execution)
-			continue
-		}
+	candidates, err := s.candidateProbes(task)
+	if err != nil || strategy == nil {
+		return candidates, err
+	}
 
-		// 更新执行状态为running
-		execution.Status = "running"
-		s.db.UpdateExecution(execution)
+	probes := strategy.Select(candidates, task.Target, s.locator)
+	probeIDs := make([]string, 0, len(probes))
+	for _, probe := range probes {
+		probeIDs = append(probeIDs, probe.ProbeID)
+	}
+	resolved, _ := json.Marshal(probeIDs)
+	task.ResolvedProbes = string(resolved)
+	if err := s.store().UpdateTaskResolvedProbes(task.TaskID, task.ResolvedProbes); err != nil {
+		log.Printf("[Scheduler] Failed to record resolved probes for task %s: %v", task.TaskID, err)
+	}
+	run := &model.TaskRun{TaskID: task.TaskID, RunNumber: runNumber, ResolvedProbes: probeIDs, CreatedAt: time.Now()}
+	if err := s.store().SaveTaskRun(run); err != nil {
+		log.Printf("[Scheduler] Failed to record resolved probes of run %d for task %s: %v", runNumber, task.TaskID, err)
 	}
 
-	// 更新任务状态
-	now := time.Now()
-	task.Status = "running"
-	if task.StartedAt == nil {
-		task.StartedAt = &now
+	log.Printf("[Scheduler] Sampling %s selected %d of %d probes for task %s", strategy.Strategy, len(probes), len(candidates), task.TaskID)
+	return probes, nil
+}
+
+// taskRunNumber 本次分发所属的轮次：持续任务为已运行次数加一，单次任务固定为 1
+func taskRunNumber(task *model.Task) int {
+	if task.Mode != "continuous" {
+		return 1
+	}
+	spec, err := schedule.Parse(task.Schedule)
+	if err != nil {
+		return 1
 	}
-	return s.db.UpdateTask(task)
+	return spec.RunCount + 1
 }
 
-// selectProbes 选择执行任务的探针
-func (s *Scheduler) selectProbes(task *model.Task) ([]*model.Probe, error) {
+// candidateProbes 抽样前的候选探针：指定列表或满足条件的在线探针
+func (s *Scheduler) candidateProbes(task *model.Task) ([]*model.Probe, error) {
 	// 如果指定了探针列表
 	var assignedProbeIDs []string
 	if task.AssignedProbes != "" {
@@ -210,16 +422,22 @@ func (s *Scheduler) selectProbes(task *model.Task) ([]*model.Probe, error) {
 		return probes, nil
 	}
 
+	// 标签选择表达式：每次分发时解析，周期任务可自动覆盖新加入的探针
+	sel, err := selector.Parse(task.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector: %w", err)
+	}
+
 	// 自动选择在线探针
 	allProbes, err := s.db.GetOnlineProbes()
 	if err != nil {
 		return nil, err
 	}
 
-	// 过滤支持该任务类型的探针
+	// 过滤支持该任务类型且满足选择表达式的探针
 	var compatibleProbes []*model.Probe
 	for _, probe := range allProbes {
-		if s.hub.IsProbeOnline(probe.ProbeID) {
+		if s.hub.IsProbeOnline(probe.ProbeID) && sel.Matches(selector.ProbeLabels(probe)) {
 			var capabilities []string
 			json.Unmarshal([]byte(probe.Capabilities), &capabilities)
 
@@ -236,68 +454,63 @@ func (s *Scheduler) selectProbes(task *model.Task) ([]*model.Probe, error) {
 	return compatibleProbes, nil
 }
 
-// updateNextRun 更新持续任务的下次运行时间
+// updateNextRun 按 schedule 计算持续任务的下次运行时间
 func (s *Scheduler) updateNextRun(task *model.Task) {
-	// 持续任务：默认 1s 间隔，最多 100 次（仅 ping/tcp_ping 支持）
-	if task.TaskType != "icmp_ping" && task.TaskType != "tcp_ping" {
-		return
-	}
-
-	const defaultIntervalSeconds = 1
-	maxRuns := 100
+	now := time.Now()
 
-	// 用 schedule JSON 记录已执行次数与间隔：{"run_count":N,"interval_seconds":1,"max_runs":100}
-	type scheduleState struct {
-		RunCount        int `json:"run_count"`
-		IntervalSeconds int `json:"interval_seconds"`
-		MaxRuns         int `json:"max_runs,omitempty"`
+	spec, err := schedule.Parse(task.Schedule)
+	if err != nil {
+		log.Printf("[Scheduler] Invalid schedule for task %s, marking failed: %v", task.TaskID, err)
+		task.Status = "failed"
+		task.CompletedAt = &now
+		task.NextRunAt = nil
+		_ = s.store().UpdateTask(task)
+		return
 	}
 
-	state := scheduleState{RunCount: 0, IntervalSeconds: defaultIntervalSeconds}
-	if task.Schedule != "" {
-		_ = json.Unmarshal([]byte(task.Schedule), &state)
-		if state.IntervalSeconds <= 0 {
-			state.IntervalSeconds = defaultIntervalSeconds
-		}
-		if state.RunCount < 0 {
-			state.RunCount = 0
-		}
-		if state.MaxRuns > 0 {
-			maxRuns = state.MaxRuns
-		}
+	// 旧版持续 ping/tcp_ping：默认 1s 间隔，max_runs 未指定时每次读取 DB 配置（默认 100），
+	// 不写回 schedule，使配置修改对运行中的任务生效；任务完成时才记录实际使用的上限
+	configuredMaxRuns := spec.IsLegacy() && spec.MaxRuns <= 0
+	if configuredMaxRuns {
+		spec.MaxRuns = s.legacyMaxRuns(task.TaskType)
 	}
 
-	// DB config 覆盖默认 maxRuns（但不覆盖 schedule.max_runs）
-	if state.MaxRuns <= 0 {
-		key := "ping_max_runs"
-		if task.TaskType == "tcp_ping" {
-			key = "tcp_ping_max_runs"
-		}
-		if v, err := s.db.GetConfig(key); err == nil {
-			if i, err := strconv.Atoi(strings.TrimSpace(v)); err == nil && i > 0 {
-				maxRuns = i
-			}
-		}
+	spec.MarkRun(now)
+	nextRun, ok := spec.Next(now)
+	if ok && configuredMaxRuns {
+		spec.MaxRuns = 0
 	}
+	task.Schedule = spec.String()
 
-	state.RunCount++
-	if state.RunCount >= maxRuns {
-		state.MaxRuns = maxRuns
-		now := time.Now()
+	if !ok {
 		task.Status = "completed"
 		task.CompletedAt = &now
 		task.NextRunAt = nil
-		stateBytes, _ := json.Marshal(state)
-		task.Schedule = string(stateBytes)
-		_ = s.db.UpdateTask(task)
+		_ = s.store().UpdateTask(task)
 		return
 	}
 
-	nextRun := time.Now().Add(time.Duration(state.IntervalSeconds) * time.Second)
 	task.NextRunAt = &nextRun
-	stateBytes, _ := json.Marshal(state)
-	task.Schedule = string(stateBytes)
-	_ = s.db.UpdateTask(task)
+	_ = s.store().UpdateTask(task)
+}
+
+func (s *Scheduler) legacyMaxRuns(taskType string) int {
+	maxRuns := 100
+	key := "ping_max_runs"
+	if taskType == "tcp_ping" {
+		key = "tcp_ping_max_runs"
+	}
+	if v, err := s.db.GetConfig(key); err == nil {
+		if i, err := strconv.Atoi(strings.TrimSpace(v)); err == nil && i > 0 {
+			maxRuns = i
+		}
+	}
+	return maxRuns
+}
+
+func isLegacySchedule(raw string) bool {
+	spec, err := schedule.Parse(raw)
+	return err == nil && spec.IsLegacy()
 }
 
 func routeTaskTimeoutConfigKey(taskType string) string {
@@ -0,0 +1,221 @@
+package scheduler
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"atlas/web/internal/model"
+	"atlas/web/internal/schedule"
+)
+
+func TestExpandTargetsAppliesBlockedNetworksAndGroups(t *testing.T) {
+	s, db := newTestScheduler(t)
+	if err := db.SetConfig("blocked_networks", "192.0.2.1/32\n198.51.100.0/24"); err != nil {
+		t.Fatalf("SetConfig failed: %v", err)
+	}
+
+	single, err := s.expandTargets(&model.Task{TaskID: "single", Target: "1.1.1.1"})
+	if err != nil || !reflect.DeepEqual(single, []string{""}) {
+		t.Fatalf("expected single-target placeholder, got %v err=%v", single, err)
+	}
+
+	cidr, err := s.expandTargets(&model.Task{TaskID: "cidr", Targets: `{"cidr":"192.0.2.0/29"}`})
+	if err != nil {
+		t.Fatalf("expandTargets failed: %v", err)
+	}
+	if len(cidr) != 5 || cidr[0] != "192.0.2.2" {
+		t.Fatalf("expected blocked address to be skipped, got %v", cidr)
+	}
+
+	now := time.Now()
+	group := &model.TargetGroup{
+		GroupID:   "group-1",
+		Name:      "edge",
+		Targets:   []string{"203.0.113.1", "198.51.100.7"},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := db.CreateTargetGroup(group); err != nil {
+		t.Fatalf("CreateTargetGroup failed: %v", err)
+	}
+
+	members, err := s.expandTargets(&model.Task{TaskID: "group", Targets: `{"group":"group-1"}`})
+	if err != nil || !reflect.DeepEqual(members, []string{"203.0.113.1"}) {
+		t.Fatalf("expected group members minus blocked ones, got %v err=%v", members, err)
+	}
+}
+
+func TestAssignTaskFailsSingleTaskWithoutTargets(t *testing.T) {
+	s, db := newTestScheduler(t)
+	if err := db.SetConfig("blocked_networks", "192.0.2.0/24"); err != nil {
+		t.Fatalf("SetConfig failed: %v", err)
+	}
+	now := time.Now()
+	if err := db.CreateTargetGroup(&model.TargetGroup{GroupID: "group-empty", Name: "empty", Targets: []string{}, CreatedAt: now, UpdatedAt: now}); err != nil {
+		t.Fatalf("CreateTargetGroup failed: %v", err)
+	}
+
+	for _, tc := range []struct{ taskID, targets string }{
+		{"task-empty-group", `{"group":"group-empty"}`},
+		{"task-all-blocked", `{"hosts":["192.0.2.1","192.0.2.2"]}`},
+	} {
+		task := &model.Task{TaskID: tc.taskID, TaskType: "icmp_ping", Mode: "single", Target: "192.0.2.1", Targets: tc.targets, Status: "pending", Priority: 5}
+		if err := db.CreateTask(task); err != nil {
+			t.Fatalf("CreateTask failed: %v", err)
+		}
+		if err := s.assignTask(task); err == nil {
+			t.Fatalf("%s: expected error for task without targets", tc.taskID)
+		}
+
+		stored, err := db.GetTask(tc.taskID)
+		if err != nil || stored.Status != "failed" || stored.CompletedAt == nil {
+			t.Fatalf("%s: expected task marked failed, got %+v (%v)", tc.taskID, stored, err)
+		}
+		if executions, _ := db.ListExecutionsByTask(tc.taskID); len(executions) != 0 {
+			t.Fatalf("%s: expected no executions, got %d", tc.taskID, len(executions))
+		}
+	}
+}
+
+func TestTriggerFollowUpsCreatesLinkedTaskWithCooldown(t *testing.T) {
+	s, db := newTestScheduler(t)
+	seedRunningExecution(t, db, "task-1", "exec-1")
+
+	probe, _ := db.GetProbe("probe-1")
+	probe.Capabilities = `["icmp_ping","mtr"]`
+	if err := db.SaveProbe(probe); err != nil {
+		t.Fatalf("SaveProbe failed: %v", err)
+	}
+
+	task, _ := db.GetTask("task-1")
+	task.Parameters = `{"ip_version":"ipv4"}`
+	task.Workflow = `{"steps":[{"name":"mtr-on-loss","when":{"field":"loss","op":">","value":20},"task_type":"mtr","parameters":{"count":10}}]}`
+	execution, _ := db.GetExecution("exec-1")
+
+	healthy := &model.Result{Target: "1.1.1.1", Status: "success", Summary: `{"packet_loss_percent":0}`}
+	s.TriggerFollowUps(task, execution, healthy)
+	if followUps, _ := db.ListFollowUpTasks("task-1"); len(followUps) != 0 {
+		t.Fatalf("expected no follow-up for a healthy result, got %d", len(followUps))
+	}
+
+	lossy := &model.Result{Target: "1.1.1.1", Status: "success", Summary: `{"packet_loss_percent":50}`}
+	s.TriggerFollowUps(task, execution, lossy)
+	s.TriggerFollowUps(task, execution, lossy)
+
+	followUps, err := db.ListFollowUpTasks("task-1")
+	if err != nil {
+		t.Fatalf("ListFollowUpTasks failed: %v", err)
+	}
+	if len(followUps) != 1 {
+		t.Fatalf("expected 1 follow-up within the cooldown, got %d", len(followUps))
+	}
+	followUp := followUps[0]
+	if followUp.TaskType != "mtr" || followUp.Mode != "single" || followUp.Target != "1.1.1.1" || followUp.Status != "pending" {
+		t.Fatalf("unexpected follow-up task: %+v", followUp)
+	}
+	if followUp.ParentExecutionID == nil || *followUp.ParentExecutionID != "exec-1" {
+		t.Fatalf("expected follow-up linked to exec-1, got %v", followUp.ParentExecutionID)
+	}
+	if followUp.WorkflowStep == nil || *followUp.WorkflowStep != "mtr-on-loss" {
+		t.Fatalf("expected workflow step mtr-on-loss, got %v", followUp.WorkflowStep)
+	}
+	if followUp.AssignedProbes != `["probe-1"]` {
+		t.Fatalf("expected follow-up pinned to probe-1, got %s", followUp.AssignedProbes)
+	}
+	if followUp.Parameters != `{"count":10,"ip_version":"ipv4"}` {
+		t.Fatalf("expected step parameters with inherited ip_version, got %s", followUp.Parameters)
+	}
+}
+
+func TestUpdateNextRunReadsLegacyMaxRunsFromConfigEachRun(t *testing.T) {
+	s, db := newTestScheduler(t)
+	if err := db.SetConfig("ping_max_runs", "5"); err != nil {
+		t.Fatalf("SetConfig failed: %v", err)
+	}
+	task := &model.Task{TaskID: "task-legacy", TaskType: "icmp_ping", Mode: "continuous", Target: "1.1.1.1", Status: "running", Priority: 5, Schedule: `{"run_count":1}`}
+	if err := db.CreateTask(task); err != nil {
+		t.Fatalf("CreateTask failed: %v", err)
+	}
+
+	s.updateNextRun(task)
+	spec, err := schedule.Parse(task.Schedule)
+	if err != nil || spec.RunCount != 2 || spec.MaxRuns != 0 || task.Status != "running" {
+		t.Fatalf("expected config limit not to be stored while running, got %s (%s)", task.Schedule, task.Status)
+	}
+
+	// 配置调低后对运行中的任务生效，完成时记录实际使用的上限
+	if err := db.SetConfig("ping_max_runs", "3"); err != nil {
+		t.Fatalf("SetConfig failed: %v", err)
+	}
+	s.updateNextRun(task)
+	spec, err = schedule.Parse(task.Schedule)
+	if err != nil || spec.RunCount != 3 || spec.MaxRuns != 3 || task.Status != "completed" {
+		t.Fatalf("expected task completed with max_runs 3, got %s (%s)", task.Schedule, task.Status)
+	}
+}
+
+func TestAssignTaskRecordsSampledProbesPerRun(t *testing.T) {
+	s, db := newTestScheduler(t)
+	task := &model.Task{
+		TaskID:   "task-sampled",
+		TaskType: "icmp_ping",
+		Mode:     "continuous",
+		Target:   "1.1.1.1",
+		Status:   "running",
+		Priority: 5,
+		Schedule: `{"type":"interval","expr":"1m","run_count":0}`,
+		Sampling: `{"strategy":"random","count":1}`,
+	}
+	if err := db.CreateTask(task); err != nil {
+		t.Fatalf("CreateTask failed: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := s.assignTask(task); err != nil {
+			t.Fatalf("assignTask failed: %v", err)
+		}
+		s.updateNextRun(task)
+	}
+
+	runs, err := db.ListTaskRuns(task.TaskID)
+	if err != nil {
+		t.Fatalf("ListTaskRuns failed: %v", err)
+	}
+	if len(runs) != 2 || runs[0].RunNumber != 2 || runs[1].RunNumber != 1 {
+		t.Fatalf("expected one resolved probe set per run, got %+v", runs)
+	}
+}
+
+func TestConcurrentResultsCreateOneFollowUpWithinCooldown(t *testing.T) {
+	s, db := newTestScheduler(t)
+	seedRunningExecution(t, db, "task-1", "exec-1")
+
+	probe, _ := db.GetProbe("probe-1")
+	probe.Capabilities = `["icmp_ping","mtr"]`
+	if err := db.SaveProbe(probe); err != nil {
+		t.Fatalf("SaveProbe failed: %v", err)
+	}
+	task, _ := db.GetTask("task-1")
+	task.Workflow = `{"steps":[{"name":"mtr-on-loss","when":{"field":"loss","op":">","value":20},"task_type":"mtr"}]}`
+	execution, _ := db.GetExecution("exec-1")
+
+	lossy := &model.Result{Target: "1.1.1.1", Status: "success", Summary: `{"packet_loss_percent":50}`}
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			s.TriggerFollowUps(task, execution, lossy)
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if followUps, _ := db.ListFollowUpTasks("task-1"); len(followUps) != 1 {
+		t.Fatalf("expected concurrent results to create 1 follow-up, got %d", len(followUps))
+	}
+}
@@ -0,0 +1,166 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"atlas/web/internal/database"
+	"atlas/web/internal/model"
+	"atlas/web/internal/sampling"
+	"atlas/web/internal/workflow"
+)
+
+const (
+	// 后续任务写入失败时的最多尝试次数与退避基数
+	followUpCreateAttempts = 3
+	followUpRetryBackoff   = 200 * time.Millisecond
+)
+
+// TriggerFollowUps 按任务工作流检查结果，满足条件时创建后续任务交由调度器下发。
+// 非主节点转交主节点处理，避免多个节点并发检查冷却时间重复创建；未配置总线时在本节点处理
+func (s *Scheduler) TriggerFollowUps(task *model.Task, execution *model.TaskExecution, result *model.Result) {
+	if task == nil || task.Workflow == "" {
+		return
+	}
+	if !s.isLeader() && s.forwardToLeader(leaderEvent{Kind: eventResultStored, ResultID: result.ResultID}) {
+		return
+	}
+	s.triggerFollowUps(task, execution, result)
+}
+
+// triggerFollowUps 检查工作流步骤并创建后续任务
+func (s *Scheduler) triggerFollowUps(task *model.Task, execution *model.TaskExecution, result *model.Result) {
+	if task == nil || task.Workflow == "" {
+		return
+	}
+	spec, err := workflow.Parse(task.Workflow)
+	if err != nil {
+		log.Printf("[Scheduler] Ignoring invalid workflow of task %s: %v", task.TaskID, err)
+		return
+	}
+
+	summary := map[string]interface{}{}
+	if result.Summary != "" {
+		_ = json.Unmarshal([]byte(result.Summary), &summary)
+	}
+
+	s.followUpMu.Lock()
+	defer s.followUpMu.Unlock()
+
+	for _, step := range spec.Triggered(result.Status, summary) {
+		if s.followUpCoolingDown(task, execution, step) {
+			continue
+		}
+		if step.Probe == workflow.ProbeSame && !s.probeSupports(execution.ProbeID, step.TaskType) {
+			log.Printf("[Scheduler] Probe %s does not support %s, skipping workflow step %s of task %s", execution.ProbeID, step.TaskType, step.Name, task.TaskID)
+			continue
+		}
+
+		followUp, ok := buildFollowUp(task, execution, result, step)
+		if !ok {
+			log.Printf("[Scheduler] Cannot derive %s target from %s for workflow step %s of task %s", step.TaskType, result.Target, step.Name, task.TaskID)
+			continue
+		}
+		if err := s.createFollowUp(followUp); err != nil {
+			log.Printf("[Scheduler] Failed to create follow-up task for step %s of task %s: %v", step.Name, task.TaskID, err)
+			continue
+		}
+		log.Printf("[Scheduler] Workflow step %s of task %s triggered by execution %s, created task %s",
+			step.Name, task.TaskID, execution.ExecutionID, followUp.TaskID)
+	}
+}
+
+// createFollowUp 创建后续任务，写入失败时短暂退避后重试；租约已易主时不再重试
+func (s *Scheduler) createFollowUp(followUp *model.Task) error {
+	var err error
+	for attempt := 1; attempt <= followUpCreateAttempts; attempt++ {
+		if err = s.store().CreateTask(followUp); err == nil || errors.Is(err, database.ErrFenced) {
+			return err
+		}
+		if attempt == followUpCreateAttempts {
+			break
+		}
+		log.Printf("[Scheduler] Failed to create follow-up task %s (attempt %d/%d), retrying: %v", followUp.TaskID, attempt, followUpCreateAttempts, err)
+		select {
+		case <-time.After(time.Duration(attempt) * followUpRetryBackoff):
+		case <-s.stopChan:
+			return err
+		}
+	}
+	return err
+}
+
+// followUpCoolingDown 同一步骤在同一 (探针, 目标) 上的触发间隔未到
+func (s *Scheduler) followUpCoolingDown(task *model.Task, execution *model.TaskExecution, step workflow.Step) bool {
+	cooldown := step.Cooldown()
+	if cooldown <= 0 {
+		return false
+	}
+	last, err := s.db.LastFollowUpAt(task.TaskID, step.Name, execution.ProbeID, execution.Target)
+	if err != nil {
+		log.Printf("[Scheduler] Failed to check cooldown of workflow step %s: %v", step.Name, err)
+		return true
+	}
+	return last != nil && time.Since(*last) < cooldown
+}
+
+func (s *Scheduler) probeSupports(probeID, taskType string) bool {
+	probe, err := s.db.GetProbe(probeID)
+	if err != nil {
+		return false
+	}
+	var capabilities []string
+	_ = json.Unmarshal([]byte(probe.Capabilities), &capabilities)
+	return supportsTaskType(capabilities, taskType)
+}
+
+// buildFollowUp 构建后续单次任务，继承父任务的优先级与 ip_version
+func buildFollowUp(task *model.Task, execution *model.TaskExecution, result *model.Result, step workflow.Step) (*model.Task, bool) {
+	target := step.Target
+	if target == "" {
+		var ok bool
+		if target, ok = workflow.FollowUpTarget(task.TaskType, result.Target, step.TaskType); !ok {
+			return nil, false
+		}
+	}
+
+	parameters := map[string]interface{}{}
+	for k, v := range step.Parameters {
+		parameters[k] = v
+	}
+	var parentParameters map[string]interface{}
+	_ = json.Unmarshal([]byte(task.Parameters), &parentParameters)
+	if ipVersion, ok := parentParameters["ip_version"]; ok {
+		if _, exists := parameters["ip_version"]; !exists {
+			parameters["ip_version"] = ipVersion
+		}
+	}
+	parametersJSON, _ := json.Marshal(parameters)
+
+	stepName := step.Name
+	followUp := &model.Task{
+		TaskID:            uuid.New().String(),
+		TaskType:          step.TaskType,
+		Mode:              "single",
+		Target:            target,
+		Parameters:        string(parametersJSON),
+		AssignedProbes:    "[]",
+		Status:            "pending",
+		Priority:          task.Priority,
+		ParentTaskID:      &task.TaskID,
+		ParentExecutionID: &execution.ExecutionID,
+		WorkflowStep:      &stepName,
+	}
+
+	if step.Probe == workflow.ProbeAny {
+		followUp.Sampling = (&sampling.Strategy{Strategy: sampling.StrategyRandom, Count: 1}).String()
+	} else {
+		probesJSON, _ := json.Marshal([]string{execution.ProbeID})
+		followUp.AssignedProbes = string(probesJSON)
+	}
+	return followUp, true
+}
@@ -0,0 +1,62 @@
+package selector
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"atlas/web/internal/model"
+)
+
+// ProbeLabels 计算探针的生效标签：配置上报的标签被后台标签覆盖，空值表示删除；
+// 未设置 region 标签时使用探针注册的区域
+func ProbeLabels(probe *model.Probe) map[string]string {
+	labels := make(map[string]string)
+	for k, v := range DecodeLabels(probe.Labels) {
+		labels[k] = v
+	}
+	for k, v := range DecodeLabels(probe.AdminLabels) {
+		labels[k] = v
+	}
+	for k, v := range labels {
+		if v == "" {
+			delete(labels, k)
+		}
+	}
+	if _, ok := labels["region"]; !ok && probe.Region != "" {
+		labels["region"] = probe.Region
+	}
+	return labels
+}
+
+// DecodeLabels 解析标签 JSON，非法内容视为空
+func DecodeLabels(raw string) map[string]string {
+	labels := map[string]string{}
+	if strings.TrimSpace(raw) == "" {
+		return labels
+	}
+	_ = json.Unmarshal([]byte(raw), &labels)
+	return labels
+}
+
+// ValidateLabels 校验标签键值只包含表达式可引用的字符
+func ValidateLabels(labels map[string]string) error {
+	for k, v := range labels {
+		if k == "" || !isIdent(k) {
+			return fmt.Errorf("invalid label key %q", k)
+		}
+		if v != "" && !isIdent(v) {
+			return fmt.Errorf("invalid value %q for label %s", v, k)
+		}
+	}
+	return nil
+}
+
+func isIdent(s string) bool {
+	for _, r := range s {
+		if !isIdentRune(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,326 @@
+package selector
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Selector 探针标签选择表达式
+//
+// 语法：
+//
+//	country in (DE,FR) && provider!=hetzner
+//	tier=edge || (region=eu && !gpu)
+//
+// 支持 =、==、!=、in (...)、notin (...)、单独的 key（存在）、!key（不存在）、&&、||、! 与括号。
+// 标签键区分大小写，值比较不区分大小写。
+type Selector struct {
+	raw  string
+	root node
+}
+
+// Parse 解析选择表达式；空表达式匹配所有探针
+func Parse(expr string) (*Selector, error) {
+	s := &Selector{raw: strings.TrimSpace(expr)}
+	if s.raw == "" {
+		return s, nil
+	}
+
+	tokens, err := tokenize(s.raw)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.peek().text, p.peek().pos)
+	}
+	s.root = root
+	return s, nil
+}
+
+// Matches 判断标签集合是否满足表达式
+func (s *Selector) Matches(labels map[string]string) bool {
+	if s == nil || s.root == nil {
+		return true
+	}
+	return s.root.eval(labels)
+}
+
+// String 返回原始表达式
+func (s *Selector) String() string {
+	if s == nil {
+		return ""
+	}
+	return s.raw
+}
+
+type node interface {
+	eval(labels map[string]string) bool
+}
+
+type andNode struct{ left, right node }
+type orNode struct{ left, right node }
+type notNode struct{ inner node }
+type existsNode struct{ key string }
+
+type compareNode struct {
+	key    string
+	values []string
+	negate bool
+}
+
+func (n andNode) eval(l map[string]string) bool    { return n.left.eval(l) && n.right.eval(l) }
+func (n orNode) eval(l map[string]string) bool     { return n.left.eval(l) || n.right.eval(l) }
+func (n notNode) eval(l map[string]string) bool    { return !n.inner.eval(l) }
+func (n existsNode) eval(l map[string]string) bool { _, ok := l[n.key]; return ok }
+
+// compareNode：不存在该标签时，= / in 不匹配，!= / notin 匹配
+func (n compareNode) eval(l map[string]string) bool {
+	v, ok := l[n.key]
+	matched := false
+	if ok {
+		for _, want := range n.values {
+			if strings.EqualFold(v, want) {
+				matched = true
+				break
+			}
+		}
+	}
+	if n.negate {
+		return !matched
+	}
+	return matched
+}
+
+// 词法
+
+type tokenKind int
+
+const (
+	tokIdent tokenKind = iota
+	tokAnd
+	tokOr
+	tokNot
+	tokEq
+	tokNeq
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func tokenize(expr string) ([]token, error) {
+	var tokens []token
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '&' && i+1 < len(runes) && runes[i+1] == '&':
+			tokens = append(tokens, token{tokAnd, "&&", i})
+			i += 2
+		case r == '|' && i+1 < len(runes) && runes[i+1] == '|':
+			tokens = append(tokens, token{tokOr, "||", i})
+			i += 2
+		case r == '!' && i+1 < len(runes) && runes[i+1] == '=':
+			tokens = append(tokens, token{tokNeq, "!=", i})
+			i += 2
+		case r == '!':
+			tokens = append(tokens, token{tokNot, "!", i})
+			i++
+		case r == '=':
+			if i+1 < len(runes) && runes[i+1] == '=' {
+				i++
+			}
+			tokens = append(tokens, token{tokEq, "=", i})
+			i++
+		case r == '(':
+			tokens = append(tokens, token{tokLParen, "(", i})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{tokRParen, ")", i})
+			i++
+		case r == ',':
+			tokens = append(tokens, token{tokComma, ",", i})
+			i++
+		case r == '"' || r == '\'':
+			end := i + 1
+			for end < len(runes) && runes[end] != r {
+				end++
+			}
+			if end >= len(runes) {
+				return nil, fmt.Errorf("unterminated string at position %d", i)
+			}
+			tokens = append(tokens, token{tokIdent, string(runes[i+1 : end]), i})
+			i = end + 1
+		case isIdentRune(r):
+			start := i
+			for i < len(runes) && isIdentRune(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, token{tokIdent, string(runes[start:i]), start})
+		default:
+			return nil, fmt.Errorf("unexpected character %q at position %d", r, i)
+		}
+	}
+	return tokens, nil
+}
+
+func isIdentRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_-./:", r)
+}
+
+// 语法
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) done() bool { return p.pos >= len(p.tokens) }
+
+func (p *parser) peek() token {
+	if p.done() {
+		return token{kind: -1, text: "end of expression", pos: -1}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *parser) accept(kind tokenKind) bool {
+	if !p.done() && p.tokens[p.pos].kind == kind {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept(tokOr) {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept(tokAnd) {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.accept(tokNot) {
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{inner}, nil
+	}
+	if p.accept(tokLParen) {
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(tokRParen) {
+			return nil, fmt.Errorf("expected ) at position %d", p.peek().pos)
+		}
+		return inner, nil
+	}
+	return p.parseTerm()
+}
+
+func (p *parser) parseTerm() (node, error) {
+	key := p.peek()
+	if key.kind != tokIdent {
+		return nil, fmt.Errorf("expected label key, got %q", key.text)
+	}
+	p.pos++
+
+	if p.accept(tokEq) || p.accept(tokNeq) {
+		negate := p.tokens[p.pos-1].kind == tokNeq
+		value := p.peek()
+		if value.kind != tokIdent {
+			return nil, fmt.Errorf("expected value after %s, got %q", key.text, value.text)
+		}
+		p.pos++
+		return compareNode{key: key.text, values: []string{value.text}, negate: negate}, nil
+	}
+
+	// key in (...) / key notin (...) / key not in (...)
+	if next := p.peek(); next.kind == tokIdent {
+		op := strings.ToLower(next.text)
+		negate := false
+		switch op {
+		case "in":
+			p.pos++
+		case "notin":
+			p.pos++
+			negate = true
+		case "not":
+			p.pos++
+			if in := p.peek(); in.kind != tokIdent || strings.ToLower(in.text) != "in" {
+				return nil, fmt.Errorf("expected in after not at position %d", in.pos)
+			}
+			p.pos++
+			negate = true
+		default:
+			return nil, fmt.Errorf("unexpected %q at position %d", next.text, next.pos)
+		}
+		values, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		return compareNode{key: key.text, values: values, negate: negate}, nil
+	}
+
+	return existsNode{key: key.text}, nil
+}
+
+func (p *parser) parseList() ([]string, error) {
+	if !p.accept(tokLParen) {
+		return nil, fmt.Errorf("expected ( at position %d", p.peek().pos)
+	}
+	var values []string
+	for {
+		value := p.peek()
+		if value.kind != tokIdent {
+			return nil, fmt.Errorf("expected value in list, got %q", value.text)
+		}
+		p.pos++
+		values = append(values, value.text)
+		if p.accept(tokComma) {
+			continue
+		}
+		if p.accept(tokRParen) {
+			return values, nil
+		}
+		return nil, fmt.Errorf("expected , or ) at position %d", p.peek().pos)
+	}
+}
@@ -0,0 +1,77 @@
+package selector
+
+import (
+	"testing"
+
+	"atlas/web/internal/model"
+)
+
+func TestSelectorMatches(t *testing.T) {
+	aws := map[string]string{"provider": "aws", "tier": "edge", "country": "DE"}
+	hetzner := map[string]string{"provider": "hetzner", "country": "FR"}
+	us := map[string]string{"provider": "vultr", "country": "US", "gpu": "true"}
+
+	cases := []struct {
+		expr string
+		want [3]bool
+	}{
+		{"", [3]bool{true, true, true}},
+		{"country in (DE,FR) && provider!=hetzner", [3]bool{true, false, false}},
+		{"country in (de, fr)", [3]bool{true, true, false}},
+		{"country notin (DE,FR)", [3]bool{false, false, true}},
+		{"country not in (US)", [3]bool{true, true, false}},
+		{"tier=edge || (provider == hetzner && !gpu)", [3]bool{true, true, false}},
+		{"gpu", [3]bool{false, false, true}},
+		{"!tier && provider != aws", [3]bool{false, true, true}},
+		{`provider = "vultr"`, [3]bool{false, false, true}},
+	}
+
+	for _, tc := range cases {
+		sel, err := Parse(tc.expr)
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %v", tc.expr, err)
+		}
+		for i, labels := range []map[string]string{aws, hetzner, us} {
+			if got := sel.Matches(labels); got != tc.want[i] {
+				t.Fatalf("%q on %v: expected %v, got %v", tc.expr, labels, tc.want[i], got)
+			}
+		}
+	}
+}
+
+func TestSelectorRejectsInvalidExpressions(t *testing.T) {
+	for _, expr := range []string{
+		"country in DE",
+		"country in (DE",
+		"provider =",
+		"(tier=edge",
+		"tier=edge &&",
+		"tier=edge provider=aws",
+		"country not (DE)",
+		"tier > 1",
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Fatalf("expected %q to be rejected", expr)
+		}
+	}
+}
+
+func TestProbeLabelsMergeAdminOverrides(t *testing.T) {
+	probe := &model.Probe{
+		Region:      "eu",
+		Labels:      `{"provider":"aws","tier":"edge"}`,
+		AdminLabels: `{"tier":"core","provider":"","country":"DE"}`,
+	}
+
+	labels := ProbeLabels(probe)
+	if labels["tier"] != "core" || labels["country"] != "DE" || labels["region"] != "eu" {
+		t.Fatalf("unexpected labels: %v", labels)
+	}
+	if _, ok := labels["provider"]; ok {
+		t.Fatalf("expected empty admin label to remove provider, got %v", labels)
+	}
+
+	if err := ValidateLabels(map[string]string{"bad key": "x"}); err == nil {
+		t.Fatal("expected label key with space to be rejected")
+	}
+}
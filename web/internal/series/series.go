@@ -0,0 +1,56 @@
+package series
+
+import (
+	"fmt"
+	"time"
+
+	"atlas/web/internal/database"
+)
+
+// 支持的聚合方式
+const (
+	AggAvg = database.AggAvg
+	AggMin = database.AggMin
+	AggMax = database.AggMax
+	AggP50 = database.AggP50
+	AggP95 = database.AggP95
+	AggP99 = database.AggP99
+)
+
+// ValidateAggregation 校验聚合方式
+func ValidateAggregation(aggregation string) error {
+	if !database.IsSeriesAggregation(aggregation) {
+		return fmt.Errorf("aggregation must be one of avg, min, max, p50, p95, p99")
+	}
+	return nil
+}
+
+// Point 一个时间桶的聚合值
+type Point struct {
+	Time  time.Time `json:"time"` // 时间桶起点，按 step 对齐到 Unix 纪元
+	Value float64   `json:"value"`
+	Count int       `json:"count"` // 参与聚合的样本数，降采样数据按其原始样本数计
+	// Approximate 百分位合并了降采样数据（p99 以 p95 代替），不是原始样本上的精确值
+	Approximate bool `json:"approximate,omitempty"`
+}
+
+// Series 同一探针与目标的时序数据
+type Series struct {
+	ProbeID string  `json:"probe_id"`
+	Target  string  `json:"target"`
+	Points  []Point `json:"points"`
+}
+
+// Build 把数据库分桶聚合后的数据点按探针、目标归并为序列；
+// points 须已按探针、目标与时间排序（ListSeriesPoints 的返回顺序）
+func Build(points []database.SeriesPoint) []Series {
+	result := make([]Series, 0)
+	for _, p := range points {
+		if n := len(result); n == 0 || result[n-1].ProbeID != p.ProbeID || result[n-1].Target != p.Target {
+			result = append(result, Series{ProbeID: p.ProbeID, Target: p.Target})
+		}
+		last := &result[len(result)-1]
+		last.Points = append(last.Points, Point{Time: p.BucketStart, Value: p.Value, Count: p.Count, Approximate: p.Approximate})
+	}
+	return result
+}
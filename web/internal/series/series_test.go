@@ -0,0 +1,36 @@
+package series
+
+import (
+	"testing"
+	"time"
+
+	"atlas/web/internal/database"
+)
+
+func TestBuildGroupsPointsByProbeAndTarget(t *testing.T) {
+	hour := time.Date(2026, 10, 1, 13, 0, 0, 0, time.UTC)
+	points := []database.SeriesPoint{
+		{ProbeID: "p1", Target: "a", BucketStart: hour, Value: 10, Count: 2},
+		{ProbeID: "p1", Target: "a", BucketStart: hour.Add(time.Hour), Value: 20, Count: 1},
+		{ProbeID: "p1", Target: "b", BucketStart: hour, Value: 30, Count: 1},
+		{ProbeID: "p2", Target: "a", BucketStart: hour, Value: 40, Count: 3},
+	}
+
+	result := Build(points)
+	if len(result) != 3 {
+		t.Fatalf("expected 3 series, got %+v", result)
+	}
+	if result[0].ProbeID != "p1" || result[0].Target != "a" || len(result[0].Points) != 2 {
+		t.Fatalf("unexpected first series: %+v", result[0])
+	}
+	if p := result[0].Points[1]; !p.Time.Equal(hour.Add(time.Hour)) || p.Value != 20 || p.Count != 1 {
+		t.Fatalf("unexpected point: %+v", p)
+	}
+	if result[2].ProbeID != "p2" || result[2].Points[0].Count != 3 {
+		t.Fatalf("unexpected last series: %+v", result[2])
+	}
+
+	if empty := Build(nil); empty == nil || len(empty) != 0 {
+		t.Fatalf("expected an empty, non-nil slice, got %#v", empty)
+	}
+}
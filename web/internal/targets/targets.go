@@ -0,0 +1,234 @@
+package targets
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+const (
+	// DefaultMaxHosts CIDR 未指定 max_hosts 时的展开上限
+	DefaultMaxHosts = 256
+	// MaxTargets 单个任务展开后的目标数上限
+	MaxTargets = 4096
+)
+
+// Spec 多目标任务的目标定义，保存在 tasks.targets 中；hosts/cidr/group 三选一
+//
+//	{"hosts":["1.1.1.1","8.8.8.8","example.com"]}
+//	{"cidr":"192.0.2.0/24","max_hosts":254}
+//	{"cidr":"10.0.0.0/16","subnet_bits":24}   // 每个 /24 的第一个地址（网关）
+//	{"group":"<group_id>"}
+type Spec struct {
+	Hosts      []string `json:"hosts,omitempty"`
+	CIDR       string   `json:"cidr,omitempty"`
+	SubnetBits int      `json:"subnet_bits,omitempty"` // 设置时每个子网只取第一个可用地址
+	MaxHosts   int      `json:"max_hosts,omitempty"`   // CIDR 展开上限，超过时拒绝
+	Group      string   `json:"group,omitempty"`       // 目标组 ID
+}
+
+// GroupLookup 按 ID 读取目标组成员
+type GroupLookup func(groupID string) ([]string, error)
+
+// Parse 解析 tasks.targets；为空时返回 nil（单目标任务）
+func Parse(raw string) (*Spec, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	s := &Spec{}
+	if err := json.Unmarshal([]byte(raw), s); err != nil {
+		return nil, fmt.Errorf("invalid targets: %w", err)
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Validate 规范化并校验目标定义
+func (s *Spec) Validate() error {
+	s.Hosts = Normalize(s.Hosts)
+	s.CIDR = strings.TrimSpace(s.CIDR)
+	s.Group = strings.TrimSpace(s.Group)
+
+	kinds := 0
+	if len(s.Hosts) > 0 {
+		kinds++
+	}
+	if s.CIDR != "" {
+		kinds++
+	}
+	if s.Group != "" {
+		kinds++
+	}
+	if kinds != 1 {
+		return fmt.Errorf("targets requires exactly one of hosts, cidr or group")
+	}
+
+	if len(s.Hosts) > MaxTargets {
+		return fmt.Errorf("targets supports at most %d hosts", MaxTargets)
+	}
+
+	if s.CIDR == "" {
+		if s.SubnetBits != 0 || s.MaxHosts != 0 {
+			return fmt.Errorf("subnet_bits and max_hosts only apply to cidr")
+		}
+		return nil
+	}
+
+	if s.MaxHosts < 0 || s.MaxHosts > MaxTargets {
+		return fmt.Errorf("max_hosts must be between 1 and %d", MaxTargets)
+	}
+	if s.MaxHosts == 0 {
+		s.MaxHosts = DefaultMaxHosts
+	}
+	_, err := ExpandCIDR(s.CIDR, s.SubnetBits, s.MaxHosts)
+	return err
+}
+
+// String 序列化为 tasks.targets 存储格式
+func (s *Spec) String() string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
+
+// Label 用作 tasks.target 的简短描述
+func (s *Spec) Label() string {
+	switch {
+	case s.CIDR != "":
+		if s.SubnetBits > 0 {
+			return fmt.Sprintf("%s/%d", s.CIDR, s.SubnetBits)
+		}
+		return s.CIDR
+	case s.Group != "":
+		return "group:" + s.Group
+	case len(s.Hosts) == 1:
+		return s.Hosts[0]
+	case len(s.Hosts) > 1:
+		return fmt.Sprintf("%s (+%d)", s.Hosts[0], len(s.Hosts)-1)
+	}
+	return ""
+}
+
+// Expand 展开为目标列表；目标组在每次展开时读取，成员变化对后续运行生效
+func (s *Spec) Expand(lookup GroupLookup) ([]string, error) {
+	switch {
+	case s.CIDR != "":
+		return ExpandCIDR(s.CIDR, s.SubnetBits, s.MaxHosts)
+	case s.Group != "":
+		if lookup == nil {
+			return nil, fmt.Errorf("target group %s cannot be resolved", s.Group)
+		}
+		members, err := lookup(s.Group)
+		if err != nil {
+			return nil, err
+		}
+		members = Normalize(members)
+		if len(members) == 0 {
+			return nil, fmt.Errorf("target group %s is empty", s.Group)
+		}
+		if len(members) > MaxTargets {
+			return nil, fmt.Errorf("target group %s has more than %d members", s.Group, MaxTargets)
+		}
+		return members, nil
+	}
+	return s.Hosts, nil
+}
+
+// ExpandCIDR 展开前缀内的主机地址；subnetBits > 0 时每个子网只取第一个可用地址。
+// IPv4 前缀短于 /31 时跳过网络地址与广播地址。展开数量超过 maxHosts 时返回错误。
+func ExpandCIDR(cidr string, subnetBits, maxHosts int) ([]string, error) {
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cidr %q: %w", cidr, err)
+	}
+	prefix = prefix.Masked()
+	if maxHosts <= 0 {
+		maxHosts = DefaultMaxHosts
+	}
+
+	bits := prefix.Addr().BitLen()
+	if subnetBits != 0 {
+		if subnetBits < prefix.Bits() || subnetBits > bits {
+			return nil, fmt.Errorf("subnet_bits must be between %d and %d", prefix.Bits(), bits)
+		}
+		return expandSubnets(prefix, subnetBits, maxHosts)
+	}
+
+	skipEdges := prefix.Addr().Is4() && prefix.Bits() < 31
+	hostBits := bits - prefix.Bits()
+	if hostBits >= 32 {
+		return nil, fmt.Errorf("cidr %s expands to more than %d hosts", prefix, maxHosts)
+	}
+	total := 1 << hostBits
+	if skipEdges {
+		total -= 2
+	}
+	if total > maxHosts {
+		return nil, fmt.Errorf("cidr %s expands to more than %d hosts", prefix, maxHosts)
+	}
+
+	hosts := make([]string, 0, total)
+	for addr := prefix.Addr(); prefix.Contains(addr); addr = addr.Next() {
+		if skipEdges && (addr == prefix.Addr() || !prefix.Contains(addr.Next())) {
+			continue
+		}
+		hosts = append(hosts, addr.String())
+	}
+	return hosts, nil
+}
+
+// expandSubnets 每个 /subnetBits 子网取第一个可用地址（通常为网关）
+func expandSubnets(prefix netip.Prefix, subnetBits, maxHosts int) ([]string, error) {
+	count := subnetBits - prefix.Bits()
+	if count >= 32 || 1<<count > maxHosts {
+		return nil, fmt.Errorf("cidr %s has more than %d /%d subnets", prefix, maxHosts, subnetBits)
+	}
+
+	hosts := make([]string, 0, 1<<count)
+	subnet := netip.PrefixFrom(prefix.Addr(), subnetBits)
+	for i := 0; i < 1<<count; i++ {
+		first := subnet.Addr()
+		// 单地址子网（/32、/128）直接使用该地址，否则跳过网络地址
+		if subnetBits < first.BitLen() {
+			first = first.Next()
+		}
+		hosts = append(hosts, first.String())
+
+		next := lastAddr(subnet).Next()
+		if !next.IsValid() {
+			break
+		}
+		subnet = netip.PrefixFrom(next, subnetBits)
+	}
+	return hosts, nil
+}
+
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	addr := prefix.Addr()
+	raw := addr.AsSlice()
+	for bit := prefix.Bits(); bit < addr.BitLen(); bit++ {
+		raw[bit/8] |= 1 << (7 - bit%8)
+	}
+	last, _ := netip.AddrFromSlice(raw)
+	return last
+}
+
+// Normalize 去除空白与重复目标，保留原有顺序
+func Normalize(hosts []string) []string {
+	seen := make(map[string]struct{}, len(hosts))
+	out := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		host = strings.TrimSpace(host)
+		if host == "" {
+			continue
+		}
+		if _, ok := seen[host]; ok {
+			continue
+		}
+		seen[host] = struct{}{}
+		out = append(out, host)
+	}
+	return out
+}
@@ -0,0 +1,87 @@
+package targets
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestExpandCIDRSkipsNetworkAndBroadcast(t *testing.T) {
+	hosts, err := ExpandCIDR("192.0.2.5/29", 0, 0)
+	if err != nil {
+		t.Fatalf("ExpandCIDR failed: %v", err)
+	}
+	want := []string{"192.0.2.1", "192.0.2.2", "192.0.2.3", "192.0.2.4", "192.0.2.5", "192.0.2.6"}
+	if !reflect.DeepEqual(hosts, want) {
+		t.Fatalf("unexpected hosts: %v", hosts)
+	}
+
+	if hosts, _ := ExpandCIDR("192.0.2.0/31", 0, 0); len(hosts) != 2 {
+		t.Fatalf("expected /31 to keep both addresses, got %v", hosts)
+	}
+	if hosts, _ := ExpandCIDR("2001:db8::/126", 0, 0); len(hosts) != 4 {
+		t.Fatalf("expected 4 IPv6 addresses, got %v", hosts)
+	}
+}
+
+func TestExpandCIDREnforcesHostCap(t *testing.T) {
+	if _, err := ExpandCIDR("10.0.0.0/16", 0, 1024); err == nil {
+		t.Fatal("expected /16 to exceed the host cap")
+	}
+	if _, err := ExpandCIDR("2001:db8::/64", 0, MaxTargets); err == nil {
+		t.Fatal("expected IPv6 /64 to exceed the host cap")
+	}
+}
+
+func TestExpandCIDRSubnetGateways(t *testing.T) {
+	hosts, err := ExpandCIDR("10.1.0.0/22", 24, 0)
+	if err != nil {
+		t.Fatalf("ExpandCIDR failed: %v", err)
+	}
+	want := []string{"10.1.0.1", "10.1.1.1", "10.1.2.1", "10.1.3.1"}
+	if !reflect.DeepEqual(hosts, want) {
+		t.Fatalf("unexpected gateways: %v", hosts)
+	}
+
+	if _, err := ExpandCIDR("10.0.0.0/8", 24, 256); err == nil {
+		t.Fatal("expected 65536 subnets to exceed the cap")
+	}
+	if _, err := ExpandCIDR("10.0.0.0/16", 8, 0); err == nil {
+		t.Fatal("expected subnet_bits shorter than the prefix to fail")
+	}
+}
+
+func TestSpecValidateAndExpand(t *testing.T) {
+	if _, err := Parse(`{"hosts":["a"],"cidr":"192.0.2.0/30"}`); err == nil {
+		t.Fatal("expected hosts and cidr together to fail")
+	}
+	if _, err := Parse(`{"hosts":["a"],"max_hosts":3}`); err == nil {
+		t.Fatal("expected max_hosts without cidr to fail")
+	}
+
+	spec, err := Parse(`{"hosts":[" 1.1.1.1","8.8.8.8","1.1.1.1",""]}`)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if !reflect.DeepEqual(spec.Hosts, []string{"1.1.1.1", "8.8.8.8"}) {
+		t.Fatalf("expected normalized hosts, got %v", spec.Hosts)
+	}
+	if spec.Label() != "1.1.1.1 (+1)" {
+		t.Fatalf("unexpected label: %s", spec.Label())
+	}
+
+	group := &Spec{Group: "g1"}
+	if err := group.Validate(); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	lookup := func(id string) ([]string, error) {
+		if id != "g1" {
+			return nil, fmt.Errorf("not found")
+		}
+		return []string{"example.com", "example.org"}, nil
+	}
+	members, err := group.Expand(lookup)
+	if err != nil || len(members) != 2 {
+		t.Fatalf("unexpected group expansion: %v err=%v", members, err)
+	}
+}
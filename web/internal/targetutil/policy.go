@@ -0,0 +1,108 @@
+package targetutil
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+)
+
+// NormalizeBlockedNetworks 去除 blocked_networks 配置中的空行与首尾空白
+func NormalizeBlockedNetworks(raw string) string {
+	lines := strings.Split(raw, "\n")
+	out := make([]string, 0, len(lines))
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
+
+// ParseBlockedNetworks 解析每行一个 CIDR 的 blocked_networks 配置
+func ParseBlockedNetworks(blocked string) ([]*net.IPNet, error) {
+	lines := strings.Split(blocked, "\n")
+	var nets []*net.IPNet
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		_, ipnet, err := net.ParseCIDR(line)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, ipnet)
+	}
+	return nets, nil
+}
+
+// BlockedByPolicy 目标（或其解析结果）是否落在禁止探测的网段内
+func BlockedByPolicy(target string, blocked []*net.IPNet, ipVersion string) bool {
+	host := ExtractHost(target)
+	if host == "" {
+		return false
+	}
+
+	if ip := net.ParseIP(StripIPv6Zone(host)); ip != nil {
+		return ipInBlockedNetworks(ip, blocked)
+	}
+
+	ips, err := resolveHostIPsForVersion(host, ipVersion)
+	if err != nil {
+		return false
+	}
+	for _, ip := range ips {
+		if ipInBlockedNetworks(ip, blocked) {
+			return true
+		}
+	}
+	return false
+}
+
+func resolveHostIPsForVersion(host, ipVersion string) ([]net.IP, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	switch ipVersion {
+	case "ipv4":
+		ips, err := net.DefaultResolver.LookupIP(ctx, "ip4", host)
+		if err != nil {
+			return nil, err
+		}
+		return ips, nil
+	case "ipv6":
+		ips, err := net.DefaultResolver.LookupIP(ctx, "ip6", host)
+		if err != nil {
+			return nil, err
+		}
+		return ips, nil
+	default: // auto: prefer ipv4
+		ips4, err4 := net.DefaultResolver.LookupIP(ctx, "ip4", host)
+		if err4 == nil && len(ips4) > 0 {
+			return ips4, nil
+		}
+		ips6, err6 := net.DefaultResolver.LookupIP(ctx, "ip6", host)
+		if err6 != nil {
+			if err4 != nil {
+				return nil, err4
+			}
+			return nil, err6
+		}
+		return ips6, nil
+	}
+}
+
+func ipInBlockedNetworks(ip net.IP, blocked []*net.IPNet) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range blocked {
+		if n != nil && n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package websocket
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"atlas/web/internal/cluster"
+)
+
+// remoteMessage 经消息总线转发给其他节点的探针消息，Message 为已编码的 WebSocket 消息
+type remoteMessage struct {
+	ProbeID string          `json:"probe_id"`
+	Message json.RawMessage `json:"message"`
+}
+
+// SetCluster 启用多节点部署（需在 Run 之前调用）：registry 记录探针连接所在节点，
+// 发往其他节点上探针的消息经 bus 转发，SendToProbe 与 IsProbeOnline 对整个集群生效
+func (h *Hub) SetCluster(registry cluster.Registry, bus cluster.Bus) {
+	h.registry = registry
+	h.bus = bus
+}
+
+// startCluster 订阅本节点的总线频道，并在后台周期刷新连接心跳
+func (h *Hub) startCluster() {
+	if _, err := h.bus.Subscribe(h.registry.NodeID(), h.handleRemoteMessage); err != nil {
+		log.Printf("[Hub] Failed to subscribe to cluster bus: %v", err)
+	}
+	go h.heartbeat()
+}
+
+// heartbeat 周期刷新本节点持有的连接心跳
+func (h *Hub) heartbeat() {
+	ticker := time.NewTicker(h.registry.TTL() / 3)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := h.registry.Heartbeat(); err != nil {
+			log.Printf("[Hub] Failed to refresh probe connection heartbeat: %v", err)
+		}
+	}
+}
+
+// forward 把消息投递给持有探针连接的节点；对端写入失败不会回传，由调度器的超时回收兜底
+func (h *Hub) forward(node, probeID string, message []byte) error {
+	payload, err := json.Marshal(remoteMessage{ProbeID: probeID, Message: message})
+	if err != nil {
+		return err
+	}
+	return h.bus.Publish(node, payload)
+}
+
+// handleRemoteMessage 处理其他节点转发来的探针消息
+func (h *Hub) handleRemoteMessage(payload []byte) {
+	var msg remoteMessage
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		log.Printf("[Hub] Invalid cluster message: %v", err)
+		return
+	}
+	if err := h.deliverLocal(msg.ProbeID, msg.Message); err != nil {
+		log.Printf("[Hub] Failed to deliver forwarded message to probe %s: %v", msg.ProbeID, err)
+	}
+}
+
+// deliverLocal 写入本节点上的探针连接；持有读锁期间连接不会被注销或替换
+func (h *Hub) deliverLocal(probeID string, message []byte) error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	conn, ok := h.connections[probeID]
+	if !ok {
+		return ErrProbeNotConnected
+	}
+	select {
+	case conn.send <- message:
+		return nil
+	default:
+		return ErrSendTimeout
+	}
+}
+
+// remoteOwner 返回持有探针连接的其他节点；未启用集群或探针不在其他节点上时返回 false
+func (h *Hub) remoteOwner(probeID string) (string, bool) {
+	if h.registry == nil {
+		return "", false
+	}
+	node, ok := h.registry.Owner(probeID)
+	if !ok || node == h.registry.NodeID() {
+		return "", false
+	}
+	return node, true
+}
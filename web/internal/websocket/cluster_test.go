@@ -0,0 +1,199 @@
+package websocket
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"atlas/web/internal/cluster"
+)
+
+func TestSendToProbeRoutesAcrossNodes(t *testing.T) {
+	db := newTestDatabase(t)
+	seedTestProbe(t, db, "probe-remote")
+	bus := cluster.NewLocalBus()
+
+	nodeA := NewHub(db, nil, "secret")
+	nodeA.SetCluster(cluster.NewDBRegistry(db, "node-a", time.Minute), bus)
+	nodeB := NewHub(db, nil, "secret")
+	nodeB.SetCluster(cluster.NewDBRegistry(db, "node-b", time.Minute), bus)
+	go nodeA.Run()
+	go nodeB.Run()
+
+	if nodeA.IsProbeOnline("probe-remote") {
+		t.Fatal("expected probe to be offline before it connects")
+	}
+	if err := nodeA.SendToProbe("probe-remote", "task_cancel", nil); err != ErrProbeNotConnected {
+		t.Fatalf("expected ErrProbeNotConnected, got %v", err)
+	}
+
+	// 探针连接到 node-b
+	conn := testConnection(nodeB, "probe-remote")
+	nodeB.register <- conn
+	waitFor(t, func() bool { return nodeA.IsProbeOnline("probe-remote") })
+
+	// node-a 上的发送经总线转发到 node-b 的连接
+	if err := nodeA.SendToProbe("probe-remote", "task_cancel", map[string]string{"task_id": "task-1"}); err != nil {
+		t.Fatalf("SendToProbe failed: %v", err)
+	}
+	select {
+	case raw := <-conn.send:
+		var msg struct {
+			Type string            `json:"type"`
+			Data map[string]string `json:"data"`
+		}
+		if err := json.Unmarshal(raw, &msg); err != nil {
+			t.Fatalf("invalid forwarded message: %v", err)
+		}
+		if msg.Type != "task_cancel" || msg.Data["task_id"] != "task-1" {
+			t.Fatalf("unexpected forwarded message: %s", raw)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected forwarded message on node-b connection")
+	}
+
+	nodeB.unregister <- conn
+	waitFor(t, func() bool { return !nodeA.IsProbeOnline("probe-remote") })
+}
+
+func TestDisconnectFromStaleNodeKeepsNewOwner(t *testing.T) {
+	db := newTestDatabase(t)
+	seedTestProbe(t, db, "probe-moved")
+	bus := cluster.NewLocalBus()
+
+	nodeA := NewHub(db, nil, "secret")
+	nodeA.SetCluster(cluster.NewDBRegistry(db, "node-a", time.Minute), bus)
+	nodeB := NewHub(db, nil, "secret")
+	nodeB.SetCluster(cluster.NewDBRegistry(db, "node-b", time.Minute), bus)
+	go nodeA.Run()
+	go nodeB.Run()
+
+	disconnected := make(chan string, 1)
+	nodeA.SetDisconnectHandler(func(probeID string) { disconnected <- probeID })
+
+	// 探针先连 node-a，随后重连到 node-b，node-a 上的旧连接之后才超时断开
+	oldConn := testConnection(nodeA, "probe-moved")
+	nodeA.register <- oldConn
+	waitFor(t, func() bool { return nodeB.IsProbeOnline("probe-moved") })
+	nodeB.register <- testConnection(nodeB, "probe-moved")
+	waitFor(t, func() bool {
+		owner, ok := nodeA.registry.Owner("probe-moved")
+		return ok && owner == "node-b"
+	})
+	nodeA.unregister <- oldConn
+
+	waitFor(t, func() bool {
+		nodeA.mu.RLock()
+		defer nodeA.mu.RUnlock()
+		_, ok := nodeA.connections["probe-moved"]
+		return !ok
+	})
+	if !nodeA.IsProbeOnline("probe-moved") {
+		t.Fatal("expected probe to stay online through its new node")
+	}
+	select {
+	case probeID := <-disconnected:
+		t.Fatalf("expected no disconnect handling for moved probe, got %s", probeID)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestReconnectBeforeStaleUnregisterKeepsNewConnection(t *testing.T) {
+	db := newTestDatabase(t)
+	seedTestProbe(t, db, "probe-flap")
+
+	hub := NewHub(db, nil, "secret")
+	hub.SetCluster(cluster.NewDBRegistry(db, "node-a", time.Minute), cluster.NewLocalBus())
+	disconnected := make(chan string, 1)
+	hub.SetDisconnectHandler(func(probeID string) { disconnected <- probeID })
+	go hub.Run()
+
+	// 探针重连时旧连接的读协程尚未结束，旧连接的注销晚于新连接的注册
+	oldConn := testConnection(hub, "probe-flap")
+	newConn := testConnection(hub, "probe-flap")
+	hub.register <- oldConn
+	hub.register <- newConn
+	hub.unregister <- oldConn
+
+	select {
+	case <-oldConn.done:
+	case <-time.After(time.Second):
+		t.Fatal("expected replaced connection to be closed")
+	}
+	waitFor(t, func() bool {
+		hub.mu.RLock()
+		defer hub.mu.RUnlock()
+		return hub.connections["probe-flap"] == newConn
+	})
+	if owner, ok := hub.registry.Owner("probe-flap"); !ok || owner != "node-a" {
+		t.Fatalf("expected claim of the new connection to be kept, got %q (%v)", owner, ok)
+	}
+	if err := hub.SendToProbe("probe-flap", "task_cancel", nil); err != nil {
+		t.Fatalf("expected new connection to stay usable, got %v", err)
+	}
+	select {
+	case probeID := <-disconnected:
+		t.Fatalf("expected no disconnect handling for a stale connection, got %s", probeID)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestSendOnReplacedConnectionDoesNotPanic(t *testing.T) {
+	db := newTestDatabase(t)
+	seedTestProbe(t, db, "probe-flap")
+
+	hub := NewHub(db, nil, "secret")
+	go hub.Run()
+
+	oldConn := testConnection(hub, "probe-flap")
+	newConn := testConnection(hub, "probe-flap")
+	hub.register <- oldConn
+	hub.register <- newConn
+	<-oldConn.done
+
+	// 旧连接的读协程仍可能回复 ping 或心跳
+	if err := oldConn.sendMessage("pong", nil); err != ErrProbeNotConnected {
+		t.Fatalf("expected replaced connection to reject sends, got %v", err)
+	}
+
+	// 注销与发送并发时，发送方不会写入已结束的连接
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = hub.SendToProbe("probe-flap", "task_cancel", nil)
+			_ = newConn.sendMessage("pong", nil)
+		}()
+	}
+	hub.unregister <- oldConn
+	hub.unregister <- newConn
+	wg.Wait()
+
+	<-newConn.done
+	if err := newConn.sendMessage("pong", nil); err != ErrProbeNotConnected {
+		t.Fatalf("expected unregistered connection to reject sends, got %v", err)
+	}
+	if err := hub.SendToProbe("probe-flap", "task_cancel", nil); err != ErrProbeNotConnected {
+		t.Fatalf("expected ErrProbeNotConnected after unregister, got %v", err)
+	}
+}
+
+// testConnection 没有底层 websocket 的连接，发送的消息留在 send 中供断言
+func testConnection(h *Hub, probeID string) *Connection {
+	conn := newConnection(h, nil, "")
+	conn.ProbeID = probeID
+	return conn
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -32,11 +33,35 @@ var (
 
 // Connection WebSocket连接封装
 type Connection struct {
-	hub      *Hub
-	ws       *websocket.Conn
-	send     chan []byte
-	ProbeID  string
-	RemoteIP string
+	hub  *Hub
+	ws   *websocket.Conn
+	send chan []byte
+	// done 连接被注销或被重连替换时关闭。send 从不关闭，
+	// 读协程的回复与 Hub 的发送可能与注销并发，写入已关闭的通道会导致整个进程 panic
+	done      chan struct{}
+	closeOnce sync.Once
+	ProbeID   string
+	RemoteIP  string
+}
+
+func newConnection(h *Hub, ws *websocket.Conn, remoteIP string) *Connection {
+	return &Connection{
+		hub:      h,
+		ws:       ws,
+		send:     make(chan []byte, 256),
+		done:     make(chan struct{}),
+		RemoteIP: remoteIP,
+	}
+}
+
+// close 标记连接已结束并关闭底层 websocket，读写协程随之退出；可重复调用
+func (c *Connection) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		if c.ws != nil {
+			c.ws.Close()
+		}
+	})
 }
 
 // readPump 读取消息
@@ -79,14 +104,12 @@ func (c *Connection) writePump() {
 
 	for {
 		select {
-		case message, ok := <-c.send:
-			c.ws.SetWriteDeadline(time.Now().Add(writeWait))
-			if !ok {
-				// Hub关闭了发送通道
-				_ = c.ws.WriteMessage(websocket.CloseMessage, []byte{})
-				return
-			}
+		case <-c.done:
+			// 连接已被注销或被重连替换
+			return
 
+		case message := <-c.send:
+			c.ws.SetWriteDeadline(time.Now().Add(writeWait))
 			// 保持“一帧一条 JSON 消息”，避免多条消息拼接导致 probe 端 json.Unmarshal 失败
 			if err := c.ws.WriteMessage(websocket.TextMessage, message); err != nil {
 				return
@@ -121,6 +144,8 @@ func (c *Connection) handleMessage(message []byte) error {
 		return c.handleHeartbeat(msg)
 	case protocol.MsgTypeTaskResult:
 		return c.handleTaskResult(msg)
+	case protocol.MsgTypeTaskAssignAck:
+		return c.handleTaskAssignAck(msg)
 	case protocol.MsgTypeTaskStatus:
 		return c.handleTaskStatus(msg)
 	case protocol.MsgTypeProbeUpgradeAck:
@@ -149,9 +174,17 @@ func (c *Connection) sendMessage(msgType string, data interface{}) error {
 		return err
 	}
 
+	// 连接已结束时不再排队，避免与发送同时就绪时随机选中发送
+	select {
+	case <-c.done:
+		return ErrProbeNotConnected
+	default:
+	}
 	select {
 	case c.send <- msgBytes:
 		return nil
+	case <-c.done:
+		return ErrProbeNotConnected
 	case <-time.After(writeWait):
 		return ErrSendTimeout
 	}
@@ -8,11 +8,10 @@ import (
 	"strings"
 	"time"
 
-	"github.com/google/uuid"
-
 	"atlas/shared/protocol"
 	"atlas/web/internal/geoip"
 	"atlas/web/internal/model"
+	"atlas/web/internal/peeringdb"
 	"atlas/web/internal/targetutil"
 )
 
@@ -66,6 +65,12 @@ func (c *Connection) handleRegister(msg map[string]interface{}) error {
 
 	metadataJSON, _ := json.Marshal(metadata)
 
+	labels := registerMsg.Labels
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labelsJSON, _ := json.Marshal(labels)
+
 	probeIP := normalizeRemoteIP(c.RemoteIP)
 
 	probe := &model.Probe{
@@ -78,6 +83,9 @@ func (c *Connection) handleRegister(msg map[string]interface{}) error {
 		Status:        "online",
 		LastHeartbeat: time.Now(),
 		Metadata:      string(metadataJSON),
+		Labels:        string(labelsJSON),
+		// 旧版探针不上报容量，为 0 时调度器不限制下发
+		MaxConcurrentTasks: registerMsg.MaxConcurrentTasks,
 	}
 
 	// 从 metadata 中提取经纬度(如果有)
@@ -156,6 +164,9 @@ func (c *Connection) handleRegister(msg map[string]interface{}) error {
 	})
 
 	log.Printf("[Handler] Probe registered successfully: %s", probe.Name)
+
+	// 重投断线前未确认的任务
+	c.redeliverOutbox()
 	return nil
 }
 
@@ -210,6 +221,11 @@ func (c *Connection) handleHeartbeat(msg map[string]interface{}) error {
 		log.Printf("[Handler] Failed to update probe status: %v", err)
 	}
 
+	// 记录探针负载
+	if err := c.hub.db.UpdateProbeLoad(heartbeatMsg.ProbeID, heartbeatMsg.ActiveTasks, heartbeatMsg.QueuedTasks); err != nil {
+		log.Printf("[Handler] Failed to update probe load: %v", err)
+	}
+
 	// 发送心跳响应
 	c.sendMessage("heartbeat_ack", protocol.HeartbeatAckMessage{
 		Timestamp:     time.Now().Unix(),
@@ -219,7 +235,7 @@ func (c *Connection) handleHeartbeat(msg map[string]interface{}) error {
 	return nil
 }
 
-// handleTaskResult 处理任务结果
+// handleTaskResult 处理任务结果：解析后交给入库流水线，不在读协程中访问数据库
 func (c *Connection) handleTaskResult(msg map[string]interface{}) error {
 	dataBytes, _ := json.Marshal(msg["data"])
 	var resultMsg protocol.TaskResultMessage
@@ -229,106 +245,16 @@ func (c *Connection) handleTaskResult(msg map[string]interface{}) error {
 
 	log.Printf("[Handler] Received task result: execution=%s, status=%s", resultMsg.ExecutionID, resultMsg.Status)
 
-	// 更新执行记录
-	execution, err := c.hub.db.GetExecution(resultMsg.ExecutionID)
-	if err != nil {
-		log.Printf("[Handler] Failed to get execution: %v", err)
-		return err
-	}
-
-	now := time.Now()
-	execution.Status = resultMsg.Status
-	execution.CompletedAt = &now
-	if resultMsg.Error != "" {
-		execution.Error = &resultMsg.Error
-	} else {
-		execution.Error = nil
-	}
-
-	if err := c.hub.db.UpdateExecution(execution); err != nil {
-		log.Printf("[Handler] Failed to update execution: %v", err)
-		return err
-	}
-
-	// 获取任务信息以获取正确的target和test_type
-	task, err := c.hub.db.GetTask(resultMsg.TaskID)
-	if err != nil {
-		log.Printf("[Handler] Failed to get task: %v", err)
-		return err
-	}
-
-	// 为 route 类结果富化 hops IP 的 GeoIP/ISP 信息
-	if (task.TaskType == "traceroute" || task.TaskType == "mtr") && c.hub.geoip != nil {
-		enrichHopsWithGeoIP(resultMsg.ResultData, c.hub.geoip)
-	}
-
-	// 保存测试结果
-	resultDataJSON, _ := json.Marshal(resultMsg.ResultData)
-
-	// 提取摘要信息
-	summary := extractSummary(resultMsg.ResultData)
-
-	// 提取 resolved_ip 并查询 ISP/ASN 信息
-	resolvedIP := extractResolvedIP(resultMsg.ResultData)
-	if resolvedIP != "" && c.hub.geoip != nil {
-		location, err := c.hub.geoip.Lookup(resolvedIP)
-		if err == nil && location != nil {
-			if location.ISP != "" {
-				summary["target_isp"] = location.ISP
-			}
-			if location.ASN != "" {
-				summary["target_asn"] = location.ASN
-			}
-			if location.ASName != "" {
-				summary["target_as_name"] = location.ASName
-			}
-		}
-	}
-
-	summaryJSON, _ := json.Marshal(summary)
-
-	result := &model.Result{
-		ResultID:    uuid.New().String(),
-		ExecutionID: resultMsg.ExecutionID,
-		TaskID:      resultMsg.TaskID,
-		ProbeID:     resultMsg.ProbeID,
-		Target:      task.Target,
-		TestType:    task.TaskType,
-		Status:      resultMsg.Status,
-		ResultData:  string(resultDataJSON),
-		Summary:     string(summaryJSON),
-	}
-
-	if err := c.hub.db.SaveResult(result); err != nil {
-		log.Printf("[Handler] Failed to save result: %v", err)
-		return err
-	}
-
-	// 检查任务的所有执行是否都完成了
-	executions, err := c.hub.db.ListExecutionsByTask(resultMsg.TaskID)
-	if err == nil {
-		allCompleted := true
-		for _, exec := range executions {
-			if exec.Status == "pending" || exec.Status == "running" {
-				allCompleted = false
-				break
-			}
-		}
+	c.hub.ingest.submit(&resultMsg)
+	return nil
+}
 
-		// 如果所有执行都完成,更新任务状态
-		if allCompleted {
-			task, _ := c.hub.db.GetTask(resultMsg.TaskID)
-			if task != nil && task.Mode == "single" {
-				task.Status = "completed"
-				now := time.Now()
-				task.CompletedAt = &now
-				c.hub.db.UpdateTask(task)
-				log.Printf("[Handler] Task completed: %s", resultMsg.TaskID)
-			}
-		}
+// resultTarget 多目标任务的结果使用执行的目标
+func resultTarget(task *model.Task, execution *model.TaskExecution) string {
+	if execution.Target != "" {
+		return execution.Target
 	}
-
-	return nil
+	return task.Target
 }
 
 // handleTaskStatus 处理任务状态更新
@@ -347,6 +273,10 @@ func (c *Connection) handleTaskStatus(msg map[string]interface{}) error {
 	if err != nil {
 		return err
 	}
+	// 已结束或已转给其它探针的执行不再接受状态更新
+	if execution.ProbeID != c.ProbeID || (execution.Status != "pending" && execution.Status != "running") {
+		return nil
+	}
 
 	execution.Status = statusMsg.Status
 	return c.hub.db.UpdateExecution(execution)
@@ -428,6 +358,9 @@ func extractSummary(resultData interface{}) map[string]interface{} {
 	if finalURL, ok := dataMap["final_url"]; ok {
 		summary["http_final_url"] = finalURL
 	}
+	if ttfb, ok := dataMap["avg_ttfb_ms"]; ok {
+		summary["ttfb_ms"] = ttfb
+	}
 	if packetLoss, ok := dataMap["packet_loss_percent"]; ok {
 		summary["packet_loss_percent"] = packetLoss
 		summary["packet_loss"] = packetLoss
@@ -439,6 +372,29 @@ func extractSummary(resultData interface{}) map[string]interface{} {
 	return summary
 }
 
+// applySummaryMetrics 把摘要中的数值指标写入结果的独立列
+func applySummaryMetrics(result *model.Result, summary map[string]interface{}) {
+	result.AvgLatency = summaryFloat(summary, "avg_latency")
+	result.PacketLoss = summaryFloat(summary, "packet_loss_percent")
+	result.TTFB = summaryFloat(summary, "ttfb_ms")
+	if code := summaryFloat(summary, "http_status_code"); code != nil {
+		status := int(*code)
+		result.HTTPStatus = &status
+	}
+}
+
+// summaryFloat 取摘要中的数值字段，缺失或非数值时返回 nil
+func summaryFloat(summary map[string]interface{}, key string) *float64 {
+	switch v := summary[key].(type) {
+	case float64:
+		return &v
+	case int:
+		f := float64(v)
+		return &f
+	}
+	return nil
+}
+
 // extractResolvedIP 从结果数据中提取解析后的IP地址
 func extractResolvedIP(resultData interface{}) string {
 	dataMap, ok := resultData.(map[string]interface{})
@@ -464,8 +420,9 @@ func extractResolvedIP(resultData interface{}) string {
 	return ""
 }
 
-// enrichHopsWithGeoIP 为 traceroute hops 中的 IP 富化 GeoIP/ISP 信息
-func enrichHopsWithGeoIP(resultData interface{}, geoipService *geoip.GeoIPService) {
+// enrichHopsWithGeoIP 为 traceroute hops 中的 IP 富化 GeoIP/ISP 信息，
+// 并标注位于 IX peering LAN 内的跳点
+func enrichHopsWithGeoIP(resultData interface{}, geoipService *geoip.GeoIPService, ixIndex *peeringdb.Index) {
 	dataMap, ok := resultData.(map[string]interface{})
 	if !ok {
 		return
@@ -492,12 +449,37 @@ func enrichHopsWithGeoIP(resultData interface{}, geoipService *geoip.GeoIPServic
 			continue
 		}
 
-		// 跳过私有IP
 		parsedIP := net.ParseIP(ipStr)
+
+		// IX peering LAN：标注交换中心及成员 ASN
+		if match, ok := ixIndex.Lookup(parsedIP); ok {
+			ix := map[string]interface{}{
+				"ix_id":  match.IXID,
+				"name":   match.Name,
+				"city":   match.City,
+				"prefix": match.Prefix,
+			}
+			if match.Country != "" {
+				ix["country"] = match.Country
+			}
+			if match.MemberASN > 0 {
+				ix["member_asn"] = fmt.Sprintf("AS%d", match.MemberASN)
+			}
+			if match.MemberName != "" {
+				ix["member_name"] = match.MemberName
+			}
+			hop["ix"] = ix
+		}
+
+		// 跳过私有IP
 		if parsedIP != nil && (parsedIP.IsPrivate() || parsedIP.IsLoopback()) {
 			continue
 		}
 
+		if geoipService == nil {
+			continue
+		}
+
 		// 查询 GeoIP
 		location, err := geoipService.Lookup(ipStr)
 		if err != nil || location == nil {
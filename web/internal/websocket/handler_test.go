@@ -0,0 +1,41 @@
+package websocket
+
+import (
+	"testing"
+
+	"atlas/web/internal/peeringdb"
+)
+
+func TestEnrichHopsTagsIXPeeringLAN(t *testing.T) {
+	ixIndex, err := peeringdb.Parse([]byte(`{
+		"ix": {"data": [{"id": 26, "name": "AMS-IX", "city": "Amsterdam", "country": "NL"}]},
+		"ixlan": {"data": [{"id": 26, "ix_id": 26}]},
+		"ixpfx": {"data": [{"ixlan_id": 26, "prefix": "80.249.208.0/21"}]},
+		"netixlan": {"data": [{"ix_id": 26, "ixlan_id": 26, "name": "Google LLC", "asn": 15169, "ipaddr4": "80.249.208.247"}]}
+	}`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	resultData := map[string]interface{}{
+		"hops": []interface{}{
+			map[string]interface{}{"hop": float64(1), "ip": "10.0.0.1"},
+			map[string]interface{}{"hop": float64(2), "ip": "80.249.208.247"},
+			map[string]interface{}{"hop": float64(3), "ip": "*"},
+		},
+	}
+
+	enrichHopsWithGeoIP(resultData, nil, ixIndex)
+
+	hops := resultData["hops"].([]interface{})
+	if _, ok := hops[0].(map[string]interface{})["ix"]; ok {
+		t.Fatal("expected private hop to stay untagged")
+	}
+	ix, ok := hops[1].(map[string]interface{})["ix"].(map[string]interface{})
+	if !ok {
+		t.Fatal("expected IX hop to be tagged")
+	}
+	if ix["name"] != "AMS-IX" || ix["city"] != "Amsterdam" || ix["member_asn"] != "AS15169" {
+		t.Fatalf("unexpected ix tag: %#v", ix)
+	}
+}
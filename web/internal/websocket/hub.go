@@ -9,8 +9,12 @@ import (
 
 	"github.com/gorilla/websocket"
 
+	"atlas/web/internal/cluster"
 	"atlas/web/internal/database"
 	"atlas/web/internal/geoip"
+	"atlas/web/internal/model"
+	"atlas/web/internal/pathwatch"
+	"atlas/web/internal/peeringdb"
 )
 
 var upgrader = websocket.Upgrader{
@@ -25,45 +29,136 @@ var upgrader = websocket.Upgrader{
 type Hub struct {
 	db           *database.Database
 	geoip        *geoip.GeoIPService
+	ixIndex      *peeringdb.Index
+	pathWatcher  *pathwatch.Tracker
 	connections  map[string]*Connection // probeID -> Connection
 	register     chan *Connection
 	unregister   chan *Connection
 	mu           sync.RWMutex
 	sharedSecret string
+
+	registry cluster.Registry // 为 nil 时仅本节点
+	bus      cluster.Bus
+
+	ingest *ingester
+
+	onDisconnect func(probeID string)
+	onCapacity   func(probeID string)
+	onFailure    func(execution *model.TaskExecution) bool
+	onResult     func(task *model.Task, execution *model.TaskExecution, result *model.Result)
 }
 
-// NewHub 创建新的Hub
-func NewHub(db *database.Database, sharedSecret string) *Hub {
-	return &Hub{
+// NewHub 创建新的Hub；geoService 为 nil 时使用仅内存缓存的 GeoIP 服务
+func NewHub(db *database.Database, geoService *geoip.GeoIPService, sharedSecret string) *Hub {
+	if geoService == nil {
+		geoService = geoip.New()
+	}
+	h := &Hub{
 		db:           db,
-		geoip:        geoip.New(),
+		geoip:        geoService,
+		pathWatcher:  pathwatch.New(db),
 		connections:  make(map[string]*Connection),
 		register:     make(chan *Connection),
 		unregister:   make(chan *Connection),
 		sharedSecret: sharedSecret,
 	}
+	h.ingest = newIngester(h, DefaultIngestOptions())
+	return h
+}
+
+// SetIngestOptions 设置结果入库流水线参数（需在 Run 之前调用），未设置的参数使用默认值
+func (h *Hub) SetIngestOptions(opts IngestOptions) {
+	h.ingest = newIngester(h, opts)
+}
+
+// Stop 停止接收探针结果，等待已收到的结果入库并完成富化；用于服务关闭
+func (h *Hub) Stop() {
+	h.ingest.stop()
+}
+
+// IngestStats 结果入库流水线指标
+func (h *Hub) IngestStats() IngestStats {
+	return h.ingest.stats()
+}
+
+// SetIXIndex 设置用于标注 IX 跳点的 PeeringDB 索引（需在 Run 之前调用）
+func (h *Hub) SetIXIndex(index *peeringdb.Index) {
+	h.ixIndex = index
+}
+
+// SetDisconnectHandler 设置探针断线回调（需在 Run 之前调用），用于回收该探针上未完成的执行
+func (h *Hub) SetDisconnectHandler(fn func(probeID string)) {
+	h.onDisconnect = fn
+}
+
+// SetCapacityHandler 设置探针释放执行槽位时的回调（需在 Run 之前调用），用于下发排队中的执行
+func (h *Hub) SetCapacityHandler(fn func(probeID string)) {
+	h.onCapacity = fn
+}
+
+// SetFailureHandler 设置执行失败结果入库后的回调（需在 Run 之前调用），用于按任务重试策略安排下一次尝试；
+// 回调返回 true 表示已转交其他节点处理，任务是否完成由其检查
+func (h *Hub) SetFailureHandler(fn func(execution *model.TaskExecution) bool) {
+	h.onFailure = fn
+}
+
+// SetResultHandler 设置结果保存后的回调（需在 Run 之前调用），用于触发任务工作流的后续步骤
+func (h *Hub) SetResultHandler(fn func(task *model.Task, execution *model.TaskExecution, result *model.Result)) {
+	h.onResult = fn
 }
 
 // Run 启动Hub
 func (h *Hub) Run() {
+	h.ingest.start()
+	if h.registry != nil {
+		h.startCluster()
+	}
+
 	for {
 		select {
 		case conn := <-h.register:
 			h.mu.Lock()
+			// 探针在旧连接断开前重连：标记旧连接已被替换并关闭其 websocket，旧连接随后的注销会被忽略
+			if old, ok := h.connections[conn.ProbeID]; ok && old != conn {
+				old.close()
+			}
 			h.connections[conn.ProbeID] = conn
 			h.mu.Unlock()
 			log.Printf("[Hub] Registered probe: %s", conn.ProbeID)
 
+			if h.registry != nil {
+				if err := h.registry.Claim(conn.ProbeID); err != nil {
+					log.Printf("[Hub] Failed to claim probe connection: %v", err)
+				}
+			}
+
 		case conn := <-h.unregister:
 			h.mu.Lock()
-			if _, ok := h.connections[conn.ProbeID]; ok {
+			// 只注销仍在册的同一连接；已被重连替换的旧连接不能删除新连接、释放其归属或回收其执行
+			if current, ok := h.connections[conn.ProbeID]; ok && current == conn {
 				delete(h.connections, conn.ProbeID)
-				close(conn.send)
+				conn.close()
 				log.Printf("[Hub] Unregistered probe: %s", conn.ProbeID)
 
-				// 更新数据库状态
-				if err := h.db.UpdateProbeStatus(conn.ProbeID, "offline"); err != nil {
-					log.Printf("[Hub] Failed to update probe status: %v", err)
+				if h.registry != nil {
+					if err := h.registry.Release(conn.ProbeID); err != nil {
+						log.Printf("[Hub] Failed to release probe connection: %v", err)
+					}
+				}
+
+				// 探针已重连到其他节点时，由新节点负责其状态与执行
+				if node, moved := h.remoteOwner(conn.ProbeID); moved {
+					log.Printf("[Hub] Probe %s moved to node %s", conn.ProbeID, node)
+				} else {
+					// 更新数据库状态
+					if err := h.db.UpdateProbeStatus(conn.ProbeID, "offline"); err != nil {
+						log.Printf("[Hub] Failed to update probe status: %v", err)
+					}
+
+					// 回调可能再次访问 Hub，放到锁外异步执行
+					if h.onDisconnect != nil {
+						go h.onDisconnect(conn.ProbeID)
+					}
 				}
 			}
 			h.mu.Unlock()
@@ -79,28 +174,15 @@ func (h *Hub) HandleConnection(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	conn := &Connection{
-		hub:      h,
-		ws:       ws,
-		send:     make(chan []byte, 256),
-		RemoteIP: extractRemoteIP(r),
-	}
+	conn := newConnection(h, ws, extractRemoteIP(r))
 
 	// 启动读写协程
 	go conn.writePump()
 	go conn.readPump()
 }
 
-// SendToProbe 向指定探针发送消息
+// SendToProbe 向指定探针发送消息；启用集群时探针连接在其他节点上则经消息总线转发
 func (h *Hub) SendToProbe(probeID, msgType string, data interface{}) error {
-	h.mu.RLock()
-	conn, ok := h.connections[probeID]
-	h.mu.RUnlock()
-
-	if !ok {
-		return ErrProbeNotConnected
-	}
-
 	message := map[string]interface{}{
 		"type":      msgType,
 		"timestamp": time.Now().Unix(),
@@ -112,18 +194,25 @@ func (h *Hub) SendToProbe(probeID, msgType string, data interface{}) error {
 		return err
 	}
 
-	select {
-	case conn.send <- msgBytes:
-		return nil
-	default:
-		return ErrSendTimeout
+	// 在读锁内写入本节点连接，避免与注销或重连替换并发
+	if err := h.deliverLocal(probeID, msgBytes); err != ErrProbeNotConnected {
+		return err
+	}
+	node, remote := h.remoteOwner(probeID)
+	if !remote {
+		return ErrProbeNotConnected
 	}
+	return h.forward(node, probeID, msgBytes)
 }
 
-// IsProbeOnline 检查探针是否在线
+// IsProbeOnline 检查探针是否在线（连接在本节点或集群中的其他节点上）
 func (h *Hub) IsProbeOnline(probeID string) bool {
 	h.mu.RLock()
-	defer h.mu.RUnlock()
 	_, ok := h.connections[probeID]
-	return ok
+	h.mu.RUnlock()
+	if ok {
+		return true
+	}
+	_, remote := h.remoteOwner(probeID)
+	return remote
 }
@@ -0,0 +1,511 @@
+package websocket
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+
+	"atlas/shared/protocol"
+	"atlas/web/internal/database"
+	"atlas/web/internal/model"
+	"atlas/web/internal/retry"
+	"atlas/web/internal/routeutil"
+)
+
+// IngestOptions 结果入库流水线参数
+type IngestOptions struct {
+	QueueSize       int           // 待入库结果队列容量，队列满时阻塞探针读协程形成背压
+	Workers         int           // 入库协程数
+	BatchSize       int           // 单个事务最多写入的结果数
+	FlushInterval   time.Duration // 未攒满一批时的最长等待时间
+	EnrichQueueSize int           // 待富化结果队列总容量，平均分给各富化协程；队列满时跳过富化
+	EnrichWorkers   int           // 富化协程数（GeoIP/IX 查询）
+}
+
+// DefaultIngestOptions 默认流水线参数
+func DefaultIngestOptions() IngestOptions {
+	return IngestOptions{
+		QueueSize:       1024,
+		Workers:         4,
+		BatchSize:       64,
+		FlushInterval:   100 * time.Millisecond,
+		EnrichQueueSize: 1024,
+		EnrichWorkers:   4,
+	}
+}
+
+// withDefaults 未设置（<= 0）的参数使用默认值
+func (o IngestOptions) withDefaults() IngestOptions {
+	def := DefaultIngestOptions()
+	if o.QueueSize <= 0 {
+		o.QueueSize = def.QueueSize
+	}
+	if o.Workers <= 0 {
+		o.Workers = def.Workers
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = def.BatchSize
+	}
+	if o.FlushInterval <= 0 {
+		o.FlushInterval = def.FlushInterval
+	}
+	if o.EnrichQueueSize <= 0 {
+		o.EnrichQueueSize = def.EnrichQueueSize
+	}
+	if o.EnrichWorkers <= 0 {
+		o.EnrichWorkers = def.EnrichWorkers
+	}
+	return o
+}
+
+// IngestStats 结果入库流水线指标
+type IngestStats struct {
+	QueueDepth          int    `json:"queue_depth"`
+	QueueCapacity       int    `json:"queue_capacity"`
+	EnrichQueueDepth    int    `json:"enrich_queue_depth"`
+	EnrichQueueCapacity int    `json:"enrich_queue_capacity"`
+	Received            uint64 `json:"received"`
+	Stored              uint64 `json:"stored"`
+	Failed              uint64 `json:"failed"`
+	Batches             uint64 `json:"batches"`
+	Enriched            uint64 `json:"enriched"`
+	EnrichDropped       uint64 `json:"enrich_dropped"` // 富化队列已满而跳过富化的结果数
+}
+
+// ingester 结果入库流水线：读协程只负责入队，
+// 批处理协程按数量或时间攒批交给入库协程在一个事务中写入，GeoIP 等富化在写入后异步进行并回写结果行
+type ingester struct {
+	hub  *Hub
+	opts IngestOptions
+
+	queue   chan *protocol.TaskResultMessage
+	batches chan []*protocol.TaskResultMessage
+	// enrich 每个富化协程一个队列，同一探针、同一目标的结果固定进入同一队列，
+	// 保证路径变化检测按入库顺序串行读写 path_state
+	enrich []chan *enrichJob
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	// mu 保护 stopped 与关闭 queue：提交方持读锁入队，stop 持写锁关闭队列，避免写入已关闭的通道
+	mu       sync.RWMutex
+	stopped  bool
+	storeWG  sync.WaitGroup
+	enrichWG sync.WaitGroup
+
+	received atomic.Uint64
+	stored   atomic.Uint64
+	failed   atomic.Uint64
+	batched  atomic.Uint64
+	enriched atomic.Uint64
+	dropped  atomic.Uint64
+}
+
+// enrichJob 已入库、等待富化的结果
+type enrichJob struct {
+	taskType   string
+	result     *model.Result
+	resultData interface{}
+	summary    map[string]interface{}
+}
+
+// ingestedResult 一批中单条结果的处理上下文
+type ingestedResult struct {
+	task      *model.Task
+	execution *model.TaskExecution
+	result    *model.Result
+	job       *enrichJob
+	// stale 执行已被回收或转给其它探针：结果已保存，但不再更新执行、安排重试或触发工作流
+	stale bool
+}
+
+func newIngester(h *Hub, opts IngestOptions) *ingester {
+	opts = opts.withDefaults()
+	shardSize := opts.EnrichQueueSize / opts.EnrichWorkers
+	if shardSize < 1 {
+		shardSize = 1
+	}
+	enrich := make([]chan *enrichJob, opts.EnrichWorkers)
+	for i := range enrich {
+		enrich[i] = make(chan *enrichJob, shardSize)
+	}
+	return &ingester{
+		hub:     h,
+		opts:    opts,
+		queue:   make(chan *protocol.TaskResultMessage, opts.QueueSize),
+		batches: make(chan []*protocol.TaskResultMessage, opts.Workers),
+		enrich:  enrich,
+	}
+}
+
+// start 启动批处理、入库与富化协程，重复调用无效
+func (p *ingester) start() {
+	p.startOnce.Do(func() {
+		go p.collect()
+		p.storeWG.Add(p.opts.Workers)
+		for i := 0; i < p.opts.Workers; i++ {
+			go p.storeLoop()
+		}
+		p.enrichWG.Add(len(p.enrich))
+		for _, jobs := range p.enrich {
+			go p.enrichLoop(jobs)
+		}
+	})
+}
+
+// submit 结果入队；队列满时阻塞调用方（探针读协程）。停止后到达的结果不再入库，
+// 探针未收到确认的执行在重启后由回收与下发待确认记录兜底
+func (p *ingester) submit(msg *protocol.TaskResultMessage) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.stopped {
+		log.Printf("[Ingest] Dropped result for execution %s from probe %s: ingester stopped", msg.ExecutionID, msg.ProbeID)
+		return
+	}
+	p.received.Add(1)
+	p.queue <- msg
+}
+
+// stop 停止接收结果并等待已入队的结果写入、富化完成；未启动时先启动以处理已入队的结果
+func (p *ingester) stop() {
+	p.start()
+	p.stopOnce.Do(func() {
+		p.mu.Lock()
+		p.stopped = true
+		close(p.queue)
+		p.mu.Unlock()
+
+		// collect 写出最后一批后关闭 batches，入库协程随之退出
+		p.storeWG.Wait()
+		for _, jobs := range p.enrich {
+			close(jobs)
+		}
+		p.enrichWG.Wait()
+		log.Printf("[Ingest] Stopped: %d received, %d stored, %d failed", p.received.Load(), p.stored.Load(), p.failed.Load())
+	})
+}
+
+// enqueueEnrich 按探针与目标选择富化队列。队列满时跳过该结果的富化并计数：
+// 结果已经入库，只缺少 GeoIP/AS 路径等附加信息，不能让慢速的 GeoIP 查询阻塞入库协程进而阻塞探针读协程
+func (p *ingester) enqueueEnrich(job *enrichJob) {
+	select {
+	case p.enrich[p.enrichShard(job.result)] <- job:
+	default:
+		p.dropped.Add(1)
+	}
+}
+
+// enrichShard 同一探针、同一目标的结果总是映射到同一富化协程
+func (p *ingester) enrichShard(result *model.Result) int {
+	h := fnv.New32a()
+	h.Write([]byte(result.ProbeID))
+	h.Write([]byte{0})
+	h.Write([]byte(result.Target))
+	return int(h.Sum32() % uint32(len(p.enrich)))
+}
+
+// stats 当前指标
+func (p *ingester) stats() IngestStats {
+	enrichDepth, enrichCapacity := 0, 0
+	for _, jobs := range p.enrich {
+		enrichDepth += len(jobs)
+		enrichCapacity += cap(jobs)
+	}
+	return IngestStats{
+		QueueDepth:          len(p.queue),
+		QueueCapacity:       cap(p.queue),
+		EnrichQueueDepth:    enrichDepth,
+		EnrichQueueCapacity: enrichCapacity,
+		Received:            p.received.Load(),
+		Stored:              p.stored.Load(),
+		Failed:              p.failed.Load(),
+		Batches:             p.batched.Load(),
+		Enriched:            p.enriched.Load(),
+		EnrichDropped:       p.dropped.Load(),
+	}
+}
+
+// collect 攒批：达到 BatchSize 或第一条结果等待超过 FlushInterval 时交给入库协程；队列关闭时写出最后一批
+func (p *ingester) collect() {
+	var batch []*protocol.TaskResultMessage
+	timer := time.NewTimer(p.opts.FlushInterval)
+	timer.Stop()
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		p.batches <- batch
+		batch = nil
+	}
+
+	for {
+		select {
+		case msg, ok := <-p.queue:
+			if !ok {
+				timer.Stop()
+				flush()
+				close(p.batches)
+				return
+			}
+			batch = append(batch, msg)
+			if len(batch) == 1 {
+				timer.Reset(p.opts.FlushInterval)
+			}
+			if len(batch) >= p.opts.BatchSize {
+				timer.Stop()
+				flush()
+			}
+		case <-timer.C:
+			flush()
+		}
+	}
+}
+
+func (p *ingester) storeLoop() {
+	defer p.storeWG.Done()
+	for batch := range p.batches {
+		p.store(batch)
+	}
+}
+
+// store 写入一批结果：先读取执行与任务，再一个事务写入；事务失败时逐条重试，避免一条坏数据拖累整批
+func (p *ingester) store(batch []*protocol.TaskResultMessage) {
+	db := p.hub.db
+	tasks := make(map[string]*model.Task)
+	items := make([]*ingestedResult, 0, len(batch))
+
+	for _, msg := range batch {
+		execution, err := db.GetExecution(msg.ExecutionID)
+		if err != nil {
+			log.Printf("[Ingest] Failed to get execution %s: %v", msg.ExecutionID, err)
+			p.failed.Add(1)
+			continue
+		}
+		task, ok := tasks[msg.TaskID]
+		if !ok {
+			if task, err = db.GetTask(msg.TaskID); err != nil {
+				log.Printf("[Ingest] Failed to get task %s: %v", msg.TaskID, err)
+				p.failed.Add(1)
+				continue
+			}
+			tasks[msg.TaskID] = task
+		}
+		items = append(items, buildIngestedResult(task, execution, msg))
+	}
+	if len(items) == 0 {
+		return
+	}
+
+	writes := make([]database.ResultWrite, len(items))
+	for i, item := range items {
+		writes[i] = database.ResultWrite{Execution: item.execution, Result: item.result}
+	}
+	stored := items
+	if err := db.SaveResults(writes); err == nil {
+		for i, item := range items {
+			item.stale = writes[i].Stale
+		}
+	} else {
+		log.Printf("[Ingest] Batch of %d results failed, retrying individually: %v", len(writes), err)
+		stored = stored[:0:0]
+		for i, item := range items {
+			if err := db.SaveResults(writes[i : i+1]); err != nil {
+				log.Printf("[Ingest] Failed to save result for execution %s: %v", item.execution.ExecutionID, err)
+				p.failed.Add(1)
+				continue
+			}
+			item.stale = writes[i].Stale
+			stored = append(stored, item)
+		}
+	}
+	p.batched.Add(1)
+	p.stored.Add(uint64(len(stored)))
+
+	p.afterStore(stored)
+}
+
+// buildIngestedResult 按探针上报更新执行状态并生成结果行；富化字段留给异步阶段
+func buildIngestedResult(task *model.Task, execution *model.TaskExecution, msg *protocol.TaskResultMessage) *ingestedResult {
+	now := time.Now()
+	execution.Status = msg.Status
+	execution.CompletedAt = &now
+	if msg.Error != "" {
+		execution.Error = &msg.Error
+	} else {
+		execution.Error = nil
+	}
+	execution.FailureClass = nil
+	// 探针回报 cancelled 表示按服务端要求中断，不按失败重试
+	if msg.Status != "success" && msg.Status != "cancelled" {
+		class := retry.ClassifyResult(msg.Status)
+		execution.FailureClass = &class
+	}
+
+	resultDataJSON, _ := json.Marshal(msg.ResultData)
+	summary := extractSummary(msg.ResultData)
+	summaryJSON, _ := json.Marshal(summary)
+
+	result := &model.Result{
+		ResultID:    uuid.New().String(),
+		ExecutionID: msg.ExecutionID,
+		TaskID:      msg.TaskID,
+		ProbeID:     msg.ProbeID,
+		Target:      resultTarget(task, execution),
+		TestType:    task.TaskType,
+		Status:      msg.Status,
+		ResultData:  string(resultDataJSON),
+		Summary:     string(summaryJSON),
+	}
+	applySummaryMetrics(result, summary)
+
+	// 富化阶段修改自己的副本，工作流回调持有的结果不受影响
+	enriched := *result
+	return &ingestedResult{
+		task:      task,
+		execution: execution,
+		result:    result,
+		job:       &enrichJob{taskType: task.TaskType, result: &enriched, resultData: msg.ResultData, summary: summary},
+	}
+}
+
+// afterStore 入库后的后续处理：释放槽位、安排重试、触发工作流、检查任务完成并提交富化
+func (p *ingester) afterStore(items []*ingestedResult) {
+	h := p.hub
+	probes := make(map[string]bool)
+	taskIDs := make(map[string]bool)
+	deferred := make(map[string]bool)
+
+	for _, item := range items {
+		if item.stale {
+			log.Printf("[Ingest] Execution %s already finished or reassigned, kept late result from probe %s without updating it",
+				item.result.ExecutionID, item.result.ProbeID)
+			p.enqueueEnrich(item.job)
+			continue
+		}
+
+		// 执行槽位已释放，通知调度器下发该探针排队中的执行
+		if h.onCapacity != nil && !probes[item.execution.ProbeID] {
+			probes[item.execution.ProbeID] = true
+			go h.onCapacity(item.execution.ProbeID)
+		}
+
+		// 按任务重试策略安排下一次尝试，须在检查任务完成之前；转交主节点时由其检查任务完成
+		if h.onFailure != nil && item.execution.FailureClass != nil && h.onFailure(item.execution) {
+			deferred[item.task.TaskID] = true
+		}
+
+		// 工作流：结果满足条件时创建后续任务
+		if h.onResult != nil {
+			go h.onResult(item.task, item.execution, item.result)
+		}
+
+		if item.task.Mode == "single" {
+			taskIDs[item.task.TaskID] = true
+		}
+		p.enqueueEnrich(item.job)
+	}
+
+	for taskID := range taskIDs {
+		if deferred[taskID] {
+			continue
+		}
+		p.completeTaskIfDone(taskID)
+	}
+}
+
+// completeTaskIfDone 单次任务的所有执行都结束后标记任务完成
+func (p *ingester) completeTaskIfDone(taskID string) {
+	db := p.hub.db
+	active, err := db.CountActiveExecutionsByTask(taskID)
+	if err != nil || active > 0 {
+		return
+	}
+	task, err := db.GetTask(taskID)
+	if err != nil || task.Mode != "single" || isTerminalTaskStatus(task.Status) {
+		return
+	}
+	now := time.Now()
+	task.Status = "completed"
+	task.CompletedAt = &now
+	if err := db.UpdateTask(task); err != nil {
+		log.Printf("[Ingest] Failed to complete task %s: %v", taskID, err)
+		return
+	}
+	log.Printf("[Ingest] Task completed: %s", taskID)
+}
+
+func isTerminalTaskStatus(status string) bool {
+	return status == "completed" || status == "failed" || status == "cancelled"
+}
+
+func (p *ingester) enrichLoop(jobs <-chan *enrichJob) {
+	defer p.enrichWG.Done()
+	for job := range jobs {
+		p.enrichResult(job)
+	}
+}
+
+// enrichResult 为结果补充 hop 与目标的 GeoIP/ISP/IX 信息和 AS 路径，回写结果行后做路径变化检测
+func (p *ingester) enrichResult(job *enrichJob) {
+	h := p.hub
+	isRoute := job.taskType == "traceroute" || job.taskType == "mtr"
+	changed := false
+
+	// 为 route 类结果富化 hops IP 的 GeoIP/ISP/IX 信息
+	if isRoute {
+		enrichHopsWithGeoIP(job.resultData, h.geoip, h.ixIndex)
+		changed = true
+	}
+
+	// 提取 resolved_ip 并查询 ISP/ASN 信息
+	if resolvedIP := extractResolvedIP(job.resultData); resolvedIP != "" && h.geoip != nil {
+		location, err := h.geoip.Lookup(resolvedIP)
+		if err == nil && location != nil {
+			if location.ISP != "" {
+				job.summary["target_isp"] = location.ISP
+				changed = true
+			}
+			if location.ASN != "" {
+				job.summary["target_asn"] = location.ASN
+				changed = true
+			}
+			if location.ASName != "" {
+				job.summary["target_as_name"] = location.ASName
+				changed = true
+			}
+		}
+	}
+
+	// route 类结果：折叠为 AS 级路径
+	var routeHops []routeutil.Hop
+	if isRoute {
+		routeHops = routeutil.ParseHops(job.resultData)
+		if len(routeHops) > 0 {
+			job.summary["as_path"] = routeutil.BuildASPath(routeHops)
+			changed = true
+		}
+	}
+
+	if changed {
+		resultDataJSON, _ := json.Marshal(job.resultData)
+		summaryJSON, _ := json.Marshal(job.summary)
+		job.result.ResultData = string(resultDataJSON)
+		job.result.Summary = string(summaryJSON)
+		if err := h.db.UpdateResultEnrichment(job.result.ResultID, job.result.ResultData, job.result.Summary); err != nil {
+			log.Printf("[Ingest] Failed to update enriched result %s: %v", job.result.ResultID, err)
+		}
+	}
+	p.enriched.Add(1)
+
+	// 路径变化检测：只用成功的 route 结果更新指纹
+	if h.pathWatcher != nil && job.result.Status == "success" && len(routeHops) > 0 {
+		if _, err := h.pathWatcher.Observe(job.result, routeHops); err != nil {
+			log.Printf("[Ingest] Failed to track path change: %v", err)
+		}
+	}
+}
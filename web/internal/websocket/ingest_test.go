@@ -0,0 +1,303 @@
+package websocket
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"atlas/shared/protocol"
+	"atlas/web/internal/database"
+	"atlas/web/internal/model"
+	"atlas/web/internal/peeringdb"
+)
+
+func seedIngestTask(t *testing.T, db *database.Database, taskID, taskType string, executionIDs ...string) {
+	t.Helper()
+
+	if err := db.CreateTask(&model.Task{TaskID: taskID, TaskType: taskType, Mode: "single", Target: "1.1.1.1", Status: "running", Priority: 5}); err != nil {
+		t.Fatalf("CreateTask failed: %v", err)
+	}
+	for _, executionID := range executionIDs {
+		if err := db.SaveExecution(&model.TaskExecution{ExecutionID: executionID, TaskID: taskID, ProbeID: "probe-ingest", Status: "running", StartedAt: time.Now()}); err != nil {
+			t.Fatalf("SaveExecution failed: %v", err)
+		}
+		if err := db.SaveOutboxEntry(&model.OutboxEntry{ExecutionID: executionID, ProbeID: "probe-ingest", TaskID: taskID, Payload: "{}", CreatedAt: time.Now()}); err != nil {
+			t.Fatalf("SaveOutboxEntry failed: %v", err)
+		}
+	}
+}
+
+func waitForIngest(t *testing.T, hub *Hub, done func(IngestStats) bool) IngestStats {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		stats := hub.IngestStats()
+		if done(stats) {
+			return stats
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("ingest did not finish in time: %+v", stats)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestIngestBatchesResultsAndCompletesTask(t *testing.T) {
+	db := newTestDatabase(t)
+	seedTestProbe(t, db, "probe-ingest")
+	seedIngestTask(t, db, "task-batch", "icmp_ping", "exec-1", "exec-2", "exec-3")
+
+	hub := NewHub(db, nil, "secret")
+	hub.SetIngestOptions(IngestOptions{BatchSize: 10, FlushInterval: 20 * time.Millisecond})
+	released := make(chan string, 3)
+	hub.SetCapacityHandler(func(probeID string) { released <- probeID })
+	hub.ingest.start()
+
+	for i, status := range []string{"success", "success", "failed"} {
+		hub.ingest.submit(&protocol.TaskResultMessage{
+			ExecutionID: []string{"exec-1", "exec-2", "exec-3"}[i],
+			TaskID:      "task-batch",
+			ProbeID:     "probe-ingest",
+			Status:      status,
+			ResultData:  map[string]interface{}{"packet_loss_percent": float64(0), "avg_rtt_ms": 12.5},
+		})
+	}
+
+	stats := waitForIngest(t, hub, func(s IngestStats) bool { return s.Stored == 3 && s.Enriched == 3 })
+	if stats.Batches != 1 || stats.Failed != 0 || stats.QueueDepth != 0 {
+		t.Fatalf("expected one batch without failures, got %+v", stats)
+	}
+
+	results, err := db.ListResultsByTask("task-batch", 10, 0)
+	if err != nil || len(results) != 3 {
+		t.Fatalf("expected 3 stored results, got %d (%v)", len(results), err)
+	}
+	points, _, err := db.ListSeriesPoints(database.SeriesQuery{
+		Metric:      database.MetricAvgLatency,
+		Aggregation: database.AggAvg,
+		From:        time.Now().Add(-time.Hour),
+		To:          time.Now().Add(time.Hour),
+		Step:        24 * time.Hour,
+	}, 10)
+	samples := 0
+	for _, point := range points {
+		samples += point.Count
+	}
+	if err != nil || samples != 3 || points[0].Value != 12.5 {
+		t.Fatalf("expected avg_latency column filled from summary, got %+v (%v)", points, err)
+	}
+	exec, err := db.GetExecution("exec-3")
+	if err != nil || exec.Status != "failed" || exec.FailureClass == nil {
+		t.Fatalf("expected failed execution with failure class, got %+v (%v)", exec, err)
+	}
+	if entries, _ := db.ListOutboxEntries("probe-ingest"); len(entries) != 0 {
+		t.Fatalf("expected outbox cleared, got %d entries", len(entries))
+	}
+	task, err := db.GetTask("task-batch")
+	if err != nil || task.Status != "completed" {
+		t.Fatalf("expected task completed, got %+v (%v)", task, err)
+	}
+
+	// 同一批次同一探针只通知一次
+	select {
+	case probeID := <-released:
+		if probeID != "probe-ingest" {
+			t.Fatalf("unexpected capacity notification for %s", probeID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected capacity notification")
+	}
+}
+
+func TestIngestLateResultAfterReapKeepsExecutionState(t *testing.T) {
+	db := newTestDatabase(t)
+	seedTestProbe(t, db, "probe-ingest")
+	seedTestProbe(t, db, "probe-other")
+	seedIngestTask(t, db, "task-late", "icmp_ping", "exec-reaped", "exec-moved")
+
+	// 回收器已将 exec-reaped 记为超时并创建重试；exec-moved 已转给其它探针
+	reaped, _ := db.GetExecution("exec-reaped")
+	completedAt := time.Now()
+	reaped.Status, reaped.CompletedAt = "timeout", &completedAt
+	if err := db.UpdateExecution(reaped); err != nil {
+		t.Fatalf("UpdateExecution failed: %v", err)
+	}
+	retryOf := "exec-reaped"
+	if err := db.SaveExecution(&model.TaskExecution{ExecutionID: "exec-retry", TaskID: "task-late", ProbeID: "probe-ingest", Status: "pending", StartedAt: time.Now(), Attempt: 2, RetryOf: &retryOf}); err != nil {
+		t.Fatalf("SaveExecution failed: %v", err)
+	}
+	if err := db.ReassignExecution("exec-moved", "probe-other", time.Now()); err != nil {
+		t.Fatalf("ReassignExecution failed: %v", err)
+	}
+
+	hub := NewHub(db, nil, "secret")
+	hub.SetIngestOptions(IngestOptions{BatchSize: 10, FlushInterval: 20 * time.Millisecond})
+	workflows := make(chan string, 2)
+	hub.SetResultHandler(func(task *model.Task, execution *model.TaskExecution, result *model.Result) {
+		workflows <- execution.ExecutionID
+	})
+	hub.ingest.start()
+
+	for _, executionID := range []string{"exec-reaped", "exec-moved"} {
+		hub.ingest.submit(&protocol.TaskResultMessage{
+			ExecutionID: executionID,
+			TaskID:      "task-late",
+			ProbeID:     "probe-ingest",
+			Status:      "success",
+			ResultData:  map[string]interface{}{"avg_rtt_ms": 3.0},
+		})
+	}
+	waitForIngest(t, hub, func(s IngestStats) bool { return s.Stored == 2 && s.Enriched == 2 })
+
+	if results, err := db.ListResultsByTask("task-late", 10, 0); err != nil || len(results) != 2 {
+		t.Fatalf("expected late results to be kept, got %d (%v)", len(results), err)
+	}
+	if exec, err := db.GetExecution("exec-reaped"); err != nil || exec.Status != "timeout" {
+		t.Fatalf("expected reaped execution to stay timeout, got %+v (%v)", exec, err)
+	}
+	if exec, err := db.GetExecution("exec-retry"); err != nil || exec.Status != "pending" {
+		t.Fatalf("expected retry attempt untouched, got %+v (%v)", exec, err)
+	}
+	if exec, err := db.GetExecution("exec-moved"); err != nil || exec.Status != "running" || exec.ProbeID != "probe-other" {
+		t.Fatalf("expected reassigned execution untouched, got %+v (%v)", exec, err)
+	}
+	if entries, _ := db.ListOutboxEntries("probe-ingest"); len(entries) != 2 {
+		t.Fatalf("expected outbox entries of stale executions kept, got %d", len(entries))
+	}
+	if task, err := db.GetTask("task-late"); err != nil || task.Status != "running" {
+		t.Fatalf("expected task still running, got %+v (%v)", task, err)
+	}
+	select {
+	case executionID := <-workflows:
+		t.Fatalf("unexpected workflow callback for late result of %s", executionID)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestIngestEnrichesRouteResultAfterStoring(t *testing.T) {
+	db := newTestDatabase(t)
+	seedTestProbe(t, db, "probe-ingest")
+	seedIngestTask(t, db, "task-route", "traceroute", "exec-route")
+
+	ixIndex, err := peeringdb.Parse([]byte(`{
+		"ix": {"data": [{"id": 26, "name": "AMS-IX", "city": "Amsterdam", "country": "NL"}]},
+		"ixlan": {"data": [{"id": 26, "ix_id": 26}]},
+		"ixpfx": {"data": [{"ixlan_id": 26, "prefix": "80.249.208.0/21"}]},
+		"netixlan": {"data": [{"ix_id": 26, "ixlan_id": 26, "name": "Google LLC", "asn": 15169, "ipaddr4": "80.249.208.247"}]}
+	}`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	hub := NewHub(db, nil, "secret")
+	hub.geoip = nil // 不访问外部 GeoIP 服务
+	hub.SetIXIndex(ixIndex)
+	hub.ingest.start()
+
+	hub.ingest.submit(&protocol.TaskResultMessage{
+		ExecutionID: "exec-route",
+		TaskID:      "task-route",
+		ProbeID:     "probe-ingest",
+		Status:      "success",
+		ResultData: map[string]interface{}{
+			"hops": []interface{}{
+				map[string]interface{}{"hop": float64(1), "ip": "10.0.0.1"},
+				map[string]interface{}{"hop": float64(2), "ip": "80.249.208.247"},
+			},
+		},
+	})
+	waitForIngest(t, hub, func(s IngestStats) bool { return s.Enriched == 1 })
+
+	results, err := db.ListResultsByTask("task-route", 10, 0)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("expected 1 stored result, got %d (%v)", len(results), err)
+	}
+	if !strings.Contains(results[0].ResultData, "AMS-IX") {
+		t.Fatalf("expected enriched hops to be written back, got %s", results[0].ResultData)
+	}
+	if !strings.Contains(results[0].Summary, "as_path") {
+		t.Fatalf("expected as_path in summary, got %s", results[0].Summary)
+	}
+}
+
+func TestIngestEnrichKeepsOrderPerProbeAndTarget(t *testing.T) {
+	p := newIngester(NewHub(newTestDatabase(t), nil, "secret"), IngestOptions{EnrichQueueSize: 64, EnrichWorkers: 4})
+	if stats := p.stats(); stats.EnrichQueueCapacity != 64 {
+		t.Fatalf("expected enrich capacity split across workers to total 64, got %d", stats.EnrichQueueCapacity)
+	}
+
+	// 同一探针、同一目标的结果与其他结果交错入队
+	var keyed []*enrichJob
+	for i := 0; i < 8; i++ {
+		job := &enrichJob{taskType: "traceroute", result: &model.Result{ResultID: "route-" + string(rune('a'+i)), ProbeID: "probe-1", Target: "1.1.1.1"}}
+		keyed = append(keyed, job)
+		p.enqueueEnrich(job)
+		p.enqueueEnrich(&enrichJob{taskType: "traceroute", result: &model.Result{ProbeID: "probe-" + string(rune('a'+i)), Target: "1.1.1.1"}})
+	}
+
+	shard := p.enrich[p.enrichShard(keyed[0].result)]
+	var got []*enrichJob
+	for len(shard) > 0 {
+		if job := <-shard; job.result.ProbeID == "probe-1" {
+			got = append(got, job)
+		}
+	}
+	if len(got) != len(keyed) {
+		t.Fatalf("expected all %d results for probe-1 on one worker, got %d", len(keyed), len(got))
+	}
+	for i := range keyed {
+		if got[i] != keyed[i] {
+			t.Fatalf("expected results in submit order, got %s at %d", got[i].result.ResultID, i)
+		}
+	}
+}
+
+func TestIngestSkipsEnrichmentWhenShardIsFull(t *testing.T) {
+	p := newIngester(NewHub(newTestDatabase(t), nil, "secret"), IngestOptions{EnrichQueueSize: 2, EnrichWorkers: 2})
+
+	// 富化协程未启动，同一探针与目标的第二条结果不阻塞入库协程
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 2; i++ {
+			p.enqueueEnrich(&enrichJob{taskType: "traceroute", result: &model.Result{ProbeID: "probe-1", Target: "1.1.1.1"}})
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected enqueue to return when the shard is full")
+	}
+	if stats := p.stats(); stats.EnrichDropped != 1 || stats.EnrichQueueDepth != 1 {
+		t.Fatalf("expected one queued and one skipped enrichment, got %+v", stats)
+	}
+}
+
+func TestIngestStopDrainsQueuedResults(t *testing.T) {
+	db := newTestDatabase(t)
+	seedTestProbe(t, db, "probe-ingest")
+	seedIngestTask(t, db, "task-drain", "icmp_ping", "exec-1", "exec-2", "exec-3")
+
+	hub := NewHub(db, nil, "secret")
+	// 攒批等待远长于测试，只有 Stop 才会写出这一批
+	hub.SetIngestOptions(IngestOptions{BatchSize: 10, FlushInterval: time.Hour})
+	hub.ingest.start()
+	for _, executionID := range []string{"exec-1", "exec-2", "exec-3"} {
+		hub.ingest.submit(&protocol.TaskResultMessage{ExecutionID: executionID, TaskID: "task-drain", ProbeID: "probe-ingest", Status: "success"})
+	}
+
+	hub.Stop()
+	if stats := hub.IngestStats(); stats.Stored != 3 || stats.Enriched != 3 {
+		t.Fatalf("expected queued results stored and enriched before Stop returns, got %+v", stats)
+	}
+	if results, _ := db.ListResultsByTask("task-drain", 10, 0); len(results) != 3 {
+		t.Fatalf("expected 3 stored results, got %d", len(results))
+	}
+
+	// 停止后到达的结果被丢弃而不是写入已关闭的队列
+	hub.ingest.submit(&protocol.TaskResultMessage{ExecutionID: "exec-late", TaskID: "task-drain", ProbeID: "probe-ingest", Status: "success"})
+	hub.Stop()
+	if stats := hub.IngestStats(); stats.Received != 3 {
+		t.Fatalf("expected late result to be dropped, got %+v", stats)
+	}
+}
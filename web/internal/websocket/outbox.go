@@ -0,0 +1,73 @@
+package websocket
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"atlas/shared/protocol"
+	"atlas/web/internal/model"
+)
+
+// DeliverOutboxEntry 重投一条未确认的 task_assign
+func (h *Hub) DeliverOutboxEntry(entry *model.OutboxEntry) error {
+	var assignMsg protocol.TaskAssignMessage
+	if err := json.Unmarshal([]byte(entry.Payload), &assignMsg); err != nil {
+		return err
+	}
+	if err := h.SendToProbe(entry.ProbeID, protocol.MsgTypeTaskAssign, assignMsg); err != nil {
+		return err
+	}
+	return h.db.MarkOutboxSent(entry.ExecutionID, time.Now())
+}
+
+// redeliverOutbox 探针重连后重投其所有未确认的 task_assign，探针按执行 ID 去重
+func (c *Connection) redeliverOutbox() {
+	entries, err := c.hub.db.ListOutboxEntries(c.ProbeID)
+	if err != nil {
+		log.Printf("[Handler] Failed to list outbox of probe %s: %v", c.ProbeID, err)
+		return
+	}
+
+	redelivered := 0
+	for _, entry := range entries {
+		// 执行已结束（取消、回收）的记录不再重投
+		if execution, err := c.hub.db.GetExecution(entry.ExecutionID); err != nil || execution.Status != "running" {
+			_ = c.hub.db.DeleteOutboxEntry(entry.ExecutionID)
+			continue
+		}
+
+		var assignMsg protocol.TaskAssignMessage
+		if err := json.Unmarshal([]byte(entry.Payload), &assignMsg); err != nil {
+			log.Printf("[Handler] Dropping malformed outbox entry %s: %v", entry.ExecutionID, err)
+			_ = c.hub.db.DeleteOutboxEntry(entry.ExecutionID)
+			continue
+		}
+		if err := c.sendMessage(protocol.MsgTypeTaskAssign, assignMsg); err != nil {
+			log.Printf("[Handler] Failed to redeliver task %s to probe %s: %v", entry.ExecutionID, c.ProbeID, err)
+			return
+		}
+		if err := c.hub.db.MarkOutboxSent(entry.ExecutionID, time.Now()); err != nil {
+			log.Printf("[Handler] Failed to update outbox entry %s: %v", entry.ExecutionID, err)
+		}
+		redelivered++
+	}
+
+	if redelivered > 0 {
+		log.Printf("[Handler] Redelivered %d unacknowledged task(s) to probe %s", redelivered, c.ProbeID)
+	}
+}
+
+// handleTaskAssignAck 探针确认收到 task_assign
+func (c *Connection) handleTaskAssignAck(msg map[string]interface{}) error {
+	dataBytes, _ := json.Marshal(msg["data"])
+	var ackMsg protocol.TaskAssignAckMessage
+	if err := json.Unmarshal(dataBytes, &ackMsg); err != nil {
+		return err
+	}
+
+	if ackMsg.Duplicate {
+		log.Printf("[Handler] Probe %s already had task %s", c.ProbeID, ackMsg.ExecutionID)
+	}
+	return c.hub.db.DeleteOutboxEntry(ackMsg.ExecutionID)
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"atlas/web/internal/database"
+	"atlas/web/internal/database/dbtest"
 	"atlas/web/internal/model"
 )
 
@@ -26,9 +27,9 @@ func newTestDatabase(t *testing.T) *database.Database {
 	})
 
 	dbPath := filepath.Join(t.TempDir(), "atlas-websocket.db")
-	db, err := database.New(dbPath)
+	db, err := database.Open(dbtest.Source(t, dbPath))
 	if err != nil {
-		t.Fatalf("database.New failed: %v", err)
+		t.Fatalf("database.Open failed: %v", err)
 	}
 	t.Cleanup(func() {
 		_ = db.Close()
@@ -0,0 +1,303 @@
+package workflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"atlas/web/internal/targetutil"
+)
+
+// 条件字段，数值取自结果摘要
+const (
+	FieldLoss       = "loss"        // summary.packet_loss_percent
+	FieldLatency    = "latency"     // summary.avg_latency，毫秒
+	FieldStatusCode = "status_code" // summary.http_status_code
+	FieldStatus     = "status"      // 结果状态 success/failed/timeout
+	FieldFailure    = "failure"     // 结果状态不是 success
+)
+
+// 后续步骤的探针选择
+const (
+	ProbeSame = "same" // 在产生结果的探针上执行（默认）
+	ProbeAny  = "any"  // 任选一个支持该类型的在线探针
+)
+
+// MaxSteps 单个任务允许声明的后续步骤数
+const MaxSteps = 5
+
+// DefaultCooldownSeconds 同一 (步骤, 探针, 目标) 两次触发之间的默认间隔，避免持续任务每轮都触发
+const DefaultCooldownSeconds = 300
+
+var numericFields = map[string]string{
+	FieldLoss:       "packet_loss_percent",
+	FieldLatency:    "avg_latency",
+	FieldStatusCode: "http_status_code",
+}
+
+var taskTypes = map[string]bool{
+	"icmp_ping":  true,
+	"tcp_ping":   true,
+	"traceroute": true,
+	"mtr":        true,
+	"http_test":  true,
+}
+
+// Condition 对单个结果的判断条件
+//
+//	{"field":"loss","op":">","value":20}
+//	{"field":"status_code","op":">=","value":500}
+//	{"field":"failure"}
+type Condition struct {
+	Field string      `json:"field"`
+	Op    string      `json:"op,omitempty"`    // > >= < <= == !=，failure 不需要
+	Value interface{} `json:"value,omitempty"` // status 为字符串，其余为数值
+
+	number float64
+	text   string
+}
+
+// Step 结果满足条件时自动创建的后续任务
+type Step struct {
+	Name            string                 `json:"name"`
+	When            Condition              `json:"when"`
+	TaskType        string                 `json:"task_type"`
+	Target          string                 `json:"target,omitempty"` // 为空时沿用触发结果的目标
+	Parameters      map[string]interface{} `json:"parameters,omitempty"`
+	Probe           string                 `json:"probe,omitempty"`            // same/any，默认 same
+	CooldownSeconds int                    `json:"cooldown_seconds,omitempty"` // 默认 300，0 以下表示不限制
+}
+
+// Spec 任务的后续步骤定义，保存在 tasks.workflow 中
+//
+//	{"steps":[{"name":"trace-on-loss","when":{"field":"loss","op":">","value":20},"task_type":"mtr"}]}
+type Spec struct {
+	Steps []Step `json:"steps"`
+}
+
+// Parse 解析 tasks.workflow；为空时返回 nil
+func Parse(raw string) (*Spec, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	s := &Spec{}
+	if err := json.Unmarshal([]byte(raw), s); err != nil {
+		return nil, fmt.Errorf("invalid workflow: %w", err)
+	}
+	if err := s.normalize(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Validate 填充默认值并校验；parentType 为声明该工作流的任务类型，用于检查目标能否沿用
+func (s *Spec) Validate(parentType string) error {
+	if err := s.normalize(); err != nil {
+		return err
+	}
+	for _, step := range s.Steps {
+		if step.Target == "" {
+			if _, ok := FollowUpTarget(parentType, "", step.TaskType); !ok {
+				return fmt.Errorf("workflow step %s: %s cannot reuse the target of %s, set target", step.Name, step.TaskType, parentType)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Spec) normalize() error {
+	if len(s.Steps) == 0 {
+		return fmt.Errorf("workflow requires at least one step")
+	}
+	if len(s.Steps) > MaxSteps {
+		return fmt.Errorf("workflow supports at most %d steps", MaxSteps)
+	}
+
+	names := make(map[string]struct{}, len(s.Steps))
+	for i := range s.Steps {
+		step := &s.Steps[i]
+		step.Name = strings.TrimSpace(step.Name)
+		if step.Name == "" {
+			step.Name = fmt.Sprintf("step-%d", i+1)
+		}
+		if _, ok := names[step.Name]; ok {
+			return fmt.Errorf("duplicate workflow step name %q", step.Name)
+		}
+		names[step.Name] = struct{}{}
+
+		step.TaskType = strings.TrimSpace(step.TaskType)
+		if !taskTypes[step.TaskType] {
+			return fmt.Errorf("workflow step %s: unsupported task_type %q", step.Name, step.TaskType)
+		}
+		step.Target = strings.TrimSpace(step.Target)
+
+		step.Probe = strings.ToLower(strings.TrimSpace(step.Probe))
+		switch step.Probe {
+		case "":
+			step.Probe = ProbeSame
+		case ProbeSame, ProbeAny:
+		default:
+			return fmt.Errorf("workflow step %s: probe must be same or any", step.Name)
+		}
+		if step.CooldownSeconds == 0 {
+			step.CooldownSeconds = DefaultCooldownSeconds
+		}
+
+		if err := step.When.normalize(); err != nil {
+			return fmt.Errorf("workflow step %s: %w", step.Name, err)
+		}
+	}
+	return nil
+}
+
+func (c *Condition) normalize() error {
+	c.Field = strings.ToLower(strings.TrimSpace(c.Field))
+	c.Op = strings.TrimSpace(c.Op)
+
+	switch {
+	case c.Field == FieldFailure:
+		c.Op = ""
+		c.Value = nil
+		return nil
+	case c.Field == FieldStatus:
+		if c.Op != "==" && c.Op != "!=" {
+			return fmt.Errorf("condition on status supports == and != only")
+		}
+		text, ok := c.Value.(string)
+		if !ok || strings.TrimSpace(text) == "" {
+			return fmt.Errorf("condition on status requires a string value")
+		}
+		c.text = strings.TrimSpace(text)
+		c.Value = c.text
+		return nil
+	case numericFields[c.Field] != "":
+		switch c.Op {
+		case ">", ">=", "<", "<=", "==", "!=":
+		default:
+			return fmt.Errorf("unsupported condition operator %q", c.Op)
+		}
+		number, ok := toFloat(c.Value)
+		if !ok {
+			return fmt.Errorf("condition on %s requires a numeric value", c.Field)
+		}
+		c.number = number
+		return nil
+	default:
+		return fmt.Errorf("unsupported condition field %q", c.Field)
+	}
+}
+
+// String 序列化为 tasks.workflow 存储格式
+func (s *Spec) String() string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
+
+// Matches 判断结果是否满足条件；摘要中没有对应字段时不满足
+func (c *Condition) Matches(status string, summary map[string]interface{}) bool {
+	switch c.Field {
+	case FieldFailure:
+		return status != "success"
+	case FieldStatus:
+		if c.Op == "!=" {
+			return status != c.text
+		}
+		return status == c.text
+	}
+
+	value, ok := toFloat(summary[numericFields[c.Field]])
+	if !ok {
+		return false
+	}
+	switch c.Op {
+	case ">":
+		return value > c.number
+	case ">=":
+		return value >= c.number
+	case "<":
+		return value < c.number
+	case "<=":
+		return value <= c.number
+	case "==":
+		return value == c.number
+	case "!=":
+		return value != c.number
+	}
+	return false
+}
+
+// Triggered 返回结果触发的后续步骤
+func (s *Spec) Triggered(status string, summary map[string]interface{}) []Step {
+	var steps []Step
+	for _, step := range s.Steps {
+		if step.When.Matches(status, summary) {
+			steps = append(steps, step)
+		}
+	}
+	return steps
+}
+
+// Cooldown 步骤的触发间隔
+func (s *Step) Cooldown() time.Duration {
+	if s.CooldownSeconds <= 0 {
+		return 0
+	}
+	return time.Duration(s.CooldownSeconds) * time.Second
+}
+
+// FollowUpTarget 由触发结果的目标推导后续任务的目标：tcp_ping 需要 host:port，其余类型使用主机名。
+// target 为空时只检查能否推导
+func FollowUpTarget(parentType, target, stepType string) (string, bool) {
+	if stepType != "tcp_ping" {
+		if target == "" {
+			return "", true
+		}
+		host := targetutil.ExtractHost(target)
+		if stepType == "http_test" && parentType == "http_test" {
+			host = target
+		}
+		return host, host != ""
+	}
+
+	switch parentType {
+	case "tcp_ping":
+		return target, true
+	case "http_test":
+		if target == "" {
+			return "", true
+		}
+		u, err := url.Parse(targetutil.NormalizeHTTPURL(target))
+		if err != nil || u.Hostname() == "" {
+			return "", false
+		}
+		port := u.Port()
+		if port == "" {
+			port = "80"
+			if u.Scheme == "https" {
+				port = "443"
+			}
+		}
+		return net.JoinHostPort(u.Hostname(), port), true
+	}
+	return "", false
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,88 @@
+package workflow
+
+import "testing"
+
+func TestParseAndTrigger(t *testing.T) {
+	spec, err := Parse(`{"steps":[
+		{"when":{"field":"loss","op":">","value":20},"task_type":"mtr"},
+		{"name":"http-5xx","when":{"field":"status_code","op":">=","value":500},"task_type":"traceroute","probe":"any"},
+		{"name":"on-failure","when":{"field":"failure"},"task_type":"mtr","cooldown_seconds":-1}
+	]}`)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if spec.Steps[0].Name != "step-1" || spec.Steps[0].Probe != ProbeSame || spec.Steps[0].CooldownSeconds != DefaultCooldownSeconds {
+		t.Fatalf("expected defaults to be filled, got %+v", spec.Steps[0])
+	}
+	if spec.Steps[2].Cooldown() != 0 {
+		t.Fatal("expected negative cooldown to disable the limit")
+	}
+
+	tests := []struct {
+		name    string
+		status  string
+		summary map[string]interface{}
+		want    []string
+	}{
+		{"loss above threshold", "success", map[string]interface{}{"packet_loss_percent": 40.0}, []string{"step-1"}},
+		{"loss at threshold", "success", map[string]interface{}{"packet_loss_percent": 20}, nil},
+		{"server error", "success", map[string]interface{}{"http_status_code": 503.0}, []string{"http-5xx"}},
+		{"failed without summary", "timeout", map[string]interface{}{}, []string{"on-failure"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			steps := spec.Triggered(tt.status, tt.summary)
+			if len(steps) != len(tt.want) {
+				t.Fatalf("expected %v, got %d step(s)", tt.want, len(steps))
+			}
+			for i, step := range steps {
+				if step.Name != tt.want[i] {
+					t.Fatalf("expected %v, got %s at %d", tt.want, step.Name, i)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateRejectsInvalidSteps(t *testing.T) {
+	for _, raw := range []string{
+		`{"steps":[]}`,
+		`{"steps":[{"when":{"field":"loss","op":"~","value":1},"task_type":"mtr"}]}`,
+		`{"steps":[{"when":{"field":"loss","op":">","value":"high"},"task_type":"mtr"}]}`,
+		`{"steps":[{"when":{"field":"status","op":">","value":"failed"},"task_type":"mtr"}]}`,
+		`{"steps":[{"when":{"field":"jitter","op":">","value":1},"task_type":"mtr"}]}`,
+		`{"steps":[{"when":{"field":"failure"},"task_type":"dns"}]}`,
+		`{"steps":[{"name":"a","when":{"field":"failure"},"task_type":"mtr"},{"name":"a","when":{"field":"failure"},"task_type":"mtr"}]}`,
+	} {
+		if _, err := Parse(raw); err == nil {
+			t.Errorf("expected %s to be rejected", raw)
+		}
+	}
+
+	spec := &Spec{Steps: []Step{{When: Condition{Field: FieldFailure}, TaskType: "tcp_ping"}}}
+	if err := spec.Validate("icmp_ping"); err == nil {
+		t.Fatal("expected tcp_ping follow-up of icmp_ping without target to be rejected")
+	}
+	if err := spec.Validate("http_test"); err != nil {
+		t.Fatalf("expected tcp_ping follow-up of http_test to derive its target: %v", err)
+	}
+}
+
+func TestFollowUpTarget(t *testing.T) {
+	tests := []struct {
+		parentType, target, stepType, want string
+	}{
+		{"icmp_ping", "192.0.2.1", "mtr", "192.0.2.1"},
+		{"tcp_ping", "example.com:443", "traceroute", "example.com"},
+		{"tcp_ping", "[2001:db8::1]:22", "mtr", "2001:db8::1"},
+		{"http_test", "https://example.com/health", "mtr", "example.com"},
+		{"http_test", "https://example.com/health", "tcp_ping", "example.com:443"},
+		{"http_test", "http://example.com:8080/", "http_test", "http://example.com:8080/"},
+	}
+	for _, tt := range tests {
+		got, ok := FollowUpTarget(tt.parentType, tt.target, tt.stepType)
+		if !ok || got != tt.want {
+			t.Errorf("FollowUpTarget(%s, %s, %s) = %q, %v; want %q", tt.parentType, tt.target, tt.stepType, got, ok, tt.want)
+		}
+	}
+}
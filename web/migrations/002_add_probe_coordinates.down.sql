@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_probes_coordinates;
+ALTER TABLE probes DROP COLUMN longitude;
+ALTER TABLE probes DROP COLUMN latitude;
@@ -0,0 +1,11 @@
+-- GeoIP 查询结果持久化缓存
+CREATE TABLE IF NOT EXISTS geoip_cache (
+    ip TEXT PRIMARY KEY,                      -- 查询的 IP
+    provider TEXT NOT NULL,                   -- 数据来源: ip-api
+    data TEXT NOT NULL,                       -- Location JSON
+    fetched_at DATETIME NOT NULL,             -- 拉取时间
+    ttl_seconds INTEGER NOT NULL,             -- 有效期(秒)
+    expires_at DATETIME NOT NULL              -- fetched_at + ttl_seconds
+);
+
+CREATE INDEX IF NOT EXISTS idx_geoip_cache_expires_at ON geoip_cache(expires_at);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS path_changes;
+DROP TABLE IF EXISTS path_states;
+
+DELETE FROM config WHERE key IN ('path_fingerprint_mode', 'path_change_webhook_url');
@@ -0,0 +1,36 @@
+-- 路径变化检测：每个 (probe, target) 的当前路径指纹与历史变化事件
+CREATE TABLE IF NOT EXISTS path_states (
+    probe_id TEXT NOT NULL,
+    target TEXT NOT NULL,
+    mode TEXT NOT NULL,                       -- 指纹模式: ip/asn
+    fingerprint TEXT NOT NULL,
+    path TEXT NOT NULL,                       -- 规范化路径 JSON 数组
+    result_id TEXT NOT NULL,                  -- 最近一次观测到该路径的结果
+    first_seen_at DATETIME NOT NULL,
+    last_seen_at DATETIME NOT NULL,
+    PRIMARY KEY (probe_id, target)
+);
+
+CREATE TABLE IF NOT EXISTS path_changes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    change_id TEXT UNIQUE NOT NULL,
+    probe_id TEXT NOT NULL,
+    target TEXT NOT NULL,
+    task_id TEXT NOT NULL,
+    result_id TEXT NOT NULL,
+    previous_result_id TEXT,
+    mode TEXT NOT NULL,
+    old_fingerprint TEXT NOT NULL,
+    new_fingerprint TEXT NOT NULL,
+    old_path TEXT NOT NULL,                   -- JSON 数组
+    new_path TEXT NOT NULL,                   -- JSON 数组
+    diff TEXT NOT NULL,                       -- 逐跳差异 JSON
+    detected_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_path_changes_probe_target ON path_changes(probe_id, target);
+CREATE INDEX IF NOT EXISTS idx_path_changes_detected_at ON path_changes(detected_at);
+
+INSERT OR IGNORE INTO config (key, value, description) VALUES
+('path_fingerprint_mode', 'ip', '路径指纹模式: ip/asn'),
+('path_change_webhook_url', '', '路径变化通知 Webhook，留空不通知');
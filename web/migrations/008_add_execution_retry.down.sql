@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_executions_retry_of;
+ALTER TABLE task_executions DROP COLUMN retry_of;
+ALTER TABLE task_executions DROP COLUMN attempt;
+
+DELETE FROM config WHERE key IN ('lost_execution_retry', 'lost_execution_retry_wait_seconds');
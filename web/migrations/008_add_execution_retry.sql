@@ -0,0 +1,9 @@
+-- 执行回收与重新分配：记录每次尝试及其来源
+ALTER TABLE task_executions ADD COLUMN attempt INTEGER DEFAULT 1;   -- 第几次尝试
+ALTER TABLE task_executions ADD COLUMN retry_of TEXT;               -- 上一次尝试的 execution_id
+
+CREATE INDEX IF NOT EXISTS idx_executions_retry_of ON task_executions(retry_of);
+
+INSERT OR IGNORE INTO config (key, value, description) VALUES
+('lost_execution_retry', 'none', '探针断线后未完成执行的重新分配策略: none/same_probe/any_probe'),
+('lost_execution_retry_wait_seconds', '300', '等待探针重连或可用探针的最长时间(秒)，超时后执行记为 lost');
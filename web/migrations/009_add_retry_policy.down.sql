@@ -0,0 +1,3 @@
+ALTER TABLE task_executions DROP COLUMN not_before;
+ALTER TABLE task_executions DROP COLUMN failure_class;
+ALTER TABLE tasks DROP COLUMN retry_policy;
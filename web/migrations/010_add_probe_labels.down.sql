@@ -0,0 +1,3 @@
+ALTER TABLE tasks DROP COLUMN selector;
+ALTER TABLE probes DROP COLUMN admin_labels;
+ALTER TABLE probes DROP COLUMN labels;
@@ -0,0 +1,2 @@
+ALTER TABLE tasks DROP COLUMN resolved_probes;
+ALTER TABLE tasks DROP COLUMN sampling;
@@ -0,0 +1,3 @@
+ALTER TABLE probes DROP COLUMN queued_tasks;
+ALTER TABLE probes DROP COLUMN active_tasks;
+ALTER TABLE probes DROP COLUMN max_concurrent_tasks;
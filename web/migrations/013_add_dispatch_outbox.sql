@@ -0,0 +1,12 @@
+-- 任务下发待确认队列：task_assign 在探针回复 task_assign_ack（或上报结果）前保留，用于重投
+CREATE TABLE IF NOT EXISTS dispatch_outbox (
+    execution_id TEXT PRIMARY KEY,
+    probe_id TEXT NOT NULL,
+    task_id TEXT NOT NULL,
+    payload TEXT NOT NULL,                    -- task_assign 消息 JSON
+    attempts INTEGER DEFAULT 0,               -- 已发送次数
+    created_at DATETIME NOT NULL,
+    last_sent_at DATETIME                     -- 最近一次成功写入发送缓冲区的时间
+);
+
+CREATE INDEX IF NOT EXISTS idx_dispatch_outbox_probe ON dispatch_outbox(probe_id);
@@ -0,0 +1,13 @@
+-- 多目标任务：目标列表 / CIDR 展开 / 目标组
+ALTER TABLE tasks ADD COLUMN targets TEXT;               -- 目标定义 JSON: {"hosts":[...]} / {"cidr":"..."} / {"group":"..."}
+ALTER TABLE task_executions ADD COLUMN target TEXT;      -- 多目标任务中该执行的目标，为空时使用 tasks.target
+
+CREATE TABLE IF NOT EXISTS target_groups (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    group_id TEXT UNIQUE NOT NULL,
+    name TEXT NOT NULL,
+    description TEXT,
+    targets TEXT NOT NULL,                    -- 目标 JSON 数组
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
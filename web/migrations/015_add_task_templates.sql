@@ -0,0 +1,27 @@
+-- 任务模板与重跑
+ALTER TABLE tasks ADD COLUMN rerun_of TEXT;              -- 重跑来源任务的 task_id
+ALTER TABLE tasks ADD COLUMN template_id TEXT;           -- 由模板启动时的模板 ID
+ALTER TABLE tasks ADD COLUMN template_version INTEGER;   -- 启动时使用的模板版本
+
+CREATE TABLE IF NOT EXISTS task_templates (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    template_id TEXT UNIQUE NOT NULL,
+    name TEXT UNIQUE NOT NULL,
+    description TEXT,
+    version INTEGER NOT NULL DEFAULT 1,       -- 当前版本号
+    definition TEXT NOT NULL,                 -- 任务定义 JSON，结构同创建任务请求
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS task_template_versions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    template_id TEXT NOT NULL,
+    version INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    description TEXT,
+    definition TEXT NOT NULL,
+    changed_fields TEXT,                      -- 相对上一版本变化的字段 JSON 数组
+    created_at DATETIME NOT NULL,
+    UNIQUE (template_id, version)
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS task_revisions;
+ALTER TABLE tasks DROP COLUMN revision;
@@ -0,0 +1,13 @@
+-- 任务编辑、暂停与恢复
+ALTER TABLE tasks ADD COLUMN revision INTEGER NOT NULL DEFAULT 0;   -- 已编辑次数，用于并发编辑检查
+
+CREATE TABLE IF NOT EXISTS task_revisions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_id TEXT NOT NULL,
+    revision INTEGER NOT NULL,
+    changed_fields TEXT NOT NULL,             -- 变化的字段 JSON 数组
+    previous TEXT NOT NULL,                   -- 编辑前的可编辑字段 JSON
+    current TEXT NOT NULL,                    -- 编辑后的可编辑字段 JSON
+    created_at DATETIME NOT NULL,
+    UNIQUE (task_id, revision)
+);
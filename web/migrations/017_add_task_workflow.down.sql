@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_tasks_parent_task_id;
+ALTER TABLE tasks DROP COLUMN workflow_step;
+ALTER TABLE tasks DROP COLUMN parent_execution_id;
+ALTER TABLE tasks DROP COLUMN parent_task_id;
+ALTER TABLE tasks DROP COLUMN workflow;
@@ -0,0 +1,7 @@
+-- 工作流：结果满足条件时自动创建后续任务
+ALTER TABLE tasks ADD COLUMN workflow TEXT;              -- 后续步骤定义 JSON
+ALTER TABLE tasks ADD COLUMN parent_task_id TEXT;        -- 触发该任务的任务
+ALTER TABLE tasks ADD COLUMN parent_execution_id TEXT;   -- 触发该任务的执行
+ALTER TABLE tasks ADD COLUMN workflow_step TEXT;         -- 触发的步骤名
+
+CREATE INDEX IF NOT EXISTS idx_tasks_parent_task_id ON tasks(parent_task_id);
@@ -0,0 +1,9 @@
+-- 集群连接注册表：记录每个探针当前连接在哪个 Web 节点上，节点定期刷新心跳，超时视为离线
+CREATE TABLE IF NOT EXISTS probe_connections (
+    probe_id TEXT PRIMARY KEY,
+    node_id TEXT NOT NULL,                    -- 持有 WebSocket 连接的节点 ID
+    connected_at DATETIME NOT NULL,
+    heartbeat_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_probe_connections_node ON probe_connections(node_id);
@@ -0,0 +1,3 @@
+DELETE FROM config WHERE key IN ('result_retention', 'vacuum_interval_hours', 'vacuum_last_run');
+DROP INDEX IF EXISTS idx_results_type_created;
+DROP TABLE IF EXISTS result_aggregates;
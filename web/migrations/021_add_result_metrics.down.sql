@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_results_target_created;
+ALTER TABLE results DROP COLUMN ttfb_ms;
+ALTER TABLE results DROP COLUMN http_status;
+ALTER TABLE results DROP COLUMN packet_loss;
+ALTER TABLE results DROP COLUMN avg_latency;
@@ -0,0 +1,19 @@
+-- 结果数值指标：从摘要 JSON 中提取到独立列，供时序查询直接按列过滤与聚合
+ALTER TABLE results ADD COLUMN avg_latency REAL;     -- 平均延迟(ms)：ping 的 avg_rtt_ms、tcp_ping/http 的 avg_connect_time_ms
+ALTER TABLE results ADD COLUMN packet_loss REAL;     -- 丢包率(%)
+ALTER TABLE results ADD COLUMN http_status INTEGER;  -- HTTP 状态码
+ALTER TABLE results ADD COLUMN ttfb_ms REAL;         -- HTTP 首字节时间(ms)
+
+CREATE INDEX IF NOT EXISTS idx_results_target_created ON results(target, created_at);
+
+-- 回填已有结果
+UPDATE results SET
+    avg_latency = json_extract(summary, '$.avg_latency'),
+    packet_loss = json_extract(summary, '$.packet_loss_percent'),
+    http_status = json_extract(summary, '$.http_status_code'),
+    ttfb_ms = json_extract(summary, '$.ttfb_ms')
+WHERE json_valid(summary);
+
+-- 早期结果的摘要没有 ttfb_ms，从原始结果数据中提取
+UPDATE results SET ttfb_ms = json_extract(result_data, '$.avg_ttfb_ms')
+WHERE ttfb_ms IS NULL AND json_valid(result_data);
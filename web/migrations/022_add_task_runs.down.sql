@@ -0,0 +1,2 @@
+ALTER TABLE task_executions DROP COLUMN run_number;
+DROP TABLE IF EXISTS task_runs;
@@ -0,0 +1,10 @@
+-- 每轮运行实际选中的探针集合：抽样结果每轮可能不同，按轮次记录以便追溯结果由哪组探针产生
+CREATE TABLE IF NOT EXISTS task_runs (
+    task_id TEXT NOT NULL,
+    run_number INTEGER NOT NULL,              -- 单次任务为 1，持续任务为调度的第几次运行
+    resolved_probes TEXT NOT NULL,            -- 本轮选中的探针 ID JSON 数组
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (task_id, run_number)
+);
+
+ALTER TABLE task_executions ADD COLUMN run_number INTEGER;   -- 产生该执行的运行轮次，重试沿用原执行的轮次
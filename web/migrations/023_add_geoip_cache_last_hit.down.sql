@@ -0,0 +1 @@
+ALTER TABLE geoip_cache DROP COLUMN last_hit_at;
@@ -0,0 +1,10 @@
+// Package migrations 内嵌数据库迁移文件。
+// NNN_name.sql 为升级脚本，NNN_name.down.sql 为对应的回滚脚本；postgres/ 下为 PostgreSQL 版本，编号与 SQLite 一致
+package migrations
+
+import "embed"
+
+// FS 全部迁移文件
+//
+//go:embed *.sql postgres/*.sql
+var FS embed.FS
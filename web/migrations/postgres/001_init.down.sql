@@ -0,0 +1,6 @@
+-- 回滚初始表结构（先删除引用其他表的表）
+DROP TABLE IF EXISTS config;
+DROP TABLE IF EXISTS results;
+DROP TABLE IF EXISTS task_executions;
+DROP TABLE IF EXISTS tasks;
+DROP TABLE IF EXISTS probes;
@@ -0,0 +1,7 @@
+-- Add latitude and longitude columns to probes table
+
+ALTER TABLE probes ADD COLUMN IF NOT EXISTS latitude DOUBLE PRECISION;
+ALTER TABLE probes ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION;
+
+-- Create index for coordinate queries
+CREATE INDEX IF NOT EXISTS idx_probes_coordinates ON probes(latitude, longitude);
@@ -0,0 +1 @@
+-- results.status is part of 001_init now; rolling back 003 keeps the column.
@@ -0,0 +1,4 @@
+-- Add status column to results table
+-- 001 already creates this column; kept so numbering matches the SQLite migrations.
+
+ALTER TABLE results ADD COLUMN IF NOT EXISTS status TEXT DEFAULT 'success';
@@ -0,0 +1 @@
+DELETE FROM config WHERE key = 'mtr_timeout_seconds';
@@ -0,0 +1,3 @@
+INSERT INTO config (key, value, description) VALUES
+('mtr_timeout_seconds', '60', 'MTR 测试超时时间(秒)')
+ON CONFLICT (key) DO NOTHING;
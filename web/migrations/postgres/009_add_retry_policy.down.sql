@@ -0,0 +1,3 @@
+ALTER TABLE task_executions DROP COLUMN IF EXISTS not_before;
+ALTER TABLE task_executions DROP COLUMN IF EXISTS failure_class;
+ALTER TABLE tasks DROP COLUMN IF EXISTS retry_policy;
@@ -0,0 +1,4 @@
+-- 任务级重试策略与执行失败分类
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS retry_policy TEXT;                     -- 重试策略 JSON: {"max_attempts":3,"backoff_seconds":30}
+ALTER TABLE task_executions ADD COLUMN IF NOT EXISTS failure_class TEXT;          -- 失败分类: send_failure/timeout/probe_error/lost
+ALTER TABLE task_executions ADD COLUMN IF NOT EXISTS not_before TIMESTAMPTZ;         -- 重试尝试的最早下发时间
@@ -0,0 +1,3 @@
+ALTER TABLE tasks DROP COLUMN IF EXISTS selector;
+ALTER TABLE probes DROP COLUMN IF EXISTS admin_labels;
+ALTER TABLE probes DROP COLUMN IF EXISTS labels;
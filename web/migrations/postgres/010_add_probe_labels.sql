@@ -0,0 +1,4 @@
+-- 探针标签与任务的标签选择表达式
+ALTER TABLE probes ADD COLUMN IF NOT EXISTS labels TEXT;               -- 探针配置上报的标签 JSON: {"provider":"aws","tier":"edge"}
+ALTER TABLE probes ADD COLUMN IF NOT EXISTS admin_labels TEXT;         -- 后台设置的标签 JSON，覆盖同名上报标签，空值表示删除
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS selector TEXT;              -- 探针选择表达式: country in (DE,FR) && provider!=hetzner
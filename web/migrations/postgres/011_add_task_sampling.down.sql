@@ -0,0 +1,2 @@
+ALTER TABLE tasks DROP COLUMN IF EXISTS resolved_probes;
+ALTER TABLE tasks DROP COLUMN IF EXISTS sampling;
@@ -0,0 +1,3 @@
+-- 任务的探针抽样策略与实际选中的探针集合
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS sampling TEXT;              -- 抽样策略 JSON: {"strategy":"nearest","count":5}
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS resolved_probes TEXT;       -- 按策略选中的探针 ID JSON 数组，用于复现结果
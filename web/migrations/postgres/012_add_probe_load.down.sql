@@ -0,0 +1,3 @@
+ALTER TABLE probes DROP COLUMN IF EXISTS queued_tasks;
+ALTER TABLE probes DROP COLUMN IF EXISTS active_tasks;
+ALTER TABLE probes DROP COLUMN IF EXISTS max_concurrent_tasks;
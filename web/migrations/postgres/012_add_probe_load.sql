@@ -0,0 +1,4 @@
+-- 探针负载与容量：容量在注册时上报，负载随心跳更新
+ALTER TABLE probes ADD COLUMN IF NOT EXISTS max_concurrent_tasks INTEGER DEFAULT 0; -- 探针最大并发任务数，0 表示未上报（不限制）
+ALTER TABLE probes ADD COLUMN IF NOT EXISTS active_tasks INTEGER DEFAULT 0;         -- 心跳上报的执行中任务数
+ALTER TABLE probes ADD COLUMN IF NOT EXISTS queued_tasks INTEGER DEFAULT 0;         -- 心跳上报的排队任务数
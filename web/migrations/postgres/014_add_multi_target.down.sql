@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS target_groups;
+ALTER TABLE task_executions DROP COLUMN IF EXISTS target;
+ALTER TABLE tasks DROP COLUMN IF EXISTS targets;
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS task_template_versions;
+DROP TABLE IF EXISTS task_templates;
+ALTER TABLE tasks DROP COLUMN IF EXISTS template_version;
+ALTER TABLE tasks DROP COLUMN IF EXISTS template_id;
+ALTER TABLE tasks DROP COLUMN IF EXISTS rerun_of;
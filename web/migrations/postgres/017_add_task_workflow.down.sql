@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_tasks_parent_task_id;
+ALTER TABLE tasks DROP COLUMN IF EXISTS workflow_step;
+ALTER TABLE tasks DROP COLUMN IF EXISTS parent_execution_id;
+ALTER TABLE tasks DROP COLUMN IF EXISTS parent_task_id;
+ALTER TABLE tasks DROP COLUMN IF EXISTS workflow;
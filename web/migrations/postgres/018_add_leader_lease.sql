@@ -0,0 +1,8 @@
+-- 调度器主节点选举：每个租约一行，token 在每次易主时递增作为 fencing token
+CREATE TABLE IF NOT EXISTS leader_leases (
+    name TEXT PRIMARY KEY,
+    holder TEXT NOT NULL,                     -- 持有者实例 ID
+    token INTEGER NOT NULL,                   -- fencing token
+    renewed_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
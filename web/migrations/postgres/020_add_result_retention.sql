@@ -0,0 +1,28 @@
+-- 结果降采样：过期的持续 ping/tcp_ping 原始结果按小时或天聚合为一行后删除
+CREATE TABLE IF NOT EXISTS result_aggregates (
+    id BIGSERIAL PRIMARY KEY,
+    task_id TEXT NOT NULL,
+    probe_id TEXT NOT NULL,
+    target TEXT NOT NULL,
+    test_type TEXT NOT NULL,
+    granularity TEXT NOT NULL,                -- hour/day
+    bucket_start TIMESTAMPTZ NOT NULL,        -- 时间桶起点(UTC)
+    sample_count INTEGER NOT NULL,            -- 聚合的原始结果数
+    success_count INTEGER NOT NULL,
+    latency_count INTEGER NOT NULL DEFAULT 0, -- 带延迟数据的结果数，合并均值时作为权重
+    min_latency DOUBLE PRECISION,             -- 毫秒，无延迟数据时为空
+    avg_latency DOUBLE PRECISION,
+    max_latency DOUBLE PRECISION,
+    p95_latency DOUBLE PRECISION,
+    packet_loss DOUBLE PRECISION,             -- 平均丢包率(%)，失败的结果按 100% 计
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (task_id, probe_id, target, granularity, bucket_start)
+);
+
+CREATE INDEX IF NOT EXISTS idx_result_aggregates_bucket ON result_aggregates(test_type, bucket_start);
+CREATE INDEX IF NOT EXISTS idx_results_type_created ON results(test_type, created_at);
+
+INSERT INTO config (key, value, description) VALUES
+('result_retention', '{"icmp_ping":{"raw_days":7,"downsample":"hour","aggregate_days":365},"tcp_ping":{"raw_days":7,"downsample":"hour","aggregate_days":365}}', '结果保留策略(JSON)，按测试类型配置原始结果保留天数、降采样粒度与聚合结果保留天数'),
+('vacuum_interval_hours', '24', '数据库压缩(VACUUM/checkpoint)间隔(小时)，0 表示不压缩')
+ON CONFLICT (key) DO NOTHING;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_results_target_created;
+ALTER TABLE results DROP COLUMN IF EXISTS ttfb_ms;
+ALTER TABLE results DROP COLUMN IF EXISTS http_status;
+ALTER TABLE results DROP COLUMN IF EXISTS packet_loss;
+ALTER TABLE results DROP COLUMN IF EXISTS avg_latency;
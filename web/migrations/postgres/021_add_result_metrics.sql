@@ -0,0 +1,19 @@
+-- 结果数值指标：从摘要 JSON 中提取到独立列，供时序查询直接按列过滤与聚合
+ALTER TABLE results ADD COLUMN IF NOT EXISTS avg_latency DOUBLE PRECISION; -- 平均延迟(ms)：ping 的 avg_rtt_ms、tcp_ping/http 的 avg_connect_time_ms
+ALTER TABLE results ADD COLUMN IF NOT EXISTS packet_loss DOUBLE PRECISION; -- 丢包率(%)
+ALTER TABLE results ADD COLUMN IF NOT EXISTS http_status INTEGER;          -- HTTP 状态码
+ALTER TABLE results ADD COLUMN IF NOT EXISTS ttfb_ms DOUBLE PRECISION;     -- HTTP 首字节时间(ms)
+
+CREATE INDEX IF NOT EXISTS idx_results_target_created ON results(target, created_at);
+
+-- 回填已有结果
+UPDATE results SET
+    avg_latency = (summary::jsonb ->> 'avg_latency')::double precision,
+    packet_loss = (summary::jsonb ->> 'packet_loss_percent')::double precision,
+    http_status = (summary::jsonb ->> 'http_status_code')::integer,
+    ttfb_ms = (summary::jsonb ->> 'ttfb_ms')::double precision
+WHERE summary LIKE '{%';
+
+-- 早期结果的摘要没有 ttfb_ms，从原始结果数据中提取
+UPDATE results SET ttfb_ms = (result_data::jsonb ->> 'avg_ttfb_ms')::double precision
+WHERE ttfb_ms IS NULL AND result_data LIKE '{%';
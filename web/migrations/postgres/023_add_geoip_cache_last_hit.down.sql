@@ -0,0 +1 @@
+ALTER TABLE geoip_cache DROP COLUMN IF EXISTS last_hit_at;
@@ -0,0 +1,4 @@
+-- GeoIP 缓存最近命中时间：后台只刷新仍在使用的过期条目，不再使用的条目留待过期
+ALTER TABLE geoip_cache ADD COLUMN IF NOT EXISTS last_hit_at TIMESTAMPTZ;   -- 最近一次查询命中（按小时节流写入）
+
+UPDATE geoip_cache SET last_hit_at = fetched_at WHERE last_hit_at IS NULL;